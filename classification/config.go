@@ -0,0 +1,70 @@
+package classification
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultTimeScale     = 12 * time.Hour
+	defaultDistanceScale = 1000.
+)
+
+// ClassifierConfig tunes the identification of events. Without space
+// weight, events are identified by capture time only.
+type ClassifierConfig struct {
+	// TimeScale is the duration after which the similarity of two photos is
+	// divided by e, defaults to 12h
+	TimeScale string `json:"timeScale,omitempty"`
+	// DistanceScale is the distance in meters after which the similarity of
+	// two photos is divided by e, defaults to 1000
+	DistanceScale float64 `json:"distanceScale,omitempty"`
+	// TimeWeight and SpaceWeight set the importance of time versus distance,
+	// the time weight defaults to 1
+	TimeWeight  float64 `json:"timeWeight,omitempty"`
+	SpaceWeight float64 `json:"spaceWeight,omitempty"`
+	KernelSize  int     `json:"kernelSize,omitempty"`
+	// Compare only reports how the boundaries of events identified with this
+	// configuration differ from those identified by time only, without
+	// changing any event
+	Compare bool `json:"compare,omitempty"`
+}
+
+// Validate returns an error if the configuration is not valid
+func (c ClassifierConfig) Validate() error {
+	if c.TimeScale != "" {
+		if d, err := time.ParseDuration(c.TimeScale); err != nil || d <= 0 {
+			return fmt.Errorf("Bad timeScale '%s', expected a positive duration", c.TimeScale)
+		}
+	}
+	if c.DistanceScale < 0 || c.TimeWeight < 0 || c.SpaceWeight < 0 || c.KernelSize < 0 {
+		return fmt.Errorf("Scales, weights and kernel size must not be negative")
+	}
+	return nil
+}
+
+// Classifier returns the classifier with this configuration
+func (c ClassifierConfig) Classifier() (DistanceClassifier, error) {
+	if err := c.Validate(); err != nil {
+		return DistanceClassifier{}, err
+	}
+	timeScale := defaultTimeScale
+	if c.TimeScale != "" {
+		timeScale, _ = time.ParseDuration(c.TimeScale)
+	}
+	distanceScale := c.DistanceScale
+	if distanceScale == 0 {
+		distanceScale = defaultDistanceScale
+	}
+	timeWeight := c.TimeWeight
+	if timeWeight == 0 {
+		timeWeight = 1
+	}
+	return NewSimilarityClassifier(SpatioTemporalDistance(timeScale, distanceScale, timeWeight, c.SpaceWeight), c.KernelSize), nil
+}
+
+// TimeOnly returns this configuration without distance
+func (c ClassifierConfig) TimeOnly() ClassifierConfig {
+	c.SpaceWeight = 0
+	return c
+}
@@ -0,0 +1,324 @@
+package classification
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+)
+
+// EditError is returned when an edit of events is not possible
+type EditError string
+
+func (e EditError) Error() string {
+	return string(e)
+}
+
+const (
+	ErrNoEventPhotos = EditError("At least one photo is required")
+	ErrMergeTooFew   = EditError("At least two events are required to merge")
+	ErrNotAdjacent   = EditError("Only adjacent events can be merged")
+	ErrSplitAtFirst  = EditError("Cannot split an event at its first photo")
+	ErrNotInEvent    = EditError("Photo does not belong to the event")
+)
+
+const eventsPageSize = 1000
+
+// EventEditor implements the manual edits of events. Edited events are
+// marked as manual so that identifying events again does not change them.
+type EventEditor struct {
+	index *boltstore.EventIndex
+	lib   library.PhotoLibrary
+}
+
+func NewEventEditor(index *boltstore.EventIndex, lib library.PhotoLibrary) *EventEditor {
+	return &EventEditor{index: index, lib: lib}
+}
+
+// Rename sets the name of the given event
+func (e *EventEditor) Rename(ctx context.Context, id boltstore.EventID, name string) (*boltstore.Event, error) {
+	event, err := e.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	photos, err := e.index.PhotosOf(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	event.Name, event.Manual = name, true
+	return event, e.index.Replace(ctx, nil, boltstore.EventPhotos{Event: *event, Photos: photos})
+}
+
+// Merge merges the given events, which must follow each other, into the
+// earliest one
+func (e *EventEditor) Merge(ctx context.Context, ids []boltstore.EventID) (*boltstore.Event, error) {
+	if len(ids) < 2 {
+		return nil, ErrMergeTooFew
+	}
+	selected := make(map[boltstore.EventID]bool)
+	for _, id := range ids {
+		if _, err := e.get(ctx, id); err != nil {
+			return nil, err
+		}
+		selected[id] = true
+	}
+	events, err := e.allEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Events are sorted chronologically, merged events must be contiguous
+	var merged []boltstore.Event
+	for _, event := range events {
+		if selected[event.ID] {
+			merged = append(merged, event)
+		} else if len(merged) > 0 && len(merged) < len(selected) {
+			return nil, ErrNotAdjacent
+		}
+	}
+	var photos []library.ExtendedPhotoID
+	var deleted []boltstore.EventID
+	var name string
+	for i, event := range merged {
+		p, err := e.index.PhotosOf(ctx, event.ID)
+		if err != nil {
+			return nil, err
+		}
+		photos = append(photos, p...)
+		if name == "" {
+			name = event.Name
+		}
+		if i > 0 {
+			deleted = append(deleted, event.ID)
+		}
+	}
+	result, err := e.eventOver(ctx, merged[0].ID, photos)
+	if err != nil {
+		return nil, err
+	}
+	result.Name = name
+	return &result.Event, e.index.Replace(ctx, deleted, result)
+}
+
+// Split splits the given event into the photos taken before the given photo
+// and the photos taken from it on
+func (e *EventEditor) Split(ctx context.Context, id boltstore.EventID, at library.PhotoID) ([]boltstore.Event, error) {
+	event, err := e.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	photos, err := e.index.PhotosOf(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	split := -1
+	for i, p := range photos {
+		if p.ID == at {
+			split = i
+		}
+	}
+	switch split {
+	case -1:
+		return nil, ErrNotInEvent
+	case 0:
+		return nil, ErrSplitAtFirst
+	}
+	before, err := e.eventOver(ctx, id, photos[:split])
+	if err != nil {
+		return nil, err
+	}
+	before.Name = event.Name
+	after, err := e.eventOver(ctx, "", photos[split:])
+	if err != nil {
+		return nil, err
+	}
+	if err := e.index.Replace(ctx, nil, before, after); err != nil {
+		return nil, err
+	}
+	return []boltstore.Event{before.Event, after.Event}, nil
+}
+
+// Create creates a new event made of the given photos, which are removed from
+// their previous events
+func (e *EventEditor) Create(ctx context.Context, name string, ids []library.PhotoID) (*boltstore.Event, error) {
+	photos, err := e.photos(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	event, err := e.eventOver(ctx, "", photos)
+	if err != nil {
+		return nil, err
+	}
+	event.Name = name
+	updated, err := e.previousEvents(ctx, ids, event.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &event.Event, e.index.Replace(ctx, nil, append(updated, event)...)
+}
+
+// Move moves the given photos to the given event
+func (e *EventEditor) Move(ctx context.Context, ids []library.PhotoID, to boltstore.EventID) (*boltstore.Event, error) {
+	event, err := e.get(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	moved, err := e.photos(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	photos, err := e.index.PhotosOf(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	target, err := e.eventOver(ctx, to, append(photos, moved...))
+	if err != nil {
+		return nil, err
+	}
+	target.Name = event.Name
+	updated, err := e.previousEvents(ctx, ids, to)
+	if err != nil {
+		return nil, err
+	}
+	return &target.Event, e.index.Replace(ctx, nil, append(updated, target)...)
+}
+
+func (e *EventEditor) get(ctx context.Context, id boltstore.EventID) (*boltstore.Event, error) {
+	event, found, err := e.index.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, boltstore.ErrEventNotFound(id)
+	}
+	return event, nil
+}
+
+func (e *EventEditor) allEvents(ctx context.Context) (events []boltstore.Event, err error) {
+	for start := 0; ; start += eventsPageSize {
+		page, hasMore, err := e.index.FindPaged(ctx, start, eventsPageSize)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, page...)
+		if !hasMore {
+			break
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].From.Before(events[j].From)
+	})
+	return events, nil
+}
+
+func (e *EventEditor) photos(ctx context.Context, ids []library.PhotoID) ([]library.ExtendedPhotoID, error) {
+	if len(ids) == 0 {
+		return nil, ErrNoEventPhotos
+	}
+	photos := make([]library.ExtendedPhotoID, len(ids))
+	for i, id := range ids {
+		p, err := e.lib.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return nil, library.NotFound(id)
+		}
+		photos[i] = p.ExtendedPhotoID
+	}
+	return photos, nil
+}
+
+// previousEvents returns the events the given photos belong to, other than
+// the given one, without these photos
+func (e *EventEditor) previousEvents(ctx context.Context, ids []library.PhotoID, except boltstore.EventID) (updated []boltstore.EventPhotos, err error) {
+	moved := make(map[library.PhotoID]bool)
+	for _, id := range ids {
+		moved[id] = true
+	}
+	seen := make(map[boltstore.EventID]bool)
+	for _, id := range ids {
+		event, found, err := e.index.EventOf(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !found || event.ID == except || seen[event.ID] {
+			continue
+		}
+		seen[event.ID] = true
+		photos, err := e.index.PhotosOf(ctx, event.ID)
+		if err != nil {
+			return nil, err
+		}
+		var remaining []library.ExtendedPhotoID
+		for _, p := range photos {
+			if !moved[p.ID] {
+				remaining = append(remaining, p)
+			}
+		}
+		if len(remaining) == 0 {
+			// The index deletes events left without photos
+			continue
+		}
+		previous, err := e.eventOver(ctx, event.ID, remaining)
+		if err != nil {
+			return nil, err
+		}
+		previous.Name, previous.Manual = event.Name, event.Manual
+		updated = append(updated, previous)
+	}
+	return updated, nil
+}
+
+// eventOver returns the manual event made of the given photos, spanning from
+// the first to the last photo. A new ID is derived from the first photo if id
+// is empty.
+func (e *EventEditor) eventOver(ctx context.Context, id boltstore.EventID, photos []library.ExtendedPhotoID) (boltstore.EventPhotos, error) {
+	if len(photos) == 0 {
+		return boltstore.EventPhotos{}, ErrNoEventPhotos
+	}
+	sorted := make([]library.ExtendedPhotoID, len(photos))
+	copy(sorted, photos)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].SortID, sorted[j].SortID) < 0
+	})
+	first, err := e.lib.Get(ctx, sorted[0].ID)
+	if err != nil {
+		return boltstore.EventPhotos{}, err
+	}
+	last, err := e.lib.Get(ctx, sorted[len(sorted)-1].ID)
+	if err != nil {
+		return boltstore.EventPhotos{}, err
+	}
+	if id == "" {
+		if id, err = e.newID(ctx, first.DateTaken); err != nil {
+			return boltstore.EventPhotos{}, err
+		}
+	}
+	return boltstore.EventPhotos{
+		Event: boltstore.Event{
+			ID:     id,
+			From:   first.LocalTime(),
+			To:     last.LocalTime(),
+			Manual: true,
+		},
+		Photos: sorted,
+	}, nil
+}
+
+// newID returns an unused event ID derived from the given time, like the IDs
+// of identified events
+func (e *EventEditor) newID(ctx context.Context, t time.Time) (boltstore.EventID, error) {
+	base := t.Format(time.RFC3339)
+	id := boltstore.EventID(base)
+	for i := 2; ; i++ {
+		_, exists, err := e.index.Get(ctx, id)
+		if err != nil || !exists {
+			return id, err
+		}
+		id = boltstore.EventID(fmt.Sprintf("%s-%d", base, i))
+	}
+}
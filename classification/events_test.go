@@ -0,0 +1,243 @@
+package classification_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/classification"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+	"bitbucket.org/kleinnic74/photos/tasks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+type eventsLib struct {
+	library.PhotoLibrary
+	photos []*library.Photo
+}
+
+func (lib *eventsLib) Get(ctx context.Context, id library.PhotoID) (*library.Photo, error) {
+	for _, p := range lib.photos {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, library.NotFound(id)
+}
+
+var eventsDay = time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+
+// newEventsFixture returns an editor over three events: e1 with photos 1 to 4
+// taken 10 minutes apart, e2 with photos 5 and 6 four days later and e3 with
+// photos 7 and 8 another four days later
+func newEventsFixture(t *testing.T) (*classification.EventEditor, *boltstore.EventIndex, *eventsLib, func()) {
+	dir, err := ioutil.TempDir("", "events")
+	require.NoError(t, err)
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0644, nil)
+	require.NoError(t, err)
+	index, err := boltstore.NewEventIndex(db)
+	require.NoError(t, err)
+	lib := &eventsLib{}
+	taken := map[library.PhotoID]time.Duration{
+		"1": 0, "2": 10 * time.Minute, "3": 20 * time.Minute, "4": 30 * time.Minute,
+		"5": 96 * time.Hour, "6": 96*time.Hour + 10*time.Minute,
+		"7": 192 * time.Hour, "8": 192*time.Hour + 10*time.Minute,
+	}
+	for _, id := range []library.PhotoID{"1", "2", "3", "4", "5", "6", "7", "8"} {
+		lib.photos = append(lib.photos, &library.Photo{
+			ExtendedPhotoID: library.ExtendedPhotoID{ID: id, SortID: library.OrderedID(id)},
+			DateTaken:       eventsDay.Add(taken[id]),
+		})
+	}
+	ctx := context.Background()
+	for _, e := range []struct {
+		id     boltstore.EventID
+		photos []*library.Photo
+	}{
+		{"e1", lib.photos[0:4]},
+		{"e2", lib.photos[4:6]},
+		{"e3", lib.photos[6:8]},
+	} {
+		var photos []library.ExtendedPhotoID
+		for _, p := range e.photos {
+			photos = append(photos, p.ExtendedPhotoID)
+		}
+		event := boltstore.Event{ID: e.id, From: e.photos[0].DateTaken, To: e.photos[len(e.photos)-1].DateTaken}
+		require.NoError(t, index.AddPhotosToEvent(ctx, event, photos))
+	}
+	return classification.NewEventEditor(index, lib), index, lib, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// identifyEvents identifies the events of all photos again
+func identifyEvents(t *testing.T, index *boltstore.EventIndex, lib *eventsLib) {
+	repo := tasks.NewTaskRepository()
+	classification.RegisterTasks(repo, index)
+	task, err := repo.CreateTask("IdentifyEventsInGroup")
+	require.NoError(t, err)
+	identify := task.(*classification.IdentifyEventsTask)
+	for _, p := range lib.photos {
+		identify.Photos = append(identify.Photos, p.ExtendedPhotoID)
+	}
+	require.NoError(t, identify.Execute(context.Background(), tasks.NewDummyTaskExecutor(), lib))
+}
+
+func eventPhotoIDs(t *testing.T, index *boltstore.EventIndex, id boltstore.EventID) (ids []library.PhotoID) {
+	photos, err := index.PhotosOf(context.Background(), id)
+	require.NoError(t, err)
+	for _, p := range photos {
+		ids = append(ids, p.ID)
+	}
+	return
+}
+
+// storedEvent returns the event as stored in the index
+func storedEvent(t *testing.T, index *boltstore.EventIndex, id boltstore.EventID) *boltstore.Event {
+	e, found, err := index.Get(context.Background(), id)
+	require.NoError(t, err)
+	require.True(t, found, "Event %s must exist", id)
+	return e
+}
+
+func assertSpan(t *testing.T, e *boltstore.Event, from, to time.Duration) {
+	assert.True(t, e.From.Equal(eventsDay.Add(from)), "%s: bad start %s", e.ID, e.From)
+	assert.True(t, e.To.Equal(eventsDay.Add(to)), "%s: bad end %s", e.ID, e.To)
+}
+
+func TestEventEditorRename(t *testing.T) {
+	editor, index, lib, cleanup := newEventsFixture(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	e, err := editor.Rename(ctx, "e1", "Holidays")
+	require.NoError(t, err)
+	assert.Equal(t, "Holidays", e.Name)
+	assert.True(t, e.Manual)
+
+	// Renamed events survive identifying events again
+	identifyEvents(t, index, lib)
+	stored := storedEvent(t, index, "e1")
+	assert.Equal(t, "Holidays", stored.Name)
+	assert.True(t, stored.Manual)
+	assertSpan(t, stored, 0, 30*time.Minute)
+	assert.Equal(t, []library.PhotoID{"1", "2", "3", "4"}, eventPhotoIDs(t, index, "e1"))
+	assert.Empty(t, eventPhotoIDs(t, index, "e3"), "Photos of identified events must move to the events identified again")
+
+	_, err = editor.Rename(ctx, "unknown", "Nothing")
+	assert.IsType(t, boltstore.ErrEventNotFound(""), err)
+}
+
+func TestEventEditorMerge(t *testing.T) {
+	editor, index, lib, cleanup := newEventsFixture(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := editor.Merge(ctx, []boltstore.EventID{"e1"})
+	assert.Equal(t, classification.ErrMergeTooFew, err)
+	_, err = editor.Merge(ctx, []boltstore.EventID{"e1", "e3"})
+	assert.Equal(t, classification.ErrNotAdjacent, err)
+	_, err = editor.Merge(ctx, []boltstore.EventID{"e1", "unknown"})
+	assert.IsType(t, boltstore.ErrEventNotFound(""), err)
+
+	e, err := editor.Merge(ctx, []boltstore.EventID{"e2", "e1"})
+	require.NoError(t, err)
+	assert.Equal(t, boltstore.EventID("e1"), e.ID)
+	assertSpan(t, e, 0, 96*time.Hour+10*time.Minute)
+	_, found, err := index.Get(ctx, "e2")
+	require.NoError(t, err)
+	assert.False(t, found, "Merged events must be deleted")
+
+	identifyEvents(t, index, lib)
+	assert.Equal(t, []library.PhotoID{"1", "2", "3", "4", "5", "6"}, eventPhotoIDs(t, index, "e1"))
+	assertSpan(t, storedEvent(t, index, "e1"), 0, 96*time.Hour+10*time.Minute)
+}
+
+func TestEventEditorSplit(t *testing.T) {
+	editor, index, lib, cleanup := newEventsFixture(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := editor.Split(ctx, "e1", "1")
+	assert.Equal(t, classification.ErrSplitAtFirst, err)
+	_, err = editor.Split(ctx, "e1", "5")
+	assert.Equal(t, classification.ErrNotInEvent, err)
+	_, err = editor.Split(ctx, "unknown", "1")
+	assert.IsType(t, boltstore.ErrEventNotFound(""), err)
+
+	_, err = editor.Rename(ctx, "e1", "Holidays")
+	require.NoError(t, err)
+	events, err := editor.Split(ctx, "e1", "3")
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, boltstore.EventID("e1"), events[0].ID)
+	assert.Equal(t, "Holidays", events[0].Name)
+	assertSpan(t, &events[0], 0, 10*time.Minute)
+	assertSpan(t, &events[1], 20*time.Minute, 30*time.Minute)
+
+	// Both parts survive identifying events again
+	identifyEvents(t, index, lib)
+	assert.Equal(t, []library.PhotoID{"1", "2"}, eventPhotoIDs(t, index, "e1"))
+	assert.Equal(t, []library.PhotoID{"3", "4"}, eventPhotoIDs(t, index, events[1].ID))
+	assertSpan(t, storedEvent(t, index, "e1"), 0, 10*time.Minute)
+	assertSpan(t, storedEvent(t, index, events[1].ID), 20*time.Minute, 30*time.Minute)
+}
+
+func TestEventEditorCreate(t *testing.T) {
+	editor, index, lib, cleanup := newEventsFixture(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := editor.Create(ctx, "Nothing", nil)
+	assert.Equal(t, classification.ErrNoEventPhotos, err)
+	_, err = editor.Create(ctx, "Nothing", []library.PhotoID{"unknown"})
+	assert.IsType(t, library.NotFound(""), err)
+
+	e, err := editor.Create(ctx, "Evening", []library.PhotoID{"4", "3"})
+	require.NoError(t, err)
+	assert.Equal(t, "Evening", e.Name)
+	assertSpan(t, e, 20*time.Minute, 30*time.Minute)
+
+	// The previous event ends with its last remaining photo
+	assertSpan(t, storedEvent(t, index, "e1"), 0, 10*time.Minute)
+
+	identifyEvents(t, index, lib)
+	assert.Equal(t, []library.PhotoID{"3", "4"}, eventPhotoIDs(t, index, e.ID))
+	assertSpan(t, storedEvent(t, index, e.ID), 20*time.Minute, 30*time.Minute)
+}
+
+func TestEventEditorMove(t *testing.T) {
+	editor, index, lib, cleanup := newEventsFixture(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := editor.Move(ctx, []library.PhotoID{"5"}, "unknown")
+	assert.IsType(t, boltstore.ErrEventNotFound(""), err)
+	_, err = editor.Move(ctx, []library.PhotoID{"unknown"}, "e1")
+	assert.IsType(t, library.NotFound(""), err)
+	_, err = editor.Move(ctx, nil, "e1")
+	assert.Equal(t, classification.ErrNoEventPhotos, err)
+
+	e, err := editor.Move(ctx, []library.PhotoID{"5"}, "e1")
+	require.NoError(t, err)
+	assertSpan(t, e, 0, 96*time.Hour)
+	assertSpan(t, storedEvent(t, index, "e2"), 96*time.Hour+10*time.Minute, 96*time.Hour+10*time.Minute)
+
+	// Moving the last photo of an event deletes it
+	_, err = editor.Move(ctx, []library.PhotoID{"6"}, "e1")
+	require.NoError(t, err)
+	_, found, err := index.Get(ctx, "e2")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	identifyEvents(t, index, lib)
+	assert.Equal(t, []library.PhotoID{"1", "2", "3", "4", "5", "6"}, eventPhotoIDs(t, index, "e1"))
+	assertSpan(t, storedEvent(t, index, "e1"), 0, 96*time.Hour+10*time.Minute)
+}
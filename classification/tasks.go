@@ -6,9 +6,12 @@ import (
 	"time"
 
 	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/domain/gps"
 	"bitbucket.org/kleinnic74/photos/library"
 	"bitbucket.org/kleinnic74/photos/library/boltstore"
+	"bitbucket.org/kleinnic74/photos/logging"
 	"bitbucket.org/kleinnic74/photos/tasks"
+	"go.uber.org/zap"
 )
 
 // RegisterTasks defines the task to split the photo collection into events at startup
@@ -32,6 +35,8 @@ func RegisterTasks(taskRepo *tasks.TaskRepository, index *boltstore.EventIndex)
 type SplitEventTask struct {
 	index     *boltstore.EventIndex
 	threshold time.Duration
+
+	Classifier ClassifierConfig `json:"classifier,omitempty"`
 }
 
 func newSplitEventsTask(eventIndex *boltstore.EventIndex) tasks.Task {
@@ -43,6 +48,9 @@ func (t *SplitEventTask) Describe() string {
 }
 
 func (t *SplitEventTask) Execute(ctx context.Context, executor tasks.TaskExecutor, lib library.PhotoLibrary) error {
+	if err := t.Classifier.Validate(); err != nil {
+		return err
+	}
 	photos, err := lib.FindAll(ctx, consts.Ascending)
 	if err != nil {
 		return err
@@ -51,58 +59,136 @@ func (t *SplitEventTask) Execute(ctx context.Context, executor tasks.TaskExecuto
 	var last time.Time
 	for _, p := range photos {
 		if p.DateTaken.Sub(last) > t.threshold && len(group) > 1 {
-			executor.Submit(ctx, newIdentifyEventsTask(t.index, group))
+			executor.Submit(ctx, newIdentifyEventsTask(t.index, group, t.Classifier))
 			group = make([]library.ExtendedPhotoID, 0)
 		}
 		group = append(group, p.ExtendedPhotoID)
 		last = p.DateTaken
 	}
 	if len(group) > 0 {
-		executor.Submit(ctx, newIdentifyEventsTask(t.index, group))
+		executor.Submit(ctx, newIdentifyEventsTask(t.index, group, t.Classifier))
 	}
 	return nil
 }
 
 // IdentifyEventsTask is a task that will identify temporal events within a group a photos and store each such event in the event index
 type IdentifyEventsTask struct {
-	index  *boltstore.EventIndex
-	Photos []library.ExtendedPhotoID `json:"photos,omitempty"`
+	index      *boltstore.EventIndex
+	Photos     []library.ExtendedPhotoID `json:"photos,omitempty"`
+	Classifier ClassifierConfig          `json:"classifier,omitempty"`
 }
 
-func newIdentifyEventsTask(eventIndex *boltstore.EventIndex, photos []library.ExtendedPhotoID) tasks.Task {
-	return &IdentifyEventsTask{index: eventIndex, Photos: photos}
+func newIdentifyEventsTask(eventIndex *boltstore.EventIndex, photos []library.ExtendedPhotoID, classifier ClassifierConfig) tasks.Task {
+	return &IdentifyEventsTask{index: eventIndex, Photos: photos, Classifier: classifier}
 }
 
 func (t *IdentifyEventsTask) Describe() string {
 	return fmt.Sprintf("Splitting events out of %d photos", len(t.Photos))
 }
 
+// sortedPhotos holds the capture time and location of photos, loaded once
+// as classification accesses them many times
 type sortedPhotos struct {
-	ctx context.Context
-	lib library.PhotoLibrary
-	ids []library.ExtendedPhotoID
+	ids       []library.ExtendedPhotoID
+	taken     []time.Time
+	local     []time.Time
+	locations []*gps.Coordinates
 }
 
-func (s *sortedPhotos) Len() int { return len(s.ids) }
-func (s *sortedPhotos) Get(i int) time.Time {
-	p, _ := s.lib.Get(s.ctx, s.ids[i].ID)
-	return p.DateTaken
+// loadPhotos loads the given photos, photos which cannot be loaded are left out
+func loadPhotos(ctx context.Context, lib library.PhotoLibrary, ids []library.ExtendedPhotoID) *sortedPhotos {
+	log := logging.From(ctx)
+	s := &sortedPhotos{
+		ids:       make([]library.ExtendedPhotoID, 0, len(ids)),
+		taken:     make([]time.Time, 0, len(ids)),
+		local:     make([]time.Time, 0, len(ids)),
+		locations: make([]*gps.Coordinates, 0, len(ids)),
+	}
+	for _, id := range ids {
+		p, err := lib.Get(ctx, id.ID)
+		if err != nil || p == nil {
+			log.Warn("Photo not found", zap.String("photo", string(id.ID)), zap.Error(err))
+			continue
+		}
+		s.ids = append(s.ids, id)
+		s.taken = append(s.taken, p.DateTaken)
+		s.local = append(s.local, p.LocalTime())
+		s.locations = append(s.locations, p.Location)
+	}
+	return s
+}
+
+func (s *sortedPhotos) Len() int            { return len(s.ids) }
+func (s *sortedPhotos) Get(i int) time.Time { return s.taken[i] }
+
+// Location returns the location of the i-th photo, nil if unknown
+func (s *sortedPhotos) Location(i int) *gps.Coordinates {
+	return s.locations[i]
+}
+
+// LocalTime returns the capture time of the i-th photo in the timezone it was taken in
+func (s *sortedPhotos) LocalTime(i int) time.Time {
+	return s.local[i]
 }
 
 func (t *IdentifyEventsTask) Execute(ctx context.Context, _ tasks.TaskExecutor, lib library.PhotoLibrary) error {
-	c := NewDistanceClassifier(TimestampDistance(12 * time.Hour))
+	c, err := t.Classifier.Classifier()
+	if err != nil {
+		return err
+	}
 
-	photos := &sortedPhotos{ctx, lib, t.Photos}
+	// Photos of events edited by the user keep their event
+	manual, err := t.index.ManualPhotos(ctx)
+	if err != nil {
+		return err
+	}
+	ids := make([]library.ExtendedPhotoID, 0, len(t.Photos))
+	for _, p := range t.Photos {
+		if !manual[p.ID] {
+			ids = append(ids, p)
+		}
+	}
+	photos := loadPhotos(ctx, lib, ids)
+	if photos.Len() == 0 {
+		return nil
+	}
 	clusters := c.Clusters(photos)
+	if t.Classifier.Compare {
+		return t.compare(ctx, photos, clusters)
+	}
 	for _, cluster := range clusters {
-		start, end := photos.Get(cluster.First), photos.Get(cluster.First+cluster.Count-1)
+		first, last := cluster.First, cluster.First+cluster.Count-1
 		e := boltstore.Event{
-			ID:   boltstore.EventID(start.Format(time.RFC3339)),
-			From: start,
-			To:   end,
+			ID:   boltstore.EventID(photos.Get(first).Format(time.RFC3339)),
+			From: photos.LocalTime(first),
+			To:   photos.LocalTime(last),
 		}
-		ids := t.Photos[cluster.First : cluster.First+cluster.Count]
-		t.index.AddPhotosToEvent(ctx, e, ids)
+		t.index.AddPhotosToEvent(ctx, e, photos.ids[cluster.First:cluster.First+cluster.Count])
+	}
+	return nil
+}
+
+// compare logs how the given clusters differ from the clusters identified by
+// time only
+func (t *IdentifyEventsTask) compare(ctx context.Context, photos *sortedPhotos, clusters []Cluster) error {
+	log, ctx := logging.SubFrom(ctx, "compareEvents")
+	timeOnly, err := t.Classifier.TimeOnly().Classifier()
+	if err != nil {
+		return err
+	}
+	reference := timeOnly.Clusters(photos)
+	changes := CompareClusters(reference, clusters)
+	for _, i := range changes.Added {
+		log.Info("Boundary added", zap.String("photo", string(photos.ids[i].ID)), zap.Time("taken", photos.Get(i)))
+	}
+	for _, i := range changes.Removed {
+		log.Info("Boundary removed", zap.String("photo", string(photos.ids[i].ID)), zap.Time("taken", photos.Get(i)))
 	}
+	log.Info("Compared event boundaries",
+		zap.Int("nbPhotos", photos.Len()),
+		zap.Int("eventsByTime", len(reference)),
+		zap.Int("events", len(clusters)),
+		zap.Int("added", len(changes.Added)),
+		zap.Int("removed", len(changes.Removed)))
 	return nil
 }
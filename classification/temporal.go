@@ -6,13 +6,26 @@ package classification
 import (
 	"math"
 	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
 )
 
+// DefaultKernelSize is the default number of neighbours on each side of an
+// element considered to compute its novelty score
+const DefaultKernelSize = 3
+
 type TimestampedData interface {
 	Len() int
 	Get(int) time.Time
 }
 
+// LocatedData is timestamped data whose elements may have a location
+type LocatedData interface {
+	TimestampedData
+	// Location returns the location of the i-th element, nil if unknown
+	Location(int) *gps.Coordinates
+}
+
 type DistanceFunc func(i, j time.Time) float64
 
 func TimestampDistance(d time.Duration) DistanceFunc {
@@ -23,12 +36,48 @@ func TimestampDistance(d time.Duration) DistanceFunc {
 	}
 }
 
+// SimilarityFunc returns the similarity of the i-th and j-th elements of the
+// data, between 0 and 1
+type SimilarityFunc func(data TimestampedData, i, j int) float64
+
+// SpatioTemporalDistance returns the similarity decreasing exponentially with
+// the time between two elements, relative to timeScale, and with the distance
+// in meters between them, relative to distanceScale. The weights set the
+// importance of time versus distance, elements without location are compared
+// by time only, as if they were at the same place, so that their similarities
+// remain comparable to those of located elements.
+func SpatioTemporalDistance(timeScale time.Duration, distanceScale, timeWeight, spaceWeight float64) SimilarityFunc {
+	kt := timeScale.Seconds()
+	return func(data TimestampedData, i, j int) float64 {
+		dt := math.Abs(data.Get(i).Sub(data.Get(j)).Seconds()) / kt
+		var ds float64
+		if located, ok := data.(LocatedData); ok && spaceWeight != 0 {
+			if li, lj := located.Location(i), located.Location(j); li != nil && lj != nil {
+				ds = li.DistanceTo(lj) / distanceScale
+			}
+		}
+		return math.Exp(-(timeWeight*dt + spaceWeight*ds) / (timeWeight + spaceWeight))
+	}
+}
+
 type DistanceClassifier struct {
-	d DistanceFunc
+	sim        SimilarityFunc
+	kernelSize int
 }
 
 func NewDistanceClassifier(d DistanceFunc) (mat DistanceClassifier) {
-	return DistanceClassifier{d: d}
+	return NewSimilarityClassifier(func(data TimestampedData, i, j int) float64 {
+		return d(data.Get(i), data.Get(j))
+	}, DefaultKernelSize)
+}
+
+// NewSimilarityClassifier returns a classifier using the given similarity and
+// considering kernelSize neighbours on each side of an element
+func NewSimilarityClassifier(sim SimilarityFunc, kernelSize int) DistanceClassifier {
+	if kernelSize <= 0 {
+		kernelSize = DefaultKernelSize
+	}
+	return DistanceClassifier{sim: sim, kernelSize: kernelSize}
 }
 
 type NoveltyScore struct {
@@ -52,14 +101,19 @@ func (m DistanceClassifier) SelfSimilarityMatrix(data TimestampedData) [][]float
 	for i := range ssm {
 		ssm[i] = make([]float64, size)
 		for j := range ssm[i] {
-			ssm[i][j] = m.d(data.Get(i), data.Get(j))
+			ssm[i][j] = m.sim(data, i, j)
 		}
 	}
 	return ssm
 }
 
 func (m DistanceClassifier) NoveltyScores(ssm [][]float64, kernelSize int) (scores NoveltyScores) {
-	n := len(ssm)
+	return noveltyScores(len(ssm), func(i, j int) float64 { return ssm[i][j] }, kernelSize)
+}
+
+// noveltyScores computes the novelty score of each of the n elements by
+// correlating the kernel along the diagonal of the self-similarity given by sim
+func noveltyScores(n int, sim func(i, j int) float64, kernelSize int) (scores NoveltyScores) {
 	scores.Scores = make([]NoveltyScore, n)
 	scores.Min = math.Inf(1)
 	scores.Max = math.Inf(-1)
@@ -70,7 +124,7 @@ func (m DistanceClassifier) NoveltyScores(ssm [][]float64, kernelSize int) (scor
 			for y := -kernelSize; y <= kernelSize; y++ {
 				indexX, indexY := i+x, i+y
 				if indexX >= 0 && indexY >= 0 && indexX < n && indexY < n {
-					score += kernel[x+kernelSize][y+kernelSize] * ssm[indexX][indexY]
+					score += kernel[x+kernelSize][y+kernelSize] * sim(indexX, indexY)
 				}
 			}
 		}
@@ -85,13 +139,50 @@ func (m DistanceClassifier) NoveltyScores(ssm [][]float64, kernelSize int) (scor
 	return scores
 }
 
+// BandedSimilarity holds the similarities of each element with the elements
+// at most Width positions after it, which is all the kernel looks at. It
+// needs O(n·Width) memory instead of the O(n²) of the self-similarity matrix.
+type BandedSimilarity struct {
+	Width  int
+	values []float64
+}
+
+// At returns the similarity of the i-th and j-th elements, which must be at
+// most Width positions apart
+func (b BandedSimilarity) At(i, j int) float64 {
+	if i > j {
+		i, j = j, i
+	}
+	return b.values[i*(b.Width+1)+j-i]
+}
+
+// BandedSimilarity returns the similarities needed to compute the novelty
+// scores with the given kernel size
+func (m DistanceClassifier) BandedSimilarity(data TimestampedData, kernelSize int) BandedSimilarity {
+	n := data.Len()
+	// The kernel compares elements up to twice its size apart
+	b := BandedSimilarity{Width: 2 * kernelSize, values: make([]float64, n*(2*kernelSize+1))}
+	for i := 0; i < n; i++ {
+		for d := 0; d <= b.Width && i+d < n; d++ {
+			b.values[i*(b.Width+1)+d] = m.sim(data, i, i+d)
+		}
+	}
+	return b
+}
+
+// BandedNoveltyScores returns the same scores as NoveltyScores, computed from
+// the banded similarities
+func (m DistanceClassifier) BandedNoveltyScores(b BandedSimilarity, n, kernelSize int) NoveltyScores {
+	return noveltyScores(n, b.At, kernelSize)
+}
+
 type Cluster struct {
 	First, Count int
 }
 
 func (m DistanceClassifier) Clusters(data TimestampedData) (clusters []Cluster) {
-	ssm := m.SelfSimilarityMatrix(data)
-	noveltyScores := m.NoveltyScores(ssm, 3)
+	band := m.BandedSimilarity(data, m.kernelSize)
+	noveltyScores := m.BandedNoveltyScores(band, data.Len(), m.kernelSize)
 	var cluster Cluster
 	for i, s := range noveltyScores.Scores {
 		if s.Boundary {
@@ -132,3 +223,38 @@ func sign(i int) int {
 	}
 	return 1
 }
+
+// BoundaryChanges describes how the boundaries between clusters differ
+// between two clusterings of the same data
+type BoundaryChanges struct {
+	// Added are the indexes of the elements starting a cluster only in the
+	// second clustering
+	Added []int `json:"added"`
+	// Removed are the indexes of the elements starting a cluster only in the
+	// first clustering
+	Removed []int `json:"removed"`
+}
+
+// CompareClusters returns the boundaries added and removed by the clusters
+// after compared to the clusters before
+func CompareClusters(before, after []Cluster) (changes BoundaryChanges) {
+	starts := func(clusters []Cluster) map[int]bool {
+		s := make(map[int]bool, len(clusters))
+		for _, c := range clusters {
+			s[c.First] = true
+		}
+		return s
+	}
+	b, a := starts(before), starts(after)
+	for _, c := range after {
+		if !b[c.First] {
+			changes.Added = append(changes.Added, c.First)
+		}
+	}
+	for _, c := range before {
+		if !a[c.First] {
+			changes.Removed = append(changes.Removed, c.First)
+		}
+	}
+	return
+}
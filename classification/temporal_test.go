@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"bitbucket.org/kleinnic74/photos/classification"
+	"bitbucket.org/kleinnic74/photos/domain/gps"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -165,3 +166,174 @@ func loadEventData(t *testing.T) dataList {
 	}
 	return d
 }
+
+type locatedList []struct {
+	ts       time.Time
+	location *gps.Coordinates
+}
+
+func (l locatedList) Len() int                        { return len(l) }
+func (l locatedList) Get(i int) time.Time             { return l[i].ts }
+func (l locatedList) Location(i int) *gps.Coordinates { return l[i].location }
+
+// twoOutings returns photos taken on the same afternoon in two cities
+func twoOutings() locatedList {
+	start := parseTime("2019-06-01T12:00:00Z")
+	vienna := gps.Coordinates{Lat: 48.2082, Long: 16.3738}
+	bratislava := gps.Coordinates{Lat: 48.1486, Long: 17.1077}
+	minutes := []int{0, 7, 15, 31, 38, 50, 55, 71, 80, 97, 104, 112, 121, 130, 141, 150, 163, 170, 181, 190}
+	var l locatedList
+	for i, m := range minutes {
+		location, taken := vienna, start.Add(time.Duration(m)*time.Minute)
+		if i >= 10 {
+			location = bratislava
+		}
+		l = append(l, struct {
+			ts       time.Time
+			location *gps.Coordinates
+		}{taken, &location})
+	}
+	return l
+}
+
+func clusterOf(clusters []classification.Cluster, i int) int {
+	for n, c := range clusters {
+		if i >= c.First && i < c.First+c.Count {
+			return n
+		}
+	}
+	return -1
+}
+
+func TestSpatioTemporalClusters(t *testing.T) {
+	data := twoOutings()
+	byTime, err := classification.ClassifierConfig{}.Classifier()
+	assert.NoError(t, err)
+	timeClusters := byTime.Clusters(data)
+	assert.Equal(t, clusterOf(timeClusters, 8), clusterOf(timeClusters, 11), "Time only must not separate the cities")
+
+	bySpace, err := classification.ClassifierConfig{SpaceWeight: 1, DistanceScale: 5000}.Classifier()
+	assert.NoError(t, err)
+	clusters := bySpace.Clusters(data)
+	assert.Len(t, clusters, 2)
+	assert.NotEqual(t, clusterOf(clusters, 8), clusterOf(clusters, 11), "Distance must separate the cities")
+
+	changes := classification.CompareClusters(timeClusters, clusters)
+	assert.Contains(t, changes.Added, clusters[1].First)
+}
+
+func TestSpatioTemporalDistance(t *testing.T) {
+	data := twoOutings()
+	timeOnly := classification.SpatioTemporalDistance(12*time.Hour, 1000, 1, 0)
+	distance := classification.TimestampDistance(12 * time.Hour)
+	assert.InDelta(t, distance(data.Get(0), data.Get(15)), timeOnly(data, 0, 15), 1e-9)
+	combined := classification.SpatioTemporalDistance(12*time.Hour, 1000, 1, 1)
+	assert.True(t, combined(data, 0, 5) > combined(data, 0, 15))
+	assert.InDelta(t, 1., combined(data, 3, 3), 1e-9)
+	// Elements without location are compared by time only, as if they were
+	// at the same place
+	samePlace := locatedList{data[0], data[15]}
+	samePlace[1].location = samePlace[0].location
+	assert.InDelta(t, combined(samePlace, 0, 1), combined(timestampList{data.Get(0), data.Get(15)}, 0, 1), 1e-9)
+	unlocated := locatedList{data[0], data[15]}
+	unlocated[1].location = nil
+	assert.InDelta(t, combined(samePlace, 0, 1), combined(unlocated, 0, 1), 1e-9)
+}
+
+func TestSpatioTemporalClustersWithoutLocation(t *testing.T) {
+	// Photos taken every 10 minutes at the same place, some of them without
+	// location, form a single event
+	start := parseTime("2019-06-01T12:00:00Z")
+	vienna := gps.Coordinates{Lat: 48.2082, Long: 16.3738}
+	var data locatedList
+	for i := 0; i < 20; i++ {
+		location := &vienna
+		if i%3 == 1 {
+			location = nil
+		}
+		data = append(data, struct {
+			ts       time.Time
+			location *gps.Coordinates
+		}{start.Add(time.Duration(10*i) * time.Minute), location})
+	}
+	classifier, err := classification.ClassifierConfig{TimeScale: "30m", SpaceWeight: 1}.Classifier()
+	assert.NoError(t, err)
+	assert.Len(t, classifier.Clusters(data), 1)
+
+	// Unlocated photos in the other city stay with the located ones taken
+	// around the same time
+	data = twoOutings()
+	data[3].location, data[4].location, data[13].location = nil, nil, nil
+	classifier, err = classification.ClassifierConfig{SpaceWeight: 1, DistanceScale: 5000}.Classifier()
+	assert.NoError(t, err)
+	clusters := classifier.Clusters(data)
+	assert.Len(t, clusters, 2)
+	assert.Equal(t, clusterOf(clusters, 0), clusterOf(clusters, 3))
+	assert.Equal(t, clusterOf(clusters, 0), clusterOf(clusters, 4))
+	assert.Equal(t, clusterOf(clusters, 19), clusterOf(clusters, 13))
+}
+
+func TestClassifierConfigValidate(t *testing.T) {
+	assert.NoError(t, classification.ClassifierConfig{TimeScale: "6h", SpaceWeight: 2, KernelSize: 5}.Validate())
+	assert.Error(t, classification.ClassifierConfig{TimeScale: "soon"}.Validate())
+	assert.Error(t, classification.ClassifierConfig{TimeScale: "-1h"}.Validate())
+	assert.Error(t, classification.ClassifierConfig{SpaceWeight: -1}.Validate())
+}
+
+func TestCompareClusters(t *testing.T) {
+	before := []classification.Cluster{{First: 0, Count: 5}, {First: 5, Count: 5}}
+	after := []classification.Cluster{{First: 0, Count: 3}, {First: 3, Count: 7}}
+	changes := classification.CompareClusters(before, after)
+	assert.Equal(t, []int{3}, changes.Added)
+	assert.Equal(t, []int{5}, changes.Removed)
+}
+
+func TestBandedNoveltyScores(t *testing.T) {
+	data := loadEventData(t)
+	m := classification.NewDistanceClassifier(classification.TimestampDistance(12 * time.Hour))
+	full := m.NoveltyScores(m.SelfSimilarityMatrix(data), 3)
+	banded := m.BandedNoveltyScores(m.BandedSimilarity(data, 3), data.Len(), 3)
+	assert.Equal(t, len(full.Scores), len(banded.Scores))
+	for i := range full.Scores {
+		assert.InDelta(t, full.Scores[i].Score, banded.Scores[i].Score, 1e-9, "Score #%d", i)
+		assert.Equal(t, full.Scores[i].Boundary, banded.Scores[i].Boundary, "Boundary #%d", i)
+	}
+}
+
+// syntheticTimeline returns n timestamps forming bursts of photos separated
+// by longer pauses
+func syntheticTimeline(n int) timestampList {
+	l := make(timestampList, n)
+	ts := parseTime("2010-01-01T00:00:00Z")
+	for i := range l {
+		if i%50 == 0 {
+			ts = ts.Add(time.Duration(24+i%7) * time.Hour)
+		} else {
+			ts = ts.Add(time.Duration(1+i%13) * time.Minute)
+		}
+		l[i] = ts
+	}
+	return l
+}
+
+func BenchmarkClusters(b *testing.B) {
+	m := classification.NewDistanceClassifier(classification.TimestampDistance(12 * time.Hour))
+	for _, n := range []int{1000, 10000, 100000} {
+		data := syntheticTimeline(n)
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				m.Clusters(data)
+			}
+		})
+	}
+}
+
+func BenchmarkSelfSimilarityMatrix(b *testing.B) {
+	m := classification.NewDistanceClassifier(classification.TimestampDistance(12 * time.Hour))
+	data := syntheticTimeline(1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m.NoveltyScores(m.SelfSimilarityMatrix(data), 3)
+	}
+}
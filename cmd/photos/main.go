@@ -14,31 +14,51 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	bolt "go.etcd.io/bbolt"
 	"go.uber.org/zap"
 
 	"bitbucket.org/kleinnic74/photos/classification"
 	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/correction"
 	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/domain/timezone"
 	"bitbucket.org/kleinnic74/photos/events"
 	"bitbucket.org/kleinnic74/photos/geocoding"
+	"bitbucket.org/kleinnic74/photos/geocoding/geonames"
 	"bitbucket.org/kleinnic74/photos/geocoding/openstreetmap"
+	"bitbucket.org/kleinnic74/photos/geoexport"
 	"bitbucket.org/kleinnic74/photos/importer"
 	"bitbucket.org/kleinnic74/photos/index"
 	"bitbucket.org/kleinnic74/photos/library"
 	"bitbucket.org/kleinnic74/photos/library/boltstore"
 	"bitbucket.org/kleinnic74/photos/logging"
 	"bitbucket.org/kleinnic74/photos/rest"
+	"bitbucket.org/kleinnic74/photos/rest/views"
 	"bitbucket.org/kleinnic74/photos/rest/wdav"
+	"bitbucket.org/kleinnic74/photos/search"
 	"bitbucket.org/kleinnic74/photos/tasks"
+	"bitbucket.org/kleinnic74/photos/thumbs"
 )
 
 var (
 	dbName = "photos.db"
 
-	libDir string
-	uiDir  string
-	port   uint
+	libDir      string
+	uiDir       string
+	port        uint
+	ffmpegPath  string
+	geonamesDir string
+	timezones   string
+	offline     bool
+	nominatim   string
+	osmRate     float64
+
+	thumbWorkers    string
+	discoverWorkers bool
+	swarmKeyFile    string
+	thumbsMaxMB     int64
 
 	logger *zap.Logger
 	ctx    context.Context
@@ -46,12 +66,22 @@ var (
 
 func init() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s  [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s  [options] [%s [worker options]]\n", os.Args[0], thumbdCommand)
 		flag.PrintDefaults()
 	}
 	flag.StringVar(&libDir, "l", "gophotos", "Path to photo library")
 	flag.StringVar(&uiDir, "ui", "", "Path to the frontend static assets")
 	flag.UintVar(&port, "p", 8080, "HTTP server port")
+	flag.StringVar(&ffmpegPath, "ffmpeg", "", "Path to the ffmpeg binary used for video poster frames (default: lookup in PATH)")
+	flag.StringVar(&geonamesDir, "geonames", "", "Path to a directory containing a GeoNames cities dataset used for offline reverse geocoding")
+	flag.StringVar(&timezones, "timezones", "", "Path to the timezone boundaries (GeoJSON file or zip archive) of the timezone-boundary-builder project, used to find the timezone photos were taken in")
+	flag.BoolVar(&offline, "offline", false, "Do not use online services (OpenStreetMap) for reverse geocoding")
+	flag.StringVar(&nominatim, "nominatim", openstreetmap.DefaultBaseURL, "URL of the Nominatim server used for reverse geocoding")
+	flag.Float64Var(&osmRate, "nominatim-rate", geocoding.DefaultThrottleConfig.Rate, "Maximum number of requests per second sent to the Nominatim server")
+	flag.StringVar(&thumbWorkers, "thumb-workers", "", "Comma-separated URLs of thumb workers to offload thumbnail creation to")
+	flag.BoolVar(&discoverWorkers, "discover-workers", false, "Offload thumbnail creation to thumb workers discovered on the local network")
+	flag.StringVar(&swarmKeyFile, "swarm-key", "", "Path to a file containing the secret shared with the thumb workers, required to offload thumbnail creation")
+	flag.Int64Var(&thumbsMaxMB, "thumbs-max-mb", 0, "Maximum total size of the stored thumbs in MB, least recently used thumbs are removed beyond it (0: unlimited)")
 	ctx = logging.Context(context.Background(), nil)
 	logger = logging.From(ctx)
 
@@ -64,13 +94,18 @@ func init() {
 	libDir = absdir
 	logger.Info("Photoscope starting", zap.String("gitCommit", consts.GitCommit), zap.String("gitRepo", consts.GitRepo))
 	logger.Info("Library directory", zap.String("dir", libDir))
-}
 
-func main() {
-	if err := os.MkdirAll(libDir, os.ModePerm); err != nil {
-		log.Fatal("Failed to create directory", zap.String("dir", libDir), zap.Error(err))
+	if ffmpegPath != "" {
+		domain.SetFFmpegPath(ffmpegPath)
 	}
+	if ffmpeg, found := domain.FFmpegAvailable(); found {
+		logger.Info("Video poster frames enabled", zap.String("ffmpeg", ffmpeg))
+	} else {
+		logger.Info("ffmpeg not found, using placeholders for video thumbnails")
+	}
+}
 
+func main() {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Interrupt)
 	ctx, cancel := context.WithCancel(ctx)
@@ -80,6 +115,24 @@ func main() {
 		cancel()
 	}()
 
+	if flag.Arg(0) == thumbdCommand {
+		runThumbd(ctx, flag.Args()[1:])
+		return
+	}
+
+	if timezones != "" {
+		b, err := timezone.OpenBoundaries(timezones)
+		if err != nil {
+			logger.Fatal("Failed to load timezone boundaries", zap.String("file", timezones), zap.Error(err))
+		}
+		timezone.UseBoundaries(b)
+		logger.Info("Timezone boundaries loaded", zap.String("file", timezones))
+	}
+
+	if err := os.MkdirAll(libDir, os.ModePerm); err != nil {
+		log.Fatal("Failed to create directory", zap.String("dir", libDir), zap.Error(err))
+	}
+
 	db, err := bolt.Open(filepath.Join(libDir, dbName), 0600, nil)
 	if err != nil {
 		logger.Fatal("Failed to initialize data store", zap.Error(err))
@@ -111,11 +164,13 @@ func main() {
 		logger.Fatal("Failed to initialize library", zap.Error(err))
 	}
 
-	lib, err := library.NewBasicPhotoLibrary(libDir, store, domain.LocalThumber{})
+	lib, err := library.NewBasicPhotoLibrary(libDir, store, newThumber(ctx))
 	if err != nil {
 		logger.Fatal("Failed to initialize library", zap.Error(err))
 	}
 	logger.Info("Opened photo library", zap.String("path", libDir))
+	lib.ThumbCache().SetMaxBytes(ctx, thumbsMaxMB<<20)
+	prometheus.MustRegister(lib.ThumbCache())
 	migrator.AddInstances(lib)
 
 	geoindex, err := boltstore.NewBoltGeoIndex(db)
@@ -124,7 +179,27 @@ func main() {
 	}
 	migrator.AddStructure("geo", geoindex)
 
-	geocoder := geocoding.NewGeocoder(geoindex, openstreetmap.NewResolver("de,en"))
+	addressCache, err := boltstore.NewAddressCache(db)
+	if err != nil {
+		logger.Fatal("Failed to initialize address cache", zap.Error(err))
+	}
+
+	userplaces, err := boltstore.NewUserPlaceStore(db)
+	if err != nil {
+		logger.Fatal("Failed to initialize user places", zap.Error(err))
+	}
+
+	albums, err := boltstore.NewAlbumStore(db)
+	if err != nil {
+		logger.Fatal("Failed to initialize albums", zap.Error(err))
+	}
+
+	smartalbums, err := boltstore.NewSmartAlbumStore(db)
+	if err != nil {
+		logger.Fatal("Failed to initialize smart albums", zap.Error(err))
+	}
+
+	geocoder := geocoding.NewGeocoder(geoindex, newResolver(addressCache))
 	geocoder.RegisterTasks(taskRepo)
 
 	dateindex, err := boltstore.NewDateIndex(db)
@@ -133,11 +208,43 @@ func main() {
 	}
 	migrator.AddStructure("date", dateindex)
 
+	spatialindex, err := boltstore.NewSpatialIndex(db)
+	if err != nil {
+		logger.Fatal("Failed to initialize spatial index", zap.Error(err))
+	}
+	migrator.AddStructure("spatial", spatialindex)
+
+	tagindex, err := boltstore.NewTagIndex(db)
+	if err != nil {
+		logger.Fatal("Failed to initialize tag index", zap.Error(err))
+	}
+	migrator.AddStructure("tags", tagindex)
+	geocoder.UseUserPlaces(userplaces, spatialindex)
+	geocoder.AddResolvedCallback(func(ctx context.Context, photo library.ExtendedPhotoID, address *gps.Address) error {
+		_, err := lib.CorrectTimezone(ctx, photo.ID, address.Country.ID)
+		return err
+	})
+
 	eventindex, err := boltstore.NewEventIndex(db)
 	if err != nil {
 		logger.Fatal("Failed to initialize event database")
 	}
 	classification.RegisterTasks(taskRepo, eventindex)
+	correction.RegisterTasks(taskRepo, eventindex)
+	thumbs.RegisterTasks(taskRepo, lib)
+
+	textindex, err := boltstore.NewTextIndex(db)
+	if err != nil {
+		logger.Fatal("Failed to initialize text index", zap.Error(err))
+	}
+	migrator.AddStructure("text", textindex)
+	textindexer := search.NewTextIndexer(textindex, lib, geoindex, eventindex)
+	geocoder.AddResolvedCallback(textindexer.AddressResolved)
+	eventindex.AddEventsChangedCallback(textindexer.Reindex)
+
+	planner := search.NewPlanner()
+	search.RegisterSources(planner, dateindex, geoindex, spatialindex, tagindex, eventindex)
+	planner.Register("text", search.TextSource(textindex))
 
 	bus := events.NewStream()
 	go bus.Dispatch(ctx)
@@ -150,12 +257,26 @@ func main() {
 	indexer := index.NewIndexer(indexTracker, executor)
 	indexer.RegisterDirect("date", boltstore.DateIndexVersion, dateindex.Add)
 	indexer.RegisterDefered("geo", boltstore.GeoIndexVersion, geocoder.LookupPhotoOnAdd)
+	indexer.RegisterDirect("spatial", boltstore.SpatialIndexVersion, spatialindex.Add)
+	indexer.RegisterDirect("tags", boltstore.TagIndexVersion, tagindex.Add)
+	indexer.RegisterDirect("text", boltstore.TextIndexVersion, textindexer.Add)
+	indexer.RegisterDefered("thumbs", thumbs.IndexVersion, thumbs.RenderOnAdd)
 
 	indexer.RegisterTasks(taskRepo)
 
 	RegisterMigrationTask(taskRepo, migrator, indexer)
 
 	lib.AddCallback(indexer.Add)
+	lib.AddDateChangedCallback(dateindex.Move)
+	lib.AddDateChangedCallback(eventindex.Move)
+	lib.AddDateChangedCallback(geoindex.Move)
+	lib.AddDateChangedCallback(spatialindex.Update)
+	lib.AddLocationChangedCallback(spatialindex.Update)
+	lib.AddTagsChangedCallback(tagindex.Update)
+	lib.AddDateChangedCallback(tagindex.Update)
+	lib.AddDateChangedCallback(textindexer.Update)
+	lib.AddLocationChangedCallback(textindexer.Update)
+	lib.AddTagsChangedCallback(textindexer.Update)
 
 	go launchStartupTasks(ctx, taskRepo, executor)
 
@@ -176,26 +297,59 @@ func main() {
 	photoApp := rest.NewApp(lib)
 	photoApp.InitRoutes(router)
 
+	dates := rest.NewDatesHandler(taskRepo, executor)
+	dates.InitRoutes(router)
+
+	geotag := rest.NewGeotagHandler(lib, geocoder, executor)
+	geotag.InitRoutes(router)
+
 	timeline := rest.NewTimelineHandler(dateindex, lib)
 	timeline.InitRoutes(router)
 
 	geo := rest.NewGeoHandler(geoindex, lib)
 	geo.InitRoutes(router)
 
+	maps := rest.NewMapHandler(spatialindex, lib)
+	maps.InitRoutes(router)
+
+	places := rest.NewUserPlacesHandler(userplaces, geocoder, executor)
+	places.InitRoutes(router)
+
 	geocache := rest.NewGeoCacheHandler(geocoder.Cache)
 	geocache.InitRoutes(router)
 
+	tags := rest.NewTagsHandler(lib, tagindex)
+	tags.InitRoutes(router)
+
+	albumsApp := rest.NewAlbumsHandler(albums, lib)
+	albumsApp.InitRoutes(router)
+
+	smartalbumsApp := rest.NewSmartAlbumsHandler(smartalbums, planner, lib)
+	smartalbumsApp.InitRoutes(router)
+
+	searchApp := rest.NewSearchHandler(planner, textindex, lib)
+	searchApp.InitRoutes(router)
+
+	thumbStats := rest.NewThumbsHandler(lib.ThumbCache())
+	thumbStats.InitRoutes(router)
+
 	tasksApp := rest.NewTaskHandler(taskRepo, executor)
 	tasksApp.InitRoutes(router)
 
 	events := rest.NewEventsHandler(eventindex, lib)
 	events.InitRoutes(router)
 
+	exporter := geoexport.NewExporter(lib, geoindex, eventindex, userplaces, func(id library.PhotoID) map[string]string {
+		return views.PhotoLinksFor(id)
+	})
+	export := rest.NewExportHandler(exporter)
+	export.InitRoutes(router)
+
 	indexesRest := rest.NewIndexes(indexer, migrator)
 	indexesRest.Init(router)
 
 	tmpdir := filepath.Join(libDir, "tmp")
-	wdav, err := wdav.NewWebDavHandler(tmpdir, backgroundImport(executor))
+	wdav, err := wdav.NewWebDavHandler(tmpdir, backgroundImport(executor), albums, lib)
 	if err != nil {
 		logger.Fatal("Error initializing webdav interface", zap.Error(err))
 	}
@@ -251,6 +405,30 @@ func main() {
 	logger.Info("Terminated gracefully")
 }
 
+// newResolver returns the reverse geocoder: the offline GeoNames dataset if
+// configured, falling back to OpenStreetMap unless running offline
+func newResolver(cache geocoding.AddressStore) geocoding.Resolver {
+	var resolvers []geocoding.Resolver
+	if geonamesDir != "" {
+		r, err := geonames.Open(geonamesDir, geonames.DefaultMaxDistance)
+		if err != nil {
+			logger.Fatal("Failed to load GeoNames dataset", zap.String("dir", geonamesDir), zap.Error(err))
+		}
+		logger.Info("Offline reverse geocoding enabled", zap.String("dir", geonamesDir))
+		resolvers = append(resolvers, r)
+	}
+	if !offline {
+		throttle := geocoding.DefaultThrottleConfig
+		throttle.Rate = osmRate
+		osm := openstreetmap.NewResolverForURL(nominatim, &http.Client{Timeout: 5 * time.Second}, "de,en")
+		resolvers = append(resolvers, geocoding.Persistent(geocoding.Throttled(osm, throttle), cache, geocoding.DefaultCachePrecision))
+	}
+	if len(resolvers) == 0 {
+		logger.Warn("No reverse geocoder available, photo locations will not be resolved")
+	}
+	return geocoding.Chain(resolvers...)
+}
+
 func launchStartupTasks(ctx context.Context, tasksRepo *tasks.TaskRepository, executor tasks.TaskExecutor) {
 	for _, t := range tasksRepo.DefinedTasks() {
 		if t.RunOnStart {
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"bitbucket.org/kleinnic74/photos/rest"
+	"bitbucket.org/kleinnic74/photos/swarm"
+)
+
+// thumbdCommand is the command running this process as a thumb worker
+const thumbdCommand = "thumbd"
+
+// runThumbd serves thumb requests of other instances until the context is
+// cancelled, announcing this worker on the local network
+func runThumbd(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet(thumbdCommand, flag.ExitOnError)
+	workerPort := flags.Uint("p", 8090, "HTTP port of the thumb worker")
+	discoveryAddr := flags.String("discovery", swarm.DefaultDiscoveryAddr, "Multicast address on which the worker is announced")
+	keyFile := flags.String("swarm-key", "", "Path to a file containing the secret shared with the instances sending thumb requests (required)")
+	flags.Parse(args)
+
+	key, err := readSwarmKey(*keyFile)
+	if err != nil {
+		logger.Fatal("Thumb worker requires a shared key", zap.Error(err))
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		logger.Fatal("Failed to generate worker ID", zap.Error(err))
+	}
+	logger, ctx := logging.FromWithFields(ctx, zap.String("worker", id.String()))
+
+	router := mux.NewRouter()
+	worker, err := swarm.NewWorkerHandler(domain.LocalThumber{}, key)
+	if err != nil {
+		logger.Fatal("Failed to initialize thumb worker", zap.Error(err))
+	}
+	worker.InitRoutes(router)
+
+	server := http.Server{
+		Addr:        fmt.Sprintf(":%d", *workerPort),
+		Handler:     rest.WithMiddleWares(router, thumbdCommand),
+		BaseContext: func(l net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		logger.Info("Starting thumb worker...", zap.Uint("port", *workerPort))
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("HTTP server failed", zap.Error(err))
+		}
+		logger.Info("Thumb worker stopped")
+	}()
+	go func() {
+		if err := swarm.Announce(ctx, *discoveryAddr, id.String(), int(*workerPort), swarm.DefaultAnnounceInterval, key); err != nil {
+			logger.Warn("Worker cannot be discovered", zap.String("discovery", *discoveryAddr), zap.Error(err))
+		}
+	}()
+
+	<-ctx.Done()
+
+	ctxShutdown, cancelServerShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelServerShutdown()
+	if err := server.Shutdown(ctxShutdown); err != nil {
+		logger.Fatal("Failed to shutdown HTTP server", zap.Error(err))
+	}
+	logger.Info("Terminated gracefully")
+}
+
+// newThumber returns the thumber of the library: thumbs are offloaded to the
+// configured and discovered workers, falling back to creating them locally
+func newThumber(ctx context.Context) domain.Thumber {
+	if thumbWorkers == "" && !discoverWorkers {
+		return domain.LocalThumber{}
+	}
+	key, err := readSwarmKey(swarmKeyFile)
+	if err != nil {
+		logger.Fatal("Offloading thumbnail creation requires a shared key", zap.Error(err))
+	}
+	registry, err := swarm.NewRegistry(domain.JPEG, swarm.DefaultWorkerTTL, key)
+	if err != nil {
+		logger.Fatal("Failed to initialize thumb workers", zap.Error(err))
+	}
+	for _, url := range strings.Split(thumbWorkers, ",") {
+		if url = strings.TrimSpace(url); url == "" {
+			continue
+		}
+		if err := registry.Register(url); err != nil {
+			logger.Fatal("Bad thumb worker URL", zap.String("url", url), zap.Error(err))
+		}
+		logger.Info("Thumb worker configured", zap.String("url", url))
+	}
+	if discoverWorkers {
+		go func() {
+			if err := registry.Listen(ctx, swarm.DefaultDiscoveryAddr); err != nil {
+				logger.Warn("Thumb worker discovery failed", zap.Error(err))
+			}
+		}()
+	}
+	return swarm.NewBalancedThumber(ctx, registry, domain.LocalThumber{})
+}
+
+// readSwarmKey reads the secret shared by the instances and the thumb workers
+func readSwarmKey(path string) (swarm.Key, error) {
+	if path == "" {
+		return nil, swarm.ErrNoKey
+	}
+	return swarm.ReadKey(path)
+}
@@ -39,4 +39,8 @@ func main() {
 	})
 	log.Printf("DateTaken: %s", qt.DateTaken())
 	log.Printf("Location: %s", qt.Location())
+	log.Printf("Duration: %s", qt.Duration())
+	if codec, width, height, found := qt.VideoTrack(); found {
+		log.Printf("Video: %s %dx%d", codec, width, height)
+	}
 }
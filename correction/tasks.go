@@ -0,0 +1,244 @@
+// Package correction provides tasks to correct the meta-data of photos
+package correction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"bitbucket.org/kleinnic74/photos/tasks"
+	"go.uber.org/zap"
+)
+
+// ShiftDatesTaskType is the name of the task shifting the capture time of photos
+const ShiftDatesTaskType = "ShiftDates"
+
+const (
+	dayFormat     = "2006-01-02"
+	eventPageSize = 100
+)
+
+var (
+	ErrNoSelection  = errors.New("No photos selected: either photos, event or from/to is required")
+	ErrNoCorrection = errors.New("Missing correction: either offset or reference and referenceTime are required")
+)
+
+// RegisterTasks defines the tasks to correct photo meta-data
+func RegisterTasks(repo *tasks.TaskRepository, events *boltstore.EventIndex) {
+	repo.RegisterWithProperties(ShiftDatesTaskType, func() tasks.Task {
+		return NewShiftDatesTask(events)
+	}, tasks.TaskProperties{
+		RunOnStart:   false,
+		UserRunnable: true,
+	})
+}
+
+// ShiftDatesTask shifts the capture time of a selection of photos, e.g. taken
+// with a camera whose clock was wrong. The photos are selected by ID, by event
+// or by a range of days. They are shifted either by a fixed offset or by the
+// offset needed for the reference photo to have been taken at the reference time
+type ShiftDatesTask struct {
+	events *boltstore.EventIndex
+
+	Photos []library.PhotoID `json:"photos,omitempty"`
+	Event  string            `json:"event,omitempty"`
+	From   string            `json:"from,omitempty"`
+	To     string            `json:"to,omitempty"`
+
+	Offset        string          `json:"offset,omitempty"`
+	Reference     library.PhotoID `json:"reference,omitempty"`
+	ReferenceTime time.Time       `json:"referenceTime,omitempty"`
+}
+
+// NewShiftDatesTask returns an empty ShiftDatesTask
+func NewShiftDatesTask(events *boltstore.EventIndex) *ShiftDatesTask {
+	return &ShiftDatesTask{events: events}
+}
+
+func (t *ShiftDatesTask) Describe() string {
+	return "Correcting capture time of photos"
+}
+
+// Validate checks that the task has a selection of photos and a correction
+func (t *ShiftDatesTask) Validate() error {
+	if len(t.Photos) == 0 && t.Event == "" && t.From == "" && t.To == "" {
+		return ErrNoSelection
+	}
+	for _, day := range []string{t.From, t.To} {
+		if day == "" {
+			continue
+		}
+		if _, err := time.Parse(dayFormat, day); err != nil {
+			return fmt.Errorf("Bad day '%s', expected format YYYY-MM-DD", day)
+		}
+	}
+	switch {
+	case t.Offset != "":
+		if _, err := time.ParseDuration(t.Offset); err != nil {
+			return fmt.Errorf("Bad offset '%s': %s", t.Offset, err)
+		}
+	case t.Reference == "" || t.ReferenceTime.IsZero():
+		return ErrNoCorrection
+	}
+	return nil
+}
+
+func (t *ShiftDatesTask) Execute(ctx context.Context, executor tasks.TaskExecutor, lib library.PhotoLibrary) error {
+	log, ctx := logging.SubFrom(ctx, "shiftDates")
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	offset, err := t.offset(ctx, lib)
+	if err != nil {
+		return err
+	}
+	photos, err := t.selection(ctx, lib)
+	if err != nil {
+		return err
+	}
+	log.Info("Shifting capture time", zap.Duration("offset", offset), zap.Int("nbPhotos", len(photos)))
+	if offset == 0 {
+		return nil
+	}
+	var failed int
+	events := make(map[boltstore.EventID]bool)
+	for _, id := range photos {
+		p, err := lib.Get(ctx, id)
+		if err != nil || p == nil {
+			log.Warn("Photo not found", zap.String("photo", string(id)), zap.Error(err))
+			failed++
+			continue
+		}
+		if _, err := lib.ChangeDateTaken(ctx, id, p.LocalTime().Add(offset)); err != nil {
+			log.Warn("Failed to shift capture time", zap.String("photo", string(id)), zap.Error(err))
+			failed++
+			continue
+		}
+		if e, found, err := t.events.EventOf(ctx, id); err != nil {
+			log.Warn("Failed to get event of photo", zap.String("photo", string(id)), zap.Error(err))
+		} else if found {
+			events[e.ID] = true
+		}
+	}
+	for id := range events {
+		if err := t.updateBounds(ctx, lib, id); err != nil {
+			log.Warn("Failed to update event", zap.String("event", string(id)), zap.Error(err))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("Failed to shift capture time of %d out of %d photos", failed, len(photos))
+	}
+	return nil
+}
+
+// updateBounds sets the start and end of the given event to the capture time
+// of its first and last photo
+func (t *ShiftDatesTask) updateBounds(ctx context.Context, lib library.PhotoLibrary, id boltstore.EventID) error {
+	event, found, err := t.events.Get(ctx, id)
+	if err != nil || !found {
+		return err
+	}
+	photos, err := t.events.PhotosOf(ctx, id)
+	if err != nil || len(photos) == 0 {
+		return err
+	}
+	first, err := lib.Get(ctx, photos[0].ID)
+	if err != nil {
+		return err
+	}
+	last, err := lib.Get(ctx, photos[len(photos)-1].ID)
+	if err != nil {
+		return err
+	}
+	if first == nil || last == nil {
+		return nil
+	}
+	from, to := first.LocalTime(), last.LocalTime()
+	if from.Equal(event.From) && to.Equal(event.To) {
+		return nil
+	}
+	event.From, event.To = from, to
+	return t.events.Add(ctx, *event)
+}
+
+func (t *ShiftDatesTask) offset(ctx context.Context, lib library.PhotoLibrary) (time.Duration, error) {
+	if t.Offset != "" {
+		return time.ParseDuration(t.Offset)
+	}
+	ref, err := lib.Get(ctx, t.Reference)
+	if err != nil {
+		return 0, err
+	}
+	if ref == nil {
+		return 0, library.NotFound(t.Reference)
+	}
+	return t.ReferenceTime.Sub(ref.DateTaken), nil
+}
+
+// selection returns the IDs of all photos selected by this task
+func (t *ShiftDatesTask) selection(ctx context.Context, lib library.PhotoLibrary) ([]library.PhotoID, error) {
+	selected := make(map[library.PhotoID]bool)
+	var ids []library.PhotoID
+	add := func(id library.PhotoID) {
+		if !selected[id] {
+			selected[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range t.Photos {
+		add(id)
+	}
+	if t.Event != "" {
+		for start, hasMore := 0, true; hasMore; start += eventPageSize {
+			var page []library.PhotoID
+			var err error
+			page, hasMore, err = t.events.FindPhotosPaged(ctx, t.Event, start, eventPageSize)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range page {
+				add(id)
+			}
+		}
+	}
+	if t.From != "" || t.To != "" {
+		photos, err := t.photosInRange(ctx, lib)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range photos {
+			add(p.ID)
+		}
+	}
+	return ids, nil
+}
+
+// photosInRange returns the photos taken between the From and To days, both
+// inclusive, in local time of where the photos were taken
+func (t *ShiftDatesTask) photosInRange(ctx context.Context, lib library.PhotoLibrary) ([]*library.Photo, error) {
+	from, to := time.Time{}, time.Now()
+	if t.From != "" {
+		from, _ = time.Parse(dayFormat, t.From)
+	}
+	if t.To != "" {
+		to, _ = time.Parse(dayFormat, t.To)
+	}
+	// Local days may be off by up to one day compared to UTC
+	candidates, err := lib.Find(ctx, from.AddDate(0, 0, -1), to.AddDate(0, 0, 2), consts.Ascending)
+	if err != nil {
+		return nil, err
+	}
+	var photos []*library.Photo
+	for _, p := range candidates {
+		day := p.LocalTime().Format(dayFormat)
+		if (t.From == "" || day >= t.From) && (t.To == "" || day <= t.To) {
+			photos = append(photos, p)
+		}
+	}
+	return photos, nil
+}
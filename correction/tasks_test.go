@@ -0,0 +1,105 @@
+package correction_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/correction"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+	"bitbucket.org/kleinnic74/photos/tasks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+// shiftLib keeps its photos in memory and moves them in the event index when
+// their capture time changes, like the date changed callback of the library
+type shiftLib struct {
+	library.PhotoLibrary
+	events *boltstore.EventIndex
+	photos map[library.PhotoID]*library.Photo
+}
+
+func (lib *shiftLib) Get(ctx context.Context, id library.PhotoID) (*library.Photo, error) {
+	if p, found := lib.photos[id]; found {
+		return p, nil
+	}
+	return nil, library.NotFound(id)
+}
+
+func (lib *shiftLib) ChangeDateTaken(ctx context.Context, id library.PhotoID, taken time.Time) (*library.Photo, error) {
+	before, err := lib.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	after := *before
+	after.DateTaken, after.LocalDateTaken = taken.UTC(), taken
+	after.SortID = sortIDOf(after.DateTaken, id)
+	lib.photos[id] = &after
+	return &after, lib.events.Move(ctx, before, &after)
+}
+
+func sortIDOf(taken time.Time, id library.PhotoID) library.OrderedID {
+	return library.OrderedID(taken.UTC().Format(time.RFC3339) + string(id))
+}
+
+func TestShiftDatesUpdatesEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "correction")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0644, nil)
+	require.NoError(t, err)
+	defer db.Close()
+	events, err := boltstore.NewEventIndex(db)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	day := time.Date(2019, 8, 1, 10, 0, 0, 0, time.UTC)
+	lib := &shiftLib{events: events, photos: make(map[library.PhotoID]*library.Photo)}
+	for _, e := range []struct {
+		id     boltstore.EventID
+		photos map[library.PhotoID]time.Duration
+	}{
+		{"e1", map[library.PhotoID]time.Duration{"1": 0, "2": 10 * time.Minute, "3": 20 * time.Minute}},
+		{"e2", map[library.PhotoID]time.Duration{"4": 4 * time.Hour}},
+	} {
+		event := boltstore.Event{ID: e.id}
+		var ids []library.ExtendedPhotoID
+		for id, offset := range e.photos {
+			taken := day.Add(offset)
+			p := &library.Photo{
+				ExtendedPhotoID: library.ExtendedPhotoID{ID: id, SortID: sortIDOf(taken, id)},
+				DateTaken:       taken,
+				LocalDateTaken:  taken,
+			}
+			lib.photos[id] = p
+			ids = append(ids, p.ExtendedPhotoID)
+			if event.From.IsZero() || taken.Before(event.From) {
+				event.From = taken
+			}
+			if taken.After(event.To) {
+				event.To = taken
+			}
+		}
+		require.NoError(t, events.AddPhotosToEvent(ctx, event, ids))
+	}
+
+	task := correction.NewShiftDatesTask(events)
+	task.Photos = []library.PhotoID{"1"}
+	task.Offset = "30m"
+	require.NoError(t, task.Execute(ctx, tasks.NewDummyTaskExecutor(), lib))
+
+	e1, _, err := events.Get(ctx, "e1")
+	require.NoError(t, err)
+	assert.True(t, e1.From.Equal(day.Add(10*time.Minute)), "Bad start %s", e1.From)
+	assert.True(t, e1.To.Equal(day.Add(30*time.Minute)), "Bad end %s", e1.To)
+	e2, _, err := events.Get(ctx, "e2")
+	require.NoError(t, err)
+	assert.True(t, e2.From.Equal(day.Add(4*time.Hour)), "Bad start %s", e2.From)
+	assert.True(t, e2.To.Equal(day.Add(4*time.Hour)), "Bad end %s", e2.To)
+}
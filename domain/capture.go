@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/domain/timezone"
+)
+
+// localCaptureTime places the wall clock time recorded by a camera into the
+// timezone the picture was taken in: the EXIF offset if present, else the
+// timezone at the GPS location. Without both, the time is returned unchanged
+func localCaptureTime(wall time.Time, offset string, coords *gps.Coordinates) time.Time {
+	if loc, ok := parseTimeOffset(offset); ok {
+		return inLocation(wall, loc)
+	}
+	if loc, ok := locationAt(coords); ok {
+		return inLocation(wall, loc)
+	}
+	return wall
+}
+
+// localInstant returns the given instant in the timezone at the given location
+func localInstant(t time.Time, coords *gps.Coordinates) time.Time {
+	if loc, ok := locationAt(coords); ok && !t.IsZero() {
+		return t.In(loc)
+	}
+	return t
+}
+
+func locationAt(coords *gps.Coordinates) (*time.Location, bool) {
+	if coords == nil {
+		return nil, false
+	}
+	loc, err := timezone.LocationAt(*coords)
+	return loc, err == nil
+}
+
+func inLocation(wall time.Time, loc *time.Location) time.Time {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(),
+		wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), loc)
+}
+
+// parseTimeOffset parses an EXIF timezone offset like +02:00
+func parseTimeOffset(offset string) (*time.Location, bool) {
+	t, err := time.Parse("-07:00", strings.TrimSpace(offset))
+	if err != nil {
+		return nil, false
+	}
+	_, seconds := t.Zone()
+	return time.FixedZone("", seconds), true
+}
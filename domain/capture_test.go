@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+)
+
+func TestLocalCaptureTime(t *testing.T) {
+	wall := time.Date(2019, 8, 1, 10, 30, 0, 0, time.UTC)
+	data := []struct {
+		offset   string
+		coords   *gps.Coordinates
+		expected string
+	}{
+		{"", nil, "2019-08-01T10:30:00Z"},
+		{"+05:30", nil, "2019-08-01T10:30:00+05:30"},
+		{"+05:30", gps.MustNewCoordinates(48.8584, 2.2945), "2019-08-01T10:30:00+05:30"},
+		{"", gps.MustNewCoordinates(48.8584, 2.2945), "2019-08-01T10:30:00+02:00"},
+		{"", gps.MustNewCoordinates(-33.8568, 151.2153), "2019-08-01T10:30:00+10:00"},
+		{"bad", nil, "2019-08-01T10:30:00Z"},
+	}
+	for _, d := range data {
+		actual := localCaptureTime(wall, d.offset, d.coords).Format(time.RFC3339)
+		if actual != d.expected {
+			t.Errorf("Bad capture time for offset '%s' at %v: got %s, expected %s", d.offset, d.coords, actual, d.expected)
+		}
+	}
+}
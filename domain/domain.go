@@ -92,6 +92,9 @@ type MediaMetaData struct {
 	DateTaken   time.Time
 	Location    *gps.Coordinates
 	Orientation Orientation
+	Video       *VideoInfo
+	Exif        *ExifInfo
+	Keywords    []string
 }
 
 // Photo represents one image in a media library
@@ -106,6 +109,9 @@ type Photo interface {
 	DateTaken() time.Time
 	Location() *gps.Coordinates
 	Orientation() Orientation
+	Video() *VideoInfo
+	Exif() *ExifInfo
+	Keywords() []string
 }
 
 type photoFile struct {
@@ -116,6 +122,9 @@ type photoFile struct {
 	format      FormatSpec
 	location    *gps.Coordinates
 	orientation Orientation
+	video       *VideoInfo
+	exif        *ExifInfo
+	keywords    []string
 }
 
 // NewPhoto creates a new Photo instance from the image file at the given path
@@ -145,6 +154,9 @@ func NewPhoto(path string) (Photo, error) {
 		dateTaken:   meta.DateTaken,
 		location:    meta.Location,
 		orientation: meta.Orientation,
+		video:       meta.Video,
+		exif:        meta.Exif,
+		keywords:    meta.Keywords,
 		format:      format,
 	}, nil
 }
@@ -199,6 +211,18 @@ func (p *photoFile) Orientation() Orientation {
 	return p.orientation
 }
 
+func (p *photoFile) Video() *VideoInfo {
+	return p.video
+}
+
+func (p *photoFile) Exif() *ExifInfo {
+	return p.exif
+}
+
+func (p *photoFile) Keywords() []string {
+	return p.keywords
+}
+
 func (p *photoFile) Image() (image.Image, error) {
 	in, err := p.Content()
 	if err != nil {
@@ -29,6 +29,30 @@ func TestNewPhoto(t *testing.T) {
 	}
 }
 
+func TestExifInfo(t *testing.T) {
+	p, err := photos.NewPhoto("testdata/Canon_40D.jpg")
+	if err != nil {
+		t.Fatalf("Failed to load image: %s", err)
+	}
+	info := p.Exif()
+	if info == nil {
+		t.Fatalf("No EXIF info found")
+	}
+	expected := photos.ExifInfo{
+		Make:         "Canon",
+		Model:        "Canon EOS 40D",
+		FocalLength:  135,
+		Aperture:     7.1,
+		ExposureTime: "1/160",
+		ISO:          100,
+		Flash:        true,
+	}
+	info.Altitude = nil
+	if *info != expected {
+		t.Errorf("Bad EXIF info: got %#v, expected %#v", *info, expected)
+	}
+}
+
 func assertExpected(t *testing.T, expected *PhotoData, actual photos.Photo) {
 	if expected.Format != actual.Format().ID() {
 		t.Errorf("%s: Bad value for Filename: got %s, expected %s", expected.Path, actual.Format().ID(), expected.Format)
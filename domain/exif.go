@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"bitbucket.org/kleinnic74/photos/domain/formats"
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// ExifInfo contains the camera settings and technical information stored
+// in the EXIF data of a picture
+type ExifInfo struct {
+	Make         string   `json:"make,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	Lens         string   `json:"lens,omitempty"`
+	FocalLength  float64  `json:"focalLength,omitempty"`
+	Aperture     float64  `json:"aperture,omitempty"`
+	ExposureTime string   `json:"exposureTime,omitempty"`
+	ISO          int      `json:"iso,omitempty"`
+	Flash        bool     `json:"flash,omitempty"`
+	Altitude     *float64 `json:"altitude,omitempty"`
+	TimeOffset   string   `json:"timeOffset,omitempty"`
+}
+
+func exifInfoOf(ex *exif.Exif) *ExifInfo {
+	info := ExifInfo{
+		Make:         stringTag(ex, exif.Make),
+		Model:        stringTag(ex, exif.Model),
+		Lens:         stringTag(ex, exif.LensModel),
+		FocalLength:  floatTag(ex, exif.FocalLength),
+		Aperture:     floatTag(ex, exif.FNumber),
+		ExposureTime: exposureTimeOf(ex),
+		TimeOffset:   stringTag(ex, formats.OffsetTimeOriginal),
+	}
+	if info.TimeOffset == "" {
+		info.TimeOffset = stringTag(ex, formats.OffsetTime)
+	}
+	if tag, err := ex.Get(exif.ISOSpeedRatings); err == nil {
+		if iso, err := tag.Int(0); err == nil {
+			info.ISO = iso
+		}
+	}
+	if tag, err := ex.Get(exif.Flash); err == nil {
+		if flash, err := tag.Int(0); err == nil {
+			// Bit 0 indicates whether the flash fired
+			info.Flash = flash&1 != 0
+		}
+	}
+	if rat, found := ratTag(ex, exif.GPSAltitude); found {
+		altitude, _ := rat.Float64()
+		if tag, err := ex.Get(exif.GPSAltitudeRef); err == nil && len(tag.Val) > 0 && tag.Val[0] == 1 {
+			// Reference 1 means below sea level
+			altitude = -altitude
+		}
+		info.Altitude = &altitude
+	}
+	if info == (ExifInfo{}) {
+		return nil
+	}
+	return &info
+}
+
+func stringTag(ex *exif.Exif, name exif.FieldName) string {
+	tag, err := ex.Get(name)
+	if err != nil {
+		return ""
+	}
+	value, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.Trim(value, "\x00"))
+}
+
+func ratTag(ex *exif.Exif, name exif.FieldName) (*big.Rat, bool) {
+	tag, err := ex.Get(name)
+	if err != nil {
+		return nil, false
+	}
+	rat, err := tag.Rat(0)
+	if err != nil {
+		return nil, false
+	}
+	return rat, true
+}
+
+func floatTag(ex *exif.Exif, name exif.FieldName) float64 {
+	rat, found := ratTag(ex, name)
+	if !found {
+		return 0
+	}
+	f, _ := rat.Float64()
+	return f
+}
+
+// exposureTimeOf returns the exposure time as usually shown by cameras,
+// either as fraction (1/250) for short exposures or in seconds
+func exposureTimeOf(ex *exif.Exif) string {
+	rat, found := ratTag(ex, exif.ExposureTime)
+	if !found || rat.Sign() <= 0 {
+		return ""
+	}
+	if rat.Cmp(big.NewRat(1, 1)) < 0 {
+		inverse := new(big.Rat).Inv(rat)
+		denom, _ := inverse.Float64()
+		return fmt.Sprintf("1/%.0f", denom)
+	}
+	seconds, _ := rat.Float64()
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.1f", seconds), "0"), ".")
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,8 +13,10 @@ import (
 
 	"github.com/h2non/filetype"
 	"github.com/rwcarlsen/goexif/exif"
+	xwebp "golang.org/x/image/webp"
 
 	"bitbucket.org/kleinnic74/photos/domain/formats"
+	"bitbucket.org/kleinnic74/photos/domain/formats/webp"
 	"bitbucket.org/kleinnic74/photos/domain/gps"
 )
 
@@ -116,12 +119,21 @@ var (
 		typeID:     Picture,
 		metaReader: func(io.Reader, *MediaMetaData) error { return nil },
 	}
+	// WEBP is the lossy WebP format of thumbs, it is not registered as a
+	// format of photos
+	WEBP Format = formatImpl{
+		typeID:  Picture,
+		id:      "webp",
+		mime:    "image/webp",
+		decoder: xwebp.Decode,
+		encoder: webpEncode,
+	}
 )
 
 func init() {
 	formatsById[""] = UnknownFormat
 	JPEG = RegisterFormat(Picture, "jpg", "image/jpeg", exifReader, jpeg.Decode, jpegEncode, jpeg.Decode)
-	MOV = RegisterFormat(Video, "mov", "video/quicktime", quicktimeReader, nil, nil, nil)
+	MOV = RegisterFormat(Video, "mov", "video/quicktime", quicktimeReader, nil, nil, quicktimeThumb)
 }
 
 func RegisterFormat(typeID MediaType, extension string, mime string,
@@ -153,6 +165,16 @@ func FormatForExt(ext string) (Format, bool) {
 	return f, found
 }
 
+// FormatForMime returns the format having the given MIME type
+func FormatForMime(mime string) (Format, bool) {
+	for _, f := range formatsById {
+		if f.Mime() == mime && mime != "" {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
 func MustFormatForExt(ext string) FormatSpec {
 	if ext == "" {
 		return FormatSpec("")
@@ -223,13 +245,17 @@ func (f formatImpl) Encode(img image.Image, out io.Writer) error {
 	return f.encoder(img, out)
 }
 
+// exifReader reads the meta-data of JPEG images, which is found in the
+// segments before the image data
 func exifReader(in io.Reader, meta *MediaMetaData) error {
-	ex, err := exif.Decode(in)
+	data, err := formats.ReadJPEGHeader(in)
 	if err != nil {
 		return err
 	}
-	if dateTaken, err := ex.DateTime(); err == nil {
-		meta.DateTaken = dateTaken
+	meta.Keywords = formats.ReadKeywords(data)
+	ex, err := exif.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
 	}
 	if lat, long, err := ex.LatLong(); err == nil {
 		if coords, err := gps.NewCoordinates(lat, long); err == nil {
@@ -241,6 +267,14 @@ func exifReader(in io.Reader, meta *MediaMetaData) error {
 			meta.Orientation = Orientation(orientation)
 		}
 	}
+	meta.Exif = exifInfoOf(ex)
+	if dateTaken, err := ex.DateTime(); err == nil {
+		var offset string
+		if meta.Exif != nil {
+			offset = meta.Exif.TimeOffset
+		}
+		meta.DateTaken = localCaptureTime(dateTaken, offset, meta.Location)
+	}
 	return nil
 }
 
@@ -249,11 +283,16 @@ func quicktimeReader(in io.Reader, meta *MediaMetaData) error {
 	if err != nil {
 		return err
 	}
-	meta.DateTaken = qt.DateTaken()
 	meta.Location = qt.Location()
+	meta.DateTaken = localInstant(qt.DateTaken(), meta.Location)
+	meta.Video = videoInfoOf(qt)
 	return nil
 }
 
 func jpegEncode(img image.Image, out io.Writer) error {
 	return jpeg.Encode(out, img, nil)
 }
+
+func webpEncode(img image.Image, out io.Writer) error {
+	return webp.EncodeLossy(out, img, webp.DefaultQuality)
+}
@@ -28,6 +28,10 @@ func TestFormatById(t *testing.T) {
 		if actual.ID() != i.expExt {
 			t.Errorf("Bad extension for %s: Expectetd %s, got %s", i.t, i.expExt, actual.ID())
 		}
+		byMime, found := domain.FormatForMime(i.expMime)
+		if assert.True(t, found, "Expected format for mime %s", i.expMime) {
+			assert.Equal(t, i.expExt, byMime.ID())
+		}
 	}
 }
 
@@ -43,6 +47,34 @@ func TestJpegFormat(t *testing.T) {
 	}
 }
 
+// countingReader counts the bytes read from the underlying reader
+type countingReader struct {
+	r     io.Reader
+	count int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+func TestJpegMetaDataReadsHeaderOnly(t *testing.T) {
+	path := "testdata/Canon_40D.jpg"
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mustOpenFile(t, path)
+	defer r.Close()
+	in := &countingReader{r: r}
+	var meta domain.MediaMetaData
+	assert.NoError(t, domain.JPEG.DecodeMetaData(in, &meta))
+	assert.False(t, meta.DateTaken.IsZero(), "Date must be read")
+	assert.NotNil(t, meta.Exif, "EXIF data must be read")
+	assert.True(t, in.count < info.Size(), "Image data must not be read: read %d of %d bytes", in.count, info.Size())
+}
+
 func TestUnmarshalJSON(t *testing.T) {
 	data := []struct {
 		ext            string
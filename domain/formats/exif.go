@@ -1,12 +1,56 @@
 package formats
 
 import (
+	"bytes"
 	"os"
 
 	"github.com/rwcarlsen/goexif/exif"
 	"github.com/rwcarlsen/goexif/tiff"
 )
 
+// EXIF 2.31 timezone offset fields, not known to goexif
+const (
+	OffsetTime          exif.FieldName = "OffsetTime"
+	OffsetTimeOriginal  exif.FieldName = "OffsetTimeOriginal"
+	OffsetTimeDigitized exif.FieldName = "OffsetTimeDigitized"
+)
+
+var offsetFields = map[uint16]exif.FieldName{
+	0x9010: OffsetTime,
+	0x9011: OffsetTimeOriginal,
+	0x9012: OffsetTimeDigitized,
+}
+
+func init() {
+	exif.RegisterParsers(&offsetTimeParser{})
+}
+
+// offsetTimeParser loads the timezone offset tags from the EXIF sub-IFD
+type offsetTimeParser struct{}
+
+func (p *offsetTimeParser) Parse(x *exif.Exif) error {
+	// Errors are ignored here, they would stop the decoding of all
+	// subsequent parsers and the offsets are optional anyway
+	tag, err := x.Get(exif.ExifIFDPointer)
+	if err != nil {
+		return nil
+	}
+	offset, err := tag.Int64(0)
+	if err != nil {
+		return nil
+	}
+	r := bytes.NewReader(x.Raw)
+	if _, err := r.Seek(offset, 0); err != nil {
+		return nil
+	}
+	dir, _, err := tiff.DecodeDir(r, x.Tiff.Order)
+	if err != nil {
+		return nil
+	}
+	x.LoadTags(dir, offsetFields, false)
+	return nil
+}
+
 type TagHandler func(name, value string)
 
 type exifWalker struct {
@@ -0,0 +1,256 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	jpegSOI  = 0xd8
+	jpegEOI  = 0xd9
+	jpegSOS  = 0xda
+	jpegAPP1 = 0xe1
+	// jpegAPP13 holds the Photoshop image resources, amongst which IPTC data
+	jpegAPP13 = 0xed
+
+	// iptcResourceID is the ID of the Photoshop image resource holding the
+	// IPTC-IIM records
+	iptcResourceID = 0x0404
+	iptcTagMarker  = 0x1c
+	// iptcCodedCharacterSet is the dataset of the envelope record declaring
+	// the character set of the other datasets, ISO-8859-1 if not UTF-8
+	iptcEnvelopeRecord    = 1
+	iptcCodedCharacterSet = 90
+	// iptcKeywords is the dataset of the application record holding one
+	// keyword, keywords are repeated datasets
+	iptcApplicationRecord = 2
+	iptcKeywords          = 25
+
+	xmpNamespaceDC = "http://purl.org/dc/elements/1.1/"
+)
+
+var (
+	// iptcUTF8 is the ISO 2022 escape sequence designating UTF-8
+	iptcUTF8           = []byte("\x1b%G")
+	xmpSignature       = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	photoshopSignature = []byte("Photoshop 3.0\x00")
+	resourceSignature  = []byte("8BIM")
+)
+
+// ReadKeywords returns the keywords stored in the IPTC (Photoshop APP13) and
+// XMP (dc:subject) segments of the given JPEG data, in the order they are
+// found and without duplicates
+func ReadKeywords(data []byte) []string {
+	var keywords []string
+	seen := make(map[string]bool)
+	add := func(words []string) {
+		for _, w := range words {
+			w = strings.TrimSpace(w)
+			if w != "" && !seen[w] {
+				seen[w] = true
+				keywords = append(keywords, w)
+			}
+		}
+	}
+	forEachJPEGSegment(data, func(marker byte, segment []byte) {
+		switch {
+		case marker == jpegAPP13 && bytes.HasPrefix(segment, photoshopSignature):
+			add(iptcKeywordsOf(segment[len(photoshopSignature):]))
+		case marker == jpegAPP1 && bytes.HasPrefix(segment, xmpSignature):
+			add(xmpKeywordsOf(segment[len(xmpSignature):]))
+		}
+	})
+	return keywords
+}
+
+// ReadJPEGHeader reads the marker segments of the JPEG data from in up to the
+// image data, which holds no meta-data, and returns the data read. Data not
+// starting like a JPEG image is read completely. A truncated header is
+// returned as it is.
+func ReadJPEGHeader(in io.Reader) ([]byte, error) {
+	var header bytes.Buffer
+	r := io.TeeReader(in, &header)
+	b := make([]byte, 2)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return header.Bytes(), endOfHeader(err)
+	}
+	if b[0] != 0xff || b[1] != jpegSOI {
+		_, err := io.Copy(ioutil.Discard, r)
+		return header.Bytes(), err
+	}
+	for {
+		if _, err := io.ReadFull(r, b[:1]); err != nil || b[0] != 0xff {
+			return header.Bytes(), endOfHeader(err)
+		}
+		marker := b[0]
+		for marker == 0xff {
+			// Fill bytes
+			if _, err := io.ReadFull(r, b[:1]); err != nil {
+				return header.Bytes(), endOfHeader(err)
+			}
+			marker = b[0]
+		}
+		if marker == jpegSOS || marker == jpegEOI {
+			return header.Bytes(), nil
+		}
+		if _, err := io.ReadFull(r, b); err != nil {
+			return header.Bytes(), endOfHeader(err)
+		}
+		length := int64(binary.BigEndian.Uint16(b))
+		if length < 2 {
+			return header.Bytes(), nil
+		}
+		if _, err := io.CopyN(ioutil.Discard, r, length-2); err != nil {
+			return header.Bytes(), endOfHeader(err)
+		}
+	}
+}
+
+// endOfHeader returns the given read error unless it is caused by the end
+// of the data
+func endOfHeader(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil
+	}
+	return err
+}
+
+// forEachJPEGSegment calls f with the content of each marker segment before
+// the image data
+func forEachJPEGSegment(data []byte, f func(marker byte, segment []byte)) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != jpegSOI {
+		return
+	}
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xff {
+			return
+		}
+		marker := data[pos+1]
+		if marker == 0xff {
+			// Fill byte
+			pos++
+			continue
+		}
+		if marker == jpegSOS || marker == jpegEOI {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return
+		}
+		f(marker, data[pos+4:pos+2+length])
+		pos += 2 + length
+	}
+}
+
+// iptcKeywordsOf returns the keywords of the IPTC resource found in the given
+// Photoshop image resources
+func iptcKeywordsOf(resources []byte) []string {
+	var keywords []string
+	for pos := 0; pos+12 <= len(resources); {
+		if !bytes.Equal(resources[pos:pos+4], resourceSignature) {
+			return keywords
+		}
+		id := binary.BigEndian.Uint16(resources[pos+4:])
+		// The name is a Pascal string padded to an even length
+		nameLength := int(resources[pos+6])
+		pos += 6 + (nameLength+2)&^1
+		if pos+4 > len(resources) {
+			return keywords
+		}
+		size := int(binary.BigEndian.Uint32(resources[pos:]))
+		pos += 4
+		if size < 0 || pos+size > len(resources) {
+			return keywords
+		}
+		if id == iptcResourceID {
+			keywords = append(keywords, iptcRecordKeywords(resources[pos:pos+size])...)
+		}
+		pos += (size + 1) &^ 1
+	}
+	return keywords
+}
+
+// iptcRecordKeywords returns the keywords of the given IPTC records, decoded
+// as UTF-8 if the envelope record declares it and as ISO-8859-1 otherwise
+func iptcRecordKeywords(records []byte) []string {
+	var keywords [][]byte
+	utf8 := false
+	for pos := 0; pos+5 <= len(records) && records[pos] == iptcTagMarker; {
+		record, dataset := records[pos+1], records[pos+2]
+		size := int(binary.BigEndian.Uint16(records[pos+3:]))
+		pos += 5
+		if size&0x8000 != 0 || pos+size > len(records) {
+			// Extended datasets are not used for keywords
+			break
+		}
+		value := records[pos : pos+size]
+		switch {
+		case record == iptcEnvelopeRecord && dataset == iptcCodedCharacterSet:
+			utf8 = bytes.Equal(value, iptcUTF8)
+		case record == iptcApplicationRecord && dataset == iptcKeywords:
+			keywords = append(keywords, value)
+		}
+		pos += size
+	}
+	decoded := make([]string, len(keywords))
+	for i, k := range keywords {
+		if utf8 {
+			decoded[i] = string(k)
+		} else {
+			decoded[i] = latin1(k)
+		}
+	}
+	return decoded
+}
+
+// latin1 decodes the given ISO-8859-1 text, whose code points are those of
+// Unicode
+func latin1(text []byte) string {
+	runes := make([]rune, len(text))
+	for i, b := range text {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// xmpKeywordsOf returns the items of the dc:subject bag of the given XMP
+// packet
+func xmpKeywordsOf(packet []byte) []string {
+	var keywords []string
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	depth, subjectDepth := 0, 0
+	var item *strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return keywords
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if subjectDepth == 0 && t.Name.Space == xmpNamespaceDC && t.Name.Local == "subject" {
+				subjectDepth = depth
+			} else if subjectDepth > 0 && t.Name.Local == "li" {
+				item = &strings.Builder{}
+			}
+		case xml.CharData:
+			if item != nil {
+				item.Write(t)
+			}
+		case xml.EndElement:
+			if item != nil && t.Name.Local == "li" {
+				keywords = append(keywords, item.String())
+				item = nil
+			}
+			if depth == subjectDepth {
+				subjectDepth = 0
+			}
+			depth--
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func jpegSegment(marker byte, content ...[]byte) []byte {
+	var buf bytes.Buffer
+	size := 2
+	for _, c := range content {
+		size += len(c)
+	}
+	buf.Write([]byte{0xff, marker})
+	binary.Write(&buf, binary.BigEndian, uint16(size))
+	for _, c := range content {
+		buf.Write(c)
+	}
+	return buf.Bytes()
+}
+
+func iptcDataset(record, dataset byte, value string) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{iptcTagMarker, record, dataset})
+	binary.Write(&buf, binary.BigEndian, uint16(len(value)))
+	buf.WriteString(value)
+	return buf.Bytes()
+}
+
+func imageResource(id uint16, content []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(resourceSignature)
+	binary.Write(&buf, binary.BigEndian, id)
+	// Empty name, padded to an even length
+	buf.Write([]byte{0, 0})
+	binary.Write(&buf, binary.BigEndian, uint32(len(content)))
+	buf.Write(content)
+	if len(content)%2 != 0 {
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
+
+func jpegWith(segments ...[]byte) []byte {
+	data := []byte{0xff, jpegSOI}
+	for _, s := range segments {
+		data = append(data, s...)
+	}
+	return append(data, 0xff, jpegSOS, 0, 2, 0xff, jpegEOI)
+}
+
+const xmpPacket = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/">
+   <dc:title><rdf:Alt><rdf:li xml:lang="x-default">Not a keyword</rdf:li></rdf:Alt></dc:title>
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>Beach</rdf:li>
+     <rdf:li>Sunset &amp; Sea</rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>`
+
+func TestReadKeywords(t *testing.T) {
+	iptc := bytes.Join([][]byte{
+		iptcDataset(1, 90, "\x1b%G"),
+		iptcDataset(iptcApplicationRecord, 5, "Title"),
+		iptcDataset(iptcApplicationRecord, iptcKeywords, "Holidays"),
+		iptcDataset(iptcApplicationRecord, iptcKeywords, "Beach"),
+	}, nil)
+	resources := bytes.Join([][]byte{
+		imageResource(0x03ed, []byte{1, 2, 3}),
+		imageResource(iptcResourceID, iptc),
+	}, nil)
+	data := jpegWith(
+		jpegSegment(0xe0, []byte("JFIF\x00")),
+		jpegSegment(jpegAPP1, xmpSignature, []byte(xmpPacket)),
+		jpegSegment(jpegAPP13, photoshopSignature, resources),
+	)
+	expected := []string{"Beach", "Sunset & Sea", "Holidays"}
+	if keywords := ReadKeywords(data); !reflect.DeepEqual(keywords, expected) {
+		t.Errorf("Bad keywords: got %q, expected %q", keywords, expected)
+	}
+}
+
+func TestReadKeywordsWithoutMetaData(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"not jpeg":  []byte("GIF89a"),
+		"no meta":   jpegWith(jpegSegment(0xe0, []byte("JFIF\x00"))),
+		"truncated": jpegWith(jpegSegment(jpegAPP13, photoshopSignature, resourceSignature))[:20],
+	} {
+		if keywords := ReadKeywords(data); len(keywords) != 0 {
+			t.Errorf("%s: expected no keywords, got %q", name, keywords)
+		}
+	}
+}
+
+func TestReadKeywordsCharacterSet(t *testing.T) {
+	keywordsIn := func(datasets ...[]byte) []string {
+		return ReadKeywords(jpegWith(jpegSegment(jpegAPP13, photoshopSignature,
+			imageResource(iptcResourceID, bytes.Join(datasets, nil)))))
+	}
+	// Without declared character set, keywords are ISO-8859-1
+	keywords := keywordsIn(iptcDataset(iptcApplicationRecord, iptcKeywords, "Caf\xe9"))
+	if expected := []string{"Café"}; !reflect.DeepEqual(keywords, expected) {
+		t.Errorf("Bad ISO-8859-1 keywords: got %q, expected %q", keywords, expected)
+	}
+	keywords = keywordsIn(
+		iptcDataset(iptcEnvelopeRecord, iptcCodedCharacterSet, "\x1b%G"),
+		iptcDataset(iptcApplicationRecord, iptcKeywords, "Café"),
+	)
+	if expected := []string{"Café"}; !reflect.DeepEqual(keywords, expected) {
+		t.Errorf("Bad UTF-8 keywords: got %q, expected %q", keywords, expected)
+	}
+}
+
+// failingReader fails all reads, it stands for image data which must not be
+// read
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("image data must not be read")
+}
+
+func TestReadJPEGHeader(t *testing.T) {
+	header := []byte{0xff, jpegSOI}
+	header = append(header, jpegSegment(0xe0, []byte("JFIF\x00"))...)
+	header = append(header, 0xff, 0xff)
+	header = append(header, jpegSegment(jpegAPP13, photoshopSignature)...)
+	header = append(header, 0xff, jpegSOS)
+
+	data, err := ReadJPEGHeader(io.MultiReader(bytes.NewReader(header), failingReader{}))
+	if err != nil {
+		t.Fatalf("Failed to read header: %s", err)
+	}
+	if !bytes.Equal(header, data) {
+		t.Errorf("Bad header: got %x, expected %x", data, header)
+	}
+
+	for name, expected := range map[string][]byte{
+		"not jpeg":  []byte("GIF89a and more"),
+		"truncated": header[:12],
+		"empty":     nil,
+	} {
+		data, err := ReadJPEGHeader(bytes.NewReader(expected))
+		if err != nil {
+			t.Errorf("%s: failed to read header: %s", name, err)
+		}
+		if !bytes.Equal(expected, data) {
+			t.Errorf("%s: bad header: got %x, expected %x", name, data, expected)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package formats
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"regexp"
 	"strconv"
 	"time"
@@ -12,9 +14,25 @@ import (
 )
 
 const (
-	fType     = "ftyp"
-	movieData = "moov"
-	meta      = "meta"
+	fType       = "ftyp"
+	movieData   = "moov"
+	meta        = "meta"
+	movieHeader = "mvhd"
+	track       = "trak"
+	trackHeader = "tkhd"
+	media       = "mdia"
+	handler     = "hdlr"
+	sampleDesc  = "stsd"
+	coverArt    = "covr"
+
+	videoHandler = "vide"
+)
+
+// Well-known data types of QuickTime metadata values
+const (
+	dataTypeUTF8 = 1
+	dataTypeJPEG = 13
+	dataTypePNG  = 14
 )
 
 var (
@@ -24,12 +42,18 @@ var (
 		"com.apple.quicktime.creationdate":     setCreationDate,
 		"com.apple.quicktime.location.ISO6709": setLocation,
 	}
+	binaryDataParsers = map[string]BinaryDataParser{
+		"com.apple.quicktime.artwork": setCoverArt,
+		coverArt:                      setCoverArt,
+	}
 )
 
 type AtomParser func(*Quicktime, io.Reader, AtomContainer) error
 
 type MetaDataParser func(*Quicktime, string, string) error
 
+type BinaryDataParser func(*Quicktime, uint32, []byte) error
+
 type AtomContainer interface {
 	Add(a *Atom)
 }
@@ -56,11 +80,25 @@ func (a *Atom) TypeName() string {
 	return a.typ
 }
 
+type trackInfo struct {
+	handler string
+	codec   string
+	width   int
+	height  int
+}
+
 type Quicktime struct {
 	keys         map[uint32]string
 	Atoms        []*Atom
 	creationDate time.Time
 	coords       *gps.Coordinates
+	duration     time.Duration
+
+	tracks       []*trackInfo
+	currentTrack *trackInfo
+
+	coverArt     []byte
+	coverArtType uint32
 }
 
 func (parent *Atom) Walk(f AtomWalker, level int) {
@@ -105,6 +143,36 @@ func (qt *Quicktime) Location() *gps.Coordinates {
 	return qt.coords
 }
 
+// Duration returns the duration of the movie as declared in the movie header
+func (qt *Quicktime) Duration() time.Duration {
+	return qt.duration
+}
+
+// VideoTrack returns the codec and the resolution of the first video track
+// of the movie, found is false if the movie contains no video track
+func (qt *Quicktime) VideoTrack() (codec string, width, height int, found bool) {
+	for _, t := range qt.tracks {
+		if t.handler == videoHandler {
+			return t.codec, t.width, t.height, true
+		}
+	}
+	return "", 0, 0, false
+}
+
+// CoverArt returns the raw bytes of the embedded cover art image and its
+// mime type, or nil if the movie has no cover art
+func (qt *Quicktime) CoverArt() ([]byte, string) {
+	if qt.coverArt == nil {
+		return nil, ""
+	}
+	switch qt.coverArtType {
+	case dataTypePNG:
+		return qt.coverArt, "image/png"
+	default:
+		return qt.coverArt, "image/jpeg"
+	}
+}
+
 func (qt *Quicktime) defineKey(i uint32, name string) {
 	qt.keys[i] = name
 }
@@ -130,6 +198,10 @@ func parseAtoms(qt *Quicktime, in io.Reader, parent AtomContainer) error {
 					return err
 				}
 			}
+			// Parsers may not consume the whole atom
+			if _, err := io.Copy(ioutil.Discard, content); err != nil {
+				return err
+			}
 		} else {
 			if err := skip(content, a.SizeOfData()); err != nil {
 				return err
@@ -140,20 +212,13 @@ func parseAtoms(qt *Quicktime, in io.Reader, parent AtomContainer) error {
 }
 
 func skip(in io.Reader, nb int64) error {
-	var total int64
 	switch r := in.(type) {
 	case io.Seeker:
 		_, err := r.Seek(nb, io.SeekCurrent)
 		return err
 	default:
-		buf := make([]byte, 4096)
-		for count, err := in.Read(buf); total < nb; count, err = in.Read(buf) {
-			total += int64(count)
-			if total != nb && err != nil {
-				return err
-			}
-		}
-		return nil
+		_, err := io.CopyN(ioutil.Discard, in, nb)
+		return err
 	}
 }
 
@@ -185,9 +250,15 @@ func parseKeys(qt *Quicktime, in io.Reader, parent AtomContainer) error {
 
 func parseItemList(qt *Quicktime, in io.Reader, parent AtomContainer) error {
 	for a, err := nextAtom(in); err != io.EOF; a, err = nextAtom(in) {
+		if err != nil {
+			return err
+		}
 		content := io.LimitReader(in, a.SizeOfData())
 		index := binary.BigEndian.Uint32([]byte(a.typ))
 		key, found := qt.keys[index]
+		if !found && a.typ == coverArt {
+			key, found = coverArt, true
+		}
 		if found {
 			if err := parseKeyValue(qt, key, content); err != nil {
 				return err
@@ -201,6 +272,9 @@ func parseItemList(qt *Quicktime, in io.Reader, parent AtomContainer) error {
 
 func parseKeyValue(qt *Quicktime, key string, in io.Reader) error {
 	for a, err := nextAtom(in); err != io.EOF; a, err = nextAtom(in) {
+		if err != nil {
+			return err
+		}
 		switch a.TypeName() {
 		case "data":
 			var dataSpec struct {
@@ -212,12 +286,25 @@ func parseKeyValue(qt *Quicktime, key string, in io.Reader) error {
 			}
 			payloadSize := a.SizeOfData() - 8
 			switch dataSpec.Typ {
-			case 1:
+			case dataTypeUTF8:
 				var buf []byte = make([]byte, payloadSize)
 				if _, err := in.Read(buf); err != nil {
 					return err
 				}
 				qt.setMetaDataAsString(key, string(buf))
+			case dataTypeJPEG, dataTypePNG:
+				parser, found := binaryDataParsers[key]
+				if !found {
+					skip(in, payloadSize)
+					break
+				}
+				var buf []byte = make([]byte, payloadSize)
+				if _, err := io.ReadFull(in, buf); err != nil {
+					return err
+				}
+				if err := parser(qt, dataSpec.Typ, buf); err != nil {
+					return err
+				}
 			default:
 				skip(in, payloadSize)
 			}
@@ -286,11 +373,155 @@ func setLocation(qt *Quicktime, key, value string) error {
 	return nil
 }
 
+func setCoverArt(qt *Quicktime, typ uint32, data []byte) error {
+	if qt.coverArt == nil {
+		qt.coverArt = data
+		qt.coverArtType = typ
+	}
+	return nil
+}
+
+// parseMeta handles both the QuickTime flavor of the meta atom and the ISO
+// flavor which has 4 bytes of version and flags before its children
+func parseMeta(qt *Quicktime, in io.Reader, parent AtomContainer) error {
+	var header [8]byte
+	if _, err := io.ReadFull(in, header[:]); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	if string(header[4:8]) == handler {
+		return parseAtoms(qt, io.MultiReader(bytes.NewReader(header[:]), in), parent)
+	}
+	return parseAtoms(qt, io.MultiReader(bytes.NewReader(header[4:]), in), parent)
+}
+
+func parseMovieHeader(qt *Quicktime, in io.Reader, parent AtomContainer) error {
+	var version [4]byte
+	if _, err := io.ReadFull(in, version[:]); err != nil {
+		return err
+	}
+	var timescale uint32
+	var duration uint64
+	switch version[0] {
+	case 1:
+		var header struct {
+			Created   uint64
+			Modified  uint64
+			Timescale uint32
+			Duration  uint64
+		}
+		if err := binary.Read(in, binary.BigEndian, &header); err != nil {
+			return err
+		}
+		timescale, duration = header.Timescale, header.Duration
+	default:
+		var header struct {
+			Created   uint32
+			Modified  uint32
+			Timescale uint32
+			Duration  uint32
+		}
+		if err := binary.Read(in, binary.BigEndian, &header); err != nil {
+			return err
+		}
+		timescale, duration = header.Timescale, uint64(header.Duration)
+	}
+	if timescale > 0 {
+		qt.duration = time.Duration(duration) * time.Second / time.Duration(timescale)
+	}
+	return nil
+}
+
+func parseTrack(qt *Quicktime, in io.Reader, parent AtomContainer) error {
+	qt.currentTrack = &trackInfo{}
+	defer func() {
+		qt.tracks = append(qt.tracks, qt.currentTrack)
+		qt.currentTrack = nil
+	}()
+	return parseAtoms(qt, in, parent)
+}
+
+func parseTrackHeader(qt *Quicktime, in io.Reader, parent AtomContainer) error {
+	if qt.currentTrack == nil {
+		return nil
+	}
+	var version [4]byte
+	if _, err := io.ReadFull(in, version[:]); err != nil {
+		return err
+	}
+	// Skip times, track ID and duration
+	var timesSize int64 = 20
+	if version[0] == 1 {
+		timesSize = 32
+	}
+	// Skip reserved, layer, alternate group, volume and matrix
+	if err := skip(in, timesSize+52); err != nil {
+		return err
+	}
+	var dimensions struct {
+		Width  uint32
+		Height uint32
+	}
+	if err := binary.Read(in, binary.BigEndian, &dimensions); err != nil {
+		return err
+	}
+	// Dimensions are 16.16 fixed-point numbers
+	qt.currentTrack.width = int(dimensions.Width >> 16)
+	qt.currentTrack.height = int(dimensions.Height >> 16)
+	return nil
+}
+
+func parseHandler(qt *Quicktime, in io.Reader, parent AtomContainer) error {
+	if qt.currentTrack == nil || qt.currentTrack.handler != "" {
+		return nil
+	}
+	var header struct {
+		VersionFlags uint32
+		Type         [4]byte
+		SubType      [4]byte
+	}
+	if err := binary.Read(in, binary.BigEndian, &header); err != nil {
+		return err
+	}
+	qt.currentTrack.handler = string(header.SubType[:])
+	return nil
+}
+
+func parseSampleDescription(qt *Quicktime, in io.Reader, parent AtomContainer) error {
+	if qt.currentTrack == nil || qt.currentTrack.codec != "" {
+		return nil
+	}
+	var header struct {
+		VersionFlags uint32
+		NbEntries    uint32
+		Size         uint32
+		Format       [4]byte
+	}
+	if err := binary.Read(in, binary.BigEndian, &header); err != nil {
+		return err
+	}
+	if header.NbEntries > 0 {
+		qt.currentTrack.codec = string(header.Format[:])
+	}
+	return nil
+}
+
 func init() {
 	atoms = map[string]AtomParser{
-		movieData: parseAtoms,
-		meta:      parseAtoms,
-		"keys":    parseKeys,
-		"ilst":    parseItemList,
+		movieData:   parseAtoms,
+		meta:        parseMeta,
+		"udta":      parseAtoms,
+		"keys":      parseKeys,
+		"ilst":      parseItemList,
+		movieHeader: parseMovieHeader,
+		track:       parseTrack,
+		trackHeader: parseTrackHeader,
+		media:       parseAtoms,
+		handler:     parseHandler,
+		"minf":      parseAtoms,
+		"stbl":      parseAtoms,
+		sampleDesc:  parseSampleDescription,
 	}
 }
@@ -1,6 +1,8 @@
 package formats
 
 import (
+	"bytes"
+	"encoding/binary"
 	"testing"
 	"time"
 )
@@ -31,3 +33,56 @@ func TestCreationDateDecoding(t *testing.T) {
 	}
 
 }
+
+func atom(typ string, content ...[]byte) []byte {
+	var buf bytes.Buffer
+	size := 8
+	for _, c := range content {
+		size += len(c)
+	}
+	binary.Write(&buf, binary.BigEndian, uint32(size))
+	buf.WriteString(typ)
+	for _, c := range content {
+		buf.Write(c)
+	}
+	return buf.Bytes()
+}
+
+func be(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+	return buf.Bytes()
+}
+
+func TestVideoInfoDecoding(t *testing.T) {
+	mvhd := atom("mvhd", be(uint32(0), uint32(0), uint32(0), uint32(600), uint32(6300)), make([]byte, 80))
+	tkhd := atom("tkhd", be(uint32(0)), make([]byte, 72), be(uint32(1920<<16), uint32(1080<<16)))
+	hdlr := atom("hdlr", be(uint32(0)), []byte("mhlrvide"), make([]byte, 12))
+	stsd := atom("stsd", be(uint32(0), uint32(1), uint32(16)), []byte("avc1"), make([]byte, 8))
+	trak := atom("trak", tkhd, atom("mdia", hdlr, atom("minf", atom("stbl", stsd))))
+	cover := []byte{0xFF, 0xD8, 0xFF, 0xD9}
+	ilst := atom("ilst", atom("covr", atom("data", be(uint32(13), uint32(0)), cover)))
+	meta := atom("meta", be(uint32(0)), atom("hdlr", be(uint32(0), uint32(0)), []byte("mdirappl"), make([]byte, 9)), ilst)
+	movie := atom("moov", mvhd, trak, atom("udta", meta))
+
+	qt, err := ReadAsQuicktime(bytes.NewReader(movie))
+	if err != nil {
+		t.Fatalf("Failed to parse movie: %s", err)
+	}
+	if qt.Duration() != 10500*time.Millisecond {
+		t.Errorf("Bad duration, expected %s, got %s", 10500*time.Millisecond, qt.Duration())
+	}
+	codec, width, height, found := qt.VideoTrack()
+	if !found {
+		t.Fatalf("Expected a video track")
+	}
+	if codec != "avc1" || width != 1920 || height != 1080 {
+		t.Errorf("Bad video track, expected avc1 1920x1080, got %s %dx%d", codec, width, height)
+	}
+	art, mime := qt.CoverArt()
+	if !bytes.Equal(cover, art) || mime != "image/jpeg" {
+		t.Errorf("Bad cover art, expected %v (image/jpeg), got %v (%s)", cover, art, mime)
+	}
+}
@@ -0,0 +1,36 @@
+// Package webp encodes images in the lossy (VP8) WebP format, see
+// https://developers.google.com/speed/webp/docs/riff_container and RFC 6386.
+package webp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// maxDimension is the largest width or height of an image
+const maxDimension = 1 << 14
+
+// ErrTooLarge is returned when encoding an image wider or higher than 16384
+var ErrTooLarge = errors.New("webp: image is too large")
+
+// writeChunk writes a WebP file made of a single chunk
+func writeChunk(w io.Writer, fourCC string, data []byte) error {
+	padding := len(data) & 1
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+len(data)+padding))
+	copy(header[8:], "WEBP"+fourCC)
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if padding > 0 {
+		_, err := w.Write([]byte{0})
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,830 @@
+package webp
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+	"math"
+)
+
+// Lossy images are written as a single VP8 key frame, see RFC 6386. Every
+// macroblock is predicted as a whole from its reconstructed neighbours and
+// its residuals are coded with the token probabilities best fitting the
+// image. Loop filtering is not used.
+
+const (
+	// DefaultQuality is the quality of lossy images when none is given, it
+	// gives images of about the size of JPEG images of default quality
+	DefaultQuality = 75
+
+	uniformProb = 128
+
+	// The intra prediction modes of macroblocks, see section 12.2
+	predDC = 0
+	predTM = 1
+	predVE = 2
+	predHE = 3
+
+	// maxLevel is the largest quantized coefficient which can be coded
+	maxLevel = 2048 + 66
+
+	// Indexes of the blocks of a macroblock
+	firstU  = 16
+	firstV  = 20
+	blockY2 = 24
+	nBlocks = 25
+
+	nTokenProbs = nPlane * nBand * nContext * nProb
+)
+
+// boolEncoder is the boolean entropy encoder of section 7.3
+type boolEncoder struct {
+	buf      []byte
+	rng      uint32
+	bottom   uint32
+	bitCount int
+}
+
+func newBoolEncoder() *boolEncoder {
+	return &boolEncoder{rng: 255, bitCount: 24}
+}
+
+// putBit writes a bit which is false with probability prob/256
+func (e *boolEncoder) putBit(bit bool, prob uint8) {
+	split := 1 + ((e.rng-1)*uint32(prob))>>8
+	if bit {
+		e.bottom += split
+		e.rng -= split
+	} else {
+		e.rng = split
+	}
+	for e.rng < 128 {
+		e.rng <<= 1
+		if e.bottom&(1<<31) != 0 {
+			e.carry()
+		}
+		e.bottom <<= 1
+		e.bitCount--
+		if e.bitCount == 0 {
+			e.buf = append(e.buf, byte(e.bottom>>24))
+			e.bottom &= 1<<24 - 1
+			e.bitCount = 8
+		}
+	}
+}
+
+func (e *boolEncoder) carry() {
+	i := len(e.buf) - 1
+	for ; i >= 0 && e.buf[i] == 0xff; i-- {
+		e.buf[i] = 0
+	}
+	e.buf[i]++
+}
+
+// putLiteral writes the n bits of v with even probabilities, most
+// significant bit first
+func (e *boolEncoder) putLiteral(v uint32, n uint) {
+	for n > 0 {
+		n--
+		e.putBit(v&(1<<n) != 0, uniformProb)
+	}
+}
+
+// putOptionalInt writes a flag telling whether v is set followed by its
+// magnitude and sign
+func (e *boolEncoder) putOptionalInt(v int, n uint) {
+	e.putBit(v != 0, uniformProb)
+	if v == 0 {
+		return
+	}
+	if v < 0 {
+		e.putLiteral(uint32(-v), n)
+		e.putBit(true, uniformProb)
+	} else {
+		e.putLiteral(uint32(v), n)
+		e.putBit(false, uniformProb)
+	}
+}
+
+func (e *boolEncoder) bytes() []byte {
+	for i := 0; i < 32; i++ {
+		e.putBit(false, uniformProb)
+	}
+	return e.buf
+}
+
+// tokenWriter receives the bits of the coefficient tokens, either to write
+// them or to count them
+type tokenWriter interface {
+	// putBit writes a bit with a fixed probability
+	putBit(bit bool, prob uint8)
+	// putToken writes a bit with the token probability in the given slot
+	putToken(bit bool, slot int)
+}
+
+type tokenEncoder struct {
+	*boolEncoder
+	probs *[nTokenProbs]uint8
+}
+
+func (t tokenEncoder) putToken(bit bool, slot int) {
+	t.putBit(bit, t.probs[slot])
+}
+
+// tokenStats counts the bits coded with each token probability
+type tokenStats [nTokenProbs][2]uint32
+
+func (s *tokenStats) putBit(bit bool, prob uint8) {}
+
+func (s *tokenStats) putToken(bit bool, slot int) {
+	if bit {
+		s[slot][1]++
+	} else {
+		s[slot][0]++
+	}
+}
+
+// tokenSlot returns the first token probability of the given plane, band
+// and context
+func tokenSlot(plane, band, context int) int {
+	return ((plane*nBand+band)*nContext + context) * nProb
+}
+
+// putResiduals writes the tokens of the quantized coefficients of a block,
+// in zigzag order, and returns 1 if any of them is not zero
+func putResiduals(w tokenWriter, plane, context int, levels *[16]int16, first int) int {
+	last := -1
+	for n := 15; n >= first; n-- {
+		if levels[n] != 0 {
+			last = n
+			break
+		}
+	}
+	slot := tokenSlot(plane, int(bands[first]), context)
+	w.putToken(last >= 0, slot)
+	if last < 0 {
+		return 0
+	}
+	for n := first; n <= last; n++ {
+		v := int(levels[n])
+		if v < 0 {
+			v = -v
+		}
+		if v == 0 {
+			w.putToken(false, slot+1)
+			slot = tokenSlot(plane, int(bands[n+1]), 0)
+			continue
+		}
+		w.putToken(true, slot+1)
+		context = 2
+		switch {
+		case v == 1:
+			w.putToken(false, slot+2)
+			context = 1
+		case v <= 4:
+			w.putToken(true, slot+2)
+			w.putToken(false, slot+3)
+			w.putToken(v > 2, slot+4)
+			if v > 2 {
+				w.putToken(v == 4, slot+5)
+			}
+		case v <= 10:
+			w.putToken(true, slot+2)
+			w.putToken(true, slot+3)
+			w.putToken(false, slot+6)
+			w.putToken(v > 6, slot+7)
+			if v <= 6 {
+				w.putBit(v == 6, 159)
+			} else {
+				w.putBit((v-7)&2 != 0, 165)
+				w.putBit((v-7)&1 != 0, 145)
+			}
+		default:
+			w.putToken(true, slot+2)
+			w.putToken(true, slot+3)
+			w.putToken(true, slot+6)
+			cat := 3
+			for cat > 0 && v < 3+8<<uint(cat) {
+				cat--
+			}
+			w.putToken(cat >= 2, slot+8)
+			w.putToken(cat&1 != 0, slot+9+cat>>1)
+			extra := v - (3 + 8<<uint(cat))
+			probs := cat3456[cat][:]
+			bits := 0
+			for probs[bits] != 0 {
+				bits++
+			}
+			for i := 0; i < bits; i++ {
+				w.putBit(extra&(1<<uint(bits-1-i)) != 0, probs[i])
+			}
+		}
+		w.putBit(levels[n] < 0, uniformProb)
+		if n == 15 {
+			break
+		}
+		slot = tokenSlot(plane, int(bands[n+1]), context)
+		w.putToken(n < last, slot)
+	}
+	return 1
+}
+
+// quantizer holds the DC and AC quantization factors of the planes
+type quantizer struct {
+	y1, y2, uv [2]int32
+}
+
+func newQuantizer(q int) quantizer {
+	var z quantizer
+	z.y1 = [2]int32{int32(dequantTableDC[q]), int32(dequantTableAC[q])}
+	z.y2 = [2]int32{2 * int32(dequantTableDC[q]), int32(dequantTableAC[q]) * 155 / 100}
+	if z.y2[1] < 8 {
+		z.y2[1] = 8
+	}
+	uvDC := q
+	if uvDC > 117 {
+		uvDC = 117
+	}
+	z.uv = [2]int32{int32(dequantTableDC[uvDC]), int32(dequantTableAC[q])}
+	return z
+}
+
+// quantize quantizes the coefficients of a block in raster order into levels
+// in zigzag order and replaces the coefficients by their dequantized values
+func quantize(coeffs *[16]int32, levels *[16]int16, factors [2]int32, first int) {
+	for n := first; n < 16; n++ {
+		z := zigzag[n]
+		q := factors[1]
+		// Small AC coefficients are dropped more eagerly than the DC, they
+		// are mostly noise
+		bias := q / 3
+		if z == 0 {
+			q, bias = factors[0], factors[0]/2
+		}
+		c := coeffs[z]
+		level := (abs32(c) + bias) / q
+		if level > maxLevel {
+			level = maxLevel
+		}
+		if c < 0 {
+			level = -level
+		}
+		levels[n] = int16(level)
+		coeffs[z] = int32(int16(level * q))
+	}
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func clip8(v int32) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// forwardDCT transforms the differences between a 4x4 block of src and its
+// prediction
+func forwardDCT(src []uint8, srcStride int, pred []uint8, predStride int, out *[16]int32) {
+	var tmp [16]int32
+	for i := 0; i < 4; i++ {
+		s, p := src[i*srcStride:], pred[i*predStride:]
+		d0 := int32(s[0]) - int32(p[0])
+		d1 := int32(s[1]) - int32(p[1])
+		d2 := int32(s[2]) - int32(p[2])
+		d3 := int32(s[3]) - int32(p[3])
+		a0, a1, a2, a3 := d0+d3, d1+d2, d1-d2, d0-d3
+		tmp[0+i*4] = (a0 + a1) * 8
+		tmp[1+i*4] = (a2*2217 + a3*5352 + 1812) >> 9
+		tmp[2+i*4] = (a0 - a1) * 8
+		tmp[3+i*4] = (a3*2217 - a2*5352 + 937) >> 9
+	}
+	for i := 0; i < 4; i++ {
+		a0, a1 := tmp[0+i]+tmp[12+i], tmp[4+i]+tmp[8+i]
+		a2, a3 := tmp[4+i]-tmp[8+i], tmp[0+i]-tmp[12+i]
+		out[0+i] = (a0 + a1 + 7) >> 4
+		out[4+i] = (a2*2217 + a3*5352 + 12000) >> 16
+		if a3 != 0 {
+			out[4+i]++
+		}
+		out[8+i] = (a0 - a1 + 7) >> 4
+		out[12+i] = (a3*2217 - a2*5352 + 51000) >> 16
+	}
+}
+
+// inverseDCT adds the inverse transform of the coefficients to a 4x4 block,
+// exactly as decoders do
+func inverseDCT(coeffs *[16]int32, dst []uint8, stride int) {
+	const (
+		c1 = 85627 // 65536 * cos(pi/8) * sqrt(2)
+		c2 = 35468 // 65536 * sin(pi/8) * sqrt(2)
+	)
+	var m [4][4]int32
+	for i := 0; i < 4; i++ {
+		a := coeffs[i] + coeffs[8+i]
+		b := coeffs[i] - coeffs[8+i]
+		c := (coeffs[4+i]*c2)>>16 - (coeffs[12+i]*c1)>>16
+		d := (coeffs[4+i]*c1)>>16 + (coeffs[12+i]*c2)>>16
+		m[i] = [4]int32{a + d, b + c, b - c, a - d}
+	}
+	for j := 0; j < 4; j++ {
+		dc := m[0][j] + 4
+		a := dc + m[2][j]
+		b := dc - m[2][j]
+		c := (m[1][j]*c2)>>16 - (m[3][j]*c1)>>16
+		d := (m[1][j]*c1)>>16 + (m[3][j]*c2)>>16
+		row := dst[j*stride:]
+		row[0] = clip8(int32(row[0]) + (a+d)>>3)
+		row[1] = clip8(int32(row[1]) + (b+c)>>3)
+		row[2] = clip8(int32(row[2]) + (b-c)>>3)
+		row[3] = clip8(int32(row[3]) + (a-d)>>3)
+	}
+}
+
+// forwardWHT transforms the DC coefficients of the 16 luma blocks
+func forwardWHT(dc *[16]int32, out *[16]int32) {
+	var tmp [16]int32
+	for i := 0; i < 4; i++ {
+		in := dc[i*4:]
+		a0, a1 := in[0]+in[2], in[1]+in[3]
+		a2, a3 := in[1]-in[3], in[0]-in[2]
+		tmp[0+i*4] = a0 + a1
+		tmp[1+i*4] = a3 + a2
+		tmp[2+i*4] = a3 - a2
+		tmp[3+i*4] = a0 - a1
+	}
+	for i := 0; i < 4; i++ {
+		a0, a1 := tmp[0+i]+tmp[8+i], tmp[4+i]+tmp[12+i]
+		a2, a3 := tmp[4+i]-tmp[12+i], tmp[0+i]-tmp[8+i]
+		out[0+i] = (a0 + a1) >> 1
+		out[4+i] = (a3 + a2) >> 1
+		out[8+i] = (a3 - a2) >> 1
+		out[12+i] = (a0 - a1) >> 1
+	}
+}
+
+// inverseWHT restores the DC coefficients of the 16 luma blocks, exactly as
+// decoders do
+func inverseWHT(in *[16]int32, dc *[16]int32) {
+	var m [16]int32
+	for i := 0; i < 4; i++ {
+		a0, a1 := in[0+i]+in[12+i], in[4+i]+in[8+i]
+		a2, a3 := in[4+i]-in[8+i], in[0+i]-in[12+i]
+		m[0+i] = a0 + a1
+		m[8+i] = a0 - a1
+		m[4+i] = a3 + a2
+		m[12+i] = a3 - a2
+	}
+	for i := 0; i < 4; i++ {
+		d := m[i*4] + 3
+		a0, a1 := d+m[3+i*4], m[1+i*4]+m[2+i*4]
+		a2, a3 := m[1+i*4]-m[2+i*4], d-m[3+i*4]
+		dc[i*4+0] = int32(int16((a0 + a1) >> 3))
+		dc[i*4+1] = int32(int16((a3 + a2) >> 3))
+		dc[i*4+2] = int32(int16((a0 - a1) >> 3))
+		dc[i*4+3] = int32(int16((a3 - a2) >> 3))
+	}
+}
+
+// plane is a luma or chroma plane padded to whole macroblocks
+type plane struct {
+	pix    []uint8
+	stride int
+}
+
+// predict fills pred with the prediction of the n x n block at x, y from the
+// reconstructed pixels around it, see section 12.2
+func (p plane) predict(mode uint8, x, y, n int, pred []uint8) {
+	top := func(i int) uint8 { return p.pix[(y-1)*p.stride+x+i] }
+	left := func(j int) uint8 { return p.pix[(y+j)*p.stride+x-1] }
+	switch mode {
+	case predDC:
+		sum, count := 0, 0
+		if y > 0 {
+			for i := 0; i < n; i++ {
+				sum += int(top(i))
+			}
+			count += n
+		}
+		if x > 0 {
+			for j := 0; j < n; j++ {
+				sum += int(left(j))
+			}
+			count += n
+		}
+		v := uint8(0x80)
+		if count > 0 {
+			v = uint8((sum + count/2) / count)
+		}
+		for i := 0; i < n*n; i++ {
+			pred[i] = v
+		}
+	case predTM:
+		corner := int32(top(-1))
+		for j := 0; j < n; j++ {
+			for i := 0; i < n; i++ {
+				pred[j*n+i] = clip8(int32(left(j)) + int32(top(i)) - corner)
+			}
+		}
+	case predVE:
+		for j := 0; j < n; j++ {
+			for i := 0; i < n; i++ {
+				pred[j*n+i] = top(i)
+			}
+		}
+	case predHE:
+		for j := 0; j < n; j++ {
+			for i := 0; i < n; i++ {
+				pred[j*n+i] = left(j)
+			}
+		}
+	}
+}
+
+// modes returns the prediction modes usable for the block at x, y: the
+// modes using pixels outside of the image are left out, which saves
+// emulating the values decoders assume there
+func modes(x, y int) []uint8 {
+	switch {
+	case x > 0 && y > 0:
+		return []uint8{predDC, predTM, predVE, predHE}
+	case y > 0:
+		return []uint8{predDC, predVE}
+	case x > 0:
+		return []uint8{predDC, predHE}
+	}
+	return []uint8{predDC}
+}
+
+// macroblock holds the prediction modes and quantized coefficients of a
+// macroblock
+type macroblock struct {
+	yMode, uvMode uint8
+	levels        [nBlocks][16]int16
+	skip          bool
+}
+
+type lossyEncoder struct {
+	mbw, mbh  int
+	src, rec  [3]plane
+	q         int
+	quantizer quantizer
+	mbs       []macroblock
+	// skipProb is the probability of macroblocks having coefficients, it is
+	// 0 when skipping macroblocks is not used
+	skipProb uint8
+}
+
+// EncodeLossy writes the image m to w in the lossy WebP format, quality
+// ranges from 0 to 100. Transparency is not kept.
+func EncodeLossy(w io.Writer, m image.Image, quality int) error {
+	b := m.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width >= maxDimension || height >= maxDimension {
+		return ErrTooLarge
+	}
+	if quality < 0 {
+		quality = 0
+	} else if quality > 100 {
+		quality = 100
+	}
+	q := (100 - quality) * 127 / 100
+	e := &lossyEncoder{
+		mbw:       (width + 15) / 16,
+		mbh:       (height + 15) / 16,
+		q:         q,
+		quantizer: newQuantizer(q),
+	}
+	e.convert(m)
+	e.mbs = make([]macroblock, e.mbw*e.mbh)
+	for mby := 0; mby < e.mbh; mby++ {
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			e.encodeMacroblock(mbx, mby)
+		}
+	}
+
+	e.chooseSkipProb()
+	probs, updates := e.tokenProbs()
+	tokens := newBoolEncoder()
+	e.writeTokens(tokenEncoder{tokens, &probs})
+	first := e.writeHeader(&probs, updates)
+	if len(first) >= 1<<19 {
+		return ErrTooLarge
+	}
+	residuals := tokens.bytes()
+
+	frame := make([]byte, 10, 10+len(first)+len(residuals))
+	tag := uint32(len(first))<<5 | 1<<4
+	frame[0], frame[1], frame[2] = byte(tag), byte(tag>>8), byte(tag>>16)
+	copy(frame[3:], []byte{0x9d, 0x01, 0x2a})
+	binary.LittleEndian.PutUint16(frame[6:], uint16(width))
+	binary.LittleEndian.PutUint16(frame[8:], uint16(height))
+	frame = append(frame, first...)
+	frame = append(frame, residuals...)
+	return writeChunk(w, "VP8 ", frame)
+}
+
+// convert converts the image to Y'CbCr with the BT.601 coefficients and
+// ranges used by VP8, chroma is subsampled from the average of 2x2 pixels
+func (e *lossyEncoder) convert(m image.Image) {
+	b := m.Bounds()
+	width, height := 16*e.mbw, 16*e.mbh
+	for i := range e.src {
+		w, h := width, height
+		if i > 0 {
+			w, h = w/2, h/2
+		}
+		e.src[i] = plane{pix: make([]uint8, w*h), stride: w}
+		e.rec[i] = plane{pix: make([]uint8, w*h), stride: w}
+	}
+	rgb := make([][3]int32, width*2)
+	at := func(x, y int) [3]int32 {
+		if x >= b.Dx() {
+			x = b.Dx() - 1
+		}
+		if y >= b.Dy() {
+			y = b.Dy() - 1
+		}
+		r, g, bl, _ := m.At(b.Min.X+x, b.Min.Y+y).RGBA()
+		return [3]int32{int32(r >> 8), int32(g >> 8), int32(bl >> 8)}
+	}
+	for y := 0; y < height; y += 2 {
+		for j := 0; j < 2; j++ {
+			for x := 0; x < width; x++ {
+				c := at(x, y+j)
+				rgb[j*width+x] = c
+				e.src[0].pix[(y+j)*width+x] = clip8((16839*c[0] + 33059*c[1] + 6420*c[2] + 16<<16 + 1<<15) >> 16)
+			}
+		}
+		for x := 0; x < width; x += 2 {
+			var s [3]int32
+			for k := range s {
+				s[k] = rgb[x][k] + rgb[x+1][k] + rgb[width+x][k] + rgb[width+x+1][k]
+			}
+			i := y/2*e.src[1].stride + x/2
+			e.src[1].pix[i] = clip8((-9719*s[0] - 19081*s[1] + 28800*s[2] + 128<<18 + 1<<17) >> 18)
+			e.src[2].pix[i] = clip8((28800*s[0] - 24116*s[1] - 4684*s[2] + 128<<18 + 1<<17) >> 18)
+		}
+	}
+}
+
+// bestMode returns the prediction mode of the n x n block at x, y with the
+// smallest squared error
+func (e *lossyEncoder) bestMode(planes []int, x, y, n int, pred []uint8) uint8 {
+	best, bestErr := uint8(predDC), int64(math.MaxInt64)
+	for _, mode := range modes(x, y) {
+		var sse int64
+		for _, i := range planes {
+			src, rec := e.src[i], e.rec[i]
+			rec.predict(mode, x, y, n, pred)
+			for j := 0; j < n; j++ {
+				for k := 0; k < n; k++ {
+					d := int64(src.pix[(y+j)*src.stride+x+k]) - int64(pred[j*n+k])
+					sse += d * d
+				}
+			}
+		}
+		if sse < bestErr {
+			best, bestErr = mode, sse
+		}
+	}
+	return best
+}
+
+// encodeMacroblock chooses the prediction modes of a macroblock, quantizes
+// its residuals and reconstructs it as decoders will
+func (e *lossyEncoder) encodeMacroblock(mbx, mby int) {
+	mb := &e.mbs[mby*e.mbw+mbx]
+	var pred [256]uint8
+
+	// Luma, the DC coefficients of the 16 blocks are coded in the Y2 block
+	x, y := 16*mbx, 16*mby
+	mb.yMode = e.bestMode([]int{0}, x, y, 16, pred[:])
+	src, rec := e.src[0], e.rec[0]
+	rec.predict(mb.yMode, x, y, 16, pred[:])
+	var coeffs [16][16]int32
+	var dc, wht [16]int32
+	for i := range coeffs {
+		bx, by := 4*(i%4), 4*(i/4)
+		forwardDCT(src.pix[(y+by)*src.stride+x+bx:], src.stride, pred[by*16+bx:], 16, &coeffs[i])
+		dc[i] = coeffs[i][0]
+	}
+	forwardWHT(&dc, &wht)
+	quantize(&wht, &mb.levels[blockY2], e.quantizer.y2, 0)
+	inverseWHT(&wht, &dc)
+	for i := range coeffs {
+		quantize(&coeffs[i], &mb.levels[i], e.quantizer.y1, 1)
+		coeffs[i][0] = dc[i]
+		bx, by := 4*(i%4), 4*(i/4)
+		dst := rec.pix[(y+by)*rec.stride+x+bx:]
+		for j := 0; j < 4; j++ {
+			copy(dst[j*rec.stride:j*rec.stride+4], pred[(by+j)*16+bx:])
+		}
+		inverseDCT(&coeffs[i], dst, rec.stride)
+	}
+
+	// Chroma, both planes share their mode
+	x, y = 8*mbx, 8*mby
+	mb.uvMode = e.bestMode([]int{1, 2}, x, y, 8, pred[:])
+	for p := 1; p <= 2; p++ {
+		src, rec := e.src[p], e.rec[p]
+		rec.predict(mb.uvMode, x, y, 8, pred[:])
+		for i := 0; i < 4; i++ {
+			bx, by := 4*(i%2), 4*(i/2)
+			var c [16]int32
+			forwardDCT(src.pix[(y+by)*src.stride+x+bx:], src.stride, pred[by*8+bx:], 8, &c)
+			quantize(&c, &mb.levels[firstU+4*(p-1)+i], e.quantizer.uv, 0)
+			dst := rec.pix[(y+by)*rec.stride+x+bx:]
+			for j := 0; j < 4; j++ {
+				copy(dst[j*rec.stride:j*rec.stride+4], pred[(by+j)*8+bx:])
+			}
+			inverseDCT(&c, dst, rec.stride)
+		}
+	}
+
+	mb.skip = true
+	for i := range mb.levels {
+		if mb.levels[i] != [16]int16{} {
+			mb.skip = false
+			break
+		}
+	}
+}
+
+// chooseSkipProb decides whether macroblocks without coefficients are
+// flagged as skipped, which is worth it unless they are very rare
+func (e *lossyEncoder) chooseSkipProb() {
+	skipped := 0
+	for i := range e.mbs {
+		if e.mbs[i].skip {
+			skipped++
+		}
+	}
+	prob := (255*(len(e.mbs)-skipped) + len(e.mbs)/2) / len(e.mbs)
+	if prob == 0 {
+		prob = 1
+	}
+	if skipped > 0 && prob < 255 {
+		e.skipProb = uint8(prob)
+		return
+	}
+	for i := range e.mbs {
+		e.mbs[i].skip = false
+	}
+}
+
+// writeTokens writes the residuals of all macroblocks, the context of each
+// block is the number of its neighbours above and left having non-zero
+// coefficients
+func (e *lossyEncoder) writeTokens(w tokenWriter) {
+	type nz struct {
+		y    [4]int
+		u, v [2]int
+		y2   int
+	}
+	above := make([]nz, e.mbw)
+	for mby := 0; mby < e.mbh; mby++ {
+		var left nz
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			mb, up := &e.mbs[mby*e.mbw+mbx], &above[mbx]
+			if mb.skip {
+				left, *up = nz{}, nz{}
+				continue
+			}
+			left.y2 = putResiduals(w, planeY2, left.y2+up.y2, &mb.levels[blockY2], 0)
+			up.y2 = left.y2
+			for i := 0; i < 16; i++ {
+				bx, by := i%4, i/4
+				n := putResiduals(w, planeY1WithY2, left.y[by]+up.y[bx], &mb.levels[i], 1)
+				left.y[by], up.y[bx] = n, n
+			}
+			for i := 0; i < 4; i++ {
+				bx, by := i%2, i/2
+				n := putResiduals(w, planeUV, left.u[by]+up.u[bx], &mb.levels[firstU+i], 0)
+				left.u[by], up.u[bx] = n, n
+			}
+			for i := 0; i < 4; i++ {
+				bx, by := i%2, i/2
+				n := putResiduals(w, planeUV, left.v[by]+up.v[bx], &mb.levels[firstV+i], 0)
+				left.v[by], up.v[bx] = n, n
+			}
+		}
+	}
+}
+
+// tokenProbs returns the token probabilities fitting the residuals of the
+// image best, and which of them differ from the default ones enough to be
+// worth updating
+func (e *lossyEncoder) tokenProbs() (probs [nTokenProbs]uint8, updates [nTokenProbs]bool) {
+	stats := &tokenStats{}
+	e.writeTokens(stats)
+	defaults, updateProbs := flatten(&defaultTokenProb), flatten(&tokenProbUpdateProb)
+	for slot, counts := range stats {
+		probs[slot] = defaults[slot]
+		total := counts[0] + counts[1]
+		if total == 0 {
+			continue
+		}
+		p := uint8((255*uint64(counts[0]) + uint64(total)/2) / uint64(total))
+		if p == 0 {
+			p = 1
+		}
+		saved := bitCost(defaults[slot], counts) - bitCost(p, counts)
+		cost := 8 + bitCost(updateProbs[slot], [2]uint32{0, 1}) - bitCost(updateProbs[slot], [2]uint32{1, 0})
+		if saved > cost {
+			probs[slot], updates[slot] = p, true
+		}
+	}
+	return
+}
+
+// flatten returns the token probabilities indexed by their slot
+func flatten(probs *[nPlane][nBand][nContext][nProb]uint8) *[nTokenProbs]uint8 {
+	var flat [nTokenProbs]uint8
+	for i := range probs {
+		for j := range probs[i] {
+			for k := range probs[i][j] {
+				copy(flat[tokenSlot(i, j, k):], probs[i][j][k][:])
+			}
+		}
+	}
+	return &flat
+}
+
+// bitCost returns the number of bits needed to code the given numbers of
+// zeros and ones with a probability prob/256 of zeros
+func bitCost(prob uint8, counts [2]uint32) float64 {
+	p := float64(prob) / 256
+	return -float64(counts[0])*math.Log2(p) - float64(counts[1])*math.Log2(1-p)
+}
+
+// writeHeader writes the first partition of the frame: the frame header and
+// the prediction modes of all macroblocks, see section 19.2
+func (e *lossyEncoder) writeHeader(probs *[nTokenProbs]uint8, updates [nTokenProbs]bool) []byte {
+	w := newBoolEncoder()
+	// Color space and clamping type
+	w.putBit(false, uniformProb)
+	w.putBit(false, uniformProb)
+	// No segmentation
+	w.putBit(false, uniformProb)
+	// Normal loop filter of level 0, i.e. none, and sharpness 0 without
+	// adjustments
+	w.putBit(false, uniformProb)
+	w.putLiteral(0, 6)
+	w.putLiteral(0, 3)
+	w.putBit(false, uniformProb)
+	// A single token partition
+	w.putLiteral(0, 2)
+	// Quantizer index, without deltas for the planes
+	w.putLiteral(uint32(e.q), 7)
+	for i := 0; i < 5; i++ {
+		w.putOptionalInt(0, 4)
+	}
+	// Refresh entropy probabilities
+	w.putBit(false, uniformProb)
+	updateProbs := flatten(&tokenProbUpdateProb)
+	for slot, update := range updates {
+		w.putBit(update, updateProbs[slot])
+		if update {
+			w.putLiteral(uint32(probs[slot]), 8)
+		}
+	}
+
+	w.putBit(e.skipProb > 0, uniformProb)
+	if e.skipProb > 0 {
+		w.putLiteral(uint32(e.skipProb), 8)
+	}
+
+	for i := range e.mbs {
+		mb := &e.mbs[i]
+		if e.skipProb > 0 {
+			w.putBit(mb.skip, e.skipProb)
+		}
+		// 16x16 luma prediction, see section 11.2
+		w.putBit(true, 145)
+		switch mb.yMode {
+		case predDC, predVE:
+			w.putBit(false, 156)
+			w.putBit(mb.yMode == predVE, 163)
+		default:
+			w.putBit(true, 156)
+			w.putBit(mb.yMode == predTM, 128)
+		}
+		w.putBit(mb.uvMode != predDC, 142)
+		if mb.uvMode != predDC {
+			w.putBit(mb.uvMode != predVE, 114)
+			if mb.uvMode != predVE {
+				w.putBit(mb.uvMode == predTM, 183)
+			}
+		}
+	}
+	return w.bytes()
+}
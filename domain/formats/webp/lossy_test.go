@@ -0,0 +1,134 @@
+package webp
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	xwebp "golang.org/x/image/webp"
+)
+
+func testPhoto(t *testing.T) image.Image {
+	in, err := os.Open("../../testdata/Canon_40D.jpg")
+	if err != nil {
+		t.Fatalf("Failed to open test image: %s", err)
+	}
+	defer in.Close()
+	img, err := jpeg.Decode(in)
+	if err != nil {
+		t.Fatalf("Failed to decode test image: %s", err)
+	}
+	return img
+}
+
+func gradient(width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x), uint8(y), uint8(x + y), 0xff})
+		}
+	}
+	return img
+}
+
+func filled(width, height int, c color.NRGBA) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodeLossy(t *testing.T, name string, img image.Image, quality int) []byte {
+	var buf bytes.Buffer
+	if err := EncodeLossy(&buf, img, quality); err != nil {
+		t.Fatalf("%s: failed to encode: %s", name, err)
+	}
+	return buf.Bytes()
+}
+
+func decodeLossy(t *testing.T, name string, encoded []byte) *image.YCbCr {
+	decoded, err := xwebp.Decode(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("%s: failed to decode: %s", name, err)
+	}
+	ycbcr, ok := decoded.(*image.YCbCr)
+	if !ok {
+		t.Fatalf("%s: expected a Y'CbCr image, got %T", name, decoded)
+	}
+	return ycbcr
+}
+
+func TestEncodeLossy(t *testing.T) {
+	data := []struct {
+		name    string
+		img     image.Image
+		minPSNR float64
+	}{
+		{"1x1", filled(1, 1, color.NRGBA{0x80, 0x40, 0x20, 0xff}), 35},
+		{"uniform", filled(50, 50, color.NRGBA{0x20, 0x80, 0xc0, 0xff}), 35},
+		{"odd size", gradient(17, 33), 30},
+		{"gradient", gradient(300, 200), 30},
+		{"sub-image", gradient(300, 200).(*image.NRGBA).SubImage(image.Rect(10, 20, 110, 70)), 30},
+		{"photo", testPhoto(t), 28},
+	}
+	for _, d := range data {
+		// The Y'CbCr image of the decoder is not converted from the BT.601
+		// ranges, only its luma is compared
+		decoded := decodeLossy(t, d.name, encodeLossy(t, d.name, d.img, DefaultQuality))
+		assert.Equal(t, d.img.Bounds().Size(), decoded.Bounds().Size(), "%s: bad size", d.name)
+		quality := lumaPSNR(d.img, decoded)
+		assert.True(t, quality >= d.minPSNR, "%s: luma PSNR too low: %.1fdB", d.name, quality)
+	}
+}
+
+// lumaPSNR returns the peak signal to noise ratio of the luma of decoded
+// compared to the BT.601 luma of img
+func lumaPSNR(img image.Image, decoded *image.YCbCr) float64 {
+	b := img.Bounds()
+	var sse float64
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			luma := 16 + (65.738*float64(r>>8)+129.057*float64(g>>8)+25.064*float64(bl>>8))/256
+			diff := luma - float64(decoded.Y[decoded.YOffset(x, y)])
+			sse += diff * diff
+		}
+	}
+	if sse == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255*float64(b.Dx()*b.Dy())/sse)
+}
+
+func TestEncodeLossySize(t *testing.T) {
+	img := testPhoto(t)
+	lossy := encodeLossy(t, "photo", img, DefaultQuality)
+
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, img, nil); err != nil {
+		t.Fatalf("Failed to encode JPEG image: %s", err)
+	}
+	assert.True(t, len(lossy) < jpg.Len(), "Lossy image must be smaller than the JPEG one: %d >= %d", len(lossy), jpg.Len())
+}
+
+func TestEncodeLossyQuality(t *testing.T) {
+	img := testPhoto(t)
+	var lastSize int
+	var lastPSNR float64
+	for _, quality := range []int{10, 50, 90} {
+		encoded := encodeLossy(t, "photo", img, quality)
+		p := lumaPSNR(img, decodeLossy(t, fmt.Sprintf("quality %d", quality), encoded))
+		assert.True(t, len(encoded) > lastSize, "Quality %d must give larger images", quality)
+		assert.True(t, p > lastPSNR, "Quality %d must give better images", quality)
+		lastSize, lastPSNR = len(encoded), p
+	}
+}
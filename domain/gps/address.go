@@ -2,6 +2,7 @@ package gps
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 )
 
@@ -11,6 +12,50 @@ func (id PlaceID) String() string {
 	return string(id)
 }
 
+// Contains returns true if the given place is this place or one of its sub-places
+func (id PlaceID) Contains(other PlaceID) bool {
+	return other == id || strings.HasPrefix(string(other), string(id)+PlaceIDSeparator)
+}
+
+// PlaceIDSeparator separates the levels of a place ID
+const PlaceIDSeparator = "/"
+
+// missingLevel replaces unknown intermediate levels in place IDs
+const missingLevel = "_"
+
+// PlaceLevel is the administrative level of a place
+type PlaceLevel int
+
+const (
+	LevelCountry PlaceLevel = iota
+	LevelRegion
+	LevelCity
+	LevelNeighbourhood
+)
+
+var levelNames = []string{"country", "region", "city", "neighbourhood"}
+
+func (l PlaceLevel) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return fmt.Sprintf("level%d", l)
+	}
+	return levelNames[l]
+}
+
+func (l PlaceLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *PlaceLevel) UnmarshalText(text []byte) error {
+	for i, name := range levelNames {
+		if name == string(text) {
+			*l = PlaceLevel(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown place level '%s'", text)
+}
+
 type Country struct {
 	Country string    `json:"country,omitempty"`
 	ID      CountryID `json:"ciso,omitempty"`
@@ -28,8 +73,12 @@ func CountryIDFromString(id string) CountryID {
 
 type AddressFields struct {
 	Country
-	City string `json:"city,omitempty"`
-	Zip  string `json:"zip,omitempty"`
+	// Region is the state, province or region within the country
+	Region string `json:"region,omitempty"`
+	City   string `json:"city,omitempty"`
+	// Neighbourhood is the district or suburb within the city
+	Neighbourhood string `json:"neighbourhood,omitempty"`
+	Zip           string `json:"zip,omitempty"`
 }
 
 // Address is the address view of a geographical location
@@ -39,28 +88,41 @@ type Address struct {
 	BoundingBox *Rect   `json:"boundingbox,omitempty"`
 }
 
+// Place is one level of the hierarchy of an address
+type Place struct {
+	ID    PlaceID    `json:"id"`
+	Level PlaceLevel `json:"level"`
+	Name  string     `json:"name"`
+}
+
 func AsAddress(country, iso, city, zip string) Address {
-	cid := CountryIDFromString(iso)
-	return Address{
-		AddressFields: AddressFields{
-			Country: Country{
-				Country: country,
-				ID:      cid,
-			},
-			City: city,
-			Zip:  zip,
+	return NewAddress(AddressFields{
+		Country: Country{
+			Country: country,
+			ID:      CountryIDFromString(iso),
 		},
-		ID: asPlaceID(cid, city, zip),
+		City: city,
+		Zip:  zip,
+	})
+}
+
+// NewAddress returns the address with the given fields
+func NewAddress(fields AddressFields) Address {
+	fields.ID = CountryIDFromString(string(fields.ID))
+	return Address{
+		AddressFields: fields,
+		ID:            fields.placeID(),
 	}
 }
 
 func (a *Address) UnmarshalJSON(data []byte) (err error) {
-	type aliasAddress *Address
-	alias := aliasAddress(a)
-	if err = json.Unmarshal(data, alias); err != nil {
+	// plainAddress has the fields of Address but not its methods, avoiding
+	// recursive calls to UnmarshalJSON
+	type plainAddress Address
+	if err = json.Unmarshal(data, (*plainAddress)(a)); err != nil {
 		return
 	}
-	a.ID = asPlaceID(alias.AddressFields.Country.ID, alias.AddressFields.City, alias.AddressFields.Zip)
+	a.ID = a.AddressFields.placeID()
 	return
 }
 
@@ -68,6 +130,50 @@ func (a *Address) HasValidBoundingBox() bool {
 	return a.BoundingBox != nil && a.BoundingBox.W() > 0 && a.BoundingBox.H() > 0
 }
 
-func asPlaceID(countryCode CountryID, city, zip string) PlaceID {
-	return PlaceID(strings.Join([]string{strings.ToLower(string(countryCode)), strings.ToLower(zip), strings.ToLower(city)}, "_"))
+// Hierarchy returns the places containing this address, from the country
+// down to the most detailed known level. Unknown levels are skipped.
+func (a *Address) Hierarchy() []Place {
+	names := a.levelNames()
+	var places []Place
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		level := PlaceLevel(i)
+		if level == LevelCountry && a.Country.Country != "" {
+			name = a.Country.Country
+		}
+		places = append(places, Place{
+			ID:    placeIDOf(names[:i+1]),
+			Level: level,
+			Name:  name,
+		})
+	}
+	return places
+}
+
+func (a *AddressFields) levelNames() []string {
+	names := []string{string(a.Country.ID), a.Region, a.City, a.Neighbourhood}
+	if names[LevelNeighbourhood] == "" {
+		names = names[:LevelNeighbourhood]
+	}
+	return names
+}
+
+// placeID returns the ID of the most detailed level of the address, made of
+// the names of all levels down to that level
+func (a *AddressFields) placeID() PlaceID {
+	return placeIDOf(a.levelNames())
+}
+
+func placeIDOf(names []string) PlaceID {
+	parts := make([]string, len(names))
+	for i, name := range names {
+		if name == "" {
+			parts[i] = missingLevel
+		} else {
+			parts[i] = strings.ReplaceAll(strings.ToLower(name), PlaceIDSeparator, "-")
+		}
+	}
+	return PlaceID(strings.Join(parts, PlaceIDSeparator))
 }
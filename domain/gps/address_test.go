@@ -12,28 +12,29 @@ var addressData = []struct {
 	STRUCT Address
 	ID     PlaceID
 }{
-	{JSON: `{"country":"France","ciso":"fr","city":"Nice","zip":"06000","id":"fr_06000_nice"}`,
+	{JSON: `{"country":"France","ciso":"fr","city":"Nice","zip":"06000","id":"fr/_/nice"}`,
 		STRUCT: Address{
 			AddressFields: AddressFields{
 				Country: Country{Country: "France", ID: CountryIDFromString("FR")},
 				City:    "Nice",
 				Zip:     "06000",
 			},
-			ID: PlaceID("fr_06000_nice"),
+			ID: PlaceID("fr/_/nice"),
 		},
-		ID: PlaceID("fr_06000_nice"),
+		ID: PlaceID("fr/_/nice"),
 	},
-	{JSON: `{"country":"Kroatien","ciso":"hr","city":"Mali Lošinj","zip":"51553","id":"hr_51553_mali lošinj","boundingbox":[14.4693894,44.4778905,14.5315122,44.5260856]}`,
+	{JSON: `{"country":"Kroatien","ciso":"hr","region":"Primorje-Gorski Kotar","city":"Mali Lošinj","zip":"51553","id":"hr/primorje-gorski kotar/mali lošinj","boundingbox":[14.4693894,44.4778905,14.5315122,44.5260856]}`,
 		STRUCT: Address{
 			AddressFields: AddressFields{
 				Country: Country{Country: "Kroatien", ID: CountryIDFromString("hr")},
+				Region:  "Primorje-Gorski Kotar",
 				City:    "Mali Lošinj",
 				Zip:     "51553",
 			},
-			ID:          PlaceID("hr_51553_mali lošinj"),
+			ID:          PlaceID("hr/primorje-gorski kotar/mali lošinj"),
 			BoundingBox: &Rect{14.4693894, 44.4778905, 14.5315122, 44.5260856},
 		},
-		ID: PlaceID("hr_51553_mali lošinj"),
+		ID: PlaceID("hr/primorje-gorski kotar/mali lošinj"),
 	},
 }
 
@@ -60,3 +61,22 @@ func TestMarshalJSONAddress(t *testing.T) {
 		assert.Equal(t, d.JSON, string(bin))
 	}
 }
+
+func TestHierarchy(t *testing.T) {
+	a := NewAddress(AddressFields{
+		Country:       Country{Country: "Österreich", ID: "AT"},
+		City:          "Wien",
+		Neighbourhood: "Innere Stadt",
+		Zip:           "1010",
+	})
+	assert.Equal(t, PlaceID("at/_/wien/innere stadt"), a.ID)
+	assert.Equal(t, []Place{
+		{ID: "at", Level: LevelCountry, Name: "Österreich"},
+		{ID: "at/_/wien", Level: LevelCity, Name: "Wien"},
+		{ID: "at/_/wien/innere stadt", Level: LevelNeighbourhood, Name: "Innere Stadt"},
+	}, a.Hierarchy())
+	assert.True(t, PlaceID("at").Contains(a.ID))
+	assert.True(t, PlaceID("at/_/wien").Contains(a.ID))
+	assert.True(t, a.ID.Contains(a.ID))
+	assert.False(t, PlaceID("at/_/wie").Contains(a.ID))
+}
@@ -0,0 +1,117 @@
+package gps
+
+import (
+	"math"
+	"strings"
+)
+
+// MaxGeohashPrecision is the number of characters of the most precise geohash,
+// identifying cells smaller than 4cm
+const MaxGeohashPrecision = 12
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Geohash returns the geohash of the given coordinates with the given number
+// of characters. Geohashes sharing a prefix are close to each other.
+func Geohash(c Coordinates, precision int) string {
+	lat, lon := [2]float64{LatMin, LatMax}, [2]float64{LonMin, LonMax}
+	var hash strings.Builder
+	bit, value, even := 0, 0, true
+	for hash.Len() < precision {
+		interval, v := &lat, c.Lat
+		if even {
+			interval, v = &lon, c.Long
+		}
+		mid := (interval[0] + interval[1]) / 2
+		value <<= 1
+		if v >= mid {
+			value |= 1
+			interval[0] = mid
+		} else {
+			interval[1] = mid
+		}
+		even = !even
+		if bit++; bit == 5 {
+			hash.WriteByte(geohashAlphabet[value])
+			bit, value = 0, 0
+		}
+	}
+	return hash.String()
+}
+
+// GeohashBounds returns the cell identified by the given geohash
+func GeohashBounds(hash string) Rect {
+	lat, lon := [2]float64{LatMin, LatMax}, [2]float64{LonMin, LonMax}
+	even := true
+	for i := 0; i < len(hash); i++ {
+		value := strings.IndexByte(geohashAlphabet, hash[i])
+		for bit := 4; bit >= 0; bit-- {
+			interval := &lat
+			if even {
+				interval = &lon
+			}
+			mid := (interval[0] + interval[1]) / 2
+			if value&(1<<uint(bit)) != 0 {
+				interval[0] = mid
+			} else {
+				interval[1] = mid
+			}
+			even = !even
+		}
+	}
+	return RectFrom(lon[0], lat[0], lon[1], lat[1])
+}
+
+// geohashCellSize returns the width and height in degrees of the cells of
+// geohashes with the given precision
+func geohashCellSize(precision int) (float64, float64) {
+	bits := 5 * precision
+	lonBits, latBits := (bits+1)/2, bits/2
+	return (LonMax - LonMin) / math.Pow(2, float64(lonBits)), (LatMax - LatMin) / math.Pow(2, float64(latBits))
+}
+
+// GeohashesCovering returns the geohashes of the cells covering the given
+// rectangle, using the most precise cells for which at most maxCells are
+// needed
+func GeohashesCovering(r Rect, maxCells int) []string {
+	r = RectFrom(
+		math.Max(LonMin, r.X0()), math.Max(LatMin, r.Y0()),
+		math.Min(LonMax, r.X1()), math.Min(LatMax, r.Y1()))
+	precision := 1
+	for p := 2; p <= MaxGeohashPrecision; p++ {
+		c0, r0, c1, r1 := cellRange(r, p)
+		if (c1-c0+1)*(r1-r0+1) > maxCells {
+			break
+		}
+		precision = p
+	}
+	w, h := geohashCellSize(precision)
+	c0, r0, c1, r1 := cellRange(r, precision)
+	hashes := make([]string, 0, (c1-c0+1)*(r1-r0+1))
+	for col := c0; col <= c1; col++ {
+		for row := r0; row <= r1; row++ {
+			center := Coordinates{
+				Lat:  LatMin + (float64(row)+0.5)*h,
+				Long: LonMin + (float64(col)+0.5)*w,
+			}
+			hashes = append(hashes, Geohash(center, precision))
+		}
+	}
+	return hashes
+}
+
+// cellRange returns the first and last column and row of the cells with the
+// given precision intersecting the given rectangle
+func cellRange(r Rect, precision int) (c0, r0, c1, r1 int) {
+	w, h := geohashCellSize(precision)
+	index := func(v, min, size, max float64) int {
+		i := int(math.Floor((v - min) / size))
+		if last := int(math.Round((max-min)/size)) - 1; i > last {
+			return last
+		}
+		return i
+	}
+	c0, c1 = index(r.X0(), LonMin, w, LonMax), index(r.X1(), LonMin, w, LonMax)
+	r0, r1 = index(r.Y0(), LatMin, h, LatMax), index(r.Y1(), LatMin, h, LatMax)
+	return
+}
@@ -0,0 +1,40 @@
+package gps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeohash(t *testing.T) {
+	data := []struct {
+		lat, lon float64
+		hash     string
+	}{
+		{57.64911, 10.40744, "u4pruydqqvj"},
+		{48.2082, 16.3738, "u2edk"},
+		{-33.8688, 151.2093, "r3gx2"},
+	}
+	for _, d := range data {
+		c := Coordinates{Lat: d.lat, Long: d.lon}
+		hash := Geohash(c, len(d.hash))
+		assert.Equal(t, d.hash, hash)
+		bounds := GeohashBounds(hash)
+		assert.True(t, PointFromLatLon(d.lat, d.lon).In(bounds), "%s: %v not in %v", hash, c, bounds)
+	}
+}
+
+func TestGeohashesCovering(t *testing.T) {
+	vienna := RectFrom(16.18, 48.11, 16.58, 48.33)
+	hashes := GeohashesCovering(vienna, 16)
+	assert.True(t, len(hashes) > 0 && len(hashes) <= 16, "Bad number of cells: %d", len(hashes))
+	for _, c := range []Coordinates{{48.2082, 16.3738}, {48.11, 16.18}, {48.33, 16.58}} {
+		hash := Geohash(c, MaxGeohashPrecision)
+		covered := false
+		for _, h := range hashes {
+			covered = covered || hash[:len(h)] == h
+		}
+		assert.True(t, covered, "%v not covered by %v", c, hashes)
+	}
+	assert.Equal(t, 32, len(GeohashesCovering(WorldBounds, 4)))
+}
@@ -3,6 +3,7 @@ package gps
 import (
 	"errors"
 	"fmt"
+	"math"
 )
 
 var (
@@ -19,6 +20,9 @@ const (
 	LatMax = float64(90.)
 	LonMin = float64(-180.)
 	LonMax = float64(180.)
+
+	// EarthRadius is the mean radius of the earth in meters
+	EarthRadius = 6371008.8
 )
 
 var (
@@ -47,8 +51,18 @@ func (c Coordinates) String() string {
 	return fmt.Sprintf("[%f;%f]", c.Lat, c.Long)
 }
 
+// DistanceTo returns the great-circle distance in meters to the other
+// coordinates
 func (c Coordinates) DistanceTo(other *Coordinates) float64 {
-	return 0
+	lat1, lat2 := toRadians(c.Lat), toRadians(other.Lat)
+	dLat, dLon := lat2-lat1, toRadians(other.Long-c.Long)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
 }
 
 func (c *Coordinates) ISO6709() string {
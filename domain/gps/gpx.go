@@ -0,0 +1,95 @@
+package gps
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+var ErrEmptyTrack = errors.New("GPX track contains no timestamped points")
+
+// TrackPoint is a location recorded at a given time
+type TrackPoint struct {
+	Coordinates
+	Time time.Time
+}
+
+// Track is a sequence of track points sorted by time
+type Track []TrackPoint
+
+type gpxFile struct {
+	Tracks []struct {
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+type gpxPoint struct {
+	Lat  float64   `xml:"lat,attr"`
+	Lon  float64   `xml:"lon,attr"`
+	Time time.Time `xml:"time"`
+}
+
+// ReadGPX reads all timestamped points of all tracks of the given GPX document
+func ReadGPX(in io.Reader) (Track, error) {
+	var gpx gpxFile
+	if err := xml.NewDecoder(in).Decode(&gpx); err != nil {
+		return nil, err
+	}
+	var track Track
+	for _, trk := range gpx.Tracks {
+		for _, seg := range trk.Segments {
+			for _, p := range seg.Points {
+				c := Coordinates{Lat: p.Lat, Long: p.Lon}
+				if p.Time.IsZero() || !c.IsValid() {
+					continue
+				}
+				track = append(track, TrackPoint{Coordinates: c, Time: p.Time.UTC()})
+			}
+		}
+	}
+	if len(track) == 0 {
+		return nil, ErrEmptyTrack
+	}
+	sort.SliceStable(track, func(i, j int) bool { return track[i].Time.Before(track[j].Time) })
+	return track, nil
+}
+
+// Start returns the time of the first point of this track
+func (t Track) Start() time.Time {
+	return t[0].Time
+}
+
+// End returns the time of the last point of this track
+func (t Track) End() time.Time {
+	return t[len(t)-1].Time
+}
+
+// LocationAt returns the location at the given time, linearly interpolated
+// between the two surrounding track points. No location is returned if the
+// time is outside of the track or if the surrounding points are more than
+// maxGap apart
+func (t Track) LocationAt(ts time.Time, maxGap time.Duration) (*Coordinates, bool) {
+	if len(t) == 0 || ts.Before(t.Start()) || ts.After(t.End()) {
+		return nil, false
+	}
+	i := sort.Search(len(t), func(i int) bool { return !t[i].Time.Before(ts) })
+	next := t[i]
+	if next.Time.Equal(ts) {
+		c := next.Coordinates
+		return &c, true
+	}
+	prev := t[i-1]
+	gap := next.Time.Sub(prev.Time)
+	if gap > maxGap {
+		return nil, false
+	}
+	f := float64(ts.Sub(prev.Time)) / float64(gap)
+	return &Coordinates{
+		Lat:  prev.Lat + f*(next.Lat-prev.Lat),
+		Long: prev.Long + f*(next.Long-prev.Long),
+	}, true
+}
@@ -0,0 +1,52 @@
+package gps
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk><name>Walk</name>
+    <trkseg>
+      <trkpt lat="48.0" lon="11.0"><ele>520</ele><time>2019-08-01T10:00:00Z</time></trkpt>
+      <trkpt lat="48.1" lon="11.2"><time>2019-08-01T10:10:00Z</time></trkpt>
+      <trkpt lat="48.2" lon="11.2"><time>2019-08-01T12:00:00Z</time></trkpt>
+      <trkpt lat="48.2" lon="11.3"></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+func TestTrackLocationAt(t *testing.T) {
+	track, err := ReadGPX(strings.NewReader(testGPX))
+	if err != nil {
+		t.Fatalf("Failed to read GPX: %s", err)
+	}
+	assert.Equal(t, 3, len(track))
+	at := func(ts string) time.Time {
+		v, _ := time.Parse(time.RFC3339, ts)
+		return v
+	}
+	data := []struct {
+		ts       string
+		found    bool
+		lat, lon float64
+	}{
+		{"2019-08-01T09:59:59Z", false, 0, 0},
+		{"2019-08-01T10:00:00Z", true, 48.0, 11.0},
+		{"2019-08-01T12:05:00+02:00", true, 48.05, 11.1},
+		{"2019-08-01T11:00:00Z", false, 0, 0},
+		{"2019-08-01T12:00:00Z", true, 48.2, 11.2},
+		{"2019-08-01T12:00:01Z", false, 0, 0},
+	}
+	for _, d := range data {
+		c, found := track.LocationAt(at(d.ts), 30*time.Minute)
+		if assert.Equal(t, d.found, found, d.ts) && found {
+			assert.InDelta(t, d.lat, c.Lat, 1e-9, d.ts)
+			assert.InDelta(t, d.lon, c.Long, 1e-9, d.ts)
+		}
+	}
+}
@@ -11,6 +11,34 @@ func RectFrom(x0, y0, x1, y1 float64) Rect {
 	return Rect([4]float64{math.Min(x0, x1), math.Min(y0, y1), math.Max(x0, x1), math.Max(y0, y1)})
 }
 
+// RectsAround returns the rectangles containing all points within radius
+// meters of the given coordinates: one rectangle on each side of the
+// antimeridian if the area crosses it, a single rectangle otherwise
+func RectsAround(c Coordinates, radius float64) []Rect {
+	dLat := radius / EarthRadius * 180 / math.Pi
+	dLon := LonMax
+	if cos := math.Cos(c.Lat * math.Pi / 180); cos > dLat/LonMax {
+		dLon = math.Min(LonMax, dLat/cos)
+	}
+	y0, y1 := math.Max(LatMin, c.Lat-dLat), math.Min(LatMax, c.Lat+dLat)
+	x0, x1 := c.Long-dLon, c.Long+dLon
+	switch {
+	case x1-x0 >= LonMax-LonMin:
+		return []Rect{RectFrom(LonMin, y0, LonMax, y1)}
+	case x0 < LonMin:
+		return []Rect{
+			RectFrom(x0+LonMax-LonMin, y0, LonMax, y1),
+			RectFrom(LonMin, y0, x1, y1),
+		}
+	case x1 > LonMax:
+		return []Rect{
+			RectFrom(x0, y0, LonMax, y1),
+			RectFrom(LonMin, y0, x1-(LonMax-LonMin), y1),
+		}
+	}
+	return []Rect{RectFrom(x0, y0, x1, y1)}
+}
+
 func RectPointSize(x0, y0, w, h float64) Rect {
 	return Rect{x0, y0, x0 + w, y0 + h}
 }
@@ -14,3 +14,19 @@ func TestPointsAndRect(t *testing.T) {
 	assert.True(t, inside.In(bounds))
 	assert.False(t, outside.In(bounds))
 }
+
+func TestRectsAroundAntimeridian(t *testing.T) {
+	for _, center := range []gps.Coordinates{{Lat: -16.7, Long: 179.9}, {Lat: -16.7, Long: -179.9}} {
+		rects := gps.RectsAround(center, 30000)
+		if assert.Equal(t, 2, len(rects), "%v", center) {
+			assert.Equal(t, gps.LonMax, rects[0].X1())
+			assert.Equal(t, gps.LonMin, rects[1].X0())
+			// Both sides extend by the same distance from the center
+			east := gps.Coordinates{Lat: center.Lat, Long: rects[1].X1()}
+			west := gps.Coordinates{Lat: center.Lat, Long: rects[0].X0()}
+			assert.InDelta(t, center.DistanceTo(&east), center.DistanceTo(&west), 1)
+		}
+	}
+	assert.Equal(t, 1, len(gps.RectsAround(gps.Coordinates{Lat: -16.7, Long: 170}, 30000)))
+	assert.Equal(t, 1, len(gps.RectsAround(gps.Coordinates{Lat: 90, Long: 179.9}, 30000)))
+}
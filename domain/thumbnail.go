@@ -11,6 +11,11 @@ var (
 	Small  = ThumbSize{120, "S"}
 	Medium = ThumbSize{427, "M"}
 	Large  = ThumbSize{640, "L"}
+	// Preview is large enough to fit most screens
+	Preview = ThumbSize{1920, "P"}
+
+	// ThumbSizes are all sizes of thumbs, from smallest to largest
+	ThumbSizes = []ThumbSize{Small, Medium, Large, Preview}
 )
 
 type ThumbSize struct {
@@ -18,6 +23,22 @@ type ThumbSize struct {
 	Name  string
 }
 
+// ThumbFormats returns the formats in which thumbs can be encoded, the first
+// one is the default
+func ThumbFormats() []Format {
+	return []Format{JPEG, WEBP}
+}
+
+// ThumbSizeFromName returns the thumb size with the given name
+func ThumbSizeFromName(name string) (ThumbSize, bool) {
+	for _, size := range ThumbSizes {
+		if size.Name == name {
+			return size, true
+		}
+	}
+	return ThumbSize{}, false
+}
+
 func (size ThumbSize) BoundsOf(img image.Rectangle) image.Rectangle {
 	if img.Dx() > img.Dy() {
 		return image.Rect(0, 0, size.width, (size.width*img.Dy())/img.Dx())
@@ -81,3 +81,13 @@ func saveImage(img image.Image, path string) error {
 	jpeg.Encode(out, img, &jpeg.Options{Quality: 75})
 	return nil
 }
+
+func TestThumbSizeFromName(t *testing.T) {
+	for _, size := range ThumbSizes {
+		actual, ok := ThumbSizeFromName(size.Name)
+		assert.True(t, ok, "size %s", size.Name)
+		assert.Equal(t, size, actual)
+	}
+	_, ok := ThumbSizeFromName("XL")
+	assert.False(t, ok)
+}
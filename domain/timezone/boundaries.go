@@ -0,0 +1,180 @@
+package timezone
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+)
+
+// Boundaries are the polygons covering each timezone, as published by the
+// timezone-boundary-builder project (https://github.com/evansiroky/timezone-boundary-builder)
+type Boundaries struct {
+	zones []zoneBoundary
+}
+
+type zoneBoundary struct {
+	name     string
+	bounds   gps.Rect
+	polygons []polygon
+}
+
+// polygon is an outer ring followed by the rings of its holes, points are
+// longitude/latitude pairs
+type polygon struct {
+	bounds gps.Rect
+	rings  [][][2]float64
+}
+
+type featureCollection struct {
+	Features []struct {
+		Properties struct {
+			TZID string `json:"tzid"`
+		} `json:"properties"`
+		Geometry struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+	} `json:"features"`
+}
+
+// OpenBoundaries reads the timezone boundaries from the given GeoJSON file,
+// or from the first GeoJSON file of the given zip archive as released by the
+// timezone-boundary-builder project
+func OpenBoundaries(path string) (*Boundaries, error) {
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		archive, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer archive.Close()
+		for _, f := range archive.File {
+			if ext := strings.ToLower(filepath.Ext(f.Name)); ext != ".json" && ext != ".geojson" {
+				continue
+			}
+			in, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer in.Close()
+			return ReadBoundaries(in)
+		}
+		return nil, fmt.Errorf("No GeoJSON file found in %s", path)
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	return ReadBoundaries(in)
+}
+
+// ReadBoundaries reads the timezone boundaries from a GeoJSON feature
+// collection whose features have the IANA name of their zone in the tzid
+// property
+func ReadBoundaries(in io.Reader) (*Boundaries, error) {
+	var collection featureCollection
+	if err := json.NewDecoder(in).Decode(&collection); err != nil {
+		return nil, err
+	}
+	b := &Boundaries{}
+	for _, f := range collection.Features {
+		if f.Properties.TZID == "" {
+			continue
+		}
+		var polygons [][][][2]float64
+		switch f.Geometry.Type {
+		case "Polygon":
+			var p [][][2]float64
+			if err := json.Unmarshal(f.Geometry.Coordinates, &p); err != nil {
+				return nil, fmt.Errorf("%s: %s", f.Properties.TZID, err)
+			}
+			polygons = append(polygons, p)
+		case "MultiPolygon":
+			if err := json.Unmarshal(f.Geometry.Coordinates, &polygons); err != nil {
+				return nil, fmt.Errorf("%s: %s", f.Properties.TZID, err)
+			}
+		default:
+			return nil, fmt.Errorf("%s: unsupported geometry %s", f.Properties.TZID, f.Geometry.Type)
+		}
+		zone := zoneBoundary{name: f.Properties.TZID, bounds: emptyBounds()}
+		for _, rings := range polygons {
+			if len(rings) == 0 {
+				continue
+			}
+			p := polygon{bounds: boundsOf(rings[0]), rings: rings}
+			zone.bounds = union(zone.bounds, p.bounds)
+			zone.polygons = append(zone.polygons, p)
+		}
+		if len(zone.polygons) > 0 {
+			b.zones = append(b.zones, zone)
+		}
+	}
+	if len(b.zones) == 0 {
+		return nil, fmt.Errorf("No timezone boundaries found")
+	}
+	return b, nil
+}
+
+// ZoneNameAt returns the IANA name of the timezone whose boundaries contain
+// the given location, found is false outside all zones (e.g. at sea)
+func (b *Boundaries) ZoneNameAt(c gps.Coordinates) (name string, found bool) {
+	for _, zone := range b.zones {
+		if !inside(zone.bounds, c) {
+			continue
+		}
+		for _, p := range zone.polygons {
+			if inside(p.bounds, c) && p.contains(c) {
+				return zone.name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// contains tells whether the given location is inside the outer ring of the
+// polygon but not inside one of its holes
+func (p polygon) contains(c gps.Coordinates) bool {
+	in := false
+	for _, ring := range p.rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			if (a[1] > c.Lat) != (b[1] > c.Lat) &&
+				c.Long < (b[0]-a[0])*(c.Lat-a[1])/(b[1]-a[1])+a[0] {
+				in = !in
+			}
+		}
+	}
+	return in
+}
+
+func inside(r gps.Rect, c gps.Coordinates) bool {
+	return c.Long >= r.X0() && c.Long <= r.X1() && c.Lat >= r.Y0() && c.Lat <= r.Y1()
+}
+
+func emptyBounds() gps.Rect {
+	return gps.Rect{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+}
+
+func boundsOf(ring [][2]float64) gps.Rect {
+	bounds := emptyBounds()
+	for _, p := range ring {
+		bounds = union(bounds, gps.Rect{p[0], p[1], p[0], p[1]})
+	}
+	return bounds
+}
+
+func union(a, b gps.Rect) gps.Rect {
+	return gps.Rect{
+		math.Min(a[0], b[0]),
+		math.Min(a[1], b[1]),
+		math.Max(a[2], b[2]),
+		math.Max(a[3], b[3]),
+	}
+}
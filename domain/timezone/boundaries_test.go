@@ -0,0 +1,100 @@
+package timezone
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBoundaries are simplified boundaries of Portugal, Spain and France, the
+// Spanish enclave of Llívia being a hole in France
+const testBoundaries = `{"type":"FeatureCollection","features":[
+{"type":"Feature","properties":{"tzid":"Europe/Lisbon"},"geometry":{"type":"Polygon","coordinates":[
+	[[-9.5,37],[-7.05,37],[-7.05,42.15],[-9.5,42.15],[-9.5,37]]]}},
+{"type":"Feature","properties":{"tzid":"Europe/Madrid"},"geometry":{"type":"MultiPolygon","coordinates":[
+	[[[-7.05,36],[3.3,36],[3.3,42.3],[-7.05,42.3],[-7.05,36]]],
+	[[[1.97,42.45],[2.0,42.45],[2.0,42.48],[1.97,42.48],[1.97,42.45]]]]}},
+{"type":"Feature","properties":{"tzid":"Europe/Paris"},"geometry":{"type":"Polygon","coordinates":[
+	[[-1.8,42.3],[8.2,42.3],[8.2,51.1],[-1.8,51.1],[-1.8,42.3]],
+	[[1.97,42.45],[2.0,42.45],[2.0,42.48],[1.97,42.48],[1.97,42.45]]]}}
+]}`
+
+func TestBoundariesZoneNameAt(t *testing.T) {
+	b, err := ReadBoundaries(strings.NewReader(testBoundaries))
+	require.NoError(t, err)
+	data := []struct {
+		name     string
+		lat, lon float64
+		zone     string
+	}{
+		{"Elvas outskirts", 38.88, -7.10, "Europe/Lisbon"},
+		{"Badajoz", 38.88, -6.97, "Europe/Madrid"},
+		{"Llívia", 42.465, 1.985, "Europe/Madrid"},
+		{"Targasonne", 42.5, 1.99, "Europe/Paris"},
+		{"Bay of Biscay", 45, -5, ""},
+	}
+	for _, d := range data {
+		name, found := b.ZoneNameAt(gps.Coordinates{Lat: d.lat, Long: d.lon})
+		assert.Equal(t, d.zone != "", found, d.name)
+		assert.Equal(t, d.zone, name, d.name)
+	}
+}
+
+func TestLocationWithBoundaries(t *testing.T) {
+	b, err := ReadBoundaries(strings.NewReader(testBoundaries))
+	require.NoError(t, err)
+	UseBoundaries(b)
+	defer UseBoundaries(nil)
+
+	// The nearest reference location is in Spain
+	loc, err := LocationAt(gps.Coordinates{Lat: 38.88, Long: -7.10})
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Lisbon", loc.String())
+	loc, err = LocationIn(gps.Coordinates{Lat: 38.88, Long: -7.10}, "es")
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Lisbon", loc.String())
+
+	loc, err = LocationAt(gps.Coordinates{Lat: 45, Long: -5})
+	require.NoError(t, err)
+	assert.Equal(t, "UTC+0", loc.String(), "Locations at sea must get the nautical zone")
+}
+
+func TestOpenBoundaries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timezones")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	plain := filepath.Join(dir, "combined.json")
+	require.NoError(t, ioutil.WriteFile(plain, []byte(testBoundaries), 0644))
+	archive := filepath.Join(dir, "timezones.geojson.zip")
+	f, err := os.Create(archive)
+	require.NoError(t, err)
+	w := zip.NewWriter(f)
+	entry, err := w.Create("combined.json")
+	require.NoError(t, err)
+	entry.Write([]byte(testBoundaries))
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	for _, path := range []string{plain, archive} {
+		b, err := OpenBoundaries(path)
+		if assert.NoError(t, err, path) {
+			name, _ := b.ZoneNameAt(gps.Coordinates{Lat: 48.86, Long: 2.35})
+			assert.Equal(t, "Europe/Paris", name, path)
+		}
+	}
+
+	_, err = OpenBoundaries(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+	_, err = ReadBoundaries(strings.NewReader(`{"type":"FeatureCollection","features":[]}`))
+	assert.Error(t, err)
+	_, err = ReadBoundaries(strings.NewReader(`{"features":[{"properties":{"tzid":"Europe/Paris"},"geometry":{"type":"Point","coordinates":[2.35,48.86]}}]}`))
+	assert.Error(t, err)
+}
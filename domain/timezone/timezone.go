@@ -0,0 +1,137 @@
+// Package timezone infers the timezone at a location. With the timezone
+// boundaries of the timezone-boundary-builder project installed by
+// UseBoundaries, the zone whose polygons contain the location is used and
+// locations outside all zones (i.e. at sea) get the nautical timezone derived
+// from their longitude.
+//
+// Without boundaries, an embedded offline dataset of reference locations is
+// used instead. It is coarse: the zone of the nearest reference location is
+// used, which may be the zone of the neighbouring country close to a border.
+// Once the country of a location is known, only the reference locations of
+// that country are considered, so that the zone is wrong at most close to a
+// border between two zones of the same country. Locations far away from any
+// reference location get the nautical timezone.
+package timezone
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+)
+
+// maxReferenceDistance is the maximum distance in meters from a reference
+// location for its zone to be used
+const maxReferenceDistance = 1500 * 1000
+
+var (
+	locations     = make(map[string]*time.Location)
+	locationsLock sync.Mutex
+
+	boundaries     *Boundaries
+	boundariesLock sync.RWMutex
+)
+
+// UseBoundaries makes the lookups use the given timezone boundaries instead of
+// the reference locations
+func UseBoundaries(b *Boundaries) {
+	boundariesLock.Lock()
+	defer boundariesLock.Unlock()
+	boundaries = b
+}
+
+func currentBoundaries() *Boundaries {
+	boundariesLock.RLock()
+	defer boundariesLock.RUnlock()
+	return boundaries
+}
+
+// ZoneNameAt returns the IANA name of the timezone at the given location,
+// found is false if the location is outside all zone boundaries or, without
+// boundaries, not near any reference location
+func ZoneNameAt(c gps.Coordinates) (name string, found bool) {
+	if b := currentBoundaries(); b != nil {
+		return b.ZoneNameAt(c)
+	}
+	return nearestZone(c, func(ref referenceLocation) bool { return true }, maxReferenceDistance)
+}
+
+// ZoneNameIn returns the IANA name of the timezone at the given location in
+// the given country. Without boundaries, it is the zone of the nearest
+// reference location of that country or, without reference location in the
+// country, the zone of the nearest reference location.
+func ZoneNameIn(c gps.Coordinates, country gps.CountryID) (name string, found bool) {
+	if b := currentBoundaries(); b != nil {
+		return b.ZoneNameAt(c)
+	}
+	country = gps.CountryIDFromString(string(country))
+	if name, found := nearestZone(c, func(ref referenceLocation) bool {
+		return zoneCountries[ref.zone] == country
+	}, math.MaxFloat64); found {
+		return name, true
+	}
+	return ZoneNameAt(c)
+}
+
+func nearestZone(c gps.Coordinates, accept func(referenceLocation) bool, maxDistance float64) (string, bool) {
+	best, bestDistance := -1, math.MaxFloat64
+	for i, ref := range referenceLocations {
+		if !accept(ref) {
+			continue
+		}
+		d := c.DistanceTo(&gps.Coordinates{Lat: ref.lat, Long: ref.lon})
+		if d < bestDistance {
+			best, bestDistance = i, d
+		}
+	}
+	if best < 0 || bestDistance > maxDistance {
+		return "", false
+	}
+	return referenceLocations[best].zone, true
+}
+
+// LocationAt returns the timezone at the given location. For locations at sea,
+// a fixed zone with the nautical offset is returned
+func LocationAt(c gps.Coordinates) (*time.Location, error) {
+	if !c.IsValid() {
+		return nil, gps.InvalidGPSCoordinates
+	}
+	name, found := ZoneNameAt(c)
+	if !found {
+		return nauticalZone(c.Long), nil
+	}
+	return load(name)
+}
+
+// LocationIn returns the timezone at the given location in the given country
+func LocationIn(c gps.Coordinates, country gps.CountryID) (*time.Location, error) {
+	if !c.IsValid() {
+		return nil, gps.InvalidGPSCoordinates
+	}
+	name, found := ZoneNameIn(c, country)
+	if !found {
+		return nauticalZone(c.Long), nil
+	}
+	return load(name)
+}
+
+func load(name string) (*time.Location, error) {
+	locationsLock.Lock()
+	defer locationsLock.Unlock()
+	if loc, cached := locations[name]; cached {
+		return loc, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations[name] = loc
+	return loc, nil
+}
+
+func nauticalZone(lon float64) *time.Location {
+	hours := int(math.Round(lon / 15))
+	return time.FixedZone(fmt.Sprintf("UTC%+d", hours), hours*3600)
+}
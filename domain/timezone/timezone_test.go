@@ -0,0 +1,62 @@
+package timezone
+
+import (
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocationAt(t *testing.T) {
+	data := []struct {
+		lat, lon float64
+		zone     string
+	}{
+		{48.8584, 2.2945, "Europe/Paris"},
+		{40.7484, -73.9857, "America/New_York"},
+		{-33.8568, 151.2153, "Australia/Sydney"},
+		{35.6586, 139.7454, "Asia/Tokyo"},
+		{36.1069, -112.1129, "America/Phoenix"},
+		{30.0, -40.0, "UTC-3"},
+	}
+	for _, d := range data {
+		loc, err := LocationAt(gps.Coordinates{Lat: d.lat, Long: d.lon})
+		if assert.NoError(t, err) {
+			assert.Equal(t, d.zone, loc.String(), "Bad zone for [%f;%f]", d.lat, d.lon)
+		}
+	}
+}
+
+func TestLocationIn(t *testing.T) {
+	data := []struct {
+		name     string
+		lat, lon float64
+		country  gps.CountryID
+		zone     string
+	}{
+		{"Elvas", 38.88, -7.16, "PT", "Europe/Lisbon"},
+		{"Valença", 42.03, -8.64, "pt", "Europe/Lisbon"},
+		{"Badajoz", 38.88, -6.97, "es", "Europe/Madrid"},
+		{"Tui", 42.05, -8.64, "es", "Europe/Madrid"},
+		{"Phoenix", 33.45, -112.07, "us", "America/Phoenix"},
+		{"Unknown country", 48.8584, 2.2945, "zz", "Europe/Paris"},
+	}
+	for _, d := range data {
+		loc, err := LocationIn(gps.Coordinates{Lat: d.lat, Long: d.lon}, d.country)
+		if assert.NoError(t, err) {
+			assert.Equal(t, d.zone, loc.String(), d.name)
+		}
+	}
+}
+
+func TestReferenceZonesExist(t *testing.T) {
+	for _, ref := range referenceLocations {
+		if _, err := time.LoadLocation(ref.zone); err != nil {
+			t.Errorf("Unknown zone %s: %s", ref.zone, err)
+		}
+		if _, found := zoneCountries[ref.zone]; !found {
+			t.Errorf("Zone %s has no country", ref.zone)
+		}
+	}
+}
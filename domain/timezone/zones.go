@@ -0,0 +1,807 @@
+package timezone
+
+import "bitbucket.org/kleinnic74/photos/domain/gps"
+
+type referenceLocation struct {
+	lat, lon float64
+	zone     string
+}
+
+// referenceLocations lists well known places together with their timezone.
+// Border regions are covered more densely to reduce wrong guesses
+var referenceLocations = []referenceLocation{
+	// Europe
+	{38.72, -9.14, "Europe/Lisbon"},
+	{41.15, -8.61, "Europe/Lisbon"},
+	{37.02, -7.93, "Europe/Lisbon"},
+	{32.65, -16.91, "Atlantic/Madeira"},
+	{37.74, -25.67, "Atlantic/Azores"},
+	{40.42, -3.70, "Europe/Madrid"},
+	{41.39, 2.17, "Europe/Madrid"},
+	{37.39, -5.98, "Europe/Madrid"},
+	{42.24, -8.72, "Europe/Madrid"},
+	{38.88, -6.97, "Europe/Madrid"},
+	{40.97, -5.66, "Europe/Madrid"},
+	{43.26, -2.93, "Europe/Madrid"},
+	{39.47, -0.38, "Europe/Madrid"},
+	{39.57, 2.65, "Europe/Madrid"},
+	{28.12, -15.43, "Atlantic/Canary"},
+	{28.46, -16.25, "Atlantic/Canary"},
+	{48.86, 2.35, "Europe/Paris"},
+	{45.76, 4.84, "Europe/Paris"},
+	{43.30, 5.37, "Europe/Paris"},
+	{44.84, -0.58, "Europe/Paris"},
+	{48.39, -4.49, "Europe/Paris"},
+	{48.58, 7.75, "Europe/Paris"},
+	{50.63, 3.06, "Europe/Paris"},
+	{43.60, 1.44, "Europe/Paris"},
+	{43.70, 7.27, "Europe/Paris"},
+	{41.93, 8.74, "Europe/Paris"},
+	{51.51, -0.13, "Europe/London"},
+	{53.48, -2.24, "Europe/London"},
+	{55.95, -3.19, "Europe/London"},
+	{57.48, -4.22, "Europe/London"},
+	{51.48, -3.18, "Europe/London"},
+	{50.38, -4.14, "Europe/London"},
+	{54.60, -5.93, "Europe/London"},
+	{58.98, -2.96, "Europe/London"},
+	{53.35, -6.26, "Europe/Dublin"},
+	{51.90, -8.47, "Europe/Dublin"},
+	{53.27, -9.05, "Europe/Dublin"},
+	{54.65, -8.11, "Europe/Dublin"},
+	{50.85, 4.35, "Europe/Brussels"},
+	{51.22, 4.40, "Europe/Brussels"},
+	{50.63, 5.57, "Europe/Brussels"},
+	{52.37, 4.90, "Europe/Amsterdam"},
+	{53.22, 6.57, "Europe/Amsterdam"},
+	{50.85, 5.69, "Europe/Amsterdam"},
+	{49.61, 6.13, "Europe/Luxembourg"},
+	{52.52, 13.40, "Europe/Berlin"},
+	{53.55, 9.99, "Europe/Berlin"},
+	{48.14, 11.58, "Europe/Berlin"},
+	{50.94, 6.96, "Europe/Berlin"},
+	{50.11, 8.68, "Europe/Berlin"},
+	{51.05, 13.74, "Europe/Berlin"},
+	{48.78, 9.18, "Europe/Berlin"},
+	{47.99, 7.85, "Europe/Berlin"},
+	{54.09, 12.14, "Europe/Berlin"},
+	{47.37, 8.54, "Europe/Zurich"},
+	{46.20, 6.14, "Europe/Zurich"},
+	{46.95, 7.45, "Europe/Zurich"},
+	{46.00, 8.95, "Europe/Zurich"},
+	{48.21, 16.37, "Europe/Vienna"},
+	{47.27, 11.39, "Europe/Vienna"},
+	{47.07, 15.44, "Europe/Vienna"},
+	{47.81, 13.06, "Europe/Vienna"},
+	{41.90, 12.50, "Europe/Rome"},
+	{45.46, 9.19, "Europe/Rome"},
+	{40.85, 14.27, "Europe/Rome"},
+	{38.12, 13.36, "Europe/Rome"},
+	{39.22, 9.11, "Europe/Rome"},
+	{45.44, 12.33, "Europe/Rome"},
+	{41.12, 16.87, "Europe/Rome"},
+	{43.77, 11.26, "Europe/Rome"},
+	{35.90, 14.51, "Europe/Malta"},
+	{55.68, 12.57, "Europe/Copenhagen"},
+	{56.16, 10.20, "Europe/Copenhagen"},
+	{59.91, 10.75, "Europe/Oslo"},
+	{60.39, 5.32, "Europe/Oslo"},
+	{63.43, 10.40, "Europe/Oslo"},
+	{69.65, 18.96, "Europe/Oslo"},
+	{59.33, 18.07, "Europe/Stockholm"},
+	{57.71, 11.97, "Europe/Stockholm"},
+	{55.60, 13.00, "Europe/Stockholm"},
+	{63.83, 20.26, "Europe/Stockholm"},
+	{67.86, 20.23, "Europe/Stockholm"},
+	{60.17, 24.94, "Europe/Helsinki"},
+	{65.01, 25.47, "Europe/Helsinki"},
+	{66.50, 25.73, "Europe/Helsinki"},
+	{60.45, 22.27, "Europe/Helsinki"},
+	{59.44, 24.75, "Europe/Tallinn"},
+	{56.95, 24.11, "Europe/Riga"},
+	{54.69, 25.28, "Europe/Vilnius"},
+	{52.23, 21.01, "Europe/Warsaw"},
+	{50.06, 19.94, "Europe/Warsaw"},
+	{54.35, 18.65, "Europe/Warsaw"},
+	{51.11, 17.04, "Europe/Warsaw"},
+	{53.43, 14.55, "Europe/Warsaw"},
+	{50.08, 14.44, "Europe/Prague"},
+	{49.20, 16.61, "Europe/Prague"},
+	{48.15, 17.11, "Europe/Bratislava"},
+	{48.72, 21.26, "Europe/Bratislava"},
+	{47.50, 19.04, "Europe/Budapest"},
+	{47.53, 21.63, "Europe/Budapest"},
+	{46.06, 14.51, "Europe/Ljubljana"},
+	{45.81, 15.98, "Europe/Zagreb"},
+	{43.51, 16.44, "Europe/Zagreb"},
+	{43.86, 18.41, "Europe/Sarajevo"},
+	{44.79, 20.45, "Europe/Belgrade"},
+	{42.67, 21.17, "Europe/Belgrade"},
+	{42.44, 19.26, "Europe/Podgorica"},
+	{42.00, 21.43, "Europe/Skopje"},
+	{41.33, 19.82, "Europe/Tirane"},
+	{44.43, 26.10, "Europe/Bucharest"},
+	{46.77, 23.60, "Europe/Bucharest"},
+	{42.70, 23.32, "Europe/Sofia"},
+	{43.21, 27.91, "Europe/Sofia"},
+	{37.98, 23.73, "Europe/Athens"},
+	{40.64, 22.94, "Europe/Athens"},
+	{35.34, 25.13, "Europe/Athens"},
+	{36.43, 28.22, "Europe/Athens"},
+	{41.01, 28.98, "Europe/Istanbul"},
+	{39.93, 32.86, "Europe/Istanbul"},
+	{38.42, 27.14, "Europe/Istanbul"},
+	{36.90, 30.70, "Europe/Istanbul"},
+	{39.90, 41.27, "Europe/Istanbul"},
+	{37.91, 40.24, "Europe/Istanbul"},
+	{35.17, 33.36, "Asia/Nicosia"},
+	{47.01, 28.86, "Europe/Chisinau"},
+	{50.45, 30.52, "Europe/Kiev"},
+	{49.84, 24.03, "Europe/Kiev"},
+	{46.48, 30.72, "Europe/Kiev"},
+	{49.99, 36.23, "Europe/Kiev"},
+	{53.90, 27.56, "Europe/Minsk"},
+	{55.76, 37.62, "Europe/Moscow"},
+	{59.93, 30.36, "Europe/Moscow"},
+	{55.79, 49.12, "Europe/Moscow"},
+	{68.97, 33.09, "Europe/Moscow"},
+	{54.71, 20.51, "Europe/Kaliningrad"},
+	{53.20, 50.15, "Europe/Samara"},
+	{48.71, 44.51, "Europe/Volgograd"},
+	{64.15, -21.94, "Atlantic/Reykjavik"},
+	{65.68, -18.09, "Atlantic/Reykjavik"},
+	{62.01, -6.77, "Atlantic/Faroe"},
+	// Russia (Asia)
+	{56.84, 60.60, "Asia/Yekaterinburg"},
+	{54.99, 73.37, "Asia/Omsk"},
+	{55.03, 82.92, "Asia/Novosibirsk"},
+	{56.01, 92.89, "Asia/Krasnoyarsk"},
+	{69.35, 88.20, "Asia/Krasnoyarsk"},
+	{52.29, 104.28, "Asia/Irkutsk"},
+	{62.03, 129.73, "Asia/Yakutsk"},
+	{43.12, 131.89, "Asia/Vladivostok"},
+	{48.48, 135.08, "Asia/Vladivostok"},
+	{59.56, 150.81, "Asia/Magadan"},
+	{53.02, 158.65, "Asia/Kamchatka"},
+	{64.73, 177.51, "Asia/Anadyr"},
+	// Middle East
+	{31.77, 35.21, "Asia/Jerusalem"},
+	{32.09, 34.78, "Asia/Jerusalem"},
+	{31.95, 35.93, "Asia/Amman"},
+	{33.89, 35.50, "Asia/Beirut"},
+	{33.51, 36.29, "Asia/Damascus"},
+	{33.31, 44.36, "Asia/Baghdad"},
+	{36.19, 44.01, "Asia/Baghdad"},
+	{35.69, 51.39, "Asia/Tehran"},
+	{36.30, 59.60, "Asia/Tehran"},
+	{29.59, 52.58, "Asia/Tehran"},
+	{24.71, 46.68, "Asia/Riyadh"},
+	{21.49, 39.19, "Asia/Riyadh"},
+	{29.38, 47.99, "Asia/Kuwait"},
+	{26.23, 50.59, "Asia/Bahrain"},
+	{25.29, 51.53, "Asia/Qatar"},
+	{25.20, 55.27, "Asia/Dubai"},
+	{23.59, 58.41, "Asia/Muscat"},
+	{15.37, 44.19, "Asia/Aden"},
+	// Africa
+	{30.04, 31.24, "Africa/Cairo"},
+	{24.09, 32.90, "Africa/Cairo"},
+	{32.89, 13.19, "Africa/Tripoli"},
+	{32.12, 20.09, "Africa/Tripoli"},
+	{36.81, 10.18, "Africa/Tunis"},
+	{36.75, 3.06, "Africa/Algiers"},
+	{22.79, 5.52, "Africa/Algiers"},
+	{33.57, -7.59, "Africa/Casablanca"},
+	{31.63, -8.01, "Africa/Casablanca"},
+	{27.15, -13.20, "Africa/El_Aaiun"},
+	{14.72, -17.47, "Africa/Dakar"},
+	{18.08, -15.98, "Africa/Nouakchott"},
+	{12.64, -8.00, "Africa/Bamako"},
+	{16.77, -3.01, "Africa/Bamako"},
+	{5.36, -4.01, "Africa/Abidjan"},
+	{5.60, -0.19, "Africa/Accra"},
+	{6.52, 3.38, "Africa/Lagos"},
+	{9.08, 7.40, "Africa/Lagos"},
+	{13.51, 2.11, "Africa/Niamey"},
+	{12.13, 15.06, "Africa/Ndjamena"},
+	{15.50, 32.56, "Africa/Khartoum"},
+	{4.86, 31.57, "Africa/Juba"},
+	{9.03, 38.74, "Africa/Addis_Ababa"},
+	{15.32, 38.93, "Africa/Asmara"},
+	{11.59, 43.15, "Africa/Djibouti"},
+	{2.05, 45.32, "Africa/Mogadishu"},
+	{-1.29, 36.82, "Africa/Nairobi"},
+	{0.35, 32.58, "Africa/Kampala"},
+	{-1.95, 30.06, "Africa/Kigali"},
+	{-6.79, 39.21, "Africa/Dar_es_Salaam"},
+	{-4.44, 15.27, "Africa/Kinshasa"},
+	{-4.26, 15.24, "Africa/Brazzaville"},
+	{-11.66, 27.48, "Africa/Lubumbashi"},
+	{0.52, 25.19, "Africa/Lubumbashi"},
+	{-8.84, 13.23, "Africa/Luanda"},
+	{-15.39, 28.32, "Africa/Lusaka"},
+	{-17.83, 31.05, "Africa/Harare"},
+	{-13.96, 33.77, "Africa/Blantyre"},
+	{-25.97, 32.57, "Africa/Maputo"},
+	{-22.56, 17.08, "Africa/Windhoek"},
+	{-24.63, 25.92, "Africa/Gaborone"},
+	{-26.20, 28.05, "Africa/Johannesburg"},
+	{-33.92, 18.42, "Africa/Johannesburg"},
+	{-29.86, 31.02, "Africa/Johannesburg"},
+	{-29.31, 27.48, "Africa/Maseru"},
+	{-26.31, 31.14, "Africa/Mbabane"},
+	{0.42, 9.47, "Africa/Libreville"},
+	{4.05, 9.77, "Africa/Douala"},
+	{3.85, 11.50, "Africa/Douala"},
+	{4.39, 18.56, "Africa/Bangui"},
+	{9.64, -13.58, "Africa/Conakry"},
+	{8.47, -13.23, "Africa/Freetown"},
+	{6.30, -10.80, "Africa/Monrovia"},
+	{12.37, -1.52, "Africa/Ouagadougou"},
+	{6.13, 1.22, "Africa/Lome"},
+	{6.37, 2.39, "Africa/Porto-Novo"},
+	{14.93, -23.51, "Atlantic/Cape_Verde"},
+	{-18.88, 47.51, "Indian/Antananarivo"},
+	{-20.16, 57.50, "Indian/Mauritius"},
+	{-20.88, 55.45, "Indian/Reunion"},
+	{-4.62, 55.45, "Indian/Mahe"},
+	// Asia
+	{34.56, 69.21, "Asia/Kabul"},
+	{24.86, 67.01, "Asia/Karachi"},
+	{31.55, 74.34, "Asia/Karachi"},
+	{33.68, 73.05, "Asia/Karachi"},
+	{28.61, 77.21, "Asia/Kolkata"},
+	{19.08, 72.88, "Asia/Kolkata"},
+	{22.57, 88.36, "Asia/Kolkata"},
+	{13.08, 80.27, "Asia/Kolkata"},
+	{12.97, 77.59, "Asia/Kolkata"},
+	{26.14, 91.74, "Asia/Kolkata"},
+	{34.08, 74.80, "Asia/Kolkata"},
+	{27.72, 85.32, "Asia/Kathmandu"},
+	{27.47, 89.64, "Asia/Thimphu"},
+	{23.81, 90.41, "Asia/Dhaka"},
+	{6.93, 79.86, "Asia/Colombo"},
+	{4.18, 73.51, "Indian/Maldives"},
+	{41.30, 69.24, "Asia/Tashkent"},
+	{39.65, 66.96, "Asia/Samarkand"},
+	{43.24, 76.89, "Asia/Almaty"},
+	{51.17, 71.45, "Asia/Almaty"},
+	{50.28, 57.17, "Asia/Aqtobe"},
+	{42.87, 74.59, "Asia/Bishkek"},
+	{38.56, 68.79, "Asia/Dushanbe"},
+	{37.96, 58.33, "Asia/Ashgabat"},
+	{40.41, 49.87, "Asia/Baku"},
+	{41.72, 44.78, "Asia/Tbilisi"},
+	{40.18, 44.51, "Asia/Yerevan"},
+	{16.87, 96.20, "Asia/Yangon"},
+	{13.76, 100.50, "Asia/Bangkok"},
+	{18.79, 98.98, "Asia/Bangkok"},
+	{7.88, 98.39, "Asia/Bangkok"},
+	{17.98, 102.63, "Asia/Vientiane"},
+	{11.56, 104.92, "Asia/Phnom_Penh"},
+	{21.03, 105.85, "Asia/Ho_Chi_Minh"},
+	{10.82, 106.63, "Asia/Ho_Chi_Minh"},
+	{3.14, 101.69, "Asia/Kuala_Lumpur"},
+	{1.55, 110.36, "Asia/Kuching"},
+	{5.98, 116.07, "Asia/Kuching"},
+	{1.35, 103.82, "Asia/Singapore"},
+	{4.90, 114.94, "Asia/Brunei"},
+	{-6.21, 106.85, "Asia/Jakarta"},
+	{3.60, 98.67, "Asia/Jakarta"},
+	{-7.25, 112.75, "Asia/Jakarta"},
+	{-0.03, 109.33, "Asia/Pontianak"},
+	{-8.65, 115.22, "Asia/Makassar"},
+	{-5.15, 119.43, "Asia/Makassar"},
+	{-1.27, 116.83, "Asia/Makassar"},
+	{-2.53, 140.72, "Asia/Jayapura"},
+	{-3.70, 128.18, "Asia/Jayapura"},
+	{-8.56, 125.56, "Asia/Dili"},
+	{14.60, 120.98, "Asia/Manila"},
+	{7.19, 125.46, "Asia/Manila"},
+	{10.32, 123.89, "Asia/Manila"},
+	{39.90, 116.41, "Asia/Shanghai"},
+	{31.23, 121.47, "Asia/Shanghai"},
+	{23.13, 113.26, "Asia/Shanghai"},
+	{30.57, 104.07, "Asia/Shanghai"},
+	{29.65, 91.17, "Asia/Shanghai"},
+	{45.80, 126.53, "Asia/Shanghai"},
+	{25.04, 102.71, "Asia/Shanghai"},
+	{36.06, 103.83, "Asia/Shanghai"},
+	{43.83, 87.62, "Asia/Urumqi"},
+	{39.47, 75.99, "Asia/Urumqi"},
+	{22.32, 114.17, "Asia/Hong_Kong"},
+	{22.20, 113.54, "Asia/Macau"},
+	{25.03, 121.57, "Asia/Taipei"},
+	{47.89, 106.91, "Asia/Ulaanbaatar"},
+	{48.01, 91.64, "Asia/Hovd"},
+	{37.57, 126.98, "Asia/Seoul"},
+	{35.18, 129.08, "Asia/Seoul"},
+	{39.04, 125.76, "Asia/Pyongyang"},
+	{35.68, 139.69, "Asia/Tokyo"},
+	{34.69, 135.50, "Asia/Tokyo"},
+	{43.06, 141.35, "Asia/Tokyo"},
+	{33.59, 130.40, "Asia/Tokyo"},
+	{26.21, 127.68, "Asia/Tokyo"},
+	// Oceania
+	{-33.87, 151.21, "Australia/Sydney"},
+	{-35.28, 149.13, "Australia/Sydney"},
+	{-37.81, 144.96, "Australia/Melbourne"},
+	{-42.88, 147.33, "Australia/Hobart"},
+	{-27.47, 153.03, "Australia/Brisbane"},
+	{-16.92, 145.77, "Australia/Brisbane"},
+	{-19.26, 146.82, "Australia/Brisbane"},
+	{-20.73, 139.49, "Australia/Brisbane"},
+	{-34.93, 138.60, "Australia/Adelaide"},
+	{-12.46, 130.84, "Australia/Darwin"},
+	{-23.70, 133.88, "Australia/Darwin"},
+	{-31.95, 141.45, "Australia/Broken_Hill"},
+	{-31.95, 115.86, "Australia/Perth"},
+	{-17.96, 122.24, "Australia/Perth"},
+	{-30.75, 121.47, "Australia/Perth"},
+	{-36.85, 174.76, "Pacific/Auckland"},
+	{-41.29, 174.78, "Pacific/Auckland"},
+	{-43.53, 172.64, "Pacific/Auckland"},
+	{-45.03, 168.66, "Pacific/Auckland"},
+	{-43.95, -176.56, "Pacific/Chatham"},
+	{-9.44, 147.18, "Pacific/Port_Moresby"},
+	{-9.43, 159.95, "Pacific/Guadalcanal"},
+	{-22.27, 166.44, "Pacific/Noumea"},
+	{-17.73, 168.32, "Pacific/Efate"},
+	{-18.14, 178.44, "Pacific/Fiji"},
+	{-13.83, -171.76, "Pacific/Apia"},
+	{-14.28, -170.70, "Pacific/Pago_Pago"},
+	{-21.14, -175.20, "Pacific/Tongatapu"},
+	{-17.54, -149.57, "Pacific/Tahiti"},
+	{-21.21, -159.78, "Pacific/Rarotonga"},
+	{21.31, -157.86, "Pacific/Honolulu"},
+	{19.72, -155.08, "Pacific/Honolulu"},
+	{13.44, 144.79, "Pacific/Guam"},
+	{7.09, 171.38, "Pacific/Majuro"},
+	{1.45, 172.98, "Pacific/Tarawa"},
+	// North America
+	{40.71, -74.01, "America/New_York"},
+	{42.36, -71.06, "America/New_York"},
+	{38.91, -77.04, "America/New_York"},
+	{33.75, -84.39, "America/New_York"},
+	{25.76, -80.19, "America/New_York"},
+	{28.54, -81.38, "America/New_York"},
+	{35.23, -80.84, "America/New_York"},
+	{40.44, -80.00, "America/New_York"},
+	{41.50, -81.69, "America/New_York"},
+	{39.96, -83.00, "America/New_York"},
+	{30.33, -81.66, "America/New_York"},
+	{30.44, -84.28, "America/New_York"},
+	{43.66, -70.26, "America/New_York"},
+	{42.89, -78.88, "America/New_York"},
+	{35.96, -83.92, "America/New_York"},
+	{42.33, -83.05, "America/Detroit"},
+	{39.77, -86.16, "America/Indiana/Indianapolis"},
+	{38.25, -85.76, "America/Kentucky/Louisville"},
+	{41.88, -87.63, "America/Chicago"},
+	{36.16, -86.78, "America/Chicago"},
+	{35.15, -90.05, "America/Chicago"},
+	{29.95, -90.07, "America/Chicago"},
+	{29.76, -95.37, "America/Chicago"},
+	{32.78, -96.80, "America/Chicago"},
+	{29.42, -98.49, "America/Chicago"},
+	{30.27, -97.74, "America/Chicago"},
+	{35.47, -97.52, "America/Chicago"},
+	{39.10, -94.58, "America/Chicago"},
+	{38.63, -90.20, "America/Chicago"},
+	{44.98, -93.27, "America/Chicago"},
+	{43.04, -87.91, "America/Chicago"},
+	{41.26, -95.93, "America/Chicago"},
+	{41.59, -93.62, "America/Chicago"},
+	{46.88, -96.79, "America/Chicago"},
+	{43.55, -96.73, "America/Chicago"},
+	{30.42, -87.22, "America/Chicago"},
+	{33.52, -86.80, "America/Chicago"},
+	{37.69, -97.34, "America/Chicago"},
+	{35.22, -101.83, "America/Chicago"},
+	{33.58, -101.86, "America/Chicago"},
+	{46.81, -100.78, "America/Chicago"},
+	{39.74, -104.99, "America/Denver"},
+	{40.76, -111.89, "America/Denver"},
+	{35.08, -106.65, "America/Denver"},
+	{31.76, -106.49, "America/Denver"},
+	{41.14, -104.82, "America/Denver"},
+	{45.78, -108.50, "America/Denver"},
+	{44.08, -103.23, "America/Denver"},
+	{44.43, -110.59, "America/Denver"},
+	{38.57, -109.55, "America/Denver"},
+	{43.62, -116.20, "America/Boise"},
+	{33.45, -112.07, "America/Phoenix"},
+	{32.22, -110.97, "America/Phoenix"},
+	{35.20, -111.65, "America/Phoenix"},
+	{34.05, -118.24, "America/Los_Angeles"},
+	{37.77, -122.42, "America/Los_Angeles"},
+	{32.72, -117.16, "America/Los_Angeles"},
+	{38.58, -121.49, "America/Los_Angeles"},
+	{36.17, -115.14, "America/Los_Angeles"},
+	{39.53, -119.81, "America/Los_Angeles"},
+	{45.52, -122.68, "America/Los_Angeles"},
+	{47.61, -122.33, "America/Los_Angeles"},
+	{47.66, -117.43, "America/Los_Angeles"},
+	{40.80, -124.16, "America/Los_Angeles"},
+	{36.74, -119.79, "America/Los_Angeles"},
+	{42.33, -122.87, "America/Los_Angeles"},
+	{61.22, -149.90, "America/Anchorage"},
+	{64.84, -147.72, "America/Anchorage"},
+	{58.30, -134.42, "America/Juneau"},
+	{64.50, -165.41, "America/Nome"},
+	{51.88, -176.66, "America/Adak"},
+	{43.65, -79.38, "America/Toronto"},
+	{45.42, -75.70, "America/Toronto"},
+	{45.50, -73.57, "America/Toronto"},
+	{46.81, -71.21, "America/Toronto"},
+	{46.49, -80.99, "America/Toronto"},
+	{48.38, -89.25, "America/Thunder_Bay"},
+	{44.65, -63.58, "America/Halifax"},
+	{46.09, -64.78, "America/Moncton"},
+	{47.56, -52.71, "America/St_Johns"},
+	{53.30, -60.33, "America/Goose_Bay"},
+	{49.90, -97.14, "America/Winnipeg"},
+	{50.45, -104.62, "America/Regina"},
+	{52.13, -106.67, "America/Regina"},
+	{51.05, -114.07, "America/Edmonton"},
+	{53.55, -113.49, "America/Edmonton"},
+	{49.28, -123.12, "America/Vancouver"},
+	{48.43, -123.37, "America/Vancouver"},
+	{49.89, -119.50, "America/Vancouver"},
+	{60.72, -135.06, "America/Whitehorse"},
+	{62.45, -114.37, "America/Yellowknife"},
+	{63.75, -68.52, "America/Iqaluit"},
+	{64.18, -51.72, "America/Godthab"},
+	{32.29, -64.78, "Atlantic/Bermuda"},
+	// Mexico, Central America and Caribbean
+	{19.43, -99.13, "America/Mexico_City"},
+	{20.66, -103.35, "America/Mexico_City"},
+	{17.07, -96.73, "America/Mexico_City"},
+	{25.69, -100.32, "America/Monterrey"},
+	{20.97, -89.62, "America/Merida"},
+	{21.16, -86.85, "America/Cancun"},
+	{28.63, -106.07, "America/Chihuahua"},
+	{29.07, -110.96, "America/Hermosillo"},
+	{32.51, -117.04, "America/Tijuana"},
+	{23.25, -106.41, "America/Mazatlan"},
+	{24.14, -110.31, "America/Mazatlan"},
+	{14.63, -90.51, "America/Guatemala"},
+	{17.25, -88.77, "America/Belize"},
+	{13.69, -89.22, "America/El_Salvador"},
+	{14.07, -87.19, "America/Tegucigalpa"},
+	{12.11, -86.24, "America/Managua"},
+	{9.93, -84.08, "America/Costa_Rica"},
+	{8.98, -79.52, "America/Panama"},
+	{23.11, -82.37, "America/Havana"},
+	{20.02, -75.82, "America/Havana"},
+	{18.02, -76.80, "America/Jamaica"},
+	{18.59, -72.31, "America/Port-au-Prince"},
+	{18.49, -69.93, "America/Santo_Domingo"},
+	{18.47, -66.11, "America/Puerto_Rico"},
+	{25.05, -77.36, "America/Nassau"},
+	{13.10, -59.61, "America/Barbados"},
+	{10.66, -61.51, "America/Port_of_Spain"},
+	{14.62, -61.06, "America/Martinique"},
+	{16.24, -61.53, "America/Guadeloupe"},
+	{12.11, -68.93, "America/Curacao"},
+	// South America
+	{4.71, -74.07, "America/Bogota"},
+	{6.24, -75.58, "America/Bogota"},
+	{10.48, -66.90, "America/Caracas"},
+	{-0.18, -78.47, "America/Guayaquil"},
+	{-2.17, -79.92, "America/Guayaquil"},
+	{-0.74, -90.31, "Pacific/Galapagos"},
+	{-12.05, -77.04, "America/Lima"},
+	{-13.53, -71.97, "America/Lima"},
+	{-16.49, -68.12, "America/La_Paz"},
+	{-17.81, -63.16, "America/La_Paz"},
+	{-33.45, -70.67, "America/Santiago"},
+	{-23.65, -70.40, "America/Santiago"},
+	{-53.16, -70.91, "America/Punta_Arenas"},
+	{-27.11, -109.35, "Pacific/Easter"},
+	{-34.60, -58.38, "America/Argentina/Buenos_Aires"},
+	{-31.42, -64.18, "America/Argentina/Cordoba"},
+	{-32.89, -68.85, "America/Argentina/Mendoza"},
+	{-24.79, -65.41, "America/Argentina/Salta"},
+	{-41.13, -71.31, "America/Argentina/Salta"},
+	{-54.80, -68.30, "America/Argentina/Ushuaia"},
+	{-34.90, -56.16, "America/Montevideo"},
+	{-25.26, -57.58, "America/Asuncion"},
+	{-23.55, -46.63, "America/Sao_Paulo"},
+	{-22.91, -43.17, "America/Sao_Paulo"},
+	{-15.79, -47.88, "America/Sao_Paulo"},
+	{-30.03, -51.23, "America/Sao_Paulo"},
+	{-12.97, -38.50, "America/Bahia"},
+	{-8.05, -34.88, "America/Recife"},
+	{-3.73, -38.53, "America/Fortaleza"},
+	{-1.46, -48.49, "America/Belem"},
+	{-3.12, -60.02, "America/Manaus"},
+	{-15.60, -56.10, "America/Cuiaba"},
+	{-20.47, -54.62, "America/Campo_Grande"},
+	{-8.76, -63.90, "America/Porto_Velho"},
+	{-9.97, -67.81, "America/Rio_Branco"},
+	{2.82, -60.67, "America/Boa_Vista"},
+	{6.80, -58.16, "America/Guyana"},
+	{5.85, -55.20, "America/Paramaribo"},
+	{4.92, -52.31, "America/Cayenne"},
+	{-51.70, -57.85, "Atlantic/Stanley"},
+}
+
+// zoneCountries maps the zones of the reference locations to the country
+// using them
+var zoneCountries = map[string]gps.CountryID{
+	"Africa/Abidjan":                 "ci",
+	"Africa/Accra":                   "gh",
+	"Africa/Addis_Ababa":             "et",
+	"Africa/Algiers":                 "dz",
+	"Africa/Asmara":                  "er",
+	"Africa/Bamako":                  "ml",
+	"Africa/Bangui":                  "cf",
+	"Africa/Blantyre":                "mw",
+	"Africa/Brazzaville":             "cg",
+	"Africa/Cairo":                   "eg",
+	"Africa/Casablanca":              "ma",
+	"Africa/Conakry":                 "gn",
+	"Africa/Dakar":                   "sn",
+	"Africa/Dar_es_Salaam":           "tz",
+	"Africa/Djibouti":                "dj",
+	"Africa/Douala":                  "cm",
+	"Africa/El_Aaiun":                "eh",
+	"Africa/Freetown":                "sl",
+	"Africa/Gaborone":                "bw",
+	"Africa/Harare":                  "zw",
+	"Africa/Johannesburg":            "za",
+	"Africa/Juba":                    "ss",
+	"Africa/Kampala":                 "ug",
+	"Africa/Khartoum":                "sd",
+	"Africa/Kigali":                  "rw",
+	"Africa/Kinshasa":                "cd",
+	"Africa/Lagos":                   "ng",
+	"Africa/Libreville":              "ga",
+	"Africa/Lome":                    "tg",
+	"Africa/Luanda":                  "ao",
+	"Africa/Lubumbashi":              "cd",
+	"Africa/Lusaka":                  "zm",
+	"Africa/Maputo":                  "mz",
+	"Africa/Maseru":                  "ls",
+	"Africa/Mbabane":                 "sz",
+	"Africa/Mogadishu":               "so",
+	"Africa/Monrovia":                "lr",
+	"Africa/Nairobi":                 "ke",
+	"Africa/Ndjamena":                "td",
+	"Africa/Niamey":                  "ne",
+	"Africa/Nouakchott":              "mr",
+	"Africa/Ouagadougou":             "bf",
+	"Africa/Porto-Novo":              "bj",
+	"Africa/Tripoli":                 "ly",
+	"Africa/Tunis":                   "tn",
+	"Africa/Windhoek":                "na",
+	"America/Adak":                   "us",
+	"America/Anchorage":              "us",
+	"America/Argentina/Buenos_Aires": "ar",
+	"America/Argentina/Cordoba":      "ar",
+	"America/Argentina/Mendoza":      "ar",
+	"America/Argentina/Salta":        "ar",
+	"America/Argentina/Ushuaia":      "ar",
+	"America/Asuncion":               "py",
+	"America/Bahia":                  "br",
+	"America/Barbados":               "bb",
+	"America/Belem":                  "br",
+	"America/Belize":                 "bz",
+	"America/Boa_Vista":              "br",
+	"America/Bogota":                 "co",
+	"America/Boise":                  "us",
+	"America/Campo_Grande":           "br",
+	"America/Cancun":                 "mx",
+	"America/Caracas":                "ve",
+	"America/Cayenne":                "gf",
+	"America/Chicago":                "us",
+	"America/Chihuahua":              "mx",
+	"America/Costa_Rica":             "cr",
+	"America/Cuiaba":                 "br",
+	"America/Curacao":                "cw",
+	"America/Denver":                 "us",
+	"America/Detroit":                "us",
+	"America/Edmonton":               "ca",
+	"America/El_Salvador":            "sv",
+	"America/Fortaleza":              "br",
+	"America/Godthab":                "gl",
+	"America/Goose_Bay":              "ca",
+	"America/Guadeloupe":             "gp",
+	"America/Guatemala":              "gt",
+	"America/Guayaquil":              "ec",
+	"America/Guyana":                 "gy",
+	"America/Halifax":                "ca",
+	"America/Havana":                 "cu",
+	"America/Hermosillo":             "mx",
+	"America/Indiana/Indianapolis":   "us",
+	"America/Iqaluit":                "ca",
+	"America/Jamaica":                "jm",
+	"America/Juneau":                 "us",
+	"America/Kentucky/Louisville":    "us",
+	"America/La_Paz":                 "bo",
+	"America/Lima":                   "pe",
+	"America/Los_Angeles":            "us",
+	"America/Managua":                "ni",
+	"America/Manaus":                 "br",
+	"America/Martinique":             "mq",
+	"America/Mazatlan":               "mx",
+	"America/Merida":                 "mx",
+	"America/Mexico_City":            "mx",
+	"America/Moncton":                "ca",
+	"America/Monterrey":              "mx",
+	"America/Montevideo":             "uy",
+	"America/Nassau":                 "bs",
+	"America/New_York":               "us",
+	"America/Nome":                   "us",
+	"America/Panama":                 "pa",
+	"America/Paramaribo":             "sr",
+	"America/Phoenix":                "us",
+	"America/Port-au-Prince":         "ht",
+	"America/Port_of_Spain":          "tt",
+	"America/Porto_Velho":            "br",
+	"America/Puerto_Rico":            "pr",
+	"America/Punta_Arenas":           "cl",
+	"America/Recife":                 "br",
+	"America/Regina":                 "ca",
+	"America/Rio_Branco":             "br",
+	"America/Santiago":               "cl",
+	"America/Santo_Domingo":          "do",
+	"America/Sao_Paulo":              "br",
+	"America/St_Johns":               "ca",
+	"America/Tegucigalpa":            "hn",
+	"America/Thunder_Bay":            "ca",
+	"America/Tijuana":                "mx",
+	"America/Toronto":                "ca",
+	"America/Vancouver":              "ca",
+	"America/Whitehorse":             "ca",
+	"America/Winnipeg":               "ca",
+	"America/Yellowknife":            "ca",
+	"Asia/Aden":                      "ye",
+	"Asia/Almaty":                    "kz",
+	"Asia/Amman":                     "jo",
+	"Asia/Anadyr":                    "ru",
+	"Asia/Aqtobe":                    "kz",
+	"Asia/Ashgabat":                  "tm",
+	"Asia/Baghdad":                   "iq",
+	"Asia/Bahrain":                   "bh",
+	"Asia/Baku":                      "az",
+	"Asia/Bangkok":                   "th",
+	"Asia/Beirut":                    "lb",
+	"Asia/Bishkek":                   "kg",
+	"Asia/Brunei":                    "bn",
+	"Asia/Colombo":                   "lk",
+	"Asia/Damascus":                  "sy",
+	"Asia/Dhaka":                     "bd",
+	"Asia/Dili":                      "tl",
+	"Asia/Dubai":                     "ae",
+	"Asia/Dushanbe":                  "tj",
+	"Asia/Ho_Chi_Minh":               "vn",
+	"Asia/Hong_Kong":                 "hk",
+	"Asia/Hovd":                      "mn",
+	"Asia/Irkutsk":                   "ru",
+	"Asia/Jakarta":                   "id",
+	"Asia/Jayapura":                  "id",
+	"Asia/Jerusalem":                 "il",
+	"Asia/Kabul":                     "af",
+	"Asia/Kamchatka":                 "ru",
+	"Asia/Karachi":                   "pk",
+	"Asia/Kathmandu":                 "np",
+	"Asia/Kolkata":                   "in",
+	"Asia/Krasnoyarsk":               "ru",
+	"Asia/Kuala_Lumpur":              "my",
+	"Asia/Kuching":                   "my",
+	"Asia/Kuwait":                    "kw",
+	"Asia/Macau":                     "mo",
+	"Asia/Magadan":                   "ru",
+	"Asia/Makassar":                  "id",
+	"Asia/Manila":                    "ph",
+	"Asia/Muscat":                    "om",
+	"Asia/Nicosia":                   "cy",
+	"Asia/Novosibirsk":               "ru",
+	"Asia/Omsk":                      "ru",
+	"Asia/Phnom_Penh":                "kh",
+	"Asia/Pontianak":                 "id",
+	"Asia/Pyongyang":                 "kp",
+	"Asia/Qatar":                     "qa",
+	"Asia/Riyadh":                    "sa",
+	"Asia/Samarkand":                 "uz",
+	"Asia/Seoul":                     "kr",
+	"Asia/Shanghai":                  "cn",
+	"Asia/Singapore":                 "sg",
+	"Asia/Taipei":                    "tw",
+	"Asia/Tashkent":                  "uz",
+	"Asia/Tbilisi":                   "ge",
+	"Asia/Tehran":                    "ir",
+	"Asia/Thimphu":                   "bt",
+	"Asia/Tokyo":                     "jp",
+	"Asia/Ulaanbaatar":               "mn",
+	"Asia/Urumqi":                    "cn",
+	"Asia/Vientiane":                 "la",
+	"Asia/Vladivostok":               "ru",
+	"Asia/Yakutsk":                   "ru",
+	"Asia/Yangon":                    "mm",
+	"Asia/Yekaterinburg":             "ru",
+	"Asia/Yerevan":                   "am",
+	"Atlantic/Azores":                "pt",
+	"Atlantic/Bermuda":               "bm",
+	"Atlantic/Canary":                "es",
+	"Atlantic/Cape_Verde":            "cv",
+	"Atlantic/Faroe":                 "fo",
+	"Atlantic/Madeira":               "pt",
+	"Atlantic/Reykjavik":             "is",
+	"Atlantic/Stanley":               "fk",
+	"Australia/Adelaide":             "au",
+	"Australia/Brisbane":             "au",
+	"Australia/Broken_Hill":          "au",
+	"Australia/Darwin":               "au",
+	"Australia/Hobart":               "au",
+	"Australia/Melbourne":            "au",
+	"Australia/Perth":                "au",
+	"Australia/Sydney":               "au",
+	"Europe/Amsterdam":               "nl",
+	"Europe/Athens":                  "gr",
+	"Europe/Belgrade":                "rs",
+	"Europe/Berlin":                  "de",
+	"Europe/Bratislava":              "sk",
+	"Europe/Brussels":                "be",
+	"Europe/Bucharest":               "ro",
+	"Europe/Budapest":                "hu",
+	"Europe/Chisinau":                "md",
+	"Europe/Copenhagen":              "dk",
+	"Europe/Dublin":                  "ie",
+	"Europe/Helsinki":                "fi",
+	"Europe/Istanbul":                "tr",
+	"Europe/Kaliningrad":             "ru",
+	"Europe/Kiev":                    "ua",
+	"Europe/Lisbon":                  "pt",
+	"Europe/Ljubljana":               "si",
+	"Europe/London":                  "gb",
+	"Europe/Luxembourg":              "lu",
+	"Europe/Madrid":                  "es",
+	"Europe/Malta":                   "mt",
+	"Europe/Minsk":                   "by",
+	"Europe/Moscow":                  "ru",
+	"Europe/Oslo":                    "no",
+	"Europe/Paris":                   "fr",
+	"Europe/Podgorica":               "me",
+	"Europe/Prague":                  "cz",
+	"Europe/Riga":                    "lv",
+	"Europe/Rome":                    "it",
+	"Europe/Samara":                  "ru",
+	"Europe/Sarajevo":                "ba",
+	"Europe/Skopje":                  "mk",
+	"Europe/Sofia":                   "bg",
+	"Europe/Stockholm":               "se",
+	"Europe/Tallinn":                 "ee",
+	"Europe/Tirane":                  "al",
+	"Europe/Vienna":                  "at",
+	"Europe/Vilnius":                 "lt",
+	"Europe/Volgograd":               "ru",
+	"Europe/Warsaw":                  "pl",
+	"Europe/Zagreb":                  "hr",
+	"Europe/Zurich":                  "ch",
+	"Indian/Antananarivo":            "mg",
+	"Indian/Mahe":                    "sc",
+	"Indian/Maldives":                "mv",
+	"Indian/Mauritius":               "mu",
+	"Indian/Reunion":                 "re",
+	"Pacific/Apia":                   "ws",
+	"Pacific/Auckland":               "nz",
+	"Pacific/Chatham":                "nz",
+	"Pacific/Easter":                 "cl",
+	"Pacific/Efate":                  "vu",
+	"Pacific/Fiji":                   "fj",
+	"Pacific/Galapagos":              "ec",
+	"Pacific/Guadalcanal":            "sb",
+	"Pacific/Guam":                   "gu",
+	"Pacific/Honolulu":               "us",
+	"Pacific/Majuro":                 "mh",
+	"Pacific/Noumea":                 "nc",
+	"Pacific/Pago_Pago":              "as",
+	"Pacific/Port_Moresby":           "pg",
+	"Pacific/Rarotonga":              "ck",
+	"Pacific/Tahiti":                 "pf",
+	"Pacific/Tarawa":                 "ki",
+	"Pacific/Tongatapu":              "to",
+}
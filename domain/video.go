@@ -0,0 +1,175 @@
+package domain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // cover art may be stored as PNG
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain/formats"
+)
+
+// VideoInfo contains technical information about a video
+type VideoInfo struct {
+	Duration time.Duration `json:"duration,omitempty"`
+	Codec    string        `json:"codec,omitempty"`
+	Width    int           `json:"width,omitempty"`
+	Height   int           `json:"height,omitempty"`
+}
+
+const (
+	posterFrameOffset = 1 * time.Second
+
+	placeholderWidth  = 640
+	placeholderHeight = 360
+)
+
+var (
+	ErrFFmpegNotAvailable = errors.New("ffmpeg is not available")
+
+	placeholderBackground = color.RGBA{48, 48, 48, 255}
+	placeholderForeground = color.RGBA{220, 220, 220, 255}
+
+	ffmpegPath string
+	ffmpegOnce sync.Once
+)
+
+// SetFFmpegPath configures the ffmpeg binary used to extract poster frames
+// from videos instead of looking it up in the PATH. An empty path disables
+// poster frame extraction.
+func SetFFmpegPath(path string) {
+	ffmpegOnce.Do(func() {})
+	ffmpegPath = path
+}
+
+// FFmpegAvailable returns the path to the ffmpeg binary and whether it is available
+func FFmpegAvailable() (string, bool) {
+	ffmpegOnce.Do(func() {
+		if path, err := exec.LookPath("ffmpeg"); err == nil {
+			ffmpegPath = path
+		}
+	})
+	return ffmpegPath, ffmpegPath != ""
+}
+
+func videoInfoOf(qt *formats.Quicktime) *VideoInfo {
+	codec, width, height, found := qt.VideoTrack()
+	if !found && qt.Duration() == 0 {
+		return nil
+	}
+	return &VideoInfo{
+		Duration: qt.Duration(),
+		Codec:    strings.TrimSpace(codec),
+		Width:    width,
+		Height:   height,
+	}
+}
+
+// quicktimeThumb returns the poster frame of a quicktime movie: the embedded
+// cover art if any, else a frame extracted with ffmpeg and as a last resort
+// a placeholder image
+func quicktimeThumb(in io.Reader) (image.Image, error) {
+	f, cleanup, err := asFile(in)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	qt, err := formats.ReadAsQuicktime(f)
+	if err != nil {
+		return nil, err
+	}
+	if art, _ := qt.CoverArt(); art != nil {
+		if img, _, err := image.Decode(bytes.NewReader(art)); err == nil {
+			return img, nil
+		}
+	}
+	info := videoInfoOf(qt)
+	if img, err := extractPosterFrame(f.Name(), info); err == nil {
+		return img, nil
+	}
+	return placeholderFor(info), nil
+}
+
+// asFile returns the given reader as a file, copying the content to a
+// temporary file if needed
+func asFile(in io.Reader) (*os.File, func(), error) {
+	if f, ok := in.(*os.File); ok {
+		return f, func() {}, nil
+	}
+	tmp, err := ioutil.TempFile("", "photoscope-video-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	if _, err := io.Copy(tmp, in); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return tmp, cleanup, nil
+}
+
+func extractPosterFrame(path string, info *VideoInfo) (image.Image, error) {
+	ffmpeg, found := FFmpegAvailable()
+	if !found {
+		return nil, ErrFFmpegNotAvailable
+	}
+	offset := posterFrameOffset
+	if info != nil && info.Duration > 0 && info.Duration < 2*offset {
+		offset = info.Duration / 2
+	}
+	cmd := exec.Command(ffmpeg, "-loglevel", "error",
+		"-ss", fmt.Sprintf("%.3f", offset.Seconds()),
+		"-i", path,
+		"-frames:v", "1",
+		"-f", "image2pipe", "-vcodec", "mjpeg", "-")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return jpeg.Decode(&out)
+}
+
+// placeholderFor renders a neutral image with a play symbol having the
+// aspect ratio of the video
+func placeholderFor(info *VideoInfo) image.Image {
+	w, h := placeholderWidth, placeholderHeight
+	if info != nil && info.Width > 0 && info.Height > 0 {
+		h = w * info.Height / info.Width
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{placeholderBackground}, image.ZP, draw.Src)
+	size := h / 5
+	if w < h {
+		size = w / 5
+	}
+	x0, cy := w/2-size*2/3, h/2
+	for dy := -size; dy <= size; dy++ {
+		half := size - dy
+		if dy < 0 {
+			half = size + dy
+		}
+		for x := x0; x < x0+half*17/10; x++ {
+			img.Set(x, cy+dy, placeholderForeground)
+		}
+	}
+	return img
+}
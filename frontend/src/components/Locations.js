@@ -30,53 +30,43 @@ export default class Locations extends Component {
         return (
             <div className="Tree">
                 <ul className="Tree-node">
-                    {countries?.map((c) => <Country key={c.ciso} country={c} onClick={this.clickHandler} />)}
+                    {countries?.map((c) => <Place key={c.id} place={c} onClick={this.clickHandler} />)}
                 </ul>
             </div>
         )
     }
 }
 
-class Country extends Component {
+class Place extends Component {
+    static propTypes = {
+        place: PropTypes.object.isRequired,
+        onClick: PropTypes.func
+    }
 
-    clickHandler = (placeId) => {
-        this.props.onClick(placeId)
+    clickHandler = (ev) => {
+        ev.stopPropagation()
+        this.props.onClick(this.props.place.id)
     }
 
     render() {
         const {
             props: {
-                country: {
-                    country,
-                    places }
+                place: {
+                    name,
+                    count,
+                    places
+                },
+                onClick
             }
         } = this
-        const leaves = places.map((p,i) => {
-            return (<Leaf key={i} label={p.city || p.zip} id={p.id} onClick={this.clickHandler}/>)
-        })
         return (
-            <li>{country}
-                <ul>
-                    {leaves}
-                </ul>
+            <li onClick={this.clickHandler}>{name} ({count})
+                {places &&
+                    <ul>
+                        {places.map((p) => <Place key={p.id} place={p} onClick={onClick} />)}
+                    </ul>
+                }
             </li>
         )
     }
 }
-
-class Leaf extends Component {
-    static propTypes = {
-        id: PropTypes.string.isRequired,
-        label: PropTypes.string.isRequired,
-        onClicked: PropTypes.func
-    }
-
-    clickHandler = (ev) => {
-        ev.stopPropagation()
-        this.props.onClick(this.props.id)
-    }
-
-    render() {
-        return (<li onClick={this.clickHandler}>{this.props.label}</li>)
-    }
-}
@@ -78,7 +78,7 @@ export default class FilterSelector extends Component {
     }
 
     onPlaceSelected(placeID) {
-        this.props.onFilterChanged('/geo/photos/byplace/' + placeID)
+        this.props.onFilterChanged('/geo/photos/byplace/' + encodeURI(placeID))
     }
 
     onEventSelected(eventID) {
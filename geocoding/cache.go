@@ -53,7 +53,7 @@ type Cache struct {
 	stats    *internalStats
 	delegate Resolver
 
-	qt   *quadtree
+	qt   *QuadTree
 	lock sync.RWMutex
 }
 
@@ -100,7 +100,7 @@ func (c *Cache) ReverseGeocode(ctx context.Context, lat, lon float64) (*gps.Addr
 	place, found, err := c.delegate.ReverseGeocode(ctx, lat, lon)
 	if found && place.BoundingBox != nil {
 		c.add(*place.BoundingBox, place)
-	} else {
+	} else if found {
 		log.Info("Place has no bounding box", zap.Stringer("place", place.ID))
 	}
 	return place, found, err
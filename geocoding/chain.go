@@ -0,0 +1,37 @@
+package geocoding
+
+import (
+	"context"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"go.uber.org/zap"
+)
+
+type chain []Resolver
+
+// Chain returns a resolver asking each of the given resolvers in turn until
+// one of them resolves the location. Errors of a resolver are only returned
+// if none of the following resolvers finds the location.
+func Chain(resolvers ...Resolver) Resolver {
+	if len(resolvers) == 1 {
+		return resolvers[0]
+	}
+	return chain(resolvers)
+}
+
+func (c chain) ReverseGeocode(ctx context.Context, lat, lon float64) (*gps.Address, bool, error) {
+	var lastErr error
+	for i, r := range c {
+		address, found, err := r.ReverseGeocode(ctx, lat, lon)
+		if err != nil {
+			logging.From(ctx).Warn("Resolver failed", zap.Int("resolver", i), zap.Error(err))
+			lastErr = err
+			continue
+		}
+		if found {
+			return address, true, nil
+		}
+	}
+	return nil, false, lastErr
+}
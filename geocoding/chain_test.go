@@ -0,0 +1,44 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"github.com/stretchr/testify/assert"
+)
+
+type resolverFunc func(lat, lon float64) (*gps.Address, bool, error)
+
+func (f resolverFunc) ReverseGeocode(ctx context.Context, lat, lon float64) (*gps.Address, bool, error) {
+	return f(lat, lon)
+}
+
+func TestChain(t *testing.T) {
+	failure := errors.New("offline")
+	wien := gps.AsAddress("Austria", "at", "Wien", "")
+	notFound := resolverFunc(func(lat, lon float64) (*gps.Address, bool, error) { return nil, false, nil })
+	failing := resolverFunc(func(lat, lon float64) (*gps.Address, bool, error) { return nil, false, failure })
+	found := resolverFunc(func(lat, lon float64) (*gps.Address, bool, error) { return &wien, true, nil })
+
+	data := []struct {
+		resolvers []Resolver
+		found     bool
+		err       error
+	}{
+		{[]Resolver{found, failing}, true, nil},
+		{[]Resolver{notFound, found}, true, nil},
+		{[]Resolver{failing, found}, true, nil},
+		{[]Resolver{notFound, notFound}, false, nil},
+		{[]Resolver{notFound, failing}, false, failure},
+	}
+	for i, d := range data {
+		address, ok, err := Chain(d.resolvers...).ReverseGeocode(context.Background(), 48.2, 16.37)
+		assert.Equal(t, d.found, ok, "found %d", i)
+		assert.Equal(t, d.err, err, "error %d", i)
+		if d.found {
+			assert.Equal(t, wien.ID, address.ID)
+		}
+	}
+}
@@ -17,10 +17,16 @@ type Resolver interface {
 	ReverseGeocode(ctx context.Context, lat, long float64) (*gps.Address, bool, error)
 }
 
+// ResolvedCallback is called after the address of a photo has been resolved
+// and stored in the geo index
+type ResolvedCallback func(ctx context.Context, photo library.ExtendedPhotoID, address *gps.Address) error
+
 type Geocoder struct {
-	index    library.GeoIndex
-	resolver Resolver
-	Cache    *Cache
+	index     library.GeoIndex
+	resolver  Resolver
+	Cache     *Cache
+	photos    library.SpatialIndex
+	callbacks []ResolvedCallback
 }
 
 func NewGeocoder(idx library.GeoIndex, resolver Resolver) *Geocoder {
@@ -36,6 +42,18 @@ func (g *Geocoder) RegisterTasks(repo *tasks.TaskRepository) {
 	repo.Register("geoResolve", func() tasks.Task {
 		return NewGeoLookupTask(g)
 	})
+	repo.RegisterWithProperties(MatchTrackTaskType, func() tasks.Task {
+		return NewMatchTrackTask(g)
+	}, tasks.TaskProperties{
+		RunOnStart:   false,
+		UserRunnable: false,
+	})
+	repo.RegisterWithProperties(ResolveAreaTaskType, func() tasks.Task {
+		return NewResolveAreaTask(g)
+	}, tasks.TaskProperties{
+		RunOnStart:   false,
+		UserRunnable: false,
+	})
 	repo.RegisterWithProperties("populateCache", func() tasks.Task {
 		return newLoadKnownPlaces(g.index, g.Cache)
 	}, tasks.TaskProperties{
@@ -44,6 +62,10 @@ func (g *Geocoder) RegisterTasks(repo *tasks.TaskRepository) {
 	})
 }
 
+func (g *Geocoder) AddResolvedCallback(callback ResolvedCallback) {
+	g.callbacks = append(g.callbacks, callback)
+}
+
 func (g *Geocoder) ReverseGeocode(ctx context.Context, lat, lon float64) (*gps.Address, error) {
 	address, found, err := g.resolver.ReverseGeocode(ctx, lat, lon)
 	if err != nil {
@@ -62,6 +84,17 @@ func (g *Geocoder) LookupPhotoOnAdd(ctx context.Context, p *library.Photo) (task
 	return NewGeoLookupTaskWith(g, p.ExtendedPhotoID, *p.Location), true
 }
 
+// LookupPhotoOnChange updates the geo index after the location of the given
+// photo has been changed: a photo without location is removed from the index,
+// otherwise a task resolving its new location is returned
+func (g *Geocoder) LookupPhotoOnChange(ctx context.Context, p *library.Photo) (tasks.Task, bool, error) {
+	if p.Location == nil {
+		return nil, false, g.index.Remove(ctx, p.ExtendedPhotoID)
+	}
+	t, ok := g.LookupPhotoOnAdd(ctx, p)
+	return t, ok, nil
+}
+
 func (g *Geocoder) ResolveAndStoreLocation(ctx context.Context, p library.ExtendedPhotoID, coords gps.Coordinates) error {
 	logger, ctx := logging.FromWithNameAndFields(ctx, "geocoder", zap.String("photo", string(p.ID)))
 	logger.Info("Reverse geocoding", zap.Stringer("location", coords))
@@ -75,5 +108,13 @@ func (g *Geocoder) ResolveAndStoreLocation(ctx context.Context, p library.Extend
 	logger.Info("Geo decoded: ",
 		zap.Stringer("country", address.Country.ID),
 		zap.String("city", address.City))
-	return g.index.Update(ctx, p, address)
+	if err := g.index.Update(ctx, p, address); err != nil {
+		return err
+	}
+	for _, cb := range g.callbacks {
+		if err := cb(ctx, p, address); err != nil {
+			logger.Warn("Resolved location callback failed", zap.Error(err))
+		}
+	}
+	return nil
 }
@@ -0,0 +1,285 @@
+// Package geonames implements an offline reverse geocoder based on the
+// datasets published by GeoNames (http://download.geonames.org/export/dump/)
+package geonames
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/geocoding"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// CountriesFile is the name of the GeoNames file containing country names
+	CountriesFile = "countryInfo.txt"
+	// RegionsFile is the name of the GeoNames file containing the names of
+	// first level administrative divisions
+	RegionsFile = "admin1CodesASCII.txt"
+
+	// DefaultMaxDistance is the maximum distance in meters to the nearest
+	// city for a location to be resolved
+	DefaultMaxDistance = 30000.
+)
+
+// Columns of the GeoNames cities files
+const (
+	colName     = 1
+	colLat      = 4
+	colLon      = 5
+	colCountry  = 8
+	colAdmin1   = 10
+	cityColumns = 11
+)
+
+// Columns of the GeoNames countryInfo file
+const (
+	colISO         = 0
+	colCountryName = 4
+	countryColumns = 5
+)
+
+// Columns of the GeoNames admin1Codes file
+const (
+	colRegionCode = 0
+	colRegionName = 1
+	regionColumns = 2
+)
+
+var ErrNoCities = errors.New("No cities found in GeoNames dataset")
+
+// indexBounds are the bounds of the index of the cities, the areas around
+// cities may end on the antimeridian or a pole which the world bounds exclude
+var indexBounds = gps.RectFrom(gps.LonMin, gps.LatMin,
+	math.Nextafter(gps.LonMax, math.Inf(1)), math.Nextafter(gps.LatMax, math.Inf(1)))
+
+type city struct {
+	name     string
+	country  string
+	admin1   string
+	location gps.Coordinates
+}
+
+// Resolver resolves coordinates to the nearest city of a GeoNames dataset
+type Resolver struct {
+	cities      []city
+	countries   map[string]string
+	regions     map[string]string
+	maxDistance float64
+	index       *geocoding.QuadTree
+}
+
+// Open loads the GeoNames dataset from the given directory which must
+// contain one of the cities files (e.g. cities15000.txt) and may contain the
+// countryInfo.txt and admin1CodesASCII.txt files for country and region names
+func Open(dir string, maxDistance float64) (*Resolver, error) {
+	citiesFiles, err := filepath.Glob(filepath.Join(dir, "cities*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	if len(citiesFiles) == 0 {
+		return nil, fmt.Errorf("No GeoNames cities file found in %s", dir)
+	}
+	// When several datasets are present, the most detailed one is used
+	in, err := os.Open(pickCitiesFile(citiesFiles))
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	countries, err := openOptional(filepath.Join(dir, CountriesFile))
+	if err != nil {
+		return nil, err
+	}
+	defer countries.Close()
+	regions, err := openOptional(filepath.Join(dir, RegionsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer regions.Close()
+	return NewResolver(in, countries, regions, maxDistance)
+}
+
+type optionalFile struct {
+	*os.File
+}
+
+// openOptional opens the given file, a missing file is read as empty
+func openOptional(path string) (optionalFile, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return optionalFile{}, nil
+	}
+	return optionalFile{f}, err
+}
+
+func (f optionalFile) Read(p []byte) (int, error) {
+	if f.File == nil {
+		return 0, io.EOF
+	}
+	return f.File.Read(p)
+}
+
+func (f optionalFile) Close() error {
+	if f.File == nil {
+		return nil
+	}
+	return f.File.Close()
+}
+
+func pickCitiesFile(files []string) string {
+	best, bestSize := files[0], math.MaxInt64
+	for _, f := range files {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(f), "cities"), ".txt")
+		if size, err := strconv.Atoi(name); err == nil && size < bestSize {
+			best, bestSize = f, size
+		}
+	}
+	return best
+}
+
+// NewResolver creates a resolver from a GeoNames cities file and optional
+// countryInfo and admin1Codes files
+func NewResolver(cities, countries, regions io.Reader, maxDistance float64) (*Resolver, error) {
+	if maxDistance <= 0 {
+		maxDistance = DefaultMaxDistance
+	}
+	r := &Resolver{
+		countries:   make(map[string]string),
+		regions:     make(map[string]string),
+		maxDistance: maxDistance,
+		index:       geocoding.NewQuadTree(indexBounds),
+	}
+	if countries != nil {
+		if err := r.readCountries(countries); err != nil {
+			return nil, err
+		}
+	}
+	if regions != nil {
+		if err := r.readRegions(regions); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.readCities(cities); err != nil {
+		return nil, err
+	}
+	if len(r.cities) == 0 {
+		return nil, ErrNoCities
+	}
+	for i := range r.cities {
+		for _, rect := range gps.RectsAround(r.cities[i].location, r.maxDistance) {
+			r.index.InsertRect(rect, i)
+		}
+	}
+	return r, nil
+}
+
+func (r *Resolver) readCities(in io.Reader) error {
+	return readRecords(in, cityColumns, func(fields []string) error {
+		lat, err := strconv.ParseFloat(fields[colLat], 64)
+		if err != nil {
+			return err
+		}
+		lon, err := strconv.ParseFloat(fields[colLon], 64)
+		if err != nil {
+			return err
+		}
+		location, err := gps.NewCoordinates(lat, lon)
+		if err != nil {
+			return err
+		}
+		r.cities = append(r.cities, city{
+			name:     fields[colName],
+			country:  fields[colCountry],
+			admin1:   fields[colAdmin1],
+			location: *location,
+		})
+		return nil
+	})
+}
+
+func (r *Resolver) readCountries(in io.Reader) error {
+	return readRecords(in, countryColumns, func(fields []string) error {
+		r.countries[fields[colISO]] = fields[colCountryName]
+		return nil
+	})
+}
+
+func (r *Resolver) readRegions(in io.Reader) error {
+	return readRecords(in, regionColumns, func(fields []string) error {
+		r.regions[fields[colRegionCode]] = fields[colRegionName]
+		return nil
+	})
+}
+
+// readRecords calls f for each tab-separated line having at least the given
+// number of columns, comment lines are skipped
+func readRecords(in io.Reader, columns int, f func([]string) error) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) < columns {
+			return fmt.Errorf("line %d: expected %d columns, got %d", line, columns, len(fields))
+		}
+		if err := f(fields); err != nil {
+			return fmt.Errorf("line %d: %s", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// ReverseGeocode returns the address of the city nearest to the given
+// coordinates, if there is one within the maximum distance
+func (r *Resolver) ReverseGeocode(ctx context.Context, lat, lon float64) (*gps.Address, bool, error) {
+	pos, err := gps.NewCoordinates(lat, lon)
+	if err != nil {
+		return nil, false, err
+	}
+	nearest, found := r.nearest(*pos)
+	if !found {
+		logging.From(ctx).Debug("No city nearby", zap.Stringer("pos", pos))
+		return nil, false, nil
+	}
+	c := r.cities[nearest]
+	country, ok := r.countries[c.country]
+	if !ok {
+		country = c.country
+	}
+	address := gps.NewAddress(gps.AddressFields{
+		Country: gps.Country{
+			Country: country,
+			ID:      gps.CountryIDFromString(c.country),
+		},
+		Region: r.regions[c.country+"."+c.admin1],
+		City:   c.name,
+	})
+	return &address, true, nil
+}
+
+func (r *Resolver) nearest(pos gps.Coordinates) (int, bool) {
+	nearest, minDistance := -1, r.maxDistance
+	r.index.FindFunc(gps.PointFromLatLon(pos.Lat, pos.Long), func(o interface{}, _ gps.Rect) {
+		i := o.(int)
+		d := pos.DistanceTo(&r.cities[i].location)
+		if d < minDistance {
+			nearest, minDistance = i, d
+		}
+	})
+	return nearest, nearest >= 0
+}
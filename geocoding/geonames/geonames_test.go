@@ -0,0 +1,82 @@
+package geonames
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCities = "2761369\tVienna\tVienna\tWien\t48.20849\t16.37208\tP\tPPLC\tAT\t\t09\t900\t\t\t1691468\t\t193\tEurope/Vienna\t2020-01-01\n" +
+	"2766824\tSankt Pölten\tSankt Poelten\t\t48.2\t15.63333\tP\tPPLA\tAT\t\t03\t302\t\t\t51955\t\t272\tEurope/Vienna\t2020-01-01\n" +
+	"2660646\tGeneva\tGeneva\tGenève\t46.20222\t6.14569\tP\tPPLA\tCH\t\tGE\t2500\t\t\t183981\t\t375\tEurope/Zurich\t2020-01-01\n" +
+	"4030556\tRikitea\tRikitea\t\t-23.1203\t-134.9692\tP\tPPLA\tPF\t\t\t\t\t\t1000\t\t5\tPacific/Gambier\t2020-01-01\n" +
+	"2204431\tMatei\tMatei\t\t-16.69\t-179.88\tP\tPPL\tFJ\t\t03\t\t\t\t1000\t\t10\tPacific/Fiji\t2020-01-01\n"
+
+const testRegions = "AT.09\tVienna\tVienna\t2761367\n" +
+	"AT.03\tLower Austria\tLower Austria\t2770542\n"
+
+const testCountries = "#ISO\tISO3\tISO-Numeric\tfips\tCountry\tCapital\n" +
+	"AT\tAUT\t040\tAU\tAustria\tVienna\n" +
+	"CH\tCHE\t756\tSZ\tSwitzerland\tBern\n"
+
+func TestReverseGeocode(t *testing.T) {
+	r, err := NewResolver(strings.NewReader(testCities), strings.NewReader(testCountries), strings.NewReader(testRegions), 0)
+	if err != nil {
+		t.Fatalf("Failed to load dataset: %s", err)
+	}
+	data := []struct {
+		lat, lon float64
+		found    bool
+		city     string
+		region   string
+		country  string
+	}{
+		{48.2118494, 16.3651666, true, "Vienna", "Vienna", "Austria"},
+		{48.081489, 15.592614, true, "Sankt Pölten", "Lower Austria", "Austria"},
+		{46.23, 6.1, true, "Geneva", "", "Switzerland"},
+		{-23.12, -134.97, true, "Rikitea", "", "PF"},
+		// Across the antimeridian
+		{-16.69, 179.9, true, "Matei", "", "FJ"},
+		{47.0, 11.0, false, "", "", ""},
+		{0, 0, false, "", "", ""},
+	}
+	for _, d := range data {
+		address, found, err := r.ReverseGeocode(context.Background(), d.lat, d.lon)
+		if err != nil {
+			t.Fatalf("Error while reverse geocoding: %s", err)
+		}
+		assert.Equal(t, d.found, found, "%f/%f", d.lat, d.lon)
+		if d.found && found {
+			assert.Equal(t, d.city, address.City)
+			assert.Equal(t, d.region, address.Region)
+			assert.Equal(t, d.country, address.Country.Country)
+			assert.NotEmpty(t, address.ID)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geonames")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := Open(dir, 0); err == nil {
+		t.Errorf("Expected error for missing dataset")
+	}
+	ioutil.WriteFile(filepath.Join(dir, "cities15000.txt"), []byte("bad\tline\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "cities500.txt"), []byte(testCities), 0644)
+	r, err := Open(dir, 0)
+	if err != nil {
+		t.Fatalf("Failed to open dataset: %s", err)
+	}
+	assert.Equal(t, 5, len(r.cities))
+	address, found, _ := r.ReverseGeocode(context.Background(), 48.21, 16.37)
+	assert.True(t, found)
+	assert.Equal(t, "AT", address.Country.Country)
+}
@@ -0,0 +1,111 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"bitbucket.org/kleinnic74/photos/tasks"
+	"go.uber.org/zap"
+)
+
+// MatchTrackTaskType is the name of the task geotagging photos from a GPX track
+const MatchTrackTaskType = "matchGPXTrack"
+
+const defaultMaxTrackGap = 15 * time.Minute
+
+var ErrNoTrack = errors.New("Missing GPX track")
+
+// MatchTrackTask assigns locations interpolated from a GPX track to the photos
+// taken while the track was recorded. Offset is added to the capture time of
+// the photos to compensate for a wrong camera clock. Photos taken between two
+// track points more than MaxGap apart are not geotagged. The track comes from
+// the request creating the task and is only kept in memory.
+type MatchTrackTask struct {
+	geocoder *Geocoder
+
+	Track     gps.Track `json:"-"`
+	MaxGap    string    `json:"maxGap,omitempty"`
+	Offset    string    `json:"offset,omitempty"`
+	Overwrite bool      `json:"overwrite,omitempty"`
+}
+
+func NewMatchTrackTask(g *Geocoder) *MatchTrackTask {
+	return &MatchTrackTask{geocoder: g}
+}
+
+func (t *MatchTrackTask) Describe() string {
+	if len(t.Track) == 0 {
+		return "Geotagging photos from track"
+	}
+	return fmt.Sprintf("Geotagging photos from track of %s", t.Track.Start().Format("2006-01-02"))
+}
+
+// Validate checks the parameters of this task
+func (t *MatchTrackTask) Validate() error {
+	if len(t.Track) == 0 {
+		return ErrNoTrack
+	}
+	_, _, err := t.durations()
+	return err
+}
+
+func (t *MatchTrackTask) durations() (maxGap, offset time.Duration, err error) {
+	maxGap = defaultMaxTrackGap
+	if t.MaxGap != "" {
+		if maxGap, err = time.ParseDuration(t.MaxGap); err != nil {
+			return 0, 0, fmt.Errorf("Bad maxGap '%s': %s", t.MaxGap, err)
+		}
+	}
+	if t.Offset != "" {
+		if offset, err = time.ParseDuration(t.Offset); err != nil {
+			return 0, 0, fmt.Errorf("Bad offset '%s': %s", t.Offset, err)
+		}
+	}
+	return
+}
+
+func (t *MatchTrackTask) Execute(ctx context.Context, executor tasks.TaskExecutor, lib library.PhotoLibrary) error {
+	log, ctx := logging.SubFrom(ctx, "matchTrack")
+	if len(t.Track) == 0 {
+		return ErrNoTrack
+	}
+	maxGap, offset, err := t.durations()
+	if err != nil {
+		return err
+	}
+	track := t.Track
+	photos, err := lib.Find(ctx, track.Start().Add(-offset), track.End().Add(-offset), consts.Ascending)
+	if err != nil {
+		return err
+	}
+	log.Info("Matching track",
+		zap.Time("start", track.Start()), zap.Time("end", track.End()),
+		zap.Int("candidates", len(photos)))
+	var count int
+	for _, p := range photos {
+		if p.Location != nil && !t.Overwrite {
+			continue
+		}
+		location, found := track.LocationAt(p.DateTaken.Add(offset), maxGap)
+		if !found {
+			continue
+		}
+		updated, err := lib.SetLocation(ctx, p.ID, location)
+		if err != nil {
+			log.Warn("Failed to set location", zap.String("photo", string(p.ID)), zap.Error(err))
+			continue
+		}
+		count++
+		if lookup, ok := t.geocoder.LookupPhotoOnAdd(ctx, updated); ok {
+			executor.Submit(ctx, lookup)
+		}
+	}
+	log.Info("Geotagged photos", zap.Int("count", count))
+	return nil
+}
@@ -0,0 +1,85 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/tasks"
+	"github.com/stretchr/testify/assert"
+)
+
+type trackLib struct {
+	library.PhotoLibrary
+	photos []*library.Photo
+}
+
+func (lib *trackLib) Find(ctx context.Context, start, end time.Time, order consts.SortOrder) (found []*library.Photo, err error) {
+	for _, p := range lib.photos {
+		if !p.DateTaken.Before(start) && !p.DateTaken.After(end) {
+			found = append(found, p)
+		}
+	}
+	return
+}
+
+func (lib *trackLib) SetLocation(ctx context.Context, id library.PhotoID, location *gps.Coordinates) (*library.Photo, error) {
+	for _, p := range lib.photos {
+		if p.ID == id {
+			p.Location = location
+			return p, nil
+		}
+	}
+	return nil, library.NotFound(id)
+}
+
+const testTrack = `<?xml version="1.0"?>
+<gpx version="1.1" creator="test">
+  <trk><trkseg>
+    <trkpt lat="46.5" lon="6.6"><time>2019-08-01T10:00:00Z</time></trkpt>
+    <trkpt lat="46.6" lon="6.7"><time>2019-08-01T11:00:00Z</time></trkpt>
+  </trkseg></trk>
+</gpx>`
+
+func TestMatchTrack(t *testing.T) {
+	track, err := gps.ReadGPX(strings.NewReader(testTrack))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2019, 8, 1, 10, 0, 0, 0, time.UTC)
+	lib := &trackLib{photos: []*library.Photo{
+		{ExtendedPhotoID: library.ExtendedPhotoID{ID: "during"}, DateTaken: start.Add(30 * time.Minute)},
+		{ExtendedPhotoID: library.ExtendedPhotoID{ID: "after"}, DateTaken: start.Add(2 * time.Hour)},
+	}}
+	task := NewMatchTrackTask(nil)
+	task.Track = track
+	task.MaxGap = "2h"
+	assert.NoError(t, task.Execute(context.Background(), tasks.NewDummyTaskExecutor(), lib))
+	if assert.NotNil(t, lib.photos[0].Location) {
+		assert.InDelta(t, 46.55, lib.photos[0].Location.Lat, 1e-6)
+		assert.InDelta(t, 6.65, lib.photos[0].Location.Long, 1e-6)
+	}
+	assert.Nil(t, lib.photos[1].Location)
+}
+
+func TestMatchTrackWithoutTrack(t *testing.T) {
+	// Tasks created from their JSON parameters have no track
+	task := NewMatchTrackTask(nil)
+	assert.NoError(t, json.Unmarshal([]byte(`{"file":"photos.db","track":"photos.db"}`), task))
+	assert.Equal(t, ErrNoTrack, task.Validate())
+	assert.Equal(t, ErrNoTrack, task.Execute(context.Background(), tasks.NewDummyTaskExecutor(), &trackLib{}))
+
+	repo := tasks.NewTaskRepository()
+	NewGeocoder(nil, nil).RegisterTasks(repo)
+	for _, d := range repo.DefinedTasks() {
+		if d.Name == MatchTrackTaskType {
+			assert.False(t, d.UserRunnable, "Tracks are only uploaded with the geotag endpoint")
+			assert.Equal(t, []string{"maxGap", "offset", "overwrite"}, d.Parameters)
+		}
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 
 	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/geocoding"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,13 +25,13 @@ var data = []struct {
 		lat:      52.5487429714954,
 		lon:      -1.81602098644987,
 		response: `{"place_id":47300855,"licence":"Data © OpenStreetMap contributors, ODbL 1.0. https://osm.org/copyright","osm_type":"node","osm_id":3617499243,"lat":"48.2118494","lon":"16.3651666","display_name":"Schottenviertel, KG Innere Stadt, Innere Stadt, Wien, 1010, Austria","address":{"neighbourhood":"Schottenviertel","suburb":"KG Innere Stadt","city_district":"Innere Stadt","city":"Wien","postcode":"1010","country":"Austria","country_code":"at"},"boundingbox":["48.2018494","48.2218494","16.3551666","16.3751666"]}`,
-		out:      gps.AddressFields{Country: gps.Country{ID: "at", Country: "Austria"}, City: "Wien", Zip: "1010"},
+		out:      gps.AddressFields{Country: gps.Country{ID: "at", Country: "Austria"}, City: "Wien", Neighbourhood: "Innere Stadt", Zip: "1010"},
 	},
 	{
 		lat:      48.081489,
 		lon:      15.592614,
 		response: "{\"place_id\":94267472,\"licence\":\"Data © OpenStreetMap contributors, ODbL 1.0. https://osm.org/copyright\",\"osm_type\":\"way\",\"osm_id\":29301551,\"lat\":\"48.08147239671421\",\"lon\":\"15.592610324427119\",\"display_name\":\"Stelzhamergasse, Göblasbruck, Gemeinde Wilhelmsburg, Bezirk St. Pölten, Niederösterreich, 3150, Austria\",\"address\":{\"road\":\"Stelzhamergasse\",\"residential\":\"Göblasbruck\",\"suburb\":\"Göblasbruck\",\"town\":\"Gemeinde Wilhelmsburg\",\"county\":\"Bezirk St. Pölten\",\"state\":\"Niederösterreich\",\"postcode\":\"3150\",\"country\":\"Austria\",\"country_code\":\"at\"},\"boundingbox\":[\"48.0812307\",\"48.081669\",\"15.5916536\",\"15.5938144\"]}",
-		out:      gps.AddressFields{Country: gps.Country{ID: "at", Country: "Austria"}, City: "Gemeinde Wilhelmsburg", Zip: "3150"},
+		out:      gps.AddressFields{Country: gps.Country{ID: "at", Country: "Austria"}, Region: "Niederösterreich", City: "Gemeinde Wilhelmsburg", Neighbourhood: "Göblasbruck", Zip: "3150"},
 	},
 }
 
@@ -81,9 +84,7 @@ func TestReverseGeocode(t *testing.T) {
 		}
 		t.Logf("Resolved: %v", address)
 		assert.NotEmpty(t, address.ID)
-		assert.Equal(t, d.out.Zip, address.Zip)
-		assert.Equal(t, d.out.City, address.City)
-		assert.Equal(t, d.out.Country, address.Country)
+		assert.Equal(t, d.out, address.AddressFields)
 	}
 }
 
@@ -98,3 +99,48 @@ func newTestClient(roundTripFunc RoundTripperFunc) *http.Client {
 		Transport: roundTripFunc,
 	}
 }
+
+func TestReverseGeocodeErrors(t *testing.T) {
+	data := []struct {
+		status    int
+		header    string
+		response  string
+		temporary bool
+		found     bool
+	}{
+		{http.StatusTooManyRequests, "3", "", true, false},
+		{http.StatusServiceUnavailable, "", "", true, false},
+		{http.StatusForbidden, "", "", false, false},
+		{http.StatusOK, "", `{"error":"Unable to geocode"}`, false, false},
+	}
+	for _, d := range data {
+		var requested string
+		testClient := newTestClient(func(r *http.Request) *http.Response {
+			requested = r.URL.String()
+			header := make(http.Header)
+			if d.header != "" {
+				header.Set("Retry-After", d.header)
+			}
+			return &http.Response{
+				StatusCode: d.status,
+				Status:     http.StatusText(d.status),
+				Header:     header,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(d.response)),
+			}
+		})
+		osm := NewResolverForURL("http://nominatim.local/", testClient, "en")
+		_, found, err := osm.ReverseGeocode(context.Background(), 0, 0)
+		assert.Contains(t, requested, "http://nominatim.local/reverse?")
+		assert.Equal(t, d.found, found)
+		var temporary *geocoding.TemporaryError
+		assert.Equal(t, d.temporary, errors.As(err, &temporary), "status %d: %v", d.status, err)
+		if d.status != http.StatusOK {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+		if d.header != "" {
+			assert.Equal(t, 3*time.Second, temporary.RetryAfter)
+		}
+	}
+}
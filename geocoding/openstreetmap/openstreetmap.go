@@ -21,8 +21,9 @@ import (
 var IllegalBoundingBox = errors.New("Not a valid bounding box")
 
 const (
-	baseURL   = "https://nominatim.openstreetmap.org/"
-	userAgent = "GOPhotos/0.1"
+	// DefaultBaseURL is the URL of the public Nominatim servers
+	DefaultBaseURL = "https://nominatim.openstreetmap.org"
+	userAgent      = "GOPhotos/0.1"
 )
 
 var (
@@ -34,8 +35,9 @@ var (
 )
 
 type resolver struct {
-	lang   string
-	client *http.Client
+	baseURL string
+	lang    string
+	client  *http.Client
 }
 
 type boundingbox gps.Rect
@@ -70,22 +72,58 @@ func (b *boundingbox) UnmarshalJSON(data []byte) error {
 }
 
 type address struct {
-	City       string `json:"city"`
-	Town       string `json:"town"`
-	Village    string `json:"village"`
-	Zip        string `json:"postcode"`
-	Country    string `json:"country"`
-	CountryISO string `json:"country_code"`
+	Neighbourhood string `json:"neighbourhood"`
+	Quarter       string `json:"quarter"`
+	Suburb        string `json:"suburb"`
+	CityDistrict  string `json:"city_district"`
+	City          string `json:"city"`
+	Town          string `json:"town"`
+	Village       string `json:"village"`
+	Municipality  string `json:"municipality"`
+	State         string `json:"state"`
+	Province      string `json:"province"`
+	Region        string `json:"region"`
+	Zip           string `json:"postcode"`
+	Country       string `json:"country"`
+	CountryISO    string `json:"country_code"`
 }
 
-func (a address) CityName() string {
-	if a.City != "" {
-		return a.City
+// firstOf returns the first non-empty value
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
 	}
-	if a.Town != "" {
-		return a.Town
+	return ""
+}
+
+func (a address) CityName() string {
+	return firstOf(a.City, a.Town, a.Village, a.Municipality)
+}
+
+// RegionName returns the name of the first level administrative division
+// within the country
+func (a address) RegionName() string {
+	return firstOf(a.State, a.Province, a.Region)
+}
+
+// NeighbourhoodName returns the name of the district of the city
+func (a address) NeighbourhoodName() string {
+	return firstOf(a.CityDistrict, a.Suburb, a.Quarter, a.Neighbourhood)
+}
+
+func (a address) Fields() gps.AddressFields {
+	return gps.AddressFields{
+		Country: gps.Country{
+			Country: a.Country,
+			ID:      gps.CountryIDFromString(a.CountryISO),
+		},
+		Region:        a.RegionName(),
+		City:          a.CityName(),
+		Neighbourhood: a.NeighbourhoodName(),
+		Zip:           a.Zip,
 	}
-	return a.Village
 }
 
 type latlon float64
@@ -112,6 +150,7 @@ type location struct {
 	BoundingBox *boundingbox `json:"boundingbox"`
 	DisplayName string       `json:"display_name"`
 	Address     address      `json:"address"`
+	Error       string       `json:"error"`
 }
 
 func (l location) Pos() gps.Point {
@@ -127,9 +166,16 @@ func NewResolver(lang ...string) geocoding.Resolver {
 }
 
 func NewResolverWithClient(client *http.Client, lang ...string) geocoding.Resolver {
+	return NewResolverForURL(DefaultBaseURL, client, lang...)
+}
+
+// NewResolverForURL returns a resolver using the Nominatim server at the given
+// URL, e.g. a self-hosted instance
+func NewResolverForURL(baseURL string, client *http.Client, lang ...string) geocoding.Resolver {
 	return &resolver{
-		lang:   strings.Join(lang, ","),
-		client: client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		lang:    strings.Join(lang, ","),
+		client:  client,
 	}
 }
 
@@ -141,7 +187,7 @@ func (osm *resolver) ReverseGeocode(ctx context.Context, lat, lon float64) (*gps
 	}
 	query.Add("lat", strconv.FormatFloat(lat, 'f', -1, 64))
 	query.Add("lon", strconv.FormatFloat(lon, 'f', -1, 64))
-	url := fmt.Sprintf("%s/reverse?%s", baseURL, query.Encode())
+	url := fmt.Sprintf("%s/reverse?%s", osm.baseURL, query.Encode())
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, false, err
@@ -158,12 +204,41 @@ func (osm *resolver) ReverseGeocode(ctx context.Context, lat, lon float64) (*gps
 	if err != nil {
 		return nil, false, err
 	}
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError {
+		return nil, false, &geocoding.TemporaryError{
+			Err:        fmt.Errorf("Nominatim request failed: %s", res.Status),
+			RetryAfter: retryAfter(res),
+		}
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("Nominatim request failed: %s", res.Status)
+	}
 	logger.Debug("reverseGeocode response", zap.String("response", string(data)))
 	var location location
 	if err := json.Unmarshal(data, &location); err != nil {
 		return nil, false, err
 	}
-	address := gps.AsAddress(location.Address.Country, location.Address.CountryISO, location.Address.CityName(), location.Address.Zip)
+	if location.Error != "" {
+		logger.Debug("Location not found", zap.String("error", location.Error))
+		return nil, false, nil
+	}
+	address := gps.NewAddress(location.Address.Fields())
 	address.BoundingBox = location.BoundingBox.Rect()
 	return &address, true, nil
 }
+
+// retryAfter returns the delay requested by the server in the Retry-After
+// header, if any
+func retryAfter(res *http.Response) time.Duration {
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+package geocoding
+
+import (
+	"context"
+	"math"
+	"strconv"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"go.uber.org/zap"
+)
+
+// DefaultCachePrecision is the number of decimals coordinates are rounded to
+// for the persistent cache, 3 decimals are about 100m
+const DefaultCachePrecision = 3
+
+// AddressStore persists resolved addresses by key
+type AddressStore interface {
+	Get(ctx context.Context, key string) (*gps.Address, bool, error)
+	Put(ctx context.Context, key string, address *gps.Address) error
+}
+
+type persistent struct {
+	delegate  Resolver
+	store     AddressStore
+	precision int
+}
+
+// Persistent stores the addresses resolved by the given resolver in the store
+// keyed by the coordinates rounded to the given number of decimals, so that
+// locations close to each other are resolved only once
+func Persistent(r Resolver, store AddressStore, precision int) Resolver {
+	return &persistent{
+		delegate:  r,
+		store:     store,
+		precision: precision,
+	}
+}
+
+func (p *persistent) ReverseGeocode(ctx context.Context, lat, lon float64) (*gps.Address, bool, error) {
+	log := logging.From(ctx)
+	key := CacheKey(lat, lon, p.precision)
+	if address, found, err := p.store.Get(ctx, key); err != nil {
+		log.Warn("Could not read address cache", zap.String("key", key), zap.Error(err))
+	} else if found {
+		return address, true, nil
+	}
+	address, found, err := p.delegate.ReverseGeocode(ctx, lat, lon)
+	if err != nil || !found {
+		return address, found, err
+	}
+	if err := p.store.Put(ctx, key, address); err != nil {
+		log.Warn("Could not store address in cache", zap.String("key", key), zap.Error(err))
+	}
+	return address, true, nil
+}
+
+// CacheKey returns the key of the given coordinates rounded to the given
+// number of decimals
+func CacheKey(lat, lon float64, precision int) string {
+	return round(lat, precision) + "," + round(lon, precision)
+}
+
+func round(v float64, precision int) string {
+	scale := math.Pow10(precision)
+	rounded := math.Round(v*scale) / scale
+	if rounded == 0 {
+		// Avoid distinct keys for -0 and 0
+		rounded = 0
+	}
+	return strconv.FormatFloat(rounded, 'f', precision, 64)
+}
@@ -2,7 +2,6 @@ package geocoding
 
 import (
 	"fmt"
-	"log"
 
 	"bitbucket.org/kleinnic74/photos/domain/gps"
 )
@@ -14,7 +13,7 @@ type entry struct {
 
 type ResultFunc func(interface{}, gps.Rect)
 
-type quadtree struct {
+type QuadTree struct {
 	count  int
 	Bounds gps.Rect
 
@@ -38,11 +37,11 @@ type Visitor interface {
 	End()
 }
 
-func NewQuadTree(bounds gps.Rect) *quadtree {
-	return &quadtree{Bounds: bounds, capacity: 20, maxDepth: 10}
+func NewQuadTree(bounds gps.Rect) *QuadTree {
+	return &QuadTree{Bounds: bounds, capacity: 20, maxDepth: 10}
 }
 
-func (qt *quadtree) InsertRect(r gps.Rect, o interface{}) {
+func (qt *QuadTree) InsertRect(r gps.Rect, o interface{}) {
 	if !qt.Bounds.FullyContains(r) {
 		panic(fmt.Sprintf("Rect %v not in vounds %v", r, qt.Bounds))
 	}
@@ -57,7 +56,7 @@ func (qt *quadtree) InsertRect(r gps.Rect, o interface{}) {
 	qt.count++
 }
 
-func (qt *quadtree) Find(p gps.Point) (result []interface{}) {
+func (qt *QuadTree) Find(p gps.Point) (result []interface{}) {
 	if qt.root == nil {
 		return
 	}
@@ -67,13 +66,13 @@ func (qt *quadtree) Find(p gps.Point) (result []interface{}) {
 	return
 }
 
-func (qt *quadtree) Visit(v Visitor) {
+func (qt *QuadTree) Visit(v Visitor) {
 	v.Begin(qt.root.bounds)
 	qt.root.visit(v)
 	v.End()
 }
 
-func (qt *quadtree) FindFunc(p gps.Point, f ResultFunc) {
+func (qt *QuadTree) FindFunc(p gps.Point, f ResultFunc) {
 	if qt.root == nil {
 		return
 	}
@@ -82,7 +81,6 @@ func (qt *quadtree) FindFunc(p gps.Point, f ResultFunc) {
 }
 
 func newNode(bounds gps.Rect, capacity int, depth int) *node {
-	log.Printf("New QuadTree node: %v", bounds)
 	return &node{bounds: bounds, capacity: capacity, depth: depth}
 }
 
@@ -111,7 +109,6 @@ func (n *node) add(r gps.Rect, o interface{}) {
 
 func (n *node) split() {
 	hw, hh := n.bounds.HalfSize()
-	log.Printf("Splitting to [%f/%f]", n.bounds[0]+hw, n.bounds[1]+hh)
 	n.quads[0] = newNode(gps.RectFrom(n.bounds[0], n.bounds[1], n.bounds[0]+hw, n.bounds[1]+hh), n.capacity, n.depth-1)
 	n.quads[1] = newNode(gps.RectFrom(n.bounds[0], n.bounds[1]+hh, n.bounds[0]+hw, n.bounds[3]), n.capacity, n.depth-1)
 	n.quads[2] = newNode(gps.RectFrom(n.bounds[0]+hw, n.bounds[1], n.bounds[2], n.bounds[1]+hh), n.capacity, n.depth-1)
@@ -58,16 +58,14 @@ func (t loadKnownPlaces) Describe() string {
 
 func (t loadKnownPlaces) Execute(ctx context.Context, executor tasks.TaskExecutor, lib library.PhotoLibrary) error {
 	log, ctx := logging.SubFrom(ctx, "loadKnownPlaces")
-	countriesAndPlaces, err := t.index.Locations(ctx)
+	places, err := t.index.Places(ctx)
 	if err != nil {
 		return err
 	}
 	var count int
-	for _, c := range countriesAndPlaces.Countries {
-		for _, p := range c.Places {
-			if t.cache.Add(*p) {
-				count++
-			}
+	for _, p := range places {
+		if t.cache.Add(*p) {
+			count++
 		}
 	}
 	log.Info("Populated cache", zap.Int("entries", count))
@@ -0,0 +1,153 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"go.uber.org/zap"
+)
+
+// TemporaryError is returned by resolvers when a request failed but may
+// succeed if retried later, e.g. because the service is overloaded
+type TemporaryError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *TemporaryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TemporaryError) Unwrap() error {
+	return e.Err
+}
+
+// ThrottleConfig defines the rate of requests sent to a resolver and how
+// failed requests are retried
+type ThrottleConfig struct {
+	// Rate is the number of requests per second
+	Rate float64
+	// Burst is the number of requests which may be sent at once
+	Burst          int
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultThrottleConfig complies with the usage policy of the public
+// Nominatim servers: at most one request per second
+var DefaultThrottleConfig = ThrottleConfig{
+	Rate:           1,
+	Burst:          1,
+	MaxRetries:     4,
+	InitialBackoff: 2 * time.Second,
+	MaxBackoff:     time.Minute,
+}
+
+type throttled struct {
+	delegate Resolver
+	config   ThrottleConfig
+	limiter  *tokenBucket
+}
+
+// Throttled limits the rate of requests sent to the given resolver and
+// retries requests failing with a TemporaryError with exponential backoff
+func Throttled(r Resolver, config ThrottleConfig) Resolver {
+	return &throttled{
+		delegate: r,
+		config:   config,
+		limiter:  newTokenBucket(config.Rate, config.Burst),
+	}
+}
+
+func (t *throttled) ReverseGeocode(ctx context.Context, lat, lon float64) (*gps.Address, bool, error) {
+	backoff := t.config.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.Wait(ctx); err != nil {
+			return nil, false, err
+		}
+		address, found, err := t.delegate.ReverseGeocode(ctx, lat, lon)
+		var temporary *TemporaryError
+		if err == nil || !errors.As(err, &temporary) || attempt >= t.config.MaxRetries {
+			return address, found, err
+		}
+		wait := backoff
+		if temporary.RetryAfter > wait {
+			wait = temporary.RetryAfter
+		}
+		logging.From(ctx).Info("Resolver temporarily unavailable, retrying",
+			zap.Int("attempt", attempt+1),
+			zap.Duration("wait", wait),
+			zap.Error(err))
+		if err := sleep(ctx, wait); err != nil {
+			return nil, false, err
+		}
+		if backoff *= 2; backoff > t.config.MaxBackoff {
+			backoff = t.config.MaxBackoff
+		}
+	}
+}
+
+// tokenBucket allows rate events per second on average with bursts of at most
+// burst events
+type tokenBucket struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is available or the context is cancelled
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	return sleep(ctx, b.reserve(time.Now()))
+}
+
+// reserve takes a token and returns how long to wait before using it
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,97 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(2, 2)
+	now := b.last
+	assert.Equal(t, time.Duration(0), b.reserve(now))
+	assert.Equal(t, time.Duration(0), b.reserve(now))
+	assert.Equal(t, 500*time.Millisecond, b.reserve(now))
+	assert.Equal(t, time.Second, b.reserve(now))
+	// Tokens refill with time, but never above the burst size
+	later := now.Add(10 * time.Second)
+	assert.Equal(t, time.Duration(0), b.reserve(later))
+	assert.Equal(t, time.Duration(0), b.reserve(later))
+	assert.Equal(t, 500*time.Millisecond, b.reserve(later))
+}
+
+func TestThrottledRetries(t *testing.T) {
+	wien := gps.AsAddress("Austria", "at", "Wien", "")
+	temporary := &TemporaryError{Err: errors.New("503 Service Unavailable")}
+	config := ThrottleConfig{Rate: 1000, Burst: 1, MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	data := []struct {
+		failures int
+		err      error
+		calls    int
+	}{
+		{0, nil, 1},
+		{2, nil, 3},
+		{3, temporary, 3},
+		{-1, errors.New("400 Bad Request"), 1},
+	}
+	for i, d := range data {
+		calls := 0
+		r := Throttled(resolverFunc(func(lat, lon float64) (*gps.Address, bool, error) {
+			calls++
+			switch {
+			case d.failures < 0:
+				return nil, false, d.err
+			case calls <= d.failures:
+				return nil, false, temporary
+			}
+			return &wien, true, nil
+		}), config)
+		_, found, err := r.ReverseGeocode(context.Background(), 48.2, 16.37)
+		assert.Equal(t, d.err, err, "error %d", i)
+		assert.Equal(t, d.err == nil, found, "found %d", i)
+		assert.Equal(t, d.calls, calls, "calls %d", i)
+	}
+}
+
+type memoryStore map[string]*gps.Address
+
+func (s memoryStore) Get(ctx context.Context, key string) (*gps.Address, bool, error) {
+	a, found := s[key]
+	return a, found, nil
+}
+
+func (s memoryStore) Put(ctx context.Context, key string, address *gps.Address) error {
+	s[key] = address
+	return nil
+}
+
+func TestPersistent(t *testing.T) {
+	wien := gps.AsAddress("Austria", "at", "Wien", "")
+	calls := 0
+	store := memoryStore{}
+	r := Persistent(resolverFunc(func(lat, lon float64) (*gps.Address, bool, error) {
+		calls++
+		if lat < 0 {
+			return nil, false, nil
+		}
+		return &wien, true, nil
+	}), store, DefaultCachePrecision)
+
+	for _, pos := range [][2]float64{{48.20849, 16.37208}, {48.20811, 16.37249}, {-10, 0}, {-10, 0}} {
+		r.ReverseGeocode(context.Background(), pos[0], pos[1])
+	}
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 1, len(store))
+	assert.Contains(t, store, "48.208,16.372")
+}
+
+func TestCacheKey(t *testing.T) {
+	assert.Equal(t, "48.208,16.372", CacheKey(48.20849, 16.37208, 3))
+	assert.Equal(t, "0.000,-1.000", CacheKey(-0.0001, -0.9999, 3))
+	assert.Equal(t, "48.21,16.37", CacheKey(48.20849, 16.37208, 2))
+}
@@ -0,0 +1,114 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"bitbucket.org/kleinnic74/photos/tasks"
+	"go.uber.org/zap"
+)
+
+// ResolveAreaTaskType is the name of the task resolving again the locations
+// of the photos within an area
+const ResolveAreaTaskType = "geoResolveArea"
+
+var errNoSpatialIndex = errors.New("No spatial index to find photos in area")
+
+type userPlaceResolver struct {
+	places   library.UserPlaces
+	delegate Resolver
+}
+
+// UserPlaces returns a resolver resolving the coordinates within a
+// user-defined place to that place, overriding the delegate. The levels
+// above the place are resolved by the delegate from the center of the place.
+func UserPlaces(places library.UserPlaces, delegate Resolver) Resolver {
+	return userPlaceResolver{places: places, delegate: delegate}
+}
+
+func (r userPlaceResolver) ReverseGeocode(ctx context.Context, lat, lon float64) (*gps.Address, bool, error) {
+	place, found, err := r.places.At(ctx, gps.Coordinates{Lat: lat, Long: lon})
+	if err != nil {
+		return nil, false, err
+	}
+	if !found {
+		return r.delegate.ReverseGeocode(ctx, lat, lon)
+	}
+	fields := gps.AddressFields{Neighbourhood: place.Name}
+	center := place.Center()
+	surroundings, found, err := r.delegate.ReverseGeocode(ctx, center.Lat, center.Long)
+	if err != nil {
+		logging.From(ctx).Warn("Failed to resolve surroundings of place",
+			zap.String("place", place.Name), zap.Error(err))
+	} else if found {
+		fields.Country = surroundings.Country
+		fields.Region = surroundings.Region
+		fields.City = surroundings.City
+	}
+	address := gps.NewAddress(fields)
+	return &address, true, nil
+}
+
+// UseUserPlaces makes this geocoder resolve the locations within the given
+// places to these places. The spatial index is used to find the photos to
+// resolve again when places change.
+func (g *Geocoder) UseUserPlaces(places library.UserPlaces, photos library.SpatialIndex) {
+	g.resolver = UserPlaces(places, g.Cache)
+	g.photos = photos
+}
+
+// ResolveAreaTask resolves again the locations of all photos within an area,
+// typically after a user-defined place has been created, changed or deleted
+type ResolveAreaTask struct {
+	geocoder *Geocoder
+
+	Area gps.Rect `json:"area"`
+}
+
+func NewResolveAreaTask(g *Geocoder) *ResolveAreaTask {
+	return &ResolveAreaTask{geocoder: g}
+}
+
+func NewResolveAreaTaskWith(g *Geocoder, area gps.Rect) *ResolveAreaTask {
+	return &ResolveAreaTask{geocoder: g, Area: area}
+}
+
+func (t *ResolveAreaTask) Describe() string {
+	return fmt.Sprintf("Resolving locations of photos in %v", t.Area)
+}
+
+func (t *ResolveAreaTask) Execute(ctx context.Context, executor tasks.TaskExecutor, lib library.PhotoLibrary) error {
+	log, ctx := logging.SubFrom(ctx, "resolveArea")
+	if t.geocoder.photos == nil {
+		return errNoSpatialIndex
+	}
+	const pageSize = 100
+	var count int
+	for start := 0; ; start += pageSize {
+		photos, hasMore, err := t.geocoder.photos.FindInRectPaged(ctx, t.Area, start, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, p := range photos {
+			photo, err := lib.Get(ctx, p.ID)
+			if err != nil {
+				log.Warn("Failed to load photo", zap.String("photo", string(p.ID)), zap.Error(err))
+				continue
+			}
+			if err := t.geocoder.ResolveAndStoreLocation(ctx, photo.ExtendedPhotoID, p.Location); err != nil {
+				log.Warn("Failed to resolve location", zap.String("photo", string(p.ID)), zap.Error(err))
+				continue
+			}
+			count++
+		}
+		if !hasMore {
+			break
+		}
+	}
+	log.Info("Resolved locations in area", zap.Int("photos", count))
+	return nil
+}
@@ -0,0 +1,83 @@
+package geocoding
+
+import (
+	"context"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userPlaceList []library.UserPlace
+
+func (l userPlaceList) List(ctx context.Context) ([]library.UserPlace, error) {
+	return l, nil
+}
+
+func (l userPlaceList) Get(ctx context.Context, id library.UserPlaceID) (*library.UserPlace, bool, error) {
+	for i := range l {
+		if l[i].ID == id {
+			return &l[i], true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (l userPlaceList) Put(ctx context.Context, p *library.UserPlace) error {
+	return nil
+}
+
+func (l userPlaceList) Delete(ctx context.Context, id library.UserPlaceID) error {
+	return nil
+}
+
+func (l userPlaceList) At(ctx context.Context, c gps.Coordinates) (*library.UserPlace, bool, error) {
+	p, found := library.UserPlaceAt(l, c)
+	return p, found, nil
+}
+
+func TestUserPlacesOverrideResolver(t *testing.T) {
+	ctx := context.Background()
+	wien := gps.NewAddress(gps.AddressFields{
+		Country: gps.Country{Country: "Österreich", ID: "at"},
+		Region:  "Wien",
+		City:    "Wien",
+		Zip:     "1010",
+	})
+	var lookups []gps.Coordinates
+	delegate := resolverFunc(func(lat, lon float64) (*gps.Address, bool, error) {
+		lookups = append(lookups, gps.Coordinates{Lat: lat, Long: lon})
+		return &wien, true, nil
+	})
+	home := library.UserPlace{ID: "1", Name: "Home", Area: library.Geofence{
+		Center: &gps.Coordinates{Lat: 48.2, Long: 16.37},
+		Radius: 100,
+	}}
+	r := UserPlaces(userPlaceList{home}, delegate)
+
+	address, found, err := r.ReverseGeocode(ctx, 48.2, 16.3702)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, gps.PlaceID("at/wien/wien/home"), address.ID)
+	assert.Equal(t, "", address.Zip)
+	assert.Equal(t, []gps.Coordinates{*home.Area.Center}, lookups, "Surroundings must be resolved at the center of the place")
+
+	address, found, err = r.ReverseGeocode(ctx, 48.21, 16.37)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, wien.ID, address.ID)
+}
+
+func TestUserPlacesWithoutSurroundings(t *testing.T) {
+	notFound := resolverFunc(func(lat, lon float64) (*gps.Address, bool, error) { return nil, false, nil })
+	home := library.UserPlace{ID: "1", Name: "Home", Area: library.Geofence{
+		Center: &gps.Coordinates{Lat: 48.2, Long: 16.37},
+		Radius: 100,
+	}}
+	address, found, err := UserPlaces(userPlaceList{home}, notFound).ReverseGeocode(context.Background(), 48.2, 16.37)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, []gps.Place{{ID: "_/_/_/home", Level: gps.LevelNeighbourhood, Name: "Home"}}, address.Hierarchy())
+}
@@ -0,0 +1,275 @@
+// Package geoexport exports the locations of photos and the tracks of events
+// to formats understood by GIS tools
+package geoexport
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+)
+
+const (
+	dayFormat = "2006-01-02"
+	pageSize  = 1000
+)
+
+// Filter restricts the exported photos, empty fields do not restrict
+type Filter struct {
+	// From and To are days in the format YYYY-MM-DD
+	From    string
+	To      string
+	Country gps.CountryID
+	Event   string
+}
+
+// Validate returns an error if the filter is not valid
+func (f Filter) Validate() error {
+	for _, day := range []string{f.From, f.To} {
+		if day == "" {
+			continue
+		}
+		if _, err := time.Parse(dayFormat, day); err != nil {
+			return fmt.Errorf("Bad day '%s', expected format YYYY-MM-DD", day)
+		}
+	}
+	return nil
+}
+
+func (f Filter) includesDay(t time.Time) bool {
+	day := t.Format(dayFormat)
+	return (f.From == "" || day >= f.From) && (f.To == "" || day <= f.To)
+}
+
+// Point is a located photo
+type Point struct {
+	ID   library.PhotoID
+	Name string
+	Time time.Time
+	// Location is nil for photos taken at a private place
+	Location *gps.Coordinates
+	Event    *boltstore.Event
+	Links    map[string]string
+}
+
+// Track is the chronological path through the locations of the photos of an
+// event
+type Track struct {
+	Event  boltstore.Event
+	Points []gps.Coordinates
+}
+
+// Collection contains the exported points and tracks
+type Collection struct {
+	Points []Point
+	Tracks []Track
+}
+
+// LinksFunc returns the links to include for a photo
+type LinksFunc func(library.PhotoID) map[string]string
+
+// Exporter collects the photos to export
+type Exporter struct {
+	lib    library.PhotoLibrary
+	geo    library.GeoIndex
+	events *boltstore.EventIndex
+	places library.UserPlaces
+	links  LinksFunc
+}
+
+// NewExporter returns an exporter stripping the coordinates of the photos
+// taken within the private places, places may be nil
+func NewExporter(lib library.PhotoLibrary, geo library.GeoIndex, events *boltstore.EventIndex, places library.UserPlaces, links LinksFunc) *Exporter {
+	return &Exporter{
+		lib:    lib,
+		geo:    geo,
+		events: events,
+		places: places,
+		links:  links,
+	}
+}
+
+// Export returns the located photos matching the given filter, in
+// chronological order, and the tracks of their events
+func (e *Exporter) Export(ctx context.Context, filter Filter) (*Collection, error) {
+	photos, err := e.candidates(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var inCountry map[library.PhotoID]bool
+	if filter.Country != "" {
+		if inCountry, err = e.photosInCountry(ctx, filter.Country); err != nil {
+			return nil, err
+		}
+	}
+	eventOf, err := e.eventsOf(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	private, err := e.privatePlaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var located []*library.Photo
+	for _, p := range photos {
+		if p.Location == nil || !filter.includesDay(p.LocalTime()) {
+			continue
+		}
+		if inCountry != nil && !inCountry[p.ID] {
+			continue
+		}
+		located = append(located, p)
+	}
+	sort.Slice(located, func(i, j int) bool {
+		return located[i].DateTaken.Before(located[j].DateTaken)
+	})
+	var c Collection
+	tracks := make(map[boltstore.EventID]*Track)
+	var trackOrder []boltstore.EventID
+	for _, p := range located {
+		point := Point{
+			ID:   p.ID,
+			Name: p.Name(),
+			Time: p.LocalTime(),
+		}
+		if _, hidden := library.UserPlaceAt(private, *p.Location); !hidden {
+			point.Location = p.Location
+		}
+		if e.links != nil {
+			point.Links = e.links(p.ID)
+		}
+		if event, found := eventOf[p.ID]; found {
+			point.Event = event
+			track, found := tracks[event.ID]
+			if !found {
+				track = &Track{Event: *event}
+				tracks[event.ID] = track
+				trackOrder = append(trackOrder, event.ID)
+			}
+			// Private places are left out of tracks as well
+			if point.Location != nil {
+				track.Points = append(track.Points, *point.Location)
+			}
+		}
+		c.Points = append(c.Points, point)
+	}
+	for _, id := range trackOrder {
+		// A line needs at least two points
+		if track := tracks[id]; len(track.Points) > 1 {
+			c.Tracks = append(c.Tracks, *track)
+		}
+	}
+	return &c, nil
+}
+
+// privatePlaces returns the places whose photos must not disclose their
+// coordinates
+func (e *Exporter) privatePlaces(ctx context.Context) ([]library.UserPlace, error) {
+	if e.places == nil {
+		return nil, nil
+	}
+	places, err := e.places.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var private []library.UserPlace
+	for _, p := range places {
+		if p.Private {
+			private = append(private, p)
+		}
+	}
+	return private, nil
+}
+
+// candidates returns the photos possibly matching the filter
+func (e *Exporter) candidates(ctx context.Context, filter Filter) ([]*library.Photo, error) {
+	if filter.Event != "" {
+		var photos []*library.Photo
+		err := forAllPages(func(start int) (bool, error) {
+			ids, hasMore, err := e.events.FindPhotosPaged(ctx, filter.Event, start, pageSize)
+			if err != nil {
+				return false, err
+			}
+			for _, id := range ids {
+				if p, err := e.lib.Get(ctx, id); err == nil && p != nil {
+					photos = append(photos, p)
+				}
+			}
+			return hasMore, nil
+		})
+		return photos, err
+	}
+	if filter.From == "" && filter.To == "" {
+		return e.lib.FindAll(ctx, consts.Ascending)
+	}
+	from, to := time.Time{}, time.Now()
+	if filter.From != "" {
+		from, _ = time.Parse(dayFormat, filter.From)
+	}
+	if filter.To != "" {
+		to, _ = time.Parse(dayFormat, filter.To)
+	}
+	// Local days may be off by up to one day compared to UTC
+	return e.lib.Find(ctx, from.AddDate(0, 0, -1), to.AddDate(0, 0, 2), consts.Ascending)
+}
+
+func (e *Exporter) photosInCountry(ctx context.Context, country gps.CountryID) (map[library.PhotoID]bool, error) {
+	photos := make(map[library.PhotoID]bool)
+	err := forAllPages(func(start int) (bool, error) {
+		ids, hasMore, err := e.geo.FindByCountryPaged(ctx, country, start, pageSize)
+		for _, id := range ids {
+			photos[id] = true
+		}
+		return hasMore, err
+	})
+	return photos, err
+}
+
+// eventsOf returns the event of each photo belonging to an event matching the
+// filter
+func (e *Exporter) eventsOf(ctx context.Context, filter Filter) (map[library.PhotoID]*boltstore.Event, error) {
+	eventOf := make(map[library.PhotoID]*boltstore.Event)
+	err := forAllPages(func(start int) (bool, error) {
+		events, hasMore, err := e.events.FindPaged(ctx, start, pageSize)
+		if err != nil {
+			return false, err
+		}
+		for i := range events {
+			event := &events[i]
+			if filter.Event != "" && string(event.ID) != filter.Event {
+				continue
+			}
+			if (filter.From != "" && event.To.Format(dayFormat) < filter.From) ||
+				(filter.To != "" && event.From.Format(dayFormat) > filter.To) {
+				continue
+			}
+			if err := forAllPages(func(start int) (bool, error) {
+				ids, hasMore, err := e.events.FindPhotosPaged(ctx, string(event.ID), start, pageSize)
+				for _, id := range ids {
+					eventOf[id] = event
+				}
+				return hasMore, err
+			}); err != nil {
+				return false, err
+			}
+		}
+		return hasMore, nil
+	})
+	return eventOf, err
+}
+
+// forAllPages calls page with the start index of each page until it
+// returns that there are no more pages
+func forAllPages(page func(start int) (bool, error)) error {
+	for start := 0; ; start += pageSize {
+		hasMore, err := page(start)
+		if err != nil || !hasMore {
+			return err
+		}
+	}
+}
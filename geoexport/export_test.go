@@ -0,0 +1,85 @@
+package geoexport
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCollection() *Collection {
+	event := boltstore.Event{
+		ID:   boltstore.EventID("e1"),
+		Name: "Holidays",
+		From: time.Date(2020, 7, 1, 10, 0, 0, 0, time.UTC),
+		To:   time.Date(2020, 7, 1, 18, 0, 0, 0, time.UTC),
+	}
+	first := gps.Coordinates{Lat: 43.7, Long: 7.26}
+	second := gps.Coordinates{Lat: 43.71, Long: 7.28}
+	return &Collection{
+		Points: []Point{
+			{ID: "a", Name: "a.jpg", Time: event.From, Location: &first, Event: &event, Links: map[string]string{"thumb": "/photos/a/thumb"}},
+			{ID: "b", Name: "b.jpg", Time: event.To, Location: &second, Event: &event},
+			{ID: "c", Name: "c.jpg", Time: event.To},
+		},
+		Tracks: []Track{{Event: event, Points: []gps.Coordinates{first, second}}},
+	}
+}
+
+func TestWriteGeoJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteGeoJSON(&buf, testCollection()))
+	var fc struct {
+		Type     string
+		Features []struct {
+			Geometry *struct {
+				Type        string
+				Coordinates json.RawMessage
+			}
+			Properties map[string]interface{}
+		}
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &fc))
+	assert.Equal(t, "FeatureCollection", fc.Type)
+	require.Len(t, fc.Features, 4)
+	assert.Equal(t, "Point", fc.Features[0].Geometry.Type)
+	assert.JSONEq(t, `[7.26,43.7]`, string(fc.Features[0].Geometry.Coordinates))
+	assert.Equal(t, "/photos/a/thumb", fc.Features[0].Properties["thumb"])
+	assert.Equal(t, "e1", fc.Features[0].Properties["event"])
+	assert.Nil(t, fc.Features[2].Geometry, "Point without location must have no geometry")
+	assert.Equal(t, "LineString", fc.Features[3].Geometry.Type)
+	assert.JSONEq(t, `[[7.26,43.7],[7.28,43.71]]`, string(fc.Features[3].Geometry.Coordinates))
+	assert.Equal(t, "Holidays", fc.Features[3].Properties["name"])
+}
+
+func TestWriteKML(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteKML(&buf, testCollection()))
+	var doc kml
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &doc))
+	require.Len(t, doc.Document.Folders, 2)
+	photos, events := doc.Document.Folders[0], doc.Document.Folders[1]
+	require.Len(t, photos.Placemarks, 3)
+	assert.Equal(t, "7.260000,43.700000", photos.Placemarks[0].Point.Coordinates)
+	assert.Equal(t, `<img src="/photos/a/thumb"/>`, photos.Placemarks[0].Description.Text)
+	assert.Nil(t, photos.Placemarks[1].Description)
+	assert.Nil(t, photos.Placemarks[2].Point)
+	require.Len(t, events.Placemarks, 1)
+	assert.Equal(t, "Holidays", events.Placemarks[0].Name)
+	assert.Equal(t, "7.260000,43.700000 7.280000,43.710000", events.Placemarks[0].LineString.Coordinates)
+	assert.Equal(t, "2020-07-01T10:00:00Z", events.Placemarks[0].TimeSpan.Begin)
+}
+
+func TestFilterValidate(t *testing.T) {
+	assert.NoError(t, Filter{}.Validate())
+	assert.NoError(t, Filter{From: "2020-01-01", To: "2020-12-31"}.Validate())
+	assert.Error(t, Filter{From: "01.01.2020"}.Validate())
+	assert.True(t, Filter{From: "2020-07-01"}.includesDay(time.Date(2020, 7, 1, 23, 0, 0, 0, time.UTC)))
+	assert.False(t, Filter{To: "2020-06-30"}.includesDay(time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)))
+}
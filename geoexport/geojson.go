@@ -0,0 +1,81 @@
+package geoexport
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+)
+
+// GeoJSONContentType is the media type of GeoJSON documents (RFC 7946)
+const GeoJSONContentType = "application/geo+json"
+
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+// feature is a GeoJSON feature, its geometry is null if it has no location
+type feature struct {
+	Type       string                 `json:"type"`
+	Geometry   *geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// position returns the GeoJSON position of the coordinates: longitude first
+func position(c gps.Coordinates) [2]float64 {
+	return [2]float64{c.Long, c.Lat}
+}
+
+// WriteGeoJSON writes the collection as a GeoJSON FeatureCollection with a
+// Point feature per photo and a LineString feature per event
+func WriteGeoJSON(w io.Writer, c *Collection) error {
+	out := featureCollection{
+		Type:     "FeatureCollection",
+		Features: make([]feature, 0, len(c.Points)+len(c.Tracks)),
+	}
+	for _, p := range c.Points {
+		properties := map[string]interface{}{
+			"id":   p.ID,
+			"name": p.Name,
+			"time": p.Time.Format(time.RFC3339),
+		}
+		if p.Event != nil {
+			properties["event"] = p.Event.ID
+		}
+		for name, link := range p.Links {
+			properties[name] = link
+		}
+		f := feature{Type: "Feature", Properties: properties}
+		if p.Location != nil {
+			f.Geometry = &geometry{Type: "Point", Coordinates: position(*p.Location)}
+		}
+		out.Features = append(out.Features, f)
+	}
+	for _, t := range c.Tracks {
+		line := make([][2]float64, len(t.Points))
+		for i, p := range t.Points {
+			line[i] = position(p)
+		}
+		properties := map[string]interface{}{
+			"event": t.Event.ID,
+			"from":  t.Event.From.Format(time.RFC3339),
+			"to":    t.Event.To.Format(time.RFC3339),
+		}
+		if t.Event.Name != "" {
+			properties["name"] = t.Event.Name
+		}
+		out.Features = append(out.Features, feature{
+			Type:       "Feature",
+			Geometry:   &geometry{Type: "LineString", Coordinates: line},
+			Properties: properties,
+		})
+	}
+	return json.NewEncoder(w).Encode(&out)
+}
@@ -0,0 +1,110 @@
+package geoexport
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+)
+
+// KMLContentType is the media type of KML documents
+const KMLContentType = "application/vnd.google-earth.kml+xml"
+
+type kml struct {
+	XMLName  xml.Name    `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Document kmlDocument `xml:"Document"`
+}
+
+type kmlDocument struct {
+	Name    string      `xml:"name"`
+	Folders []kmlFolder `xml:"Folder"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string         `xml:"name"`
+	Description *kmlCDATA      `xml:"description,omitempty"`
+	TimeStamp   *kmlTimeStamp  `xml:"TimeStamp,omitempty"`
+	TimeSpan    *kmlTimeSpan   `xml:"TimeSpan,omitempty"`
+	Point       *kmlGeometry   `xml:"Point,omitempty"`
+	LineString  *kmlLineString `xml:"LineString,omitempty"`
+}
+
+type kmlCDATA struct {
+	Text string `xml:",cdata"`
+}
+
+type kmlTimeStamp struct {
+	When string `xml:"when"`
+}
+
+type kmlTimeSpan struct {
+	Begin string `xml:"begin"`
+	End   string `xml:"end"`
+}
+
+type kmlGeometry struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlLineString struct {
+	Tessellate  int    `xml:"tessellate"`
+	Coordinates string `xml:"coordinates"`
+}
+
+func kmlCoordinates(points ...gps.Coordinates) string {
+	values := make([]string, len(points))
+	for i, p := range points {
+		values[i] = fmt.Sprintf("%f,%f", p.Long, p.Lat)
+	}
+	return strings.Join(values, " ")
+}
+
+// WriteKML writes the collection as a KML document with a folder containing a
+// placemark per photo and a folder containing a path per event
+func WriteKML(w io.Writer, c *Collection) error {
+	photos := kmlFolder{Name: "Photos"}
+	for _, p := range c.Points {
+		placemark := kmlPlacemark{
+			Name:      p.Name,
+			TimeStamp: &kmlTimeStamp{When: p.Time.Format(time.RFC3339)},
+		}
+		if p.Location != nil {
+			placemark.Point = &kmlGeometry{Coordinates: kmlCoordinates(*p.Location)}
+		}
+		if thumb, found := p.Links["thumb"]; found {
+			placemark.Description = &kmlCDATA{Text: fmt.Sprintf(`<img src="%s"/>`, html.EscapeString(thumb))}
+		}
+		photos.Placemarks = append(photos.Placemarks, placemark)
+	}
+	events := kmlFolder{Name: "Events"}
+	for _, t := range c.Tracks {
+		name := t.Event.Name
+		if name == "" {
+			name = string(t.Event.ID)
+		}
+		events.Placemarks = append(events.Placemarks, kmlPlacemark{
+			Name: name,
+			TimeSpan: &kmlTimeSpan{
+				Begin: t.Event.From.Format(time.RFC3339),
+				End:   t.Event.To.Format(time.RFC3339),
+			},
+			LineString: &kmlLineString{Tessellate: 1, Coordinates: kmlCoordinates(t.Points...)},
+		})
+	}
+	doc := kml{Document: kmlDocument{Name: "Photos", Folders: []kmlFolder{photos, events}}}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(&doc)
+}
@@ -14,5 +14,6 @@ require (
 	github.com/stretchr/testify v1.6.1
 	go.etcd.io/bbolt v1.3.3
 	go.uber.org/zap v1.15.0
-	golang.org/x/net v0.0.0-20200625001655-4c5254603344
+	golang.org/x/image v0.18.0
+	golang.org/x/net v0.25.0 // selected through golang.org/x/image (x/text v0.16.0 requires x/tools, which requires it)
 )
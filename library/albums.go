@@ -0,0 +1,158 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrNoAlbumTitle = errors.New("Album needs a title")
+
+// AlbumID identifies an album
+type AlbumID string
+
+// Album is a collection of photos curated by the user, kept in the order
+// chosen by the user
+type Album struct {
+	ID          AlbumID `json:"id"`
+	Title       string  `json:"title"`
+	Description string  `json:"description,omitempty"`
+	// Cover is the photo representing the album, the first photo of the
+	// album is used if it is not set
+	Cover   PhotoID   `json:"cover,omitempty"`
+	Created time.Time `json:"created"`
+	// Count is the number of photos in the album
+	Count int `json:"count"`
+}
+
+// Validate returns an error if the album has no title
+func (a Album) Validate() error {
+	if strings.TrimSpace(a.Title) == "" {
+		return ErrNoAlbumTitle
+	}
+	return nil
+}
+
+// ErrAlbumNotFound is returned when an album does not exist
+type ErrAlbumNotFound AlbumID
+
+func (e ErrAlbumNotFound) Error() string {
+	return fmt.Sprintf("No album with id %s", string(e))
+}
+
+// ErrNotInAlbum is returned when referencing a photo of an album, e.g. as
+// cover, which is not part of the album
+type ErrNotInAlbum PhotoID
+
+func (e ErrNotInAlbum) Error() string {
+	return fmt.Sprintf("Photo %s is not part of the album", string(e))
+}
+
+// Albums persists the albums of the user
+type Albums interface {
+	// FindPaged returns the albums, most recently created first
+	FindPaged(ctx context.Context, start, maxCount int) ([]Album, bool, error)
+	Get(context.Context, AlbumID) (*Album, bool, error)
+	// Put creates or updates the title, the description and the cover of the
+	// given album, a new album is given an ID
+	Put(context.Context, *Album) error
+	Delete(context.Context, AlbumID) error
+
+	// PhotosPaged returns the photos of the given album in their order
+	PhotosPaged(ctx context.Context, id AlbumID, start, maxCount int) ([]PhotoID, bool, error)
+	// AddPhotos appends the given photos to the album, photos already part of
+	// the album are left where they are
+	AddPhotos(ctx context.Context, id AlbumID, photos []PhotoID) error
+	// RemovePhotos removes the given photos from the album
+	RemovePhotos(ctx context.Context, id AlbumID, photos []PhotoID) error
+	// MovePhoto moves the given photo right after the photo after, or to the
+	// start of the album if after is empty
+	MovePhoto(ctx context.Context, id AlbumID, photo, after PhotoID) error
+}
+
+const (
+	// positionDigits are the digits of position keys, in ascending byte order
+	positionDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// positionWidth is the number of digits of the keys of appended elements
+	positionWidth = 4
+)
+
+// PositionAfter returns the position key of an element appended after last,
+// an empty last being the start of an empty list. The first digits of last
+// are counted up as a number of fixed width, so that keys do not grow with
+// the number of appended elements.
+func PositionAfter(last string) string {
+	key := []byte(last)
+	if len(key) == 0 {
+		key = []byte{positionDigits[len(positionDigits)/2]}
+	}
+	for len(key) < positionWidth {
+		key = append(key, positionDigits[0])
+	}
+	key = key[:positionWidth]
+	for i := positionWidth - 1; i >= 0; i-- {
+		digit := strings.IndexByte(positionDigits, key[i])
+		if digit+1 < len(positionDigits) {
+			key[i] = positionDigits[digit+1]
+			// Keys never end with the smallest digit
+			if key[positionWidth-1] == positionDigits[0] {
+				key[positionWidth-1] = positionDigits[1]
+			}
+			return string(key)
+		}
+		key[i] = positionDigits[0]
+	}
+	// All keys of this width after last are used
+	return PositionBetween(last, "")
+}
+
+// PositionBetween returns a position key sorting strictly between before and
+// after, an empty before is the start and an empty after the end of the
+// list. Moving or inserting an element thus only changes its own key, appended
+// elements should use PositionAfter instead. Keys never end with
+// the smallest digit, so that a key can always be found between two keys.
+func PositionBetween(before, after string) string {
+	if after != "" {
+		// Keep the common prefix, before being padded with the smallest digit
+		n := 0
+		for n < len(after) && digitAt(before, n) == after[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(before) {
+				rest = before[n:]
+			}
+			return after[:n] + PositionBetween(rest, after[n:])
+		}
+	}
+	low := 0
+	if before != "" {
+		low = strings.IndexByte(positionDigits, before[0])
+	}
+	high := len(positionDigits)
+	if after != "" {
+		high = strings.IndexByte(positionDigits, after[0])
+	}
+	if high-low > 1 {
+		return string(positionDigits[(low+high)/2])
+	}
+	// The first digits are adjacent
+	if len(after) > 1 {
+		return after[:1]
+	}
+	rest := ""
+	if before != "" {
+		rest = before[1:]
+	}
+	return string(positionDigits[low]) + PositionBetween(rest, "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return positionDigits[0]
+}
@@ -0,0 +1,80 @@
+package library
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPositionBetween(t *testing.T) {
+	data := []struct {
+		before, after string
+	}{
+		{"", ""},
+		{"V", ""},
+		{"", "V"},
+		{"V", "W"},
+		{"V", "V1"},
+		{"V1", "W"},
+		{"", "1"},
+		{"z", ""},
+		{"zz", ""},
+		{"", "01"},
+	}
+	for _, d := range data {
+		p := PositionBetween(d.before, d.after)
+		assert.True(t, p > d.before, "%q must sort after %q", p, d.before)
+		if d.after != "" {
+			assert.True(t, p < d.after, "%q must sort before %q", p, d.after)
+		}
+		assert.NotEqual(t, positionDigits[0], p[len(p)-1], "%q must not end with the smallest digit", p)
+	}
+}
+
+func TestPositionBetweenKeepsOrder(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	var keys []string
+	for i := 0; i < 500; i++ {
+		at := random.Intn(len(keys) + 1)
+		var before, after string
+		if at > 0 {
+			before = keys[at-1]
+		}
+		if at < len(keys) {
+			after = keys[at]
+		}
+		key := PositionBetween(before, after)
+		keys = append(keys[:at], append([]string{key}, keys[at:]...)...)
+	}
+	assert.True(t, sort.StringsAreSorted(keys))
+	for i := 1; i < len(keys); i++ {
+		assert.NotEqual(t, keys[i-1], keys[i])
+	}
+}
+
+func TestPositionAfter(t *testing.T) {
+	var keys []string
+	last := ""
+	for i := 0; i < 5000; i++ {
+		last = PositionAfter(last)
+		keys = append(keys, last)
+	}
+	assert.True(t, sort.StringsAreSorted(keys))
+	for i, k := range keys {
+		assert.Len(t, k, positionWidth, "Appended keys must not grow")
+		assert.NotEqual(t, positionDigits[0], k[len(k)-1], "%q must not end with the smallest digit", k)
+		if i > 0 {
+			assert.NotEqual(t, keys[i-1], k)
+		}
+	}
+
+	// Appending after inserted keys and once all keys are used
+	for _, before := range []string{"V0zV", "V", "zzzz", "zzzzz"} {
+		p := PositionAfter(before)
+		assert.True(t, p > before, "%q must sort after %q", p, before)
+	}
+	between := PositionBetween(keys[10], keys[11])
+	assert.True(t, keys[10] < between && between < keys[11], "%q must sort between %q and %q", between, keys[10], keys[11])
+}
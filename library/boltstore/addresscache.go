@@ -0,0 +1,66 @@
+package boltstore
+
+import (
+	"context"
+	"encoding/json"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// addressCacheBucket stores reverse geocoded addresses, indexed by rounded coordinates
+	addressCacheBucket = []byte("addressCache_v2")
+)
+
+// AddressCache persists the addresses resolved by reverse geocoding
+type AddressCache struct {
+	db *bolt.DB
+}
+
+type cachedAddress struct {
+	gps.AddressFields
+	BoundingBox *gps.Rect `json:"boundingbox,omitempty"`
+}
+
+func NewAddressCache(db *bolt.DB) (*AddressCache, error) {
+	// Addresses cached without administrative levels must be resolved again
+	if err := deleteBuckets(db, "addressCache"); err != nil {
+		return nil, err
+	}
+	if err := createBucket(db, addressCacheBucket); err != nil {
+		return nil, err
+	}
+	return &AddressCache{db: db}, nil
+}
+
+func (c *AddressCache) Get(ctx context.Context, key string) (address *gps.Address, found bool, err error) {
+	err = c.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(addressCacheBucket).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		var cached cachedAddress
+		if err := json.Unmarshal(data, &cached); err != nil {
+			return err
+		}
+		a := gps.NewAddress(cached.AddressFields)
+		a.BoundingBox = cached.BoundingBox
+		address, found = &a, true
+		return nil
+	})
+	return
+}
+
+func (c *AddressCache) Put(ctx context.Context, key string, address *gps.Address) error {
+	data, err := json.Marshal(cachedAddress{
+		AddressFields: address.AddressFields,
+		BoundingBox:   address.BoundingBox,
+	})
+	if err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(addressCacheBucket).Put([]byte(key), data)
+	})
+}
@@ -0,0 +1,37 @@
+package boltstore
+
+import (
+	"context"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestAddressCache(t *testing.T) {
+	runTestWithBoltDB(t, testAddressCache)
+}
+
+func testAddressCache(t *testing.T, db *bolt.DB) {
+	ctx := context.Background()
+	cache, err := NewAddressCache(db)
+	if err != nil {
+		t.Fatalf("Failed to init address cache: %s", err)
+	}
+	if _, found, err := cache.Get(ctx, "48.208,16.372"); err != nil || found {
+		t.Errorf("Expected no address, got found=%t, err=%v", found, err)
+	}
+	wien := gps.AsAddress("Austria", "AT", "Wien", "1010")
+	bbox := gps.RectFrom(16.3551666, 48.2018494, 16.3751666, 48.2218494)
+	wien.BoundingBox = &bbox
+	if err := cache.Put(ctx, "48.208,16.372", &wien); err != nil {
+		t.Fatalf("Failed to store address: %s", err)
+	}
+	address, found, err := cache.Get(ctx, "48.208,16.372")
+	if err != nil {
+		t.Fatalf("Failed to read address: %s", err)
+	}
+	assert.True(t, found)
+	assert.Equal(t, wien, *address)
+}
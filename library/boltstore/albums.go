@@ -0,0 +1,286 @@
+package boltstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// albumsBucket contains the albums, indexed by ID
+	albumsBucket = []byte("albums")
+	// photosByAlbumBucket contains one bucket per album holding the IDs of
+	// its photos, indexed by their position key
+	photosByAlbumBucket = []byte("photosByAlbum")
+	// positionsByAlbumBucket contains one bucket per album holding the
+	// position keys of its photos, indexed by PhotoID
+	positionsByAlbumBucket = []byte("positionsByAlbum")
+)
+
+// AlbumStore persists the albums of the user with the order of their photos
+type AlbumStore struct {
+	db *bolt.DB
+}
+
+func NewAlbumStore(db *bolt.DB) (*AlbumStore, error) {
+	for _, name := range [][]byte{albumsBucket, photosByAlbumBucket, positionsByAlbumBucket} {
+		if err := createBucket(db, name); err != nil {
+			return nil, err
+		}
+	}
+	return &AlbumStore{db: db}, nil
+}
+
+// FindPaged returns the albums, most recently created first
+func (s *AlbumStore) FindPaged(ctx context.Context, start, maxCount int) ([]library.Album, bool, error) {
+	albums := []library.Album{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(albumsBucket).ForEach(func(k, v []byte) error {
+			var a library.Album
+			if err := json.Unmarshal(v, &a); err != nil {
+				return err
+			}
+			albums = append(albums, a)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	sort.SliceStable(albums, func(i, j int) bool {
+		return albums[i].Created.After(albums[j].Created)
+	})
+	if start >= len(albums) {
+		return []library.Album{}, false, nil
+	}
+	albums = albums[start:]
+	if len(albums) > maxCount {
+		return albums[:maxCount], true, nil
+	}
+	return albums, false, nil
+}
+
+func (s *AlbumStore) Get(ctx context.Context, id library.AlbumID) (album *library.Album, found bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		album, err = albumIn(tx, id)
+		return err
+	})
+	return album, album != nil, err
+}
+
+func albumIn(tx *bolt.Tx, id library.AlbumID) (*library.Album, error) {
+	data := tx.Bucket(albumsBucket).Get([]byte(id))
+	if data == nil {
+		return nil, nil
+	}
+	var a library.Album
+	return &a, json.Unmarshal(data, &a)
+}
+
+func putAlbum(tx *bolt.Tx, album *library.Album) error {
+	data, err := json.Marshal(album)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(albumsBucket).Put([]byte(album.ID), data)
+}
+
+// Put creates or updates the given album, new albums are given an ID. The
+// creation time and the number of photos are maintained by the store.
+func (s *AlbumStore) Put(ctx context.Context, album *library.Album) error {
+	if err := album.Validate(); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if album.ID == "" {
+			album.ID = library.AlbumID(uuid.New().String())
+			album.Created = time.Now().UTC()
+			album.Count = 0
+			album.Cover = ""
+			return putAlbum(tx, album)
+		}
+		existing, err := albumIn(tx, album.ID)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return library.ErrAlbumNotFound(album.ID)
+		}
+		if album.Cover != "" && positionOf(tx, album.ID, album.Cover) == nil {
+			return library.ErrNotInAlbum(album.Cover)
+		}
+		album.Created = existing.Created
+		album.Count = existing.Count
+		return putAlbum(tx, album)
+	})
+}
+
+func (s *AlbumStore) Delete(ctx context.Context, id library.AlbumID) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{photosByAlbumBucket, positionsByAlbumBucket} {
+			if tx.Bucket(name).Bucket([]byte(id)) == nil {
+				continue
+			}
+			if err := tx.Bucket(name).DeleteBucket([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(albumsBucket).Delete([]byte(id))
+	})
+}
+
+// PhotosPaged returns the photos of the given album in their order
+func (s *AlbumStore) PhotosPaged(ctx context.Context, id library.AlbumID, start, maxCount int) (photos []library.PhotoID, hasMore bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		album, err := albumIn(tx, id)
+		if err != nil {
+			return err
+		}
+		if album == nil {
+			return library.ErrAlbumNotFound(id)
+		}
+		b := tx.Bucket(photosByAlbumBucket).Bucket([]byte(id))
+		if b == nil {
+			return nil
+		}
+		c := newForwardCursor(b.Cursor()).Skip(uint(start)).Limit(uint(maxCount))
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			photos = append(photos, library.PhotoID(v))
+		}
+		hasMore = c.HasMore()
+		return nil
+	})
+	return
+}
+
+// AddPhotos appends the given photos to the album, photos already part of
+// the album keep their position
+func (s *AlbumStore) AddPhotos(ctx context.Context, id library.AlbumID, photos []library.PhotoID) error {
+	return s.updatePhotos(id, func(tx *bolt.Tx, album *library.Album, byPosition, positions *bolt.Bucket) error {
+		last, _ := byPosition.Cursor().Last()
+		for _, p := range photos {
+			if positions.Get([]byte(p)) != nil {
+				continue
+			}
+			position := []byte(library.PositionAfter(string(last)))
+			if err := putPosition(byPosition, positions, p, position); err != nil {
+				return err
+			}
+			last = position
+			album.Count++
+		}
+		return nil
+	})
+}
+
+// RemovePhotos removes the given photos from the album, the cover is reset
+// if it is removed
+func (s *AlbumStore) RemovePhotos(ctx context.Context, id library.AlbumID, photos []library.PhotoID) error {
+	return s.updatePhotos(id, func(tx *bolt.Tx, album *library.Album, byPosition, positions *bolt.Bucket) error {
+		for _, p := range photos {
+			removed, err := removePosition(byPosition, positions, p)
+			if err != nil {
+				return err
+			}
+			if !removed {
+				continue
+			}
+			album.Count--
+			if album.Cover == p {
+				album.Cover = ""
+			}
+		}
+		return nil
+	})
+}
+
+// MovePhoto moves the given photo right after the photo after, or to the
+// start of the album if after is empty
+func (s *AlbumStore) MovePhoto(ctx context.Context, id library.AlbumID, photo, after library.PhotoID) error {
+	return s.updatePhotos(id, func(tx *bolt.Tx, album *library.Album, byPosition, positions *bolt.Bucket) error {
+		if photo == after {
+			return nil
+		}
+		if positions.Get([]byte(photo)) == nil {
+			return library.ErrNotInAlbum(photo)
+		}
+		var anchor []byte
+		if after != "" {
+			if anchor = positions.Get([]byte(after)); anchor == nil {
+				return library.ErrNotInAlbum(after)
+			}
+			// The key is only valid during the transaction
+			anchor = append([]byte{}, anchor...)
+		}
+		if _, err := removePosition(byPosition, positions, photo); err != nil {
+			return err
+		}
+		c := byPosition.Cursor()
+		var next []byte
+		if anchor == nil {
+			next, _ = c.First()
+		} else if k, _ := c.Seek(anchor); k != nil && bytes.Equal(k, anchor) {
+			next, _ = c.Next()
+		}
+		position := []byte(library.PositionBetween(string(anchor), string(next)))
+		return putPosition(byPosition, positions, photo, position)
+	})
+}
+
+// updatePhotos calls f with the buckets holding the photos of the given album
+// and stores the album afterwards
+func (s *AlbumStore) updatePhotos(id library.AlbumID, f func(tx *bolt.Tx, album *library.Album, byPosition, positions *bolt.Bucket) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		album, err := albumIn(tx, id)
+		if err != nil {
+			return err
+		}
+		if album == nil {
+			return library.ErrAlbumNotFound(id)
+		}
+		byPosition, err := tx.Bucket(photosByAlbumBucket).CreateBucketIfNotExists([]byte(id))
+		if err != nil {
+			return err
+		}
+		positions, err := tx.Bucket(positionsByAlbumBucket).CreateBucketIfNotExists([]byte(id))
+		if err != nil {
+			return err
+		}
+		if err := f(tx, album, byPosition, positions); err != nil {
+			return err
+		}
+		return putAlbum(tx, album)
+	})
+}
+
+func positionOf(tx *bolt.Tx, id library.AlbumID, photo library.PhotoID) []byte {
+	positions := tx.Bucket(positionsByAlbumBucket).Bucket([]byte(id))
+	if positions == nil {
+		return nil
+	}
+	return positions.Get([]byte(photo))
+}
+
+func putPosition(byPosition, positions *bolt.Bucket, photo library.PhotoID, position []byte) error {
+	if err := byPosition.Put(position, []byte(photo)); err != nil {
+		return err
+	}
+	return positions.Put([]byte(photo), position)
+}
+
+func removePosition(byPosition, positions *bolt.Bucket, photo library.PhotoID) (bool, error) {
+	position := positions.Get([]byte(photo))
+	if position == nil {
+		return false, nil
+	}
+	if err := byPosition.Delete(position); err != nil {
+		return false, err
+	}
+	return true, positions.Delete([]byte(photo))
+}
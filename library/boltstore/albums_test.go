@@ -0,0 +1,85 @@
+package boltstore
+
+import (
+	"context"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestAlbumStore(t *testing.T) {
+	runTestWithBoltDB(t, testAlbumStore)
+}
+
+func albumPhotos(t *testing.T, s *AlbumStore, id library.AlbumID) []library.PhotoID {
+	photos, _, err := s.PhotosPaged(context.Background(), id, 0, 100)
+	assert.NoError(t, err)
+	return photos
+}
+
+func testAlbumStore(t *testing.T, db *bolt.DB) {
+	ctx := context.Background()
+	s, err := NewAlbumStore(db)
+	if err != nil {
+		t.Fatalf("Failed to init album store: %s", err)
+	}
+	assert.Equal(t, library.ErrNoAlbumTitle, s.Put(ctx, &library.Album{Title: " "}))
+
+	album := library.Album{Title: "Holidays", Description: "Summer 2019"}
+	if err := s.Put(ctx, &album); err != nil {
+		t.Fatalf("Failed to create album: %s", err)
+	}
+	assert.NotEmpty(t, album.ID)
+	assert.False(t, album.Created.IsZero())
+
+	assert.NoError(t, s.AddPhotos(ctx, album.ID, []library.PhotoID{"a", "b", "c"}))
+	assert.NoError(t, s.AddPhotos(ctx, album.ID, []library.PhotoID{"b", "d"}))
+	assert.Equal(t, []library.PhotoID{"a", "b", "c", "d"}, albumPhotos(t, s, album.ID))
+
+	photos, hasMore, err := s.PhotosPaged(ctx, album.ID, 1, 2)
+	assert.NoError(t, err)
+	assert.True(t, hasMore)
+	assert.Equal(t, []library.PhotoID{"b", "c"}, photos)
+
+	assert.NoError(t, s.MovePhoto(ctx, album.ID, "d", ""))
+	assert.Equal(t, []library.PhotoID{"d", "a", "b", "c"}, albumPhotos(t, s, album.ID))
+	assert.NoError(t, s.MovePhoto(ctx, album.ID, "d", "b"))
+	assert.Equal(t, []library.PhotoID{"a", "b", "d", "c"}, albumPhotos(t, s, album.ID))
+	assert.NoError(t, s.MovePhoto(ctx, album.ID, "a", "c"))
+	assert.Equal(t, []library.PhotoID{"b", "d", "c", "a"}, albumPhotos(t, s, album.ID))
+	assert.Equal(t, library.ErrNotInAlbum("x"), s.MovePhoto(ctx, album.ID, "a", "x"))
+
+	album.Cover = "x"
+	assert.Equal(t, library.ErrNotInAlbum("x"), s.Put(ctx, &album))
+	album.Cover = "c"
+	album.Title = "Summer holidays"
+	assert.NoError(t, s.Put(ctx, &album))
+
+	assert.NoError(t, s.RemovePhotos(ctx, album.ID, []library.PhotoID{"c", "x"}))
+	assert.Equal(t, []library.PhotoID{"b", "d", "a"}, albumPhotos(t, s, album.ID))
+	stored, found, err := s.Get(ctx, album.ID)
+	assert.NoError(t, err)
+	if assert.True(t, found) {
+		assert.Equal(t, "Summer holidays", stored.Title)
+		assert.Equal(t, 3, stored.Count)
+		assert.Empty(t, stored.Cover, "Removing the cover must reset it")
+	}
+
+	other := library.Album{Title: "Other"}
+	assert.NoError(t, s.Put(ctx, &other))
+	albums, _, err := s.FindPaged(ctx, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, albums, 2) {
+		assert.Equal(t, other.ID, albums[0].ID, "Newest albums must come first")
+	}
+
+	assert.NoError(t, s.Delete(ctx, album.ID))
+	_, found, err = s.Get(ctx, album.ID)
+	assert.NoError(t, err)
+	assert.False(t, found)
+	_, _, err = s.PhotosPaged(ctx, album.ID, 0, 10)
+	assert.Equal(t, library.ErrAlbumNotFound(album.ID), err)
+	assert.Equal(t, library.ErrAlbumNotFound("x"), s.AddPhotos(ctx, "x", []library.PhotoID{"a"}))
+}
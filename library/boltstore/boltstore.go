@@ -135,11 +135,25 @@ func (store *BoltStore) Update(p *library.Photo) error {
 		return err
 	}
 	return store.db.Update(func(tx *bolt.Tx) error {
-		internalID := tx.Bucket(idMapBucket).Get([]byte(p.ID))
+		idMap := tx.Bucket(idMapBucket)
+		internalID := idMap.Get([]byte(p.ID))
 		if internalID == nil {
 			return library.NotFound(p.ID)
 		}
 		b := tx.Bucket(photosBucket)
+		if !bytes.Equal(internalID, p.SortID) {
+			// SortID has changed, re-key the photo
+			if existing := b.Get(p.SortID); existing != nil {
+				return library.PhotoAlreadyExists(p.ID)
+			}
+			if err := b.Delete(internalID); err != nil {
+				return err
+			}
+			if err := idMap.Put([]byte(p.ID), p.SortID); err != nil {
+				return err
+			}
+			internalID = p.SortID
+		}
 		if err := b.Put(internalID, encoded); err != nil {
 			return err
 		}
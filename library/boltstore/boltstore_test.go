@@ -55,6 +55,30 @@ func TestAddThenGet(t *testing.T) {
 	})
 }
 
+func TestUpdateWithNewSortID(t *testing.T) {
+	runTestWithStore(t, func(t *testing.T, db *BoltStore) {
+		photo := library.RandomPhoto()
+		if err := db.Add(photo); err != nil {
+			t.Fatalf("Failed to add photo: %s", err)
+		}
+		photo.SortID = library.OrderedID("2019-01-01T00:00:00Z" + string(photo.ID))
+		if err := db.Update(photo); err != nil {
+			t.Fatalf("Failed to update photo: %s", err)
+		}
+		found, _ := db.FindAll(consts.Ascending)
+		if len(found) != 1 {
+			t.Fatalf("Bad number of photos returned, expected %d, got %d", 1, len(found))
+		}
+		updated, err := db.Get(photo.ID)
+		if err != nil {
+			t.Fatalf("Should have found a photo with id %s", photo.ID)
+		}
+		if string(updated.SortID) != string(photo.SortID) {
+			t.Errorf("Bad SortID: expected %s, got %s", photo.SortID, updated.SortID)
+		}
+	})
+}
+
 func BenchmarkAdd(b *testing.B) {
 	// Initialize store
 	dbFile := filepath.Join(dbpath, dbfile)
@@ -11,7 +11,7 @@ import (
 	"go.uber.org/zap"
 )
 
-const DateIndexVersion = library.Version(3)
+const DateIndexVersion = library.Version(4)
 
 // DateIndex indexes photos by date
 type DateIndex struct {
@@ -42,16 +42,18 @@ func NewDateIndex(db *bolt.DB) (*DateIndex, error) {
 func (d *DateIndex) MigrateStructure(ctx context.Context, from library.Version) (library.Version, bool, error) {
 	migrations := index.NewStructuralMigrations()
 	migrations.Register(3, resetBuckets(d.db, datesBucket))
+	migrations.Register(4, resetBuckets(d.db, datesBucket))
 	reindex, err := migrations.Apply(ctx, from, DateIndexVersion)
 	return DateIndexVersion, reindex, err
 }
 
-// Add will add the given photo to this date index based on its taken time
+// Add will add the given photo to this date index based on the day it was
+// taken on in the local time of where it was taken
 func (d *DateIndex) Add(ctx context.Context, photo *library.Photo) error {
 	return d.db.Update(func(tx *bolt.Tx) error {
 		log, _ := logging.FromWithNameAndFields(ctx, "boltdateindex")
 		b := tx.Bucket(datesBucket)
-		key := d.dayKey(photo.DateTaken)
+		key := d.dayKey(photo.LocalTime())
 		dayBucket, err := b.CreateBucketIfNotExists([]byte(key))
 		if err != nil {
 			log.Warn("Failed to create sub-bucket", zap.String("bucket", key), zap.Error(err))
@@ -61,6 +63,30 @@ func (d *DateIndex) Add(ctx context.Context, photo *library.Photo) error {
 	})
 }
 
+// Move moves the given photo from the day it was taken on before to the day
+// it is taken on after a change of its capture time
+func (d *DateIndex) Move(ctx context.Context, before, after *library.Photo) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(datesBucket)
+		oldKey := []byte(d.dayKey(before.LocalTime()))
+		if dayBucket := b.Bucket(oldKey); dayBucket != nil {
+			if err := dayBucket.Delete([]byte(before.SortID)); err != nil {
+				return err
+			}
+			if k, _ := dayBucket.Cursor().First(); k == nil {
+				if err := b.DeleteBucket(oldKey); err != nil {
+					return err
+				}
+			}
+		}
+		dayBucket, err := b.CreateBucketIfNotExists([]byte(d.dayKey(after.LocalTime())))
+		if err != nil {
+			return err
+		}
+		return dayBucket.Put([]byte(after.SortID), []byte(after.ID))
+	})
+}
+
 // FindRange returns all photos in the given date range
 func (d *DateIndex) FindRangePaged(ctx context.Context, from, to time.Time, start, maxCount int) (ids []library.PhotoID, hasMore bool, err error) {
 	from, to = startOfDay(from), endOfDay(to)
@@ -3,6 +3,7 @@ package boltstore
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"bitbucket.org/kleinnic74/photos/library"
@@ -14,6 +15,8 @@ import (
 var (
 	eventsBucket        = []byte("_events")
 	photosByEventBucket = []byte("_photosByEvent")
+	// eventOfPhotosBucket contains the ID of the event of each photo
+	eventOfPhotosBucket = []byte("_eventOfPhotos")
 )
 
 type EventID string
@@ -23,10 +26,31 @@ type Event struct {
 	Name string    `json:"name,omitempty"`
 	From time.Time `json:"from"`
 	To   time.Time `json:"to"`
+	// Manual events have been edited by the user and are left untouched when
+	// events are identified again
+	Manual bool `json:"manual,omitempty"`
 }
 
+// ErrEventNotFound is returned when an event does not exist
+type ErrEventNotFound EventID
+
+func (e ErrEventNotFound) Error() string {
+	return fmt.Sprintf("No event with id %s", string(e))
+}
+
+// EventPhotos is an event with all its photos
+type EventPhotos struct {
+	Event
+	Photos []library.ExtendedPhotoID
+}
+
+// EventsChangedCallback is called after the given photos have been moved to
+// another event or their event has been renamed
+type EventsChangedCallback func(ctx context.Context, photos []library.PhotoID) error
+
 type EventIndex struct {
-	db *bolt.DB
+	db        *bolt.DB
+	callbacks []EventsChangedCallback
 }
 
 func NewEventIndex(db *bolt.DB) (*EventIndex, error) {
@@ -37,7 +61,23 @@ func NewEventIndex(db *bolt.DB) (*EventIndex, error) {
 		if _, err := tx.CreateBucketIfNotExists(photosByEventBucket); err != nil {
 			return err
 		}
-		return nil
+		if tx.Bucket(eventOfPhotosBucket) != nil {
+			return nil
+		}
+		// Events stored before the event of each photo was tracked
+		eventOfPhotos, err := tx.CreateBucket(eventOfPhotosBucket)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(photosByEventBucket).ForEach(func(eventID, _ []byte) error {
+			photos := tx.Bucket(photosByEventBucket).Bucket(eventID)
+			if photos == nil {
+				return nil
+			}
+			return photos.ForEach(func(_, photoID []byte) error {
+				return eventOfPhotos.Put(photoID, eventID)
+			})
+		})
 	}); err != nil {
 		return nil, err
 	}
@@ -46,45 +86,304 @@ func NewEventIndex(db *bolt.DB) (*EventIndex, error) {
 	}, nil
 }
 
+func (index *EventIndex) AddEventsChangedCallback(callback EventsChangedCallback) {
+	index.callbacks = append(index.callbacks, callback)
+}
+
+// changed calls the callbacks after the event of the given photos changed
+func (index *EventIndex) changed(ctx context.Context, photos []library.PhotoID) {
+	if len(photos) == 0 {
+		return
+	}
+	log := logging.From(ctx)
+	for _, cb := range index.callbacks {
+		if err := cb(ctx, photos); err != nil {
+			log.Warn("Events change callback failed", zap.Error(err))
+		}
+	}
+}
+
 func (index *EventIndex) Add(ctx context.Context, e Event) error {
-	return index.db.Update(func(tx *bolt.Tx) error {
+	var changed []library.PhotoID
+	err := index.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(eventsBucket)
 		v, err := json.Marshal(&e)
 		if err != nil {
 			return err
 		}
+		changed = photosIn(tx, e.ID)
 		return b.Put([]byte(e.ID), v)
 	})
+	if err == nil {
+		index.changed(ctx, changed)
+	}
+	return err
 }
 
+// photosIn returns the IDs of the photos of the given event
+func photosIn(tx *bolt.Tx, id EventID) (photos []library.PhotoID) {
+	if b := tx.Bucket(photosByEventBucket).Bucket([]byte(id)); b != nil {
+		b.ForEach(func(_, photoID []byte) error {
+			photos = append(photos, library.PhotoID(photoID))
+			return nil
+		})
+	}
+	return
+}
+
+// AddPhotosToEvent adds the given photos to the given identified event,
+// moving them out of the event they belonged to before. Manual events and
+// their photos are left untouched.
 func (index *EventIndex) AddPhotosToEvent(ctx context.Context, e Event, photos []library.ExtendedPhotoID) error {
 	log, ctx := logging.FromWithNameAndFields(ctx, "eventindex", zap.String("event", string(e.ID)))
 	encoded, err := json.Marshal(&e)
 	if err != nil {
 		return err
 	}
+	var changed []library.PhotoID
 	err = index.db.Update(func(tx *bolt.Tx) error {
-		log.Info("Updating event", zap.Int("nbPhotos", len(photos)))
-		if err := tx.Bucket(eventsBucket).Put([]byte(e.ID), encoded); err != nil {
+		if existing, err := eventIn(tx, e.ID); err != nil || (existing != nil && existing.Manual) {
 			return err
 		}
-		b, err := tx.Bucket(photosByEventBucket).CreateBucketIfNotExists([]byte(e.ID))
-		if err != nil {
+		log.Info("Updating event", zap.Int("nbPhotos", len(photos)))
+		if err := tx.Bucket(eventsBucket).Put([]byte(e.ID), encoded); err != nil {
 			return err
 		}
 		for _, p := range photos {
-			if err := b.Put([]byte(p.SortID), []byte(p.ID)); err != nil {
+			previous, err := eventOfPhoto(tx, p.ID)
+			if err != nil {
+				return err
+			}
+			if previous != nil && previous.Manual {
+				continue
+			}
+			if err := addPhoto(tx, e.ID, p); err != nil {
 				return err
 			}
 		}
+		changed = photosIn(tx, e.ID)
 		return nil
 	})
 	if err != nil {
 		log.Warn("Event update failed", zap.Error(err))
+		return err
+	}
+	index.changed(ctx, changed)
+	return nil
+}
+
+// Replace atomically deletes the given events and stores the given events
+// with exactly the given photos. Photos are moved out of the events they
+// belonged to before, events left without photos are deleted.
+func (index *EventIndex) Replace(ctx context.Context, deleted []EventID, events ...EventPhotos) error {
+	var changed []library.PhotoID
+	err := index.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range deleted {
+			changed = append(changed, photosIn(tx, id)...)
+			if err := deleteEvent(tx, id); err != nil {
+				return err
+			}
+		}
+		for _, e := range events {
+			encoded, err := json.Marshal(&e.Event)
+			if err != nil {
+				return err
+			}
+			if err := tx.Bucket(eventsBucket).Put([]byte(e.ID), encoded); err != nil {
+				return err
+			}
+			keep := make(map[library.PhotoID]bool)
+			for _, p := range e.Photos {
+				keep[p.ID] = true
+				if err := addPhoto(tx, e.ID, p); err != nil {
+					return err
+				}
+			}
+			var removed []library.PhotoID
+			if b := tx.Bucket(photosByEventBucket).Bucket([]byte(e.ID)); b != nil {
+				b.ForEach(func(_, photoID []byte) error {
+					if !keep[library.PhotoID(photoID)] {
+						removed = append(removed, library.PhotoID(photoID))
+					}
+					return nil
+				})
+			}
+			for _, id := range removed {
+				if err := removePhoto(tx, e.ID, id); err != nil {
+					return err
+				}
+			}
+			changed = append(changed, removed...)
+			changed = append(changed, photosIn(tx, e.ID)...)
+		}
+		return nil
+	})
+	if err == nil {
+		index.changed(ctx, changed)
 	}
 	return err
 }
 
+func eventIn(tx *bolt.Tx, id EventID) (*Event, error) {
+	data := tx.Bucket(eventsBucket).Get([]byte(id))
+	if data == nil {
+		return nil, nil
+	}
+	var e Event
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func eventOfPhoto(tx *bolt.Tx, id library.PhotoID) (*Event, error) {
+	eventID := tx.Bucket(eventOfPhotosBucket).Get([]byte(id))
+	if eventID == nil {
+		return nil, nil
+	}
+	return eventIn(tx, EventID(eventID))
+}
+
+// addPhoto adds the photo to the given event, removing it from its previous
+// event
+func addPhoto(tx *bolt.Tx, id EventID, p library.ExtendedPhotoID) error {
+	if previous := tx.Bucket(eventOfPhotosBucket).Get([]byte(p.ID)); previous != nil && EventID(previous) != id {
+		if err := removePhoto(tx, EventID(previous), p.ID); err != nil {
+			return err
+		}
+	}
+	b, err := tx.Bucket(photosByEventBucket).CreateBucketIfNotExists([]byte(id))
+	if err != nil {
+		return err
+	}
+	if err := b.Put([]byte(p.SortID), []byte(p.ID)); err != nil {
+		return err
+	}
+	return tx.Bucket(eventOfPhotosBucket).Put([]byte(p.ID), []byte(id))
+}
+
+// removePhoto removes the photo from the given event, the event is deleted if
+// it has no photos left
+func removePhoto(tx *bolt.Tx, id EventID, photo library.PhotoID) error {
+	if err := tx.Bucket(eventOfPhotosBucket).Delete([]byte(photo)); err != nil {
+		return err
+	}
+	b := tx.Bucket(photosByEventBucket).Bucket([]byte(id))
+	if b == nil {
+		return nil
+	}
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if library.PhotoID(v) == photo {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	if k, _ := b.Cursor().First(); k != nil {
+		return nil
+	}
+	return deleteEvent(tx, id)
+}
+
+func deleteEvent(tx *bolt.Tx, id EventID) error {
+	if b := tx.Bucket(photosByEventBucket).Bucket([]byte(id)); b != nil {
+		if err := b.ForEach(func(_, photoID []byte) error {
+			if eventID := tx.Bucket(eventOfPhotosBucket).Get(photoID); EventID(eventID) == id {
+				return tx.Bucket(eventOfPhotosBucket).Delete(photoID)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := tx.Bucket(photosByEventBucket).DeleteBucket([]byte(id)); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(eventsBucket).Delete([]byte(id))
+}
+
+// Move updates the SortID of the given photo in the event it belongs to
+func (index *EventIndex) Move(ctx context.Context, before, after *library.Photo) error {
+	return index.db.Update(func(tx *bolt.Tx) error {
+		eventID := tx.Bucket(eventOfPhotosBucket).Get([]byte(before.ID))
+		if eventID == nil {
+			return nil
+		}
+		b := tx.Bucket(photosByEventBucket).Bucket(eventID)
+		if b == nil || b.Get([]byte(before.SortID)) == nil {
+			return nil
+		}
+		if err := b.Delete([]byte(before.SortID)); err != nil {
+			return err
+		}
+		return b.Put([]byte(after.SortID), []byte(after.ID))
+	})
+}
+
+// Get returns the event with the given ID
+func (index *EventIndex) Get(ctx context.Context, id EventID) (e *Event, found bool, err error) {
+	err = index.db.View(func(tx *bolt.Tx) error {
+		e, err = eventIn(tx, id)
+		found = e != nil
+		return err
+	})
+	return
+}
+
+// EventOf returns the event the given photo belongs to
+func (index *EventIndex) EventOf(ctx context.Context, photo library.PhotoID) (e *Event, found bool, err error) {
+	err = index.db.View(func(tx *bolt.Tx) error {
+		e, err = eventOfPhoto(tx, photo)
+		found = e != nil
+		return err
+	})
+	return
+}
+
+// PhotosOf returns all photos of the given event, in chronological order
+func (index *EventIndex) PhotosOf(ctx context.Context, id EventID) (photos []library.ExtendedPhotoID, err error) {
+	err = index.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(photosByEventBucket).Bucket([]byte(id))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			photos = append(photos, library.ExtendedPhotoID{
+				ID:     library.PhotoID(v),
+				SortID: library.OrderedID(append([]byte{}, k...)),
+			})
+			return nil
+		})
+	})
+	return
+}
+
+// ManualPhotos returns the photos belonging to manual events
+func (index *EventIndex) ManualPhotos(ctx context.Context) (photos map[library.PhotoID]bool, err error) {
+	photos = make(map[library.PhotoID]bool)
+	err = index.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(eventsBucket).ForEach(func(k, v []byte) error {
+			var e Event
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if !e.Manual {
+				return nil
+			}
+			if b := tx.Bucket(photosByEventBucket).Bucket(k); b != nil {
+				b.ForEach(func(_, photoID []byte) error {
+					photos[library.PhotoID(photoID)] = true
+					return nil
+				})
+			}
+			return nil
+		})
+	})
+	return
+}
+
 func (index *EventIndex) FindPaged(ctx context.Context, start, maxCount int) (events []Event, hasMore bool, err error) {
 	err = index.db.View(func(tx *bolt.Tx) error {
 		c := tx.Bucket(eventsBucket).Cursor()
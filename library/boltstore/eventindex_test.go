@@ -0,0 +1,91 @@
+package boltstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func eventPhotos(ids ...string) []library.ExtendedPhotoID {
+	photos := make([]library.ExtendedPhotoID, len(ids))
+	for i, id := range ids {
+		photos[i] = library.ExtendedPhotoID{ID: library.PhotoID(id), SortID: library.OrderedID("s" + id)}
+	}
+	return photos
+}
+
+func photoIDsOf(t *testing.T, index *EventIndex, id EventID) []library.PhotoID {
+	photos, _, err := index.FindPhotosPaged(context.Background(), string(id), 0, 100)
+	require.NoError(t, err)
+	return photos
+}
+
+func TestEventIndexManualEvents(t *testing.T) {
+	runTestWithBoltDB(t, func(t *testing.T, db *bolt.DB) {
+		ctx := context.Background()
+		index, err := NewEventIndex(db)
+		require.NoError(t, err)
+		day := time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC)
+
+		first := Event{ID: "e1", From: day, To: day.Add(time.Hour)}
+		second := Event{ID: "e2", From: day.Add(24 * time.Hour), To: day.Add(25 * time.Hour)}
+		require.NoError(t, index.AddPhotosToEvent(ctx, first, eventPhotos("1", "2", "3")))
+		require.NoError(t, index.AddPhotosToEvent(ctx, second, eventPhotos("4", "5")))
+
+		// Identifying events again moves photos out of their previous event
+		require.NoError(t, index.AddPhotosToEvent(ctx, second, eventPhotos("3")))
+		assert.Equal(t, []library.PhotoID{"1", "2"}, photoIDsOf(t, index, "e1"))
+		e, found, err := index.EventOf(ctx, "3")
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, EventID("e2"), e.ID)
+
+		// Manual events and their photos are protected
+		first.Name, first.Manual = "Holidays", true
+		require.NoError(t, index.Replace(ctx, nil, EventPhotos{Event: first, Photos: eventPhotos("1", "2", "3")}))
+		require.NoError(t, index.AddPhotosToEvent(ctx, Event{ID: "e1"}, eventPhotos("4")))
+		require.NoError(t, index.AddPhotosToEvent(ctx, second, eventPhotos("1", "4")))
+		assert.Equal(t, []library.PhotoID{"1", "2", "3"}, photoIDsOf(t, index, "e1"))
+		assert.Equal(t, []library.PhotoID{"4", "5"}, photoIDsOf(t, index, "e2"))
+		e, _, err = index.Get(ctx, "e1")
+		require.NoError(t, err)
+		assert.Equal(t, "Holidays", e.Name)
+		manual, err := index.ManualPhotos(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[library.PhotoID]bool{"1": true, "2": true, "3": true}, manual)
+
+		// Events left without photos are deleted
+		require.NoError(t, index.Replace(ctx, nil, EventPhotos{Event: first, Photos: eventPhotos("1", "2", "3", "4", "5")}))
+		_, found, err = index.Get(ctx, "e2")
+		require.NoError(t, err)
+		assert.False(t, found)
+		events, _, err := index.FindPaged(ctx, 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+
+		require.NoError(t, index.Replace(ctx, []EventID{"e1"}))
+		_, found, err = index.EventOf(ctx, "1")
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+}
+
+func TestEventIndexMove(t *testing.T) {
+	runTestWithBoltDB(t, func(t *testing.T, db *bolt.DB) {
+		ctx := context.Background()
+		index, err := NewEventIndex(db)
+		require.NoError(t, err)
+		require.NoError(t, index.AddPhotosToEvent(ctx, Event{ID: "e1"}, eventPhotos("1", "2")))
+		before := &library.Photo{ExtendedPhotoID: eventPhotos("1")[0]}
+		after := &library.Photo{ExtendedPhotoID: library.ExtendedPhotoID{ID: "1", SortID: library.OrderedID("s3")}}
+		require.NoError(t, index.Move(ctx, before, after))
+		photos, err := index.PhotosOf(ctx, "e1")
+		require.NoError(t, err)
+		assert.Equal(t, []library.ExtendedPhotoID{eventPhotos("2")[0], after.ExtendedPhotoID}, photos)
+	})
+}
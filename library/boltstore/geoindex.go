@@ -2,9 +2,9 @@ package boltstore
 
 import (
 	"bytes"
+	"container/heap"
 	"context"
 	"encoding/json"
-	"fmt"
 
 	"bitbucket.org/kleinnic74/photos/domain/gps"
 	"bitbucket.org/kleinnic74/photos/index"
@@ -14,7 +14,7 @@ import (
 	"go.uber.org/zap"
 )
 
-const GeoIndexVersion = library.Version(11)
+const GeoIndexVersion = library.Version(12)
 
 type boltGeoIndex struct {
 	db *bolt.DB
@@ -22,30 +22,23 @@ type boltGeoIndex struct {
 
 var (
 	// placeOfPhotos tracks the location of each photo, indexed by PhotoID
-	placeOfPhotos = []byte("photoplaces_v3")
-	// photosByPlace tracks the photos at a given place, indexed by place key
-	photosByPlace = []byte("photosByPlace_v3")
-	// allCountriesBucket contains all known countries, indexed by country code
-	allCountriesBucket = []byte("allcountries_v3")
-	// placesByCountryBucket stores all places in a given country, indexed by country code
-	placesByCountryBucket = []byte("placesByCountry_v3")
-)
-
-const (
-	unknownPlacesKey = "_unknown"
+	placeOfPhotos = []byte("photoplaces_v4")
+	// photosByPlace tracks the photos at a given place, indexed by the ID of
+	// the most detailed level of the place
+	photosByPlace = []byte("photosByPlace_v4")
+	// placesBucket contains the address of all places having photos, indexed by
+	// the ID of the most detailed level of the place
+	placesBucket = []byte("places_v4")
 )
 
 func NewBoltGeoIndex(db *bolt.DB) (library.GeoIndex, error) {
 	if err := createBucket(db, placeOfPhotos); err != nil {
 		return nil, err
 	}
-	if err := createBucket(db, allCountriesBucket); err != nil {
-		return nil, err
-	}
-	if err := createBucket(db, placesByCountryBucket); err != nil {
+	if err := createBucket(db, photosByPlace); err != nil {
 		return nil, err
 	}
-	if err := createBucket(db, photosByPlace); err != nil {
+	if err := createBucket(db, placesBucket); err != nil {
 		return nil, err
 	}
 	return &boltGeoIndex{
@@ -58,7 +51,8 @@ func (idx *boltGeoIndex) MigrateStructure(ctx context.Context, from library.Vers
 	migrations.Register(library.Version(3), index.StructuralMigrationFunc(idx.deleteLegacyBuckets))
 	migrations.Register(library.Version(4), index.ForceReindex)
 	migrations.Register(library.Version(5), index.ForceReindex)
-	migrations.Register(library.Version(11), resetBuckets(idx.db, placeOfPhotos, photosByPlace, allCountriesBucket, placesByCountryBucket))
+	migrations.Register(library.Version(11), resetBuckets(idx.db, placeOfPhotos, photosByPlace))
+	migrations.Register(library.Version(12), index.StructuralMigrationFunc(idx.deleteFlatPlaceBuckets))
 	reindex, err := migrations.Apply(ctx, from, GeoIndexVersion)
 	return GeoIndexVersion, reindex, err
 }
@@ -67,6 +61,12 @@ func (idx *boltGeoIndex) deleteLegacyBuckets(ctx context.Context) (bool, error)
 	return true, deleteBuckets(idx.db, "photoplaces", "photosByPlace", "allcountries", "placesByCountry")
 }
 
+// deleteFlatPlaceBuckets deletes the buckets of the index without place
+// hierarchy, photos must be reindexed to resolve their administrative levels
+func (idx *boltGeoIndex) deleteFlatPlaceBuckets(ctx context.Context) (bool, error) {
+	return true, deleteBuckets(idx.db, "photoplaces_v3", "photosByPlace_v3", "allcountries_v3", "placesByCountry_v3")
+}
+
 func (idx *boltGeoIndex) Has(ctx context.Context, id library.PhotoID) (exists bool) {
 	logger, ctx := logging.FromWithNameAndFields(ctx, "geoStore")
 	err := idx.db.View(func(tx *bolt.Tx) error {
@@ -107,123 +107,218 @@ func (idx *boltGeoIndex) Update(ctx context.Context, id library.ExtendedPhotoID,
 		return err
 	}
 	return idx.db.Update(func(tx *bolt.Tx) error {
+		previous, err := placeOf(tx, id.ID)
+		if err != nil {
+			return err
+		}
+		if previous != nil && previous.ID != address.ID {
+			if err := removeFromPlace(tx, previous, id.SortID); err != nil {
+				return err
+			}
+		}
 		b := tx.Bucket(placeOfPhotos)
 		if err := b.Put([]byte(id.ID), encodedAddress); err != nil {
 			return err
 		}
-		allCountries := tx.Bucket(allCountriesBucket)
-		// Save country object
-		encodedCountry, err := json.Marshal(&address.Country)
+		placeID := []byte(address.ID)
+		if err := tx.Bucket(placesBucket).Put(placeID, encodedAddress); err != nil {
+			return err
+		}
+		photosAtPlace, err := tx.Bucket(photosByPlace).CreateBucketIfNotExists(placeID)
 		if err != nil {
 			return err
 		}
-		allCountries.Put([]byte(address.Country.ID), encodedCountry)
-
-		placesByCountry := tx.Bucket(placesByCountryBucket)
-		placesInCountryBucketName := []byte(address.Country.ID)
+		return photosAtPlace.Put([]byte(id.SortID), []byte(id.ID))
+	})
+}
 
-		placesInCountry, err := placesByCountry.CreateBucketIfNotExists(placesInCountryBucketName)
-		if err != nil {
+// Remove removes the given photo from this index
+func (idx *boltGeoIndex) Remove(ctx context.Context, id library.ExtendedPhotoID) error {
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		address, err := placeOf(tx, id.ID)
+		if err != nil || address == nil {
 			return err
 		}
-		placeID := []byte(address.ID)
-		if err := placesInCountry.Put(placeID, encodedAddress); err != nil {
+		if err := removeFromPlace(tx, address, id.SortID); err != nil {
 			return err
 		}
+		return tx.Bucket(placeOfPhotos).Delete([]byte(id.ID))
+	})
+}
 
-		photosByPlace := tx.Bucket(photosByPlace)
-		photosAtPlace, err := photosByPlace.CreateBucketIfNotExists(placeID)
-		if err != nil {
+// Move updates the SortID of the given photo at its place
+func (idx *boltGeoIndex) Move(ctx context.Context, before, after *library.Photo) error {
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		address, err := placeOf(tx, before.ID)
+		if err != nil || address == nil {
 			return err
 		}
-		return photosAtPlace.Put([]byte(id.SortID), []byte(id.ID))
+		photosAtPlace := tx.Bucket(photosByPlace).Bucket([]byte(address.ID))
+		if photosAtPlace == nil {
+			return nil
+		}
+		if err := photosAtPlace.Delete([]byte(before.SortID)); err != nil {
+			return err
+		}
+		return photosAtPlace.Put([]byte(after.SortID), []byte(after.ID))
 	})
 }
 
+func placeOf(tx *bolt.Tx, id library.PhotoID) (*gps.Address, error) {
+	data := tx.Bucket(placeOfPhotos).Get([]byte(id))
+	if data == nil {
+		return nil, nil
+	}
+	var address gps.Address
+	if err := json.Unmarshal(data, &address); err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// removeFromPlace removes the given photo from the photos at the given place,
+// places without photos are removed as well
+func removeFromPlace(tx *bolt.Tx, address *gps.Address, sortID library.OrderedID) error {
+	placeID := []byte(address.ID)
+	photosAtPlace := tx.Bucket(photosByPlace).Bucket(placeID)
+	if photosAtPlace == nil {
+		return nil
+	}
+	if err := photosAtPlace.Delete([]byte(sortID)); err != nil {
+		return err
+	}
+	if k, _ := photosAtPlace.Cursor().First(); k != nil {
+		return nil
+	}
+	if err := tx.Bucket(photosByPlace).DeleteBucket(placeID); err != nil {
+		return err
+	}
+	return tx.Bucket(placesBucket).Delete(placeID)
+}
+
+// Locations returns the tree of places having photos, from the countries down
+// to the most detailed known level
 func (idx *boltGeoIndex) Locations(ctx context.Context) (*library.Locations, error) {
 	var locations library.Locations
+	nodes := make(map[gps.PlaceID]*library.PlaceNode)
 	err := idx.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(allCountriesBucket)
-		return b.ForEach(func(k, v []byte) error {
-			var country gps.Country
-			if err := json.Unmarshal(v, &country); err != nil {
+		photos := tx.Bucket(photosByPlace)
+		return tx.Bucket(placesBucket).ForEach(func(k, v []byte) error {
+			var address gps.Address
+			if err := json.Unmarshal(v, &address); err != nil {
 				return err
 			}
-			var countryAndPlaces library.CountryAndPlaces
-			countryAndPlaces.Country = country
-
-			placesByCountry := tx.Bucket(placesByCountryBucket)
-			if places := placesByCountry.Bucket([]byte(country.ID)); places != nil {
-				c := places.Cursor()
-				for k, v := c.First(); k != nil; k, v = c.Next() {
-					var address gps.Address
-					if err := json.Unmarshal(v, &address); err != nil {
-						return err
+			var count int
+			if photosAtPlace := photos.Bucket(k); photosAtPlace != nil {
+				count = photosAtPlace.Stats().KeyN
+			}
+			var parent *library.PlaceNode
+			for _, place := range address.Hierarchy() {
+				node, found := nodes[place.ID]
+				if !found {
+					node = &library.PlaceNode{Place: place}
+					nodes[place.ID] = node
+					if parent == nil {
+						locations.Countries = append(locations.Countries, node)
+					} else {
+						parent.Places = append(parent.Places, node)
 					}
-					countryAndPlaces.Places = append(countryAndPlaces.Places, &address)
 				}
+				node.Count += count
+				parent = node
 			}
-			locations.Countries = append(locations.Countries, &countryAndPlaces)
 			return nil
 		})
 	})
 	return &locations, err
 }
 
-func (idx *boltGeoIndex) FindByPlacePaged(ctx context.Context, placeID gps.PlaceID, startAt int, maxCount int) (photos []library.PhotoID, hasMore bool, err error) {
+// Places returns the addresses of all places having photos
+func (idx *boltGeoIndex) Places(ctx context.Context) (places []*gps.Address, err error) {
 	err = idx.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(photosByPlace)
-		sub := b.Bucket([]byte(placeID))
-		if sub == nil {
-			return nil
-		}
-		c := sub.Cursor()
-		var index int
-		var count int
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			if index < startAt {
-				index++
-				continue
-			}
-			if count >= maxCount {
-				hasMore = true
-				return nil
+		return tx.Bucket(placesBucket).ForEach(func(k, v []byte) error {
+			var address gps.Address
+			if err := json.Unmarshal(v, &address); err != nil {
+				return err
 			}
-			photos = append(photos, library.PhotoID(v))
-			count++
-		}
-		return nil
+			places = append(places, &address)
+			return nil
+		})
 	})
 	return
 }
 
-func (idx *boltGeoIndex) FindByCountryPaged(ctx context.Context, country gps.CountryID, startAt int, maxCount int) (photos []library.PhotoID, hasMore bool, err error) {
+// FindByPlacePaged returns the photos taken at the given place or any of its
+// sub-places, in chronological order
+func (idx *boltGeoIndex) FindByPlacePaged(ctx context.Context, placeID gps.PlaceID, startAt int, maxCount int) (photos []library.PhotoID, hasMore bool, err error) {
 	err = idx.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(photosByPlace)
-		keyPrefix := []byte(fmt.Sprintf("%s/", country))
+		var cursors placeCursors
+		add := func(sub *bolt.Bucket) {
+			c := sub.Cursor()
+			if k, v := c.First(); k != nil {
+				cursors = append(cursors, &placeCursor{c: c, k: k, v: v})
+			}
+		}
+		if sub := b.Bucket([]byte(placeID)); sub != nil {
+			add(sub)
+		}
+		// Sub-places are stored with IDs prefixed by the ID of this place
+		keyPrefix := []byte(placeID + gps.PlaceIDSeparator)
 		buckets := b.Cursor()
-		var index int
-		var count int
 		for subK, _ := buckets.Seek(keyPrefix); subK != nil && bytes.HasPrefix(subK, keyPrefix); subK, _ = buckets.Next() {
-			sub := b.Bucket(subK)
-			if sub == nil {
-				continue
+			if sub := b.Bucket(subK); sub != nil {
+				add(sub)
 			}
-			c := sub.Cursor()
-			for k, v := c.First(); k != nil; k, v = c.Next() {
-				if index < startAt {
-					index++
-					continue
-				}
-				if count >= maxCount {
+		}
+		// The photos of each place are sorted by SortID, merging them keeps
+		// the chronological order
+		heap.Init(&cursors)
+		for index := 0; len(cursors) > 0; index++ {
+			next := cursors[0]
+			if index >= startAt {
+				if len(photos) >= maxCount {
 					hasMore = true
 					return nil
 				}
-				photos = append(photos, library.PhotoID(v))
-				count++
+				photos = append(photos, library.PhotoID(next.v))
+			}
+			if next.k, next.v = next.c.Next(); next.k == nil {
+				heap.Pop(&cursors)
+			} else {
+				heap.Fix(&cursors, 0)
 			}
 		}
 		return nil
 	})
 	return
 }
+
+// placeCursor iterates over the photos of a place, ordered by SortID
+type placeCursor struct {
+	c    *bolt.Cursor
+	k, v []byte
+}
+
+// placeCursors is a heap of cursors, ordered by the SortID of their current
+// photo
+type placeCursors []*placeCursor
+
+func (h placeCursors) Len() int           { return len(h) }
+func (h placeCursors) Less(i, j int) bool { return bytes.Compare(h[i].k, h[j].k) < 0 }
+func (h placeCursors) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *placeCursors) Push(x interface{}) {
+	*h = append(*h, x.(*placeCursor))
+}
+
+func (h *placeCursors) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+func (idx *boltGeoIndex) FindByCountryPaged(ctx context.Context, country gps.CountryID, startAt int, maxCount int) (photos []library.PhotoID, hasMore bool, err error) {
+	return idx.FindByPlacePaged(ctx, gps.PlaceID(country), startAt, maxCount)
+}
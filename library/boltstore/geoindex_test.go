@@ -0,0 +1,98 @@
+package boltstore
+
+import (
+	"context"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestGeoIndexHierarchy(t *testing.T) {
+	runTestWithBoltDB(t, testGeoIndexHierarchy)
+}
+
+func testGeoIndexHierarchy(t *testing.T, db *bolt.DB) {
+	ctx := context.Background()
+	idx, err := NewBoltGeoIndex(db)
+	if err != nil {
+		t.Fatalf("Failed to init geo index: %s", err)
+	}
+	austria := gps.Country{Country: "Österreich", ID: "at"}
+	innereStadt := gps.NewAddress(gps.AddressFields{Country: austria, Region: "Wien", City: "Wien", Neighbourhood: "Innere Stadt"})
+	leopoldstadt := gps.NewAddress(gps.AddressFields{Country: austria, Region: "Wien", City: "Wien", Neighbourhood: "Leopoldstadt"})
+	wilhelmsburg := gps.NewAddress(gps.AddressFields{Country: austria, Region: "Niederösterreich", City: "Wilhelmsburg"})
+	wiener := gps.NewAddress(gps.AddressFields{Country: austria, Region: "Niederösterreich", City: "Wiener Neustadt"})
+
+	places := map[library.PhotoID]gps.Address{
+		"1": innereStadt,
+		"2": innereStadt,
+		"3": leopoldstadt,
+		"4": wilhelmsburg,
+		"5": wiener,
+	}
+	for id, address := range places {
+		address := address
+		photo := library.ExtendedPhotoID{ID: id, SortID: library.OrderedID(id)}
+		if err := idx.Update(ctx, photo, &address); err != nil {
+			t.Fatalf("Failed to index photo %s: %s", id, err)
+		}
+	}
+
+	locations, err := idx.Locations(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read locations: %s", err)
+	}
+	assert.Equal(t, 1, len(locations.Countries))
+	at := locations.Countries[0]
+	assert.Equal(t, "Österreich", at.Name)
+	assert.Equal(t, 5, at.Count)
+	assert.Equal(t, 2, len(at.Places))
+	wien, found := locations.Find("at/wien/wien")
+	if assert.True(t, found) {
+		assert.Equal(t, gps.LevelCity, wien.Level)
+		assert.Equal(t, 3, wien.Count)
+		assert.Equal(t, 2, len(wien.Places))
+	}
+
+	data := []struct {
+		place  gps.PlaceID
+		photos []library.PhotoID
+	}{
+		{"at", []library.PhotoID{"1", "2", "3", "4", "5"}},
+		{"at/wien", []library.PhotoID{"1", "2", "3"}},
+		{"at/wien/wien/innere stadt", []library.PhotoID{"1", "2"}},
+		{"at/niederösterreich/wien", nil},
+		{"at/niederösterreich/wiener neustadt", []library.PhotoID{"5"}},
+	}
+	for _, d := range data {
+		photos, hasMore, err := idx.FindByPlacePaged(ctx, d.place, 0, 10)
+		assert.NoError(t, err)
+		assert.False(t, hasMore)
+		assert.Equal(t, d.photos, photos, "Photos at %s", d.place)
+	}
+	photos, hasMore, _ := idx.FindByCountryPaged(ctx, "at", 1, 2)
+	assert.Equal(t, []library.PhotoID{"2", "3"}, photos, "Photos of sub-places must be in chronological order")
+	assert.True(t, hasMore)
+	photos, hasMore, _ = idx.FindByCountryPaged(ctx, "at", 3, 2)
+	assert.Equal(t, []library.PhotoID{"4", "5"}, photos)
+	assert.False(t, hasMore)
+
+	// Moving a photo removes empty places
+	if err := idx.Update(ctx, library.ExtendedPhotoID{ID: "3", SortID: library.OrderedID("3")}, &innereStadt); err != nil {
+		t.Fatalf("Failed to update photo: %s", err)
+	}
+	if _, found := locations.Find(leopoldstadt.ID); !found {
+		t.Errorf("Expected place %s in old locations", leopoldstadt.ID)
+	}
+	locations, _ = idx.Locations(ctx)
+	if _, found := locations.Find(leopoldstadt.ID); found {
+		t.Errorf("Expected place %s to be removed", leopoldstadt.ID)
+	}
+	address, found, err := idx.Get(ctx, "3")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, innereStadt.ID, address.ID)
+}
@@ -0,0 +1,95 @@
+package boltstore
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// smartAlbumsBucket contains the smart albums, indexed by ID
+	smartAlbumsBucket = []byte("smartAlbums")
+)
+
+// SmartAlbumStore persists the smart albums of the user
+type SmartAlbumStore struct {
+	db *bolt.DB
+}
+
+func NewSmartAlbumStore(db *bolt.DB) (*SmartAlbumStore, error) {
+	if err := createBucket(db, smartAlbumsBucket); err != nil {
+		return nil, err
+	}
+	return &SmartAlbumStore{db: db}, nil
+}
+
+// List returns the smart albums, most recently created first
+func (s *SmartAlbumStore) List(ctx context.Context) (albums []library.SmartAlbum, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(smartAlbumsBucket).ForEach(func(k, v []byte) error {
+			var a library.SmartAlbum
+			if err := json.Unmarshal(v, &a); err != nil {
+				return err
+			}
+			albums = append(albums, a)
+			return nil
+		})
+	})
+	sort.SliceStable(albums, func(i, j int) bool {
+		return albums[i].Created.After(albums[j].Created)
+	})
+	return
+}
+
+func (s *SmartAlbumStore) Get(ctx context.Context, id library.SmartAlbumID) (album *library.SmartAlbum, found bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(smartAlbumsBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		album, found = new(library.SmartAlbum), true
+		return json.Unmarshal(data, album)
+	})
+	return
+}
+
+// Put creates or updates the given smart album, new albums are given an ID.
+// The creation time is maintained by the store.
+func (s *SmartAlbumStore) Put(ctx context.Context, album *library.SmartAlbum) error {
+	if err := album.Validate(); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(smartAlbumsBucket)
+		if album.ID == "" {
+			album.ID = library.SmartAlbumID(uuid.New().String())
+			album.Created = time.Now().UTC()
+		} else {
+			data := b.Get([]byte(album.ID))
+			if data == nil {
+				return library.ErrSmartAlbumNotFound(album.ID)
+			}
+			var existing library.SmartAlbum
+			if err := json.Unmarshal(data, &existing); err != nil {
+				return err
+			}
+			album.Created = existing.Created
+		}
+		data, err := json.Marshal(album)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(album.ID), data)
+	})
+}
+
+func (s *SmartAlbumStore) Delete(ctx context.Context, id library.SmartAlbumID) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(smartAlbumsBucket).Delete([]byte(id))
+	})
+}
@@ -0,0 +1,50 @@
+package boltstore
+
+import (
+	"context"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestSmartAlbumStore(t *testing.T) {
+	runTestWithBoltDB(t, testSmartAlbumStore)
+}
+
+func testSmartAlbumStore(t *testing.T, db *bolt.DB) {
+	ctx := context.Background()
+	s, err := NewSmartAlbumStore(db)
+	if err != nil {
+		t.Fatalf("Failed to init smart album store: %s", err)
+	}
+	assert.Equal(t, library.ErrNoAlbumTitle, s.Put(ctx, &library.SmartAlbum{Title: " ", Query: "tag=beach"}))
+	assert.IsType(t, library.QuerySyntaxError{}, s.Put(ctx, &library.SmartAlbum{Title: "Beach", Query: "tag="}))
+
+	album := library.SmartAlbum{Title: "Beach", Query: "tag=beach"}
+	if err := s.Put(ctx, &album); err != nil {
+		t.Fatalf("Failed to create smart album: %s", err)
+	}
+	assert.NotEmpty(t, album.ID)
+	assert.False(t, album.Created.IsZero())
+
+	update := library.SmartAlbum{ID: album.ID, Title: "Beach in France", Query: "tag=beach country=fr"}
+	assert.NoError(t, s.Put(ctx, &update))
+	assert.Equal(t, album.Created, update.Created)
+	found, exists, err := s.Get(ctx, album.ID)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "tag=beach country=fr", found.Query)
+
+	assert.Equal(t, library.ErrSmartAlbumNotFound("unknown"), s.Put(ctx, &library.SmartAlbum{ID: "unknown", Title: "x", Query: "tag=x"}))
+
+	albums, err := s.List(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, albums, 1)
+
+	assert.NoError(t, s.Delete(ctx, album.ID))
+	_, exists, err = s.Get(ctx, album.ID)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
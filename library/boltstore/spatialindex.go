@@ -0,0 +1,199 @@
+package boltstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/index"
+	"bitbucket.org/kleinnic74/photos/library"
+	bolt "go.etcd.io/bbolt"
+)
+
+const SpatialIndexVersion = library.Version(1)
+
+// maxQueryCells is the maximum number of geohash cells scanned for a query
+const maxQueryCells = 32
+
+var (
+	// photosByGeohash contains the photos with their location, indexed by the
+	// geohash of their location followed by their SortID
+	photosByGeohash = []byte("photosByGeohash")
+	// geohashOfPhotos contains the key in photosByGeohash of each photo,
+	// indexed by PhotoID
+	geohashOfPhotos = []byte("geohashOfPhotos")
+)
+
+// SpatialIndex indexes photos by the geohash of their location
+type SpatialIndex struct {
+	db *bolt.DB
+}
+
+func NewSpatialIndex(db *bolt.DB) (*SpatialIndex, error) {
+	if err := createBucket(db, photosByGeohash); err != nil {
+		return nil, err
+	}
+	if err := createBucket(db, geohashOfPhotos); err != nil {
+		return nil, err
+	}
+	return &SpatialIndex{db: db}, nil
+}
+
+func (idx *SpatialIndex) MigrateStructure(ctx context.Context, from library.Version) (library.Version, bool, error) {
+	migrations := index.NewStructuralMigrations()
+	migrations.Register(1, index.ForceReindex)
+	reindex, err := migrations.Apply(ctx, from, SpatialIndexVersion)
+	return SpatialIndexVersion, reindex, err
+}
+
+// Add adds the given photo to the index if it has a location
+func (idx *SpatialIndex) Add(ctx context.Context, photo *library.Photo) error {
+	if photo.Location == nil || !photo.Location.IsValid() {
+		return nil
+	}
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		if err := removeFromSpatialIndex(tx, photo.ID); err != nil {
+			return err
+		}
+		return addToSpatialIndex(tx, photo)
+	})
+}
+
+// Update re-indexes the given photo after its location or its capture time
+// was changed
+func (idx *SpatialIndex) Update(ctx context.Context, before, after *library.Photo) error {
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		if err := removeFromSpatialIndex(tx, before.ID); err != nil {
+			return err
+		}
+		if after.Location == nil || !after.Location.IsValid() {
+			return nil
+		}
+		return addToSpatialIndex(tx, after)
+	})
+}
+
+func addToSpatialIndex(tx *bolt.Tx, photo *library.Photo) error {
+	value, err := json.Marshal(library.GeoPhoto{ID: photo.ID, Location: *photo.Location})
+	if err != nil {
+		return err
+	}
+	key := append([]byte(gps.Geohash(*photo.Location, gps.MaxGeohashPrecision)), photo.SortID...)
+	if err := tx.Bucket(photosByGeohash).Put(key, value); err != nil {
+		return err
+	}
+	return tx.Bucket(geohashOfPhotos).Put([]byte(photo.ID), key)
+}
+
+func removeFromSpatialIndex(tx *bolt.Tx, id library.PhotoID) error {
+	keys := tx.Bucket(geohashOfPhotos)
+	key := keys.Get([]byte(id))
+	if key == nil {
+		return nil
+	}
+	if err := tx.Bucket(photosByGeohash).Delete(key); err != nil {
+		return err
+	}
+	return keys.Delete([]byte(id))
+}
+
+// forEachInRect calls f for each photo located in the given rectangle
+func (idx *SpatialIndex) forEachInRect(r gps.Rect, f func(library.GeoPhoto) bool) error {
+	return idx.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(photosByGeohash).Cursor()
+		for _, cell := range gps.GeohashesCovering(r, maxQueryCells) {
+			prefix := []byte(cell)
+			for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+				var p library.GeoPhoto
+				if err := json.Unmarshal(v, &p); err != nil {
+					return err
+				}
+				if !contains(r, p.Location) {
+					continue
+				}
+				if !f(p) {
+					return nil
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func contains(r gps.Rect, c gps.Coordinates) bool {
+	return c.Long >= r.X0() && c.Long <= r.X1() && c.Lat >= r.Y0() && c.Lat <= r.Y1()
+}
+
+func (idx *SpatialIndex) FindInRectPaged(ctx context.Context, r gps.Rect, start, maxCount int) (photos []library.GeoPhoto, hasMore bool, err error) {
+	var index int
+	err = idx.forEachInRect(r, func(p library.GeoPhoto) bool {
+		if index < start {
+			index++
+			return true
+		}
+		if len(photos) == maxCount {
+			hasMore = true
+			return false
+		}
+		photos = append(photos, p)
+		return true
+	})
+	return
+}
+
+func (idx *SpatialIndex) FindNearPaged(ctx context.Context, c gps.Coordinates, radius float64, start, maxCount int) ([]library.GeoPhoto, bool, error) {
+	var photos []library.GeoPhoto
+	var distances []float64
+	for _, r := range gps.RectsAround(c, radius) {
+		err := idx.forEachInRect(r, func(p library.GeoPhoto) bool {
+			if d := c.DistanceTo(&p.Location); d <= radius {
+				photos = append(photos, p)
+				distances = append(distances, d)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	sort.Sort(byDistance{photos, distances})
+	if start >= len(photos) {
+		return nil, false, nil
+	}
+	photos = photos[start:]
+	if len(photos) > maxCount {
+		return photos[:maxCount], true, nil
+	}
+	return photos, false, nil
+}
+
+type byDistance struct {
+	photos    []library.GeoPhoto
+	distances []float64
+}
+
+func (s byDistance) Len() int {
+	return len(s.photos)
+}
+
+func (s byDistance) Less(i, j int) bool {
+	return s.distances[i] < s.distances[j]
+}
+
+func (s byDistance) Swap(i, j int) {
+	s.photos[i], s.photos[j] = s.photos[j], s.photos[i]
+	s.distances[i], s.distances[j] = s.distances[j], s.distances[i]
+}
+
+func (idx *SpatialIndex) Clusters(ctx context.Context, r gps.Rect, zoom int) ([]library.Cluster, error) {
+	var photos []library.GeoPhoto
+	if err := idx.forEachInRect(r, func(p library.GeoPhoto) bool {
+		photos = append(photos, p)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return library.ClusterPhotos(photos, zoom), nil
+}
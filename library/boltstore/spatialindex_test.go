@@ -0,0 +1,111 @@
+package boltstore
+
+import (
+	"context"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestSpatialIndex(t *testing.T) {
+	runTestWithBoltDB(t, testSpatialIndex)
+}
+
+func testSpatialIndex(t *testing.T, db *bolt.DB) {
+	ctx := context.Background()
+	idx, err := NewSpatialIndex(db)
+	if err != nil {
+		t.Fatalf("Failed to init spatial index: %s", err)
+	}
+	photos := []*library.Photo{
+		photoAt("stephansdom", 48.20849, 16.37208),
+		photoAt("prater", 48.21667, 16.39583),
+		photoAt("schoenbrunn", 48.18486, 16.31222),
+		photoAt("paris", 48.85341, 2.3488),
+		photoAt("sydney", -33.8688, 151.2093),
+		{ExtendedPhotoID: library.ExtendedPhotoID{ID: "nowhere", SortID: library.OrderedID("nowhere")}},
+	}
+	for _, p := range photos {
+		if err := idx.Add(ctx, p); err != nil {
+			t.Fatalf("Failed to add photo %s: %s", p.ID, err)
+		}
+	}
+
+	vienna := gps.RectFrom(16.18, 48.11, 16.58, 48.33)
+	found, hasMore, err := idx.FindInRectPaged(ctx, vienna, 0, 10)
+	assert.NoError(t, err)
+	assert.False(t, hasMore)
+	assert.ElementsMatch(t, []library.PhotoID{"stephansdom", "prater", "schoenbrunn"}, idsOf(found))
+
+	found, hasMore, _ = idx.FindInRectPaged(ctx, vienna, 1, 1)
+	assert.Equal(t, 1, len(found))
+	assert.True(t, hasMore)
+
+	found, hasMore, err = idx.FindNearPaged(ctx, gps.Coordinates{Lat: 48.2082, Long: 16.3738}, 3000, 0, 10)
+	assert.NoError(t, err)
+	assert.False(t, hasMore)
+	assert.Equal(t, []library.PhotoID{"stephansdom", "prater"}, idsOf(found))
+
+	clusters, err := idx.Clusters(ctx, gps.WorldBounds, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(clusters))
+
+	// Changing the location moves the photo in the index
+	before := photos[3]
+	after := *before
+	after.Location = gps.MustNewCoordinates(48.19, 16.35)
+	if err := idx.Update(ctx, before, &after); err != nil {
+		t.Fatalf("Failed to update photo: %s", err)
+	}
+	found, _, _ = idx.FindInRectPaged(ctx, vienna, 0, 10)
+	assert.Equal(t, 4, len(found))
+	cleared := after
+	cleared.Location = nil
+	idx.Update(ctx, &after, &cleared)
+	found, _, _ = idx.FindInRectPaged(ctx, gps.WorldBounds, 0, 10)
+	assert.Equal(t, 4, len(found))
+}
+
+func TestSpatialIndexAntimeridian(t *testing.T) {
+	runTestWithBoltDB(t, testSpatialIndexAntimeridian)
+}
+
+func testSpatialIndexAntimeridian(t *testing.T, db *bolt.DB) {
+	ctx := context.Background()
+	idx, err := NewSpatialIndex(db)
+	if err != nil {
+		t.Fatalf("Failed to init spatial index: %s", err)
+	}
+	for _, p := range []*library.Photo{
+		photoAt("west", -16.7, 179.95),
+		photoAt("east", -16.7, -179.95),
+		photoAt("far", -16.7, -179.0),
+	} {
+		if err := idx.Add(ctx, p); err != nil {
+			t.Fatalf("Failed to add photo %s: %s", p.ID, err)
+		}
+	}
+	found, _, err := idx.FindNearPaged(ctx, gps.Coordinates{Lat: -16.7, Long: 179.9}, 30000, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []library.PhotoID{"west", "east"}, idsOf(found))
+	found, _, err = idx.FindNearPaged(ctx, gps.Coordinates{Lat: -16.7, Long: -179.9}, 30000, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []library.PhotoID{"east", "west"}, idsOf(found))
+}
+
+func photoAt(id library.PhotoID, lat, lon float64) *library.Photo {
+	return &library.Photo{
+		ExtendedPhotoID: library.ExtendedPhotoID{ID: id, SortID: library.OrderedID(id)},
+		Location:        gps.MustNewCoordinates(lat, lon),
+	}
+}
+
+func idsOf(photos []library.GeoPhoto) (ids []library.PhotoID) {
+	for _, p := range photos {
+		ids = append(ids, p.ID)
+	}
+	return
+}
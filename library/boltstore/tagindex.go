@@ -0,0 +1,184 @@
+package boltstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+
+	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/index"
+	"bitbucket.org/kleinnic74/photos/library"
+	bolt "go.etcd.io/bbolt"
+)
+
+const TagIndexVersion = library.Version(1)
+
+var (
+	// photosByTag contains one bucket per tag holding the IDs of the photos
+	// having that tag, indexed by their SortID
+	photosByTag = []byte("photosByTag")
+	// tagCounts contains the number of photos having each tag
+	tagCounts = []byte("tagCounts")
+	// tagsOfPhotos contains the indexed tags of each photo, indexed by PhotoID
+	tagsOfPhotos = []byte("tagsOfPhotos")
+)
+
+type indexedTags struct {
+	SortID library.OrderedID `json:"sortId"`
+	Tags   []string          `json:"tags"`
+}
+
+// TagIndex indexes photos by their tags
+type TagIndex struct {
+	db *bolt.DB
+}
+
+func NewTagIndex(db *bolt.DB) (*TagIndex, error) {
+	for _, name := range [][]byte{photosByTag, tagCounts, tagsOfPhotos} {
+		if err := createBucket(db, name); err != nil {
+			return nil, err
+		}
+	}
+	return &TagIndex{db: db}, nil
+}
+
+func (idx *TagIndex) MigrateStructure(ctx context.Context, from library.Version) (library.Version, bool, error) {
+	migrations := index.NewStructuralMigrations()
+	migrations.Register(1, index.ForceReindex)
+	reindex, err := migrations.Apply(ctx, from, TagIndexVersion)
+	return TagIndexVersion, reindex, err
+}
+
+// Add adds the given photo to the index of each of its tags
+func (idx *TagIndex) Add(ctx context.Context, photo *library.Photo) error {
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		if err := removeFromTagIndex(tx, photo.ID); err != nil {
+			return err
+		}
+		return addToTagIndex(tx, photo)
+	})
+}
+
+// Update re-indexes the given photo after its tags or its capture time were
+// changed
+func (idx *TagIndex) Update(ctx context.Context, before, after *library.Photo) error {
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		if err := removeFromTagIndex(tx, before.ID); err != nil {
+			return err
+		}
+		return addToTagIndex(tx, after)
+	})
+}
+
+func addToTagIndex(tx *bolt.Tx, photo *library.Photo) error {
+	if len(photo.Tags) == 0 {
+		return nil
+	}
+	tags := library.NormalizeTags(photo.Tags)
+	for _, tag := range tags {
+		photos, err := tx.Bucket(photosByTag).CreateBucketIfNotExists([]byte(tag))
+		if err != nil {
+			return err
+		}
+		if err := photos.Put(photo.SortID, []byte(photo.ID)); err != nil {
+			return err
+		}
+		if err := addToTagCount(tx, tag, 1); err != nil {
+			return err
+		}
+	}
+	value, err := json.Marshal(indexedTags{SortID: photo.SortID, Tags: tags})
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(tagsOfPhotos).Put([]byte(photo.ID), value)
+}
+
+func removeFromTagIndex(tx *bolt.Tx, id library.PhotoID) error {
+	photoTags := tx.Bucket(tagsOfPhotos)
+	value := photoTags.Get([]byte(id))
+	if value == nil {
+		return nil
+	}
+	var indexed indexedTags
+	if err := json.Unmarshal(value, &indexed); err != nil {
+		return err
+	}
+	for _, tag := range indexed.Tags {
+		if photos := tx.Bucket(photosByTag).Bucket([]byte(tag)); photos != nil {
+			if err := photos.Delete(indexed.SortID); err != nil {
+				return err
+			}
+		}
+		if err := addToTagCount(tx, tag, -1); err != nil {
+			return err
+		}
+	}
+	return photoTags.Delete([]byte(id))
+}
+
+// addToTagCount changes the number of photos having the given tag, tags no
+// longer used are removed
+func addToTagCount(tx *bolt.Tx, tag string, delta int) error {
+	counts := tx.Bucket(tagCounts)
+	var count int
+	if value := counts.Get([]byte(tag)); value != nil {
+		count = int(binary.BigEndian.Uint32(value))
+	}
+	count += delta
+	if count <= 0 {
+		if err := counts.Delete([]byte(tag)); err != nil {
+			return err
+		}
+		if tx.Bucket(photosByTag).Bucket([]byte(tag)) == nil {
+			return nil
+		}
+		return tx.Bucket(photosByTag).DeleteBucket([]byte(tag))
+	}
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(count))
+	return counts.Put([]byte(tag), value)
+}
+
+// FindByTagPaged returns the photos having the given tag, ordered by their
+// capture time
+func (idx *TagIndex) FindByTagPaged(ctx context.Context, tag string, start, maxCount int, order consts.SortOrder) (photos []library.PhotoID, hasMore bool, err error) {
+	err = idx.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(photosByTag).Bucket([]byte(library.NormalizeTag(tag)))
+		if b == nil {
+			return nil
+		}
+		c := newCursor(b.Cursor(), order).Skip(uint(start)).Limit(uint(maxCount))
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			photos = append(photos, library.PhotoID(v))
+		}
+		hasMore = c.HasMore()
+		return nil
+	})
+	return
+}
+
+// Tags returns the tags starting with the given prefix, most used first
+func (idx *TagIndex) Tags(ctx context.Context, prefix string, maxCount int) ([]library.TagCount, error) {
+	tags := []library.TagCount{}
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		p := []byte(library.NormalizeTag(prefix))
+		c := tx.Bucket(tagCounts).Cursor()
+		for k, v := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, v = c.Next() {
+			tags = append(tags, library.TagCount{Name: string(k), Count: int(binary.BigEndian.Uint32(v))})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].Count > tags[j].Count
+	})
+	if maxCount > 0 && len(tags) > maxCount {
+		tags = tags[:maxCount]
+	}
+	return tags, nil
+}
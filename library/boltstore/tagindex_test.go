@@ -0,0 +1,76 @@
+package boltstore
+
+import (
+	"context"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestTagIndex(t *testing.T) {
+	runTestWithBoltDB(t, testTagIndex)
+}
+
+func photoTagged(id library.PhotoID, tags ...string) *library.Photo {
+	return &library.Photo{
+		ExtendedPhotoID: library.ExtendedPhotoID{ID: id, SortID: library.OrderedID(id)},
+		Tags:            tags,
+	}
+}
+
+func testTagIndex(t *testing.T, db *bolt.DB) {
+	ctx := context.Background()
+	idx, err := NewTagIndex(db)
+	if err != nil {
+		t.Fatalf("Failed to init tag index: %s", err)
+	}
+	photos := []*library.Photo{
+		photoTagged("1", "beach", "holidays"),
+		photoTagged("2", "Beach"),
+		photoTagged("3", "beach", "berlin"),
+		photoTagged("4"),
+	}
+	for _, p := range photos {
+		if err := idx.Add(ctx, p); err != nil {
+			t.Fatalf("Failed to add photo %s: %s", p.ID, err)
+		}
+	}
+
+	found, _, err := idx.FindByTagPaged(ctx, "BEACH", 0, 10, consts.Ascending)
+	assert.NoError(t, err)
+	assert.Equal(t, []library.PhotoID{"1", "2", "3"}, found)
+
+	found, hasMore, err := idx.FindByTagPaged(ctx, "beach", 0, 2, consts.Descending)
+	assert.NoError(t, err)
+	assert.True(t, hasMore)
+	assert.Equal(t, []library.PhotoID{"3", "2"}, found)
+
+	found, _, err = idx.FindByTagPaged(ctx, "unknown", 0, 10, consts.Ascending)
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+
+	tags, err := idx.Tags(ctx, "be", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []library.TagCount{{Name: "beach", Count: 3}, {Name: "berlin", Count: 1}}, tags)
+
+	tags, err = idx.Tags(ctx, "", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []library.TagCount{{Name: "beach", Count: 3}}, tags)
+
+	// Removing the last photo of a tag removes the tag
+	before := photos[2]
+	after := *before
+	after.Tags = []string{"beach"}
+	if err := idx.Update(ctx, before, &after); err != nil {
+		t.Fatalf("Failed to update photo: %s", err)
+	}
+	tags, err = idx.Tags(ctx, "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []library.TagCount{{Name: "beach", Count: 3}, {Name: "holidays", Count: 1}}, tags)
+	found, _, err = idx.FindByTagPaged(ctx, "berlin", 0, 10, consts.Ascending)
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+}
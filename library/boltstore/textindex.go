@@ -0,0 +1,249 @@
+package boltstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sort"
+
+	"bitbucket.org/kleinnic74/photos/index"
+	"bitbucket.org/kleinnic74/photos/library"
+	bolt "go.etcd.io/bbolt"
+)
+
+const TextIndexVersion = library.Version(2)
+
+var (
+	// textPostings contains one bucket per term holding the photos having
+	// that term, indexed by PhotoID. Values are the weight of the term
+	// followed by the SortID of the photo.
+	textPostings = []byte("textPostings")
+	// termsOfPhotos contains the indexed terms of each photo with their
+	// weight, indexed by PhotoID
+	termsOfPhotos = []byte("termsOfPhotos")
+	// termCounts contains the number of photos having each term
+	termCounts = []byte("termCounts")
+	// textStats contains the number of indexed photos
+	textStats = []byte("textStats")
+)
+
+var documentCount = []byte("documents")
+
+const (
+	// prefixMatchFactor lowers the score of terms only starting with a word
+	// of the query compared to terms equal to it
+	prefixMatchFactor = 0.5
+	// maxPrefixMatches is the maximum number of terms a word of the query
+	// is expanded to
+	maxPrefixMatches = 100
+)
+
+type indexedTerms struct {
+	SortID library.OrderedID `json:"sortId"`
+	Terms  map[string]int    `json:"terms"`
+}
+
+// TextIndex is an inverted index of the words describing the photos
+type TextIndex struct {
+	db *bolt.DB
+}
+
+func NewTextIndex(db *bolt.DB) (*TextIndex, error) {
+	for _, name := range [][]byte{textPostings, termsOfPhotos, termCounts, textStats} {
+		if err := createBucket(db, name); err != nil {
+			return nil, err
+		}
+	}
+	return &TextIndex{db: db}, nil
+}
+
+func (idx *TextIndex) MigrateStructure(ctx context.Context, from library.Version) (library.Version, bool, error) {
+	migrations := index.NewStructuralMigrations()
+	migrations.Register(1, index.ForceReindex)
+	migrations.Register(2, resetBuckets(idx.db, textPostings, termsOfPhotos, termCounts, textStats))
+	reindex, err := migrations.Apply(ctx, from, TextIndexVersion)
+	return TextIndexVersion, reindex, err
+}
+
+// TextDocument are the terms describing a photo, with their weight in the
+// score of the photo
+type TextDocument struct {
+	library.ExtendedPhotoID
+	Terms map[string]int
+}
+
+// Put replaces the terms of the photos of the given documents
+func (idx *TextIndex) Put(ctx context.Context, docs ...TextDocument) error {
+	return idx.db.Update(func(tx *bolt.Tx) error {
+		for _, doc := range docs {
+			if err := putTerms(tx, doc.ExtendedPhotoID, doc.Terms); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func putTerms(tx *bolt.Tx, photo library.ExtendedPhotoID, terms map[string]int) error {
+	if err := removeTerms(tx, photo.ID); err != nil {
+		return err
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+	postings := tx.Bucket(textPostings)
+	for term, weight := range terms {
+		if weight > math.MaxUint8 {
+			weight = math.MaxUint8
+		}
+		b, err := postings.CreateBucketIfNotExists([]byte(term))
+		if err != nil {
+			return err
+		}
+		value := append([]byte{byte(weight)}, photo.SortID...)
+		if err := b.Put([]byte(photo.ID), value); err != nil {
+			return err
+		}
+		if err := addToCount(tx.Bucket(termCounts), []byte(term), 1); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(indexedTerms{SortID: photo.SortID, Terms: terms})
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(termsOfPhotos).Put([]byte(photo.ID), data); err != nil {
+		return err
+	}
+	return addToCount(tx.Bucket(textStats), documentCount, 1)
+}
+
+func removeTerms(tx *bolt.Tx, id library.PhotoID) error {
+	data := tx.Bucket(termsOfPhotos).Get([]byte(id))
+	if data == nil {
+		return nil
+	}
+	var indexed indexedTerms
+	if err := json.Unmarshal(data, &indexed); err != nil {
+		return err
+	}
+	postings := tx.Bucket(textPostings)
+	for term := range indexed.Terms {
+		b := postings.Bucket([]byte(term))
+		if b == nil {
+			continue
+		}
+		if err := b.Delete([]byte(id)); err != nil {
+			return err
+		}
+		if err := addToCount(tx.Bucket(termCounts), []byte(term), -1); err != nil {
+			return err
+		}
+		if k, _ := b.Cursor().First(); k == nil {
+			if err := postings.DeleteBucket([]byte(term)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Bucket(termsOfPhotos).Delete([]byte(id)); err != nil {
+		return err
+	}
+	return addToCount(tx.Bucket(textStats), documentCount, -1)
+}
+
+// addToCount changes the count stored under the given key, counts dropping
+// to zero are removed
+func addToCount(counts *bolt.Bucket, key []byte, delta int) error {
+	count := readCount(counts, key) + delta
+	if count <= 0 {
+		return counts.Delete(key)
+	}
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(count))
+	return counts.Put(key, value)
+}
+
+func readCount(counts *bolt.Bucket, key []byte) int {
+	if value := counts.Get(key); value != nil {
+		return int(binary.BigEndian.Uint32(value))
+	}
+	return 0
+}
+
+// Find returns the photos having all given terms, or terms starting with
+// them, most relevant first. The score of a photo is the sum over the given
+// terms of the best weighted inverse document frequency of the matching
+// terms of the photo, photos with the same score are returned most recent
+// first.
+func (idx *TextIndex) Find(ctx context.Context, terms []string) (matches []library.TextMatch, err error) {
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	err = idx.db.View(func(tx *bolt.Tx) error {
+		total := float64(readCount(tx.Bucket(textStats), documentCount))
+		counts := tx.Bucket(termCounts)
+		postings := tx.Bucket(textPostings)
+		var found map[library.PhotoID]*library.TextMatch
+		seen := make(map[string]bool)
+		for _, term := range terms {
+			if seen[term] {
+				continue
+			}
+			seen[term] = true
+			scores := make(map[library.PhotoID]*library.TextMatch)
+			c := postings.Cursor()
+			prefix := []byte(term)
+			expanded := 0
+			for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix) && expanded < maxPrefixMatches; k, _ = c.Next() {
+				expanded++
+				b := postings.Bucket(k)
+				count := readCount(counts, k)
+				if b == nil || count == 0 {
+					continue
+				}
+				factor := 1.0
+				if len(k) != len(prefix) {
+					factor = prefixMatchFactor
+				}
+				idf := math.Log(1 + total/float64(count))
+				if err := b.ForEach(func(id, v []byte) error {
+					photo := library.PhotoID(id)
+					if found != nil && found[photo] == nil {
+						return nil
+					}
+					score := float64(v[0]) * idf * factor
+					if m, exists := scores[photo]; !exists {
+						sortID := append(library.OrderedID{}, v[1:]...)
+						scores[photo] = &library.TextMatch{ID: photo, SortID: sortID, Score: score}
+					} else if score > m.Score {
+						m.Score = score
+					}
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+			if found != nil {
+				for id, m := range scores {
+					m.Score += found[id].Score
+				}
+			}
+			if found = scores; len(found) == 0 {
+				return nil
+			}
+		}
+		for _, m := range found {
+			matches = append(matches, *m)
+		}
+		return nil
+	})
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return bytes.Compare(matches[i].SortID, matches[j].SortID) > 0
+	})
+	return
+}
@@ -0,0 +1,124 @@
+package boltstore
+
+import (
+	"context"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestTextIndex(t *testing.T) {
+	runTestWithBoltDB(t, testTextIndex)
+}
+
+func textDocument(id library.PhotoID, terms map[string]int) TextDocument {
+	return TextDocument{
+		ExtendedPhotoID: library.ExtendedPhotoID{ID: id, SortID: library.OrderedID(id)},
+		Terms:           terms,
+	}
+}
+
+func matchedIDs(matches []library.TextMatch) (ids []library.PhotoID) {
+	for _, m := range matches {
+		ids = append(ids, m.ID)
+	}
+	return
+}
+
+func testTextIndex(t *testing.T, db *bolt.DB) {
+	ctx := context.Background()
+	idx, err := NewTextIndex(db)
+	if err != nil {
+		t.Fatalf("Failed to init text index: %s", err)
+	}
+	err = idx.Put(ctx,
+		textDocument("1", map[string]int{"img": 1, "paris": 2, "2019": 1}),
+		textDocument("2", map[string]int{"img": 1, "paris": 3, "2019": 1}),
+		textDocument("3", map[string]int{"img": 1, "parisian": 1, "2020": 1}),
+		textDocument("4", map[string]int{"img": 1, "berlin": 2, "2019": 1}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to index photos: %s", err)
+	}
+
+	data := []struct {
+		terms    []string
+		expected []library.PhotoID
+	}{
+		{nil, nil},
+		{[]string{"paris"}, []library.PhotoID{"2", "1", "3"}},
+		{[]string{"par", "2019"}, []library.PhotoID{"2", "1"}},
+		{[]string{"img"}, []library.PhotoID{"4", "3", "2", "1"}},
+		{[]string{"2019", "berlin", "2019"}, []library.PhotoID{"4"}},
+		{[]string{"paris", "berlin"}, nil},
+		{[]string{"london"}, nil},
+	}
+	for _, d := range data {
+		found, err := idx.Find(ctx, d.terms)
+		assert.NoError(t, err)
+		assert.Equal(t, d.expected, matchedIDs(found), "%v", d.terms)
+	}
+
+	// Re-indexing replaces the previous terms of the photo
+	if err := idx.Put(ctx, textDocument("2", map[string]int{"img": 1, "london": 2})); err != nil {
+		t.Fatalf("Failed to index photo: %s", err)
+	}
+	found, err := idx.Find(ctx, []string{"paris"})
+	assert.NoError(t, err)
+	assert.Equal(t, []library.PhotoID{"1", "3"}, matchedIDs(found))
+	found, err = idx.Find(ctx, []string{"lon"})
+	assert.NoError(t, err)
+	assert.Equal(t, []library.PhotoID{"2"}, matchedIDs(found))
+
+	// Photos without terms are removed from the index
+	if err := idx.Put(ctx, textDocument("4", nil)); err != nil {
+		t.Fatalf("Failed to remove photo: %s", err)
+	}
+	found, err = idx.Find(ctx, []string{"berlin"})
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func TestTextIndexCounts(t *testing.T) {
+	runTestWithBoltDB(t, testTextIndexCounts)
+}
+
+func testTextIndexCounts(t *testing.T, db *bolt.DB) {
+	ctx := context.Background()
+	idx, err := NewTextIndex(db)
+	if err != nil {
+		t.Fatalf("Failed to init text index: %s", err)
+	}
+	counts := func() (documents int, terms map[string]int) {
+		terms = make(map[string]int)
+		db.View(func(tx *bolt.Tx) error {
+			documents = readCount(tx.Bucket(textStats), documentCount)
+			return tx.Bucket(termCounts).ForEach(func(k, _ []byte) error {
+				terms[string(k)] = readCount(tx.Bucket(termCounts), k)
+				return nil
+			})
+		})
+		return
+	}
+
+	assert.NoError(t, idx.Put(ctx,
+		textDocument("1", map[string]int{"img": 1, "paris": 2}),
+		textDocument("2", map[string]int{"img": 1, "berlin": 3}),
+	))
+	documents, terms := counts()
+	assert.Equal(t, 2, documents)
+	assert.Equal(t, map[string]int{"img": 2, "paris": 1, "berlin": 1}, terms)
+
+	// Re-indexing a photo does not count it twice
+	assert.NoError(t, idx.Put(ctx, textDocument("1", map[string]int{"img": 1, "london": 2})))
+	documents, terms = counts()
+	assert.Equal(t, 2, documents)
+	assert.Equal(t, map[string]int{"img": 2, "london": 1, "berlin": 1}, terms)
+
+	assert.NoError(t, idx.Put(ctx, textDocument("1", nil), textDocument("2", nil)))
+	documents, terms = counts()
+	assert.Equal(t, 0, documents)
+	assert.Empty(t, terms)
+}
@@ -0,0 +1,89 @@
+package boltstore
+
+import (
+	"context"
+	"encoding/json"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// userPlacesBucket contains the places defined by the user, indexed by ID
+	userPlacesBucket = []byte("userPlaces")
+)
+
+// UserPlaceStore persists the places defined by the user
+type UserPlaceStore struct {
+	db *bolt.DB
+}
+
+func NewUserPlaceStore(db *bolt.DB) (*UserPlaceStore, error) {
+	if err := createBucket(db, userPlacesBucket); err != nil {
+		return nil, err
+	}
+	return &UserPlaceStore{db: db}, nil
+}
+
+// List returns all places defined by the user
+func (s *UserPlaceStore) List(ctx context.Context) (places []library.UserPlace, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(userPlacesBucket).ForEach(func(k, v []byte) error {
+			var p library.UserPlace
+			if err := json.Unmarshal(v, &p); err != nil {
+				return err
+			}
+			places = append(places, p)
+			return nil
+		})
+	})
+	return
+}
+
+func (s *UserPlaceStore) Get(ctx context.Context, id library.UserPlaceID) (place *library.UserPlace, found bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(userPlacesBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		place, found = new(library.UserPlace), true
+		return json.Unmarshal(data, place)
+	})
+	return
+}
+
+// Put creates or updates the given place, new places are given an ID
+func (s *UserPlaceStore) Put(ctx context.Context, place *library.UserPlace) error {
+	if err := place.Validate(); err != nil {
+		return err
+	}
+	if place.ID == "" {
+		place.ID = library.UserPlaceID(uuid.New().String())
+	}
+	data, err := json.Marshal(place)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(userPlacesBucket).Put([]byte(place.ID), data)
+	})
+}
+
+func (s *UserPlaceStore) Delete(ctx context.Context, id library.UserPlaceID) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(userPlacesBucket).Delete([]byte(id))
+	})
+}
+
+// At returns the place containing the given coordinates, the smallest one if
+// several places contain them
+func (s *UserPlaceStore) At(ctx context.Context, c gps.Coordinates) (*library.UserPlace, bool, error) {
+	places, err := s.List(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	place, found := library.UserPlaceAt(places, c)
+	return place, found, nil
+}
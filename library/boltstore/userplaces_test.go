@@ -0,0 +1,48 @@
+package boltstore
+
+import (
+	"context"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestUserPlaceStore(t *testing.T) {
+	runTestWithBoltDB(t, func(t *testing.T, db *bolt.DB) {
+		ctx := context.Background()
+		store, err := NewUserPlaceStore(db)
+		require.NoError(t, err)
+
+		home := library.UserPlace{
+			Name:    "Home",
+			Private: true,
+			Area:    library.Geofence{Center: &gps.Coordinates{Lat: 48.2, Long: 16.37}, Radius: 50},
+		}
+		require.NoError(t, store.Put(ctx, &home))
+		assert.NotEmpty(t, home.ID)
+		assert.Error(t, store.Put(ctx, &library.UserPlace{Name: "Nowhere"}))
+
+		loaded, found, err := store.Get(ctx, home.ID)
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, home, *loaded)
+
+		at, found, err := store.At(ctx, gps.Coordinates{Lat: 48.2, Long: 16.3701})
+		require.NoError(t, err)
+		if assert.True(t, found) {
+			assert.Equal(t, home.ID, at.ID)
+		}
+
+		require.NoError(t, store.Delete(ctx, home.ID))
+		places, err := store.List(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, places)
+		_, found, err = store.Get(ctx, home.ID)
+		assert.NoError(t, err)
+		assert.False(t, found)
+	})
+}
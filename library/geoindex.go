@@ -6,13 +6,34 @@ import (
 	"bitbucket.org/kleinnic74/photos/domain/gps"
 )
 
+// Locations is the tree of all places having photos, grouped by country
 type Locations struct {
-	Countries []*CountryAndPlaces `json:"countries"`
+	Countries []*PlaceNode `json:"countries"`
 }
 
-type CountryAndPlaces struct {
-	gps.Country
-	Places []*gps.Address `json:"places"`
+// PlaceNode is a place within the tree of locations
+type PlaceNode struct {
+	gps.Place
+	// Count is the number of photos at this place and its sub-places
+	Count  int          `json:"count"`
+	Places []*PlaceNode `json:"places,omitempty"`
+}
+
+// Find returns the node of the given place within the tree
+func (l *Locations) Find(id gps.PlaceID) (*PlaceNode, bool) {
+	return findPlace(l.Countries, id)
+}
+
+func findPlace(nodes []*PlaceNode, id gps.PlaceID) (*PlaceNode, bool) {
+	for _, n := range nodes {
+		if n.ID == id {
+			return n, true
+		}
+		if n.ID.Contains(id) {
+			return findPlace(n.Places, id)
+		}
+	}
+	return nil, false
 }
 
 type GeoIndex interface {
@@ -21,8 +42,13 @@ type GeoIndex interface {
 	Has(context.Context, PhotoID) bool
 	Get(context.Context, PhotoID) (*gps.Address, bool, error)
 	Update(context.Context, ExtendedPhotoID, *gps.Address) error
+	Move(ctx context.Context, before, after *Photo) error
+	Remove(context.Context, ExtendedPhotoID) error
 
 	Locations(context.Context) (*Locations, error)
+	Places(context.Context) ([]*gps.Address, error)
+	// FindByPlacePaged returns the photos at the given place, at any level,
+	// including the photos of all its sub-places
 	FindByPlacePaged(context.Context, gps.PlaceID, int, int) ([]PhotoID, bool, error)
 	FindByCountryPaged(context.Context, gps.CountryID, int, int) ([]PhotoID, bool, error)
 }
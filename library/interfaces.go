@@ -8,6 +8,7 @@ import (
 
 	"bitbucket.org/kleinnic74/photos/consts"
 	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/domain/gps"
 )
 
 // PhotoID is the unique identifier of a Photo
@@ -35,8 +36,12 @@ type PhotoLibrary interface {
 	FindAllPaged(ctx context.Context, start, maxCount int, order consts.SortOrder) ([]*Photo, bool, error)
 	Find(ctx context.Context, start, end time.Time, order consts.SortOrder) ([]*Photo, error)
 
+	ChangeDateTaken(ctx context.Context, id PhotoID, taken time.Time) (*Photo, error)
+	SetLocation(ctx context.Context, id PhotoID, location *gps.Coordinates) (*Photo, error)
+	UpdateTags(ctx context.Context, id PhotoID, add, remove []string) (*Photo, error)
+
 	OpenContent(ctx context.Context, id PhotoID) (io.ReadCloser, *Photo, error)
-	OpenThumb(ctx context.Context, id PhotoID, size domain.ThumbSize) (io.ReadCloser, domain.Format, error)
+	OpenThumb(ctx context.Context, id PhotoID, size domain.ThumbSize, format domain.Format) (io.ReadCloser, error)
 }
 
 type PhotoIndex interface {
@@ -14,6 +14,8 @@ import (
 
 	"bitbucket.org/kleinnic74/photos/consts"
 	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/domain/timezone"
 	"bitbucket.org/kleinnic74/photos/logging"
 	"github.com/reusee/mmh3"
 	"go.uber.org/zap"
@@ -25,6 +27,18 @@ const (
 
 type NewPhotoCallback func(ctx context.Context, p *Photo) error
 
+// DateChangedCallback is called after the capture time of a photo has been
+// changed with the photo as it was before and after the change
+type DateChangedCallback func(ctx context.Context, before, after *Photo) error
+
+// LocationChangedCallback is called after the location of a photo has been
+// changed with the photo as it was before and after the change
+type LocationChangedCallback func(ctx context.Context, before, after *Photo) error
+
+// TagsChangedCallback is called after the tags of a photo have been changed
+// with the photo as it was before and after the change
+type TagsChangedCallback func(ctx context.Context, before, after *Photo) error
+
 // BasicPhotoLibrary is a library storing photos on the filesystem
 type BasicPhotoLibrary struct {
 	basedir  string
@@ -32,11 +46,16 @@ type BasicPhotoLibrary struct {
 	dirMode  os.FileMode
 	db       ClosableStore
 
-	thumbdir    string
-	thumber     domain.Thumber
-	thumbFormat domain.Format
+	thumbdir     string
+	thumber      domain.Thumber
+	thumbFormats []domain.Format
+	thumbFlights thumbFlights
+	thumbCache   *ThumbCache
 
-	callbacks []NewPhotoCallback
+	callbacks         []NewPhotoCallback
+	dateCallbacks     []DateChangedCallback
+	locationCallbacks []LocationChangedCallback
+	tagsCallbacks     []TagsChangedCallback
 }
 
 // ReaderFunc is a function providing an io.ReadCloser
@@ -86,15 +105,20 @@ func NewBasicPhotoLibrary(basedir string, store ClosableStore, thumber domain.Th
 	if err := os.MkdirAll(thumbsDir, defaultDirMode); err != nil {
 		return nil, err
 	}
+	thumbCache, err := newThumbCache(thumbsDir)
+	if err != nil {
+		return nil, err
+	}
 	return &BasicPhotoLibrary{
 		basedir:  absdir,
 		photodir: photosDir,
 		dirMode:  defaultDirMode,
 		db:       store,
 
-		thumbdir:    thumbsDir,
-		thumbFormat: domain.MustFormatForExt("jpg"),
-		thumber:     thumber,
+		thumbdir:     thumbsDir,
+		thumbFormats: domain.ThumbFormats(),
+		thumber:      thumber,
+		thumbCache:   thumbCache,
 	}, nil
 }
 
@@ -102,6 +126,18 @@ func (lib *BasicPhotoLibrary) AddCallback(callback NewPhotoCallback) {
 	lib.callbacks = append(lib.callbacks, callback)
 }
 
+func (lib *BasicPhotoLibrary) AddDateChangedCallback(callback DateChangedCallback) {
+	lib.dateCallbacks = append(lib.dateCallbacks, callback)
+}
+
+func (lib *BasicPhotoLibrary) AddLocationChangedCallback(callback LocationChangedCallback) {
+	lib.locationCallbacks = append(lib.locationCallbacks, callback)
+}
+
+func (lib *BasicPhotoLibrary) AddTagsChangedCallback(callback TagsChangedCallback) {
+	lib.tagsCallbacks = append(lib.tagsCallbacks, callback)
+}
+
 // Add adds a photo to this library. If the given photo already exists, then
 // an error of type PhotoAlreadyExists is returned
 func (lib *BasicPhotoLibrary) Add(ctx context.Context, photo domain.Photo, content io.Reader) error {
@@ -126,12 +162,16 @@ func (lib *BasicPhotoLibrary) Add(ctx context.Context, photo domain.Photo, conte
 			ID:     id,
 			SortID: orderedID,
 		},
-		DateTaken:   photo.DateTaken().UTC(),
-		Location:    photo.Location(),
-		Format:      photo.Format(),
-		Orientation: photo.Orientation(),
-		Size:        size,
-		Hash:        hash,
+		DateTaken:      photo.DateTaken().UTC(),
+		LocalDateTaken: photo.DateTaken(),
+		Location:       photo.Location(),
+		Format:         photo.Format(),
+		Orientation:    photo.Orientation(),
+		Size:           size,
+		Hash:           hash,
+		Video:          photo.Video(),
+		Exif:           photo.Exif(),
+		Tags:           NormalizeTags(photo.Keywords()),
 	}
 	logging.From(ctx).Info("Added", zap.String("photo", string(id)), zap.Any("location", p.Location))
 	if err := lib.db.Add(p); err != nil {
@@ -148,6 +188,150 @@ func (lib *BasicPhotoLibrary) Get(ctx context.Context, id PhotoID) (*Photo, erro
 	return lib.db.Get(id)
 }
 
+// ChangeDateTaken changes the capture time of the photo with the given ID, the
+// location of the given time being the timezone the photo was taken in. The
+// SortID of the photo is recomputed and its file is moved to the directory
+// of the new day
+func (lib *BasicPhotoLibrary) ChangeDateTaken(ctx context.Context, id PhotoID, taken time.Time) (*Photo, error) {
+	log, ctx := logging.FromWithNameAndFields(ctx, "library", zap.String("photo", string(id)))
+	before, err := lib.db.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, NotFound(id)
+	}
+	after := *before
+	after.DateTaken = taken.UTC()
+	after.LocalDateTaken = taken
+	after.SortID = orderedIDOf(after.DateTaken, id)
+	after.Path = filepath.Join(taken.Format("2006/01/02"), filepath.Base(before.Path))
+	if err := lib.movePhotoFile(ctx, before.Path, after.Path); err != nil {
+		return nil, err
+	}
+	if err := lib.db.Update(&after); err != nil {
+		// Move the file back to keep the store and the filesystem consistent
+		if err := lib.movePhotoFile(ctx, after.Path, before.Path); err != nil {
+			log.Error("Failed to restore photo file", zap.String("path", after.Path), zap.Error(err))
+		}
+		return nil, err
+	}
+	log.Info("Changed date taken", zap.Time("from", before.DateTaken), zap.Time("to", after.DateTaken))
+	lib.dateChanged(ctx, before, &after)
+	return &after, nil
+}
+
+// dateChanged notifies the date changed callbacks
+func (lib *BasicPhotoLibrary) dateChanged(ctx context.Context, before, after *Photo) {
+	log := logging.From(ctx)
+	for _, cb := range lib.dateCallbacks {
+		if err := cb(ctx, before, after); err != nil {
+			log.Warn("Date change callback failed", zap.String("photo", string(after.ID)), zap.Error(err))
+		}
+	}
+}
+
+func (lib *BasicPhotoLibrary) tagsChanged(ctx context.Context, before, after *Photo) {
+	log := logging.From(ctx)
+	for _, cb := range lib.tagsCallbacks {
+		if err := cb(ctx, before, after); err != nil {
+			log.Warn("Tags change callback failed", zap.String("photo", string(after.ID)), zap.Error(err))
+		}
+	}
+}
+
+// CorrectTimezone places the capture time of the photo with the given ID in
+// the timezone at its location within the given country, once the address
+// of the photo is known: the timezone guessed on import from the location
+// alone may be the one of a neighbouring country. The wall clock time of
+// pictures is kept, videos keep their instant of capture. Photos whose
+// camera recorded the timezone offset are left untouched.
+func (lib *BasicPhotoLibrary) CorrectTimezone(ctx context.Context, id PhotoID, country gps.CountryID) (*Photo, error) {
+	photo, err := lib.db.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if photo == nil {
+		return nil, NotFound(id)
+	}
+	if photo.Location == nil || photo.LocalDateTaken.IsZero() || (photo.Exif != nil && photo.Exif.TimeOffset != "") {
+		return photo, nil
+	}
+	loc, err := timezone.LocationIn(*photo.Location, country)
+	if err != nil {
+		return photo, nil
+	}
+	var local time.Time
+	if photo.Format.Type() == domain.Video {
+		local = photo.DateTaken.In(loc)
+	} else {
+		wall := photo.LocalDateTaken
+		local = time.Date(wall.Year(), wall.Month(), wall.Day(),
+			wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), loc)
+	}
+	_, offset := local.Zone()
+	_, before := photo.LocalDateTaken.Zone()
+	if offset == before && local.Equal(photo.LocalDateTaken) {
+		return photo, nil
+	}
+	return lib.ChangeDateTaken(ctx, id, local)
+}
+
+// SetLocation sets the GPS location of the photo with the given ID, a nil
+// location clears it
+func (lib *BasicPhotoLibrary) SetLocation(ctx context.Context, id PhotoID, location *gps.Coordinates) (*Photo, error) {
+	log, ctx := logging.FromWithNameAndFields(ctx, "library", zap.String("photo", string(id)))
+	before, err := lib.db.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, NotFound(id)
+	}
+	after := *before
+	after.Location = location
+	if err := lib.db.Update(&after); err != nil {
+		return nil, err
+	}
+	log.Info("Changed location", zap.Any("location", location))
+	lib.locationChanged(ctx, before, &after)
+	return &after, nil
+}
+
+// locationChanged notifies the location changed callbacks
+func (lib *BasicPhotoLibrary) locationChanged(ctx context.Context, before, after *Photo) {
+	log := logging.From(ctx)
+	for _, cb := range lib.locationCallbacks {
+		if err := cb(ctx, before, after); err != nil {
+			log.Warn("Location change callback failed", zap.String("photo", string(after.ID)), zap.Error(err))
+		}
+	}
+}
+
+// UpdateTags adds and removes the given tags to and from the photo with the
+// given ID, tags are normalized first. Tags both added and removed are removed.
+func (lib *BasicPhotoLibrary) UpdateTags(ctx context.Context, id PhotoID, add, remove []string) (*Photo, error) {
+	log, ctx := logging.FromWithNameAndFields(ctx, "library", zap.String("photo", string(id)))
+	before, err := lib.db.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, NotFound(id)
+	}
+	after := *before
+	after.Tags = withoutTags(NormalizeTags(append(append([]string{}, before.Tags...), add...)), remove)
+	if equalTags(before.Tags, after.Tags) {
+		return before, nil
+	}
+	if err := lib.db.Update(&after); err != nil {
+		return nil, err
+	}
+	log.Info("Changed tags", zap.Strings("tags", after.Tags))
+	lib.tagsChanged(ctx, before, &after)
+	return &after, nil
+}
+
 // FindAll returns all photos from the underlying store
 func (lib *BasicPhotoLibrary) FindAll(ctx context.Context, order consts.SortOrder) ([]*Photo, error) {
 	return lib.db.FindAll(order)
@@ -217,6 +401,21 @@ func (lib *BasicPhotoLibrary) addPhotoFile(ctx context.Context, in io.Reader, ba
 	return size, nil
 }
 
+func (lib *BasicPhotoLibrary) movePhotoFile(ctx context.Context, from, to string) error {
+	if from == to {
+		return nil
+	}
+	target := filepath.Join(lib.photodir, to)
+	if _, err := os.Stat(target); err == nil {
+		return PhotoFileAlreadyExists(to)
+	}
+	if err := lib.createDirectory(ctx, lib.photodir, filepath.Dir(to)); err != nil {
+		return err
+	}
+	logging.From(ctx).Info("Moving photo", zap.String("from", from), zap.String("to", to))
+	return os.Rename(filepath.Join(lib.photodir, from), target)
+}
+
 func (lib *BasicPhotoLibrary) openPhoto(path string) (io.ReadCloser, error) {
 	return os.Open(filepath.Join(lib.photodir, path))
 }
@@ -229,53 +428,42 @@ func (lib *BasicPhotoLibrary) fileSizeOf(path string) int64 {
 	return info.Size()
 }
 
-func (lib *BasicPhotoLibrary) OpenThumb(ctx context.Context, id PhotoID, size domain.ThumbSize) (io.ReadCloser, domain.Format, error) {
-	logger, ctx := logging.FromWithNameAndFields(ctx, "library", zap.String("photo", string(id)))
-
+// OpenThumb returns the thumb of the given photo encoded in the given format,
+// creating it if needed
+func (lib *BasicPhotoLibrary) OpenThumb(ctx context.Context, id PhotoID, size domain.ThumbSize, format domain.Format) (io.ReadCloser, error) {
+	if !lib.isThumbFormat(format) {
+		return nil, ErrUnsupportedThumbFormat(format.ID())
+	}
+	_, ctx = logging.FromWithNameAndFields(ctx, "library", zap.String("photo", string(id)))
 	photo, err := lib.Get(ctx, id)
 	if err != nil {
 		// Photo does not exist
-		return nil, nil, err
+		return nil, err
 	}
-	dir := filepath.Join(lib.thumbdir, string(photo.ID))
-	path := filepath.Join(dir, size.Name+".jpg")
-	if _, err := os.Stat(path); err != nil {
-		// Thumb does not exist yet
-		logger = logger.With(zap.String("thumb", path))
-		start := time.Now()
-		logger.Debug("Creating thumbnail")
-		if _, err := os.Stat(dir); err != nil {
-			if err = os.MkdirAll(dir, defaultDirMode); err != nil {
-				return nil, nil, err
-			}
+	path := lib.thumbPath(photo.ID, size, format)
+	if _, err := os.Stat(path); err == nil {
+		lib.thumbCache.accessed(path)
+		thumb, err := os.Open(path)
+		if !os.IsNotExist(err) {
+			return thumb, err
 		}
-
-		baseImage, err := lib.openPhoto(photo.Path)
-		if err != nil {
-			logger.Error("Failed to open image content", zap.Error(err))
-			return nil, nil, err
-		}
-		defer baseImage.Close()
-		thumb, err := lib.thumber.CreateThumb(baseImage, photo.Format, photo.Orientation, domain.Small)
-		if err != nil {
-			logger.Error("Failed to created thumb", zap.Error(err))
-			return nil, nil, err
+		// Thumb has been evicted in the meantime
+	}
+	// Thumb does not exist yet, concurrent requests wait for the same creation
+	err = lib.thumbFlights.Do(path, func() error {
+		if _, err := os.Stat(path); err == nil {
+			return nil
 		}
-		out, err := os.Create(path)
-		if err != nil {
-			logger.Error("Failed to save thumb", zap.Error(err))
-			return nil, nil, err
+		if err := lib.createThumb(ctx, photo, size, format, path); err != nil {
+			return err
 		}
-		defer out.Close()
-		lib.thumbFormat.Encode(thumb, out)
-		logger.Info("Created thumb", zap.Duration("duration", time.Since(start)))
-	}
-	// Thumb exists
-	f, err := os.Open(path)
+		lib.thumbCache.added(ctx, path)
+		return nil
+	})
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	return f, lib.thumbFormat, nil
+	return os.Open(path)
 }
 
 func canonicalizeFilename(photo domain.Photo) (dir, filename string, id PhotoID) {
@@ -331,8 +519,17 @@ func (lib *BasicPhotoLibrary) MigrateInstances(ctx context.Context, progress fun
 				zap.Int("pre_schema", int(p.schema)),
 				zap.Int("new_schema", int(updated.schema)),
 				zap.Any("content", updated))
-			lib.db.Update(&updated)
+			if err := lib.db.Update(&updated); err != nil {
+				logger.Warn("Failed to store migrated photo", zap.String("photo", string(p.ID)), zap.Error(err))
+				continue
+			}
 			count++
+			if !updated.DateTaken.Equal(p.DateTaken) || !bytes.Equal(updated.SortID, p.SortID) {
+				lib.dateChanged(ctx, p, &updated)
+			}
+			if !equalTags(updated.Tags, p.Tags) {
+				lib.tagsChanged(ctx, p, &updated)
+			}
 		}
 		progress(i, len(photos))
 	}
@@ -394,11 +591,96 @@ func addSortID(ctx context.Context, p Photo, in ReaderFunc) (Photo, error) {
 	return p, nil
 }
 
+func addVideoInfo(ctx context.Context, p Photo, in ReaderFunc) (Photo, error) {
+	if p.Format.Type() != domain.Video || p.Video != nil {
+		return p, nil
+	}
+	content, err := in()
+	if err != nil {
+		return p, err
+	}
+	defer content.Close()
+	var meta domain.MediaMetaData
+	if err := p.Format.DecodeMetaData(content, &meta); err != nil {
+		// Unreadable videos are left as they are to let later migrations apply
+		logging.From(ctx).Warn("Failed to read video meta-data", zap.String("photo", string(p.ID)), zap.Error(err))
+		return p, nil
+	}
+	p.Video = meta.Video
+	return p, nil
+}
+
+func addExifInfo(ctx context.Context, p Photo, in ReaderFunc) (Photo, error) {
+	if p.Format.Type() != domain.Picture || p.Exif != nil {
+		return p, nil
+	}
+	content, err := in()
+	if err != nil {
+		return p, err
+	}
+	defer content.Close()
+	var meta domain.MediaMetaData
+	if err := p.Format.DecodeMetaData(content, &meta); err != nil {
+		// Pictures without EXIF data are left as they are
+		return p, nil
+	}
+	p.Exif = meta.Exif
+	return p, nil
+}
+
+// addKeywords adds the IPTC and XMP keywords of pictures to their tags, like
+// for pictures added to the library
+func addKeywords(ctx context.Context, p Photo, in ReaderFunc) (Photo, error) {
+	if p.Format.Type() != domain.Picture {
+		return p, nil
+	}
+	content, err := in()
+	if err != nil {
+		return p, err
+	}
+	defer content.Close()
+	var meta domain.MediaMetaData
+	// Keywords are read even if the picture has no EXIF data
+	p.Format.DecodeMetaData(content, &meta)
+	if len(meta.Keywords) > 0 {
+		p.Tags = NormalizeTags(append(append([]string{}, p.Tags...), meta.Keywords...))
+	}
+	return p, nil
+}
+
+// addLocalTime stores the capture time in the timezone the photo was taken in
+// and corrects the UTC capture time accordingly. The SortID is recomputed from
+// the corrected time, the indexes are updated by the date changed callbacks
+// once the photo is stored
+func addLocalTime(ctx context.Context, p Photo, in ReaderFunc) (Photo, error) {
+	if !p.LocalDateTaken.IsZero() {
+		return p, nil
+	}
+	content, err := in()
+	if err != nil {
+		return p, err
+	}
+	defer content.Close()
+	var meta domain.MediaMetaData
+	if err := p.Format.DecodeMetaData(content, &meta); err != nil || meta.DateTaken.IsZero() {
+		// Without meta-data the capture time is only a guess, keep it
+		return p, nil
+	}
+	p.DateTaken = meta.DateTaken.UTC()
+	p.LocalDateTaken = meta.DateTaken
+	p.SortID = orderedIDOf(p.DateTaken, p.ID)
+	return p, nil
+}
+
 func instanceMigrations() InstanceMigrations {
 	migrations := NewInstanceMigrations()
 	migrations.Register(Version(1), InstanceFunc(migratePath))
 	migrations.Register(Version(1), InstanceFunc(addOrientation))
 	migrations.Register(Version(3), InstanceFunc(migrateHash))
 	migrations.Register(Version(6), InstanceFunc(addSortID))
+	migrations.Register(Version(7), InstanceFunc(addVideoInfo))
+	migrations.Register(Version(8), InstanceFunc(addExifInfo))
+	migrations.Register(Version(9), InstanceFunc(addLocalTime))
+	migrations.Register(Version(10), InstanceFunc(addKeywords))
 	return migrations
 }
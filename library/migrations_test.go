@@ -3,9 +3,12 @@ package library
 import (
 	"context"
 	"io"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 
+	"bitbucket.org/kleinnic74/photos/domain"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -47,3 +50,17 @@ func TestMigrations(t *testing.T) {
 		assert.Equal(t, d.dst, result, "Photo #%d", i)
 	}
 }
+
+func TestMigrateUnreadableVideo(t *testing.T) {
+	src := Photo{
+		schema:          Version(6),
+		ExtendedPhotoID: ExtendedPhotoID{ID: "1234", SortID: OrderedID("1234")},
+		Format:          domain.MustFormatForExt("mov"),
+	}
+	result, err := instanceMigrations().Apply(context.Background(), src, func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("not a video")), nil
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, result.Video)
+	assert.Equal(t, Version(currentSchema), result.schema)
+}
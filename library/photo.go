@@ -13,24 +13,37 @@ import (
 	"github.com/reusee/mmh3"
 )
 
-const currentSchema = 6
+const currentSchema = 10
 
 type Photo struct {
 	ExtendedPhotoID
-	schema      Version
-	Path        string             `json:"path,omitempty"`
-	Size        int64              `json:"size,omitempty"`
-	Orientation domain.Orientation `json:"or,omitempty"`
-	Format      domain.FormatSpec  `json:"format"`
-	DateTaken   time.Time          `json:"dateUN,omitempty"`
-	Location    *gps.Coordinates   `json:"gps,omitempty"`
-	Hash        BinaryHash         `json:"hash,omitempty"`
+	schema         Version
+	Path           string             `json:"path,omitempty"`
+	Size           int64              `json:"size,omitempty"`
+	Orientation    domain.Orientation `json:"or,omitempty"`
+	Format         domain.FormatSpec  `json:"format"`
+	DateTaken      time.Time          `json:"dateUN,omitempty"`
+	LocalDateTaken time.Time          `json:"dateLocal,omitempty"`
+	Location       *gps.Coordinates   `json:"gps,omitempty"`
+	Hash           BinaryHash         `json:"hash,omitempty"`
+	Video          *domain.VideoInfo  `json:"video,omitempty"`
+	Exif           *domain.ExifInfo   `json:"exif,omitempty"`
+	Tags           []string           `json:"tags,omitempty"`
 }
 
 func (p *Photo) Name() string {
 	return filepath.Base(p.Path)
 }
 
+// LocalTime returns the capture time in the timezone the photo was taken in,
+// or the capture time in UTC if that timezone is not known
+func (p *Photo) LocalTime() time.Time {
+	if p.LocalDateTaken.IsZero() {
+		return p.DateTaken
+	}
+	return p.LocalDateTaken
+}
+
 func (p *Photo) HasHash() bool {
 	return len(p.Hash) > 0
 }
@@ -38,23 +51,31 @@ func (p *Photo) HasHash() bool {
 func (p *Photo) MarshalJSON() ([]byte, error) {
 	out := struct {
 		ExtendedPhotoID
-		Schema      Version            `json:"schema"`
-		Path        string             `json:"path,omitempty"`
-		Format      string             `json:"format"`
-		Size        int                `json:"size,omitempty"`
-		DateTaken   int64              `json:"dateUN"`
-		Location    *gps.Coordinates   `json:"gps,omitempty"`
-		Orientation domain.Orientation `json:"or,omitempty"`
-		Hash        BinaryHash         `json:"hash,omitempty"`
+		Schema         Version            `json:"schema"`
+		Path           string             `json:"path,omitempty"`
+		Format         string             `json:"format"`
+		Size           int                `json:"size,omitempty"`
+		DateTaken      int64              `json:"dateUN"`
+		LocalDateTaken string             `json:"dateLocal,omitempty"`
+		Location       *gps.Coordinates   `json:"gps,omitempty"`
+		Orientation    domain.Orientation `json:"or,omitempty"`
+		Hash           BinaryHash         `json:"hash,omitempty"`
+		Video          *domain.VideoInfo  `json:"video,omitempty"`
+		Exif           *domain.ExifInfo   `json:"exif,omitempty"`
+		Tags           []string           `json:"tags,omitempty"`
 	}{
 		Schema:          currentSchema,
 		ExtendedPhotoID: p.ExtendedPhotoID,
 		Path:            p.Path,
 		Format:          p.Format.ID(),
 		DateTaken:       p.DateTaken.UnixNano(),
+		LocalDateTaken:  formatLocalTime(p.LocalDateTaken),
 		Location:        p.Location,
 		Orientation:     p.Orientation,
 		Hash:            p.Hash,
+		Video:           p.Video,
+		Exif:            p.Exif,
+		Tags:            p.Tags,
 	}
 	return json.Marshal(&out)
 }
@@ -63,14 +84,18 @@ func (p *Photo) UnmarshalJSON(buf []byte) error {
 	// TODO get rid of this, format should be marshallabled to string
 	var data struct {
 		ExtendedPhotoID
-		Schema      Version            `json:"schema"`
-		Path        string             `json:"path"`
-		Format      domain.FormatSpec  `json:"format"`
-		Size        int                `json:"size"`
-		DateTaken   int64              `json:"dateUN"`
-		Location    *gps.Coordinates   `json:"gps"`
-		Orientation domain.Orientation `json:"or,omitempty"`
-		Hash        BinaryHash         `json:"hash,omitempty"`
+		Schema         Version            `json:"schema"`
+		Path           string             `json:"path"`
+		Format         domain.FormatSpec  `json:"format"`
+		Size           int                `json:"size"`
+		DateTaken      int64              `json:"dateUN"`
+		LocalDateTaken string             `json:"dateLocal,omitempty"`
+		Location       *gps.Coordinates   `json:"gps"`
+		Orientation    domain.Orientation `json:"or,omitempty"`
+		Hash           BinaryHash         `json:"hash,omitempty"`
+		Video          *domain.VideoInfo  `json:"video,omitempty"`
+		Exif           *domain.ExifInfo   `json:"exif,omitempty"`
+		Tags           []string           `json:"tags,omitempty"`
 	}
 	err := json.Unmarshal(buf, &data)
 	if err != nil {
@@ -82,13 +107,28 @@ func (p *Photo) UnmarshalJSON(buf []byte) error {
 	if data.DateTaken != 0 {
 		p.DateTaken = time.Unix(data.DateTaken/1e9, data.DateTaken%1e9).In(time.UTC)
 	}
+	if data.LocalDateTaken != "" {
+		if p.LocalDateTaken, err = time.Parse(time.RFC3339Nano, data.LocalDateTaken); err != nil {
+			return err
+		}
+	}
 	p.Location = data.Location
 	p.Orientation = data.Orientation
 	p.Hash = data.Hash
+	p.Video = data.Video
+	p.Exif = data.Exif
+	p.Tags = data.Tags
 	p.schema = data.Schema
 	return nil
 }
 
+func formatLocalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
 func orderedIDOf(ts time.Time, key PhotoID) OrderedID {
 	var id bytes.Buffer
 	id.Write([]byte(ts.UTC().Format(time.RFC3339)))
@@ -106,6 +146,6 @@ func boundaryIDs(begin, end time.Time) (low, high OrderedID) {
 	var hbuf bytes.Buffer
 	hbuf.Write([]byte(end.UTC().Format(time.RFC3339)))
 	hbuf.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF})
-	high = OrderedID(lbuf.Bytes())
+	high = OrderedID(hbuf.Bytes())
 	return
 }
@@ -22,6 +22,13 @@ var photoData = []struct {
 			Format:          domain.MustFormatForExt("jpg"),
 			DateTaken:       time.Date(2019, 11, 07, 17, 42, 12, 0, time.UTC),
 		}},
+	{fmt.Sprintf(`{"id":"456","sortId":"AQIDBA==","schema":%d,"format":"jpg","dateUN":1573148532000000000,"dateLocal":"2019-11-07T19:42:12+02:00"}`, currentSchema),
+		Photo{schema: currentSchema,
+			ExtendedPhotoID: ExtendedPhotoID{ID: "456", SortID: []byte{1, 2, 3, 4}},
+			Format:          domain.MustFormatForExt("jpg"),
+			DateTaken:       time.Date(2019, 11, 07, 17, 42, 12, 0, time.UTC),
+			LocalDateTaken:  time.Date(2019, 11, 07, 19, 42, 12, 0, time.FixedZone("", 2*3600)),
+		}},
 }
 
 func TestPhotoJSONUnmarshal(t *testing.T) {
@@ -72,3 +79,26 @@ func TestByteOrderOfId(t *testing.T) {
 		}
 	}
 }
+
+func TestBoundaryIDs(t *testing.T) {
+	begin := time.Date(2019, 11, 7, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2019, 11, 8, 0, 0, 0, 0, time.UTC)
+	low, high := boundaryIDs(begin, end)
+	data := []struct {
+		ts     time.Time
+		inside bool
+	}{
+		{begin.Add(-time.Second), false},
+		{begin, true},
+		{begin.Add(12 * time.Hour), true},
+		{end, true},
+		{end.Add(time.Second), false},
+	}
+	for _, d := range data {
+		for _, id := range []PhotoID{"abc", "cde", "xyz"} {
+			sortID := orderedIDOf(d.ts, id)
+			inside := bytes.Compare(sortID, low) >= 0 && bytes.Compare(sortID, high) <= 0
+			assert.Equal(t, d.inside, inside, "%s %s", d.ts, id)
+		}
+	}
+}
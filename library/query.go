@@ -0,0 +1,285 @@
+package library
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Query is a condition on photos, as used by smart albums. Queries are
+// written as terms of the form field=value, combined with AND, OR, NOT and
+// parentheses, e.g. `country=fr AND (tag=beach OR tag="sea side")`. Terms
+// following each other without an operator must all match.
+type Query interface {
+	String() string
+}
+
+// Term matches the photos whose field has the given value
+type Term struct {
+	Field string
+	Value string
+}
+
+// And matches the photos matching all of its queries
+type And []Query
+
+// Or matches the photos matching any of its queries
+type Or []Query
+
+// Not matches the photos not matching its query
+type Not struct {
+	Query Query
+}
+
+func (t Term) String() string {
+	return t.Field + "=" + quoteValue(t.Value)
+}
+
+func (q And) String() string {
+	parts := make([]string, len(q))
+	for i, c := range q {
+		if _, isOr := c.(Or); isOr {
+			parts[i] = "(" + c.String() + ")"
+		} else {
+			parts[i] = c.String()
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
+
+func (q Or) String() string {
+	parts := make([]string, len(q))
+	for i, c := range q {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, " OR ")
+}
+
+func (q Not) String() string {
+	switch q.Query.(type) {
+	case And, Or:
+		return "NOT (" + q.Query.String() + ")"
+	}
+	return "NOT " + q.Query.String()
+}
+
+func quoteValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\n()\"") {
+		return `"` + strings.Replace(v, `"`, `'`, -1) + `"`
+	}
+	return v
+}
+
+// QuerySyntaxError is returned when parsing an invalid query
+type QuerySyntaxError struct {
+	Offset  int
+	Message string
+}
+
+func (e QuerySyntaxError) Error() string {
+	return fmt.Sprintf("Invalid query at %d: %s", e.Offset, e.Message)
+}
+
+// ParseQuery parses the given query, field names and operators are case
+// insensitive
+func ParseQuery(query string) (Query, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, QuerySyntaxError{Offset: 0, Message: "empty query"}
+	}
+	p := &queryParser{tokens: tokens, end: len(query)}
+	q, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := p.peek(); ok {
+		return nil, QuerySyntaxError{Offset: t.offset, Message: fmt.Sprintf("unexpected '%s'", t.text)}
+	}
+	return q, nil
+}
+
+type tokenKind int
+
+const (
+	tokenTerm tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind   tokenKind
+	offset int
+	text   string
+	term   Term
+}
+
+func isDelimiter(r rune) bool {
+	return unicode.IsSpace(r) || r == '(' || r == ')'
+}
+
+func tokenize(query string) ([]token, error) {
+	var tokens []token
+	runes := []rune(query)
+	// Offsets are given in bytes of the query
+	offset := func(i int) int { return len(string(runes[:i])) }
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen, offset: offset(i), text: "("})
+			i++
+			continue
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose, offset: offset(i), text: ")"})
+			i++
+			continue
+		}
+		start := i
+		for i < len(runes) && !isDelimiter(runes[i]) && runes[i] != '=' {
+			i++
+		}
+		word := string(runes[start:i])
+		if i == len(runes) || runes[i] != '=' {
+			t := token{offset: offset(start), text: word}
+			switch strings.ToUpper(word) {
+			case "AND":
+				t.kind = tokenAnd
+			case "OR":
+				t.kind = tokenOr
+			case "NOT":
+				t.kind = tokenNot
+			default:
+				return nil, QuerySyntaxError{Offset: t.offset, Message: fmt.Sprintf("expected field=value instead of '%s'", word)}
+			}
+			tokens = append(tokens, t)
+			continue
+		}
+		if word == "" {
+			return nil, QuerySyntaxError{Offset: offset(start), Message: "missing field name"}
+		}
+		i++
+		var value string
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, QuerySyntaxError{Offset: offset(i), Message: "unterminated quote"}
+			}
+			value, i = string(runes[i+1:end]), end+1
+		} else {
+			valueStart := i
+			for i < len(runes) && !isDelimiter(runes[i]) {
+				i++
+			}
+			value = string(runes[valueStart:i])
+			if value == "" {
+				return nil, QuerySyntaxError{Offset: offset(valueStart), Message: fmt.Sprintf("missing value of '%s'", word)}
+			}
+		}
+		tokens = append(tokens, token{
+			kind:   tokenTerm,
+			offset: offset(start),
+			text:   string(runes[start:i]),
+			term:   Term{Field: strings.ToLower(word), Value: value},
+		})
+	}
+	return tokens, nil
+}
+
+// queryParser is a recursive descent parser of the grammar:
+//
+//	or    = and { "OR" and }
+//	and   = unary { [ "AND" ] unary }
+//	unary = "NOT" unary | "(" or ")" | field "=" value
+type queryParser struct {
+	tokens []token
+	pos    int
+	end    int
+}
+
+func (p *queryParser) peek() (token, bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return token{}, false
+}
+
+func (p *queryParser) parseOr() (Query, error) {
+	var or Or
+	for {
+		q, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, q)
+		if t, ok := p.peek(); !ok || t.kind != tokenOr {
+			break
+		}
+		p.pos++
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *queryParser) parseAnd() (Query, error) {
+	var and And
+	for {
+		q, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, q)
+		t, ok := p.peek()
+		if !ok || t.kind == tokenOr || t.kind == tokenClose {
+			break
+		}
+		if t.kind == tokenAnd {
+			p.pos++
+		}
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *queryParser) parseUnary() (Query, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, QuerySyntaxError{Offset: p.end, Message: "unexpected end of query"}
+	}
+	p.pos++
+	switch t.kind {
+	case tokenTerm:
+		return t.term, nil
+	case tokenNot:
+		q, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Query: q}, nil
+	case tokenOpen:
+		q, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, ok := p.peek(); !ok || closing.kind != tokenClose {
+			return nil, QuerySyntaxError{Offset: t.offset, Message: "unbalanced parenthesis"}
+		}
+		p.pos++
+		return q, nil
+	}
+	return nil, QuerySyntaxError{Offset: t.offset, Message: fmt.Sprintf("unexpected '%s'", t.text)}
+}
@@ -0,0 +1,78 @@
+package library
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	data := []struct {
+		query    string
+		expected Query
+		str      string
+	}{
+		{"tag=beach", Term{"tag", "beach"}, "tag=beach"},
+		{
+			"country=fr AND year=2019 and TAG=beach",
+			And{Term{"country", "fr"}, Term{"year", "2019"}, Term{"tag", "beach"}},
+			"country=fr AND year=2019 AND tag=beach",
+		},
+		{
+			"country=fr year=2019",
+			And{Term{"country", "fr"}, Term{"year", "2019"}},
+			"country=fr AND year=2019",
+		},
+		{
+			`country=fr (tag=beach OR tag="sea side")`,
+			And{Term{"country", "fr"}, Or{Term{"tag", "beach"}, Term{"tag", "sea side"}}},
+			`country=fr AND (tag=beach OR tag="sea side")`,
+		},
+		{
+			"tag=beach AND country=fr OR tag=ski",
+			Or{And{Term{"tag", "beach"}, Term{"country", "fr"}}, Term{"tag", "ski"}},
+			"tag=beach AND country=fr OR tag=ski",
+		},
+		{
+			"year=2019 NOT (tag=work OR tag=screenshot)",
+			And{Term{"year", "2019"}, Not{Or{Term{"tag", "work"}, Term{"tag", "screenshot"}}}},
+			"year=2019 AND NOT (tag=work OR tag=screenshot)",
+		},
+		{"place=fr/Île-de-France/Paris", Term{"place", "fr/Île-de-France/Paris"}, "place=fr/Île-de-France/Paris"},
+	}
+	for _, d := range data {
+		q, err := ParseQuery(d.query)
+		if assert.NoError(t, err, d.query) {
+			assert.Equal(t, d.expected, q, d.query)
+			assert.Equal(t, d.str, q.String(), d.query)
+			again, err := ParseQuery(q.String())
+			assert.NoError(t, err)
+			assert.Equal(t, q, again, "%s must parse to the same query", q)
+		}
+	}
+}
+
+func TestParseInvalidQuery(t *testing.T) {
+	data := []struct {
+		query  string
+		offset int
+	}{
+		{"", 0},
+		{"  ", 0},
+		{"beach", 0},
+		{"tag=", 4},
+		{"=beach", 0},
+		{`tag="beach`, 4},
+		{"tag=beach AND", 13},
+		{"tag=beach OR OR tag=sea", 13},
+		{"(tag=beach", 0},
+		{"tag=beach)", 9},
+		{"NOT", 3},
+	}
+	for _, d := range data {
+		_, err := ParseQuery(d.query)
+		if assert.Error(t, err, d.query) {
+			assert.Equal(t, d.offset, err.(QuerySyntaxError).Offset, "%q: %s", d.query, err)
+		}
+	}
+}
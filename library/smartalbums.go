@@ -0,0 +1,48 @@
+package library
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SmartAlbumID identifies a smart album
+type SmartAlbumID string
+
+// SmartAlbum is an album whose photos are the photos currently matching its
+// query
+type SmartAlbum struct {
+	ID          SmartAlbumID `json:"id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	Query       string       `json:"query"`
+	Created     time.Time    `json:"created"`
+}
+
+// Validate returns an error if the album has no title or its query cannot
+// be parsed
+func (a SmartAlbum) Validate() error {
+	if strings.TrimSpace(a.Title) == "" {
+		return ErrNoAlbumTitle
+	}
+	_, err := ParseQuery(a.Query)
+	return err
+}
+
+// ErrSmartAlbumNotFound is returned when a smart album does not exist
+type ErrSmartAlbumNotFound SmartAlbumID
+
+func (e ErrSmartAlbumNotFound) Error() string {
+	return fmt.Sprintf("No smart album with id %s", string(e))
+}
+
+// SmartAlbums persists the smart albums of the user
+type SmartAlbums interface {
+	// List returns the smart albums, most recently created first
+	List(context.Context) ([]SmartAlbum, error)
+	Get(context.Context, SmartAlbumID) (*SmartAlbum, bool, error)
+	// Put creates or updates the given smart album, a new album is given an ID
+	Put(context.Context, *SmartAlbum) error
+	Delete(context.Context, SmartAlbumID) error
+}
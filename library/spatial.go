@@ -0,0 +1,107 @@
+package library
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+)
+
+// MaxZoom is the most detailed zoom level of maps
+const MaxZoom = 22
+
+// clusterCellsPerTile is the number of clusters per map tile in each direction
+const clusterCellsPerTile = 4
+
+// GeoPhoto is a photo with its location
+type GeoPhoto struct {
+	ID       PhotoID         `json:"id"`
+	Location gps.Coordinates `json:"location"`
+}
+
+// Cluster groups photos close to each other at a given zoom level
+type Cluster struct {
+	// Center is the mean location of the photos of the cluster
+	Center      gps.Coordinates `json:"center"`
+	BoundingBox gps.Rect        `json:"boundingbox"`
+	Count       int             `json:"count"`
+	// Photo is a photo representing the cluster
+	Photo PhotoID `json:"photo"`
+}
+
+// SpatialIndex indexes photos by their coordinates
+type SpatialIndex interface {
+	// FindInRectPaged returns the photos located in the given rectangle
+	FindInRectPaged(ctx context.Context, r gps.Rect, start, maxCount int) ([]GeoPhoto, bool, error)
+	// FindNearPaged returns the photos within radius meters from the given
+	// coordinates, nearest first
+	FindNearPaged(ctx context.Context, c gps.Coordinates, radius float64, start, maxCount int) ([]GeoPhoto, bool, error)
+	// Clusters returns the clusters of the photos in the given rectangle at the
+	// given zoom level
+	Clusters(ctx context.Context, r gps.Rect, zoom int) ([]Cluster, error)
+}
+
+// RectAround returns the rectangle containing all points within radius meters
+// of the given coordinates. Areas crossing the antimeridian are contained in a
+// rectangle spanning all longitudes, gps.RectsAround splits them instead.
+func RectAround(c gps.Coordinates, radius float64) gps.Rect {
+	rects := gps.RectsAround(c, radius)
+	if len(rects) == 1 {
+		return rects[0]
+	}
+	return gps.RectFrom(gps.LonMin, rects[0].Y0(), gps.LonMax, rects[0].Y1())
+}
+
+// ClusterPhotos groups the given photos on a grid whose cells are a quarter of
+// a map tile at the given zoom level
+func ClusterPhotos(photos []GeoPhoto, zoom int) []Cluster {
+	cellSize := (gps.LonMax - gps.LonMin) / math.Pow(2, float64(zoom)) / clusterCellsPerTile
+	type cell struct{ x, y int }
+	type accumulator struct {
+		cluster  Cluster
+		lat, lon float64
+	}
+	cells := make(map[cell]*accumulator)
+	var keys []cell
+	for _, p := range photos {
+		key := cell{
+			x: int(math.Floor((p.Location.Long - gps.LonMin) / cellSize)),
+			y: int(math.Floor((p.Location.Lat - gps.LatMin) / cellSize)),
+		}
+		acc, found := cells[key]
+		if !found {
+			acc = &accumulator{cluster: Cluster{
+				BoundingBox: gps.RectFrom(p.Location.Long, p.Location.Lat, p.Location.Long, p.Location.Lat),
+				Photo:       p.ID,
+			}}
+			cells[key] = acc
+			keys = append(keys, key)
+		}
+		acc.cluster.Count++
+		acc.lat += p.Location.Lat
+		acc.lon += p.Location.Long
+		acc.cluster.BoundingBox = gps.RectFrom(
+			math.Min(acc.cluster.BoundingBox.X0(), p.Location.Long),
+			math.Min(acc.cluster.BoundingBox.Y0(), p.Location.Lat),
+			math.Max(acc.cluster.BoundingBox.X1(), p.Location.Long),
+			math.Max(acc.cluster.BoundingBox.Y1(), p.Location.Lat),
+		)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].y != keys[j].y {
+			return keys[i].y > keys[j].y
+		}
+		return keys[i].x < keys[j].x
+	})
+	clusters := make([]Cluster, len(keys))
+	for i, key := range keys {
+		acc := cells[key]
+		acc.cluster.Center = gps.Coordinates{
+			Lat:  acc.lat / float64(acc.cluster.Count),
+			Long: acc.lon / float64(acc.cluster.Count),
+		}
+		clusters[i] = acc.cluster
+	}
+	return clusters
+}
@@ -0,0 +1,46 @@
+package library
+
+import (
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterPhotos(t *testing.T) {
+	photos := []GeoPhoto{
+		{ID: "1", Location: gps.Coordinates{Lat: 48.20849, Long: 16.37208}},
+		{ID: "2", Location: gps.Coordinates{Lat: 48.21667, Long: 16.39583}},
+		{ID: "3", Location: gps.Coordinates{Lat: 48.85341, Long: 2.3488}},
+	}
+	world := ClusterPhotos(photos, 4)
+	assert.Equal(t, 2, len(world))
+	for _, c := range world {
+		if c.Count == 2 {
+			assert.Equal(t, PhotoID("1"), c.Photo)
+			assert.InDelta(t, 48.21258, c.Center.Lat, 0.0001)
+			assert.Equal(t, gps.RectFrom(16.37208, 48.20849, 16.39583, 48.21667), c.BoundingBox)
+		}
+	}
+	street := ClusterPhotos(photos, 16)
+	assert.Equal(t, 3, len(street))
+	assert.Equal(t, 1, len(ClusterPhotos(photos, 0)))
+	assert.Empty(t, ClusterPhotos(nil, 10))
+}
+
+func TestRectAround(t *testing.T) {
+	center := gps.Coordinates{Lat: 48.2, Long: 16.37}
+	r := RectAround(center, 1000)
+	for _, c := range []gps.Coordinates{{Lat: r.Y0(), Long: center.Long}, {Lat: center.Lat, Long: r.X1()}} {
+		assert.InDelta(t, 1000, center.DistanceTo(&c), 1)
+	}
+	assert.Equal(t, gps.WorldBounds[0], RectAround(gps.Coordinates{Lat: 90, Long: 0}, 1000).X0())
+}
+
+func TestRectAroundAntimeridian(t *testing.T) {
+	for _, center := range []gps.Coordinates{{Lat: -16.7, Long: 179.9}, {Lat: -16.7, Long: -179.9}} {
+		r := RectAround(center, 30000)
+		assert.Equal(t, gps.LonMin, r.X0())
+		assert.Equal(t, gps.LonMax, r.X1())
+	}
+}
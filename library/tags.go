@@ -0,0 +1,82 @@
+package library
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"bitbucket.org/kleinnic74/photos/consts"
+)
+
+// TagCount is a tag with the number of photos having it
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// TagIndex indexes photos by their tags
+type TagIndex interface {
+	// FindByTagPaged returns the photos having the given tag in the order of
+	// their capture time
+	FindByTagPaged(ctx context.Context, tag string, start, maxCount int, order consts.SortOrder) ([]PhotoID, bool, error)
+	// Tags returns the tags starting with the given prefix, most used first.
+	// A maxCount of 0 returns all of them.
+	Tags(ctx context.Context, prefix string, maxCount int) ([]TagCount, error)
+}
+
+// NormalizeTag returns the tag in the form it is stored in: lower case, with
+// control characters removed and whitespace collapsed to single spaces
+func NormalizeTag(tag string) string {
+	tag = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return unicode.ToLower(r)
+	}, tag)
+	return strings.Join(strings.Fields(tag), " ")
+}
+
+// NormalizeTags normalizes the given tags and returns them sorted, without
+// duplicates and empty tags
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, t := range tags {
+		t = NormalizeTag(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		normalized = append(normalized, t)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// withoutTags returns the normalized tags not contained in remove
+func withoutTags(tags []string, remove []string) []string {
+	removed := make(map[string]bool)
+	for _, t := range remove {
+		removed[NormalizeTag(t)] = true
+	}
+	var kept []string
+	for _, t := range tags {
+		if !removed[t] {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+func equalTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
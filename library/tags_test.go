@@ -0,0 +1,114 @@
+package library
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags := NormalizeTags([]string{" New\tYork ", "beach", "BEACH", "", "new york", "\x00"})
+	assert.Equal(t, []string{"beach", "new york"}, tags)
+}
+
+// updatablePhotoStore stores updates of its single photo
+type updatablePhotoStore struct {
+	singlePhotoStore
+}
+
+func (s updatablePhotoStore) Update(p *Photo) error {
+	*s.photo = *p
+	return nil
+}
+
+func (s updatablePhotoStore) FindAll(order consts.SortOrder) ([]*Photo, error) {
+	photo := *s.photo
+	return []*Photo{&photo}, nil
+}
+
+// jpegWithKeywords returns the header of a JPEG image holding the given IPTC
+// keywords
+func jpegWithKeywords(keywords ...string) []byte {
+	var iptc bytes.Buffer
+	for _, k := range keywords {
+		iptc.Write([]byte{0x1c, 2, 25})
+		binary.Write(&iptc, binary.BigEndian, uint16(len(k)))
+		iptc.WriteString(k)
+	}
+	var resources bytes.Buffer
+	resources.WriteString("Photoshop 3.0\x00")
+	resources.WriteString("8BIM")
+	resources.Write([]byte{0x04, 0x04, 0, 0})
+	binary.Write(&resources, binary.BigEndian, uint32(iptc.Len()))
+	resources.Write(iptc.Bytes())
+	if iptc.Len()%2 != 0 {
+		resources.WriteByte(0)
+	}
+	var data bytes.Buffer
+	data.Write([]byte{0xff, 0xd8, 0xff, 0xed})
+	binary.Write(&data, binary.BigEndian, uint16(resources.Len()+2))
+	data.Write(resources.Bytes())
+	data.Write([]byte{0xff, 0xda, 0, 2, 0xff, 0xd9})
+	return data.Bytes()
+}
+
+func TestMigrateKeywords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keywords")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "photo.jpg"), jpegWithKeywords("Beach", "Sunset"), 0644); err != nil {
+		t.Fatalf("Failed to create photo: %s", err)
+	}
+	photo := &Photo{
+		ExtendedPhotoID: ExtendedPhotoID{ID: "1234"},
+		Path:            "photo.jpg",
+		Format:          domain.MustFormatForExt("jpg"),
+		Tags:            []string{"holidays"},
+		schema:          currentSchema - 1,
+	}
+	lib := &BasicPhotoLibrary{db: updatablePhotoStore{singlePhotoStore{photo: photo}}, photodir: dir}
+	var changes [][]string
+	lib.AddTagsChangedCallback(func(ctx context.Context, before, after *Photo) error {
+		changes = append(changes, after.Tags)
+		return nil
+	})
+
+	assert.NoError(t, lib.MigrateInstances(context.Background(), func(int, int) {}))
+	assert.Equal(t, []string{"beach", "holidays", "sunset"}, photo.Tags)
+	assert.Equal(t, Version(currentSchema), photo.schema)
+	assert.Equal(t, [][]string{{"beach", "holidays", "sunset"}}, changes, "Tag indexes must be updated")
+}
+
+func TestUpdateTags(t *testing.T) {
+	ctx := context.Background()
+	photo := &Photo{ExtendedPhotoID: ExtendedPhotoID{ID: "1234"}, Tags: []string{"beach"}}
+	lib := &BasicPhotoLibrary{db: updatablePhotoStore{singlePhotoStore{photo: photo}}}
+	var changes [][]string
+	lib.AddTagsChangedCallback(func(ctx context.Context, before, after *Photo) error {
+		changes = append(changes, after.Tags)
+		return nil
+	})
+
+	updated, err := lib.UpdateTags(ctx, "1234", []string{"Sunset", "holidays"}, []string{"beach"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"holidays", "sunset"}, updated.Tags)
+	assert.Equal(t, []string{"holidays", "sunset"}, photo.Tags)
+
+	// Unchanged tags are not stored again
+	_, err = lib.UpdateTags(ctx, "1234", []string{"SUNSET"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"holidays", "sunset"}}, changes)
+
+	_, err = lib.UpdateTags(ctx, "5678", []string{"sunset"}, nil)
+	assert.Equal(t, NotFound("5678"), err)
+}
@@ -0,0 +1,82 @@
+package library
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// TextMatch is a photo found in the full-text index
+type TextMatch struct {
+	ID     PhotoID
+	SortID OrderedID
+	// Score is the relevance of the photo, higher is more relevant
+	Score float64
+}
+
+// TextIndex finds photos by the words of their file name, place, event, tags
+// and capture date
+type TextIndex interface {
+	// Find returns the photos having all given terms, or terms starting with
+	// them, most relevant first
+	Find(ctx context.Context, terms []string) ([]TextMatch, error)
+}
+
+// foldedLetters maps accented latin letters to the letter without accent
+var foldedLetters = map[rune]string{}
+
+func init() {
+	for base, accented := range map[string]string{
+		"a":  "àáâãäåāăą",
+		"c":  "çćĉċč",
+		"d":  "ďđ",
+		"e":  "èéêëēĕėęě",
+		"g":  "ĝğġģ",
+		"h":  "ĥħ",
+		"i":  "ìíîïĩīĭįı",
+		"j":  "ĵ",
+		"k":  "ķ",
+		"l":  "ĺļľŀł",
+		"n":  "ñńņňŉ",
+		"o":  "òóôõöøōŏő",
+		"r":  "ŕŗř",
+		"s":  "śŝşšș",
+		"t":  "ţťŧț",
+		"u":  "ùúûüũūŭůűų",
+		"w":  "ŵ",
+		"y":  "ýÿŷ",
+		"z":  "źżž",
+		"ae": "æ",
+		"oe": "œ",
+		"ss": "ß",
+	} {
+		for _, r := range accented {
+			foldedLetters[r] = base
+		}
+	}
+}
+
+// Tokenize splits the given text into the terms of the full-text index:
+// sequences of letters and digits, in lower case and without accents
+func Tokenize(text string) []string {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case foldedLetters[r] != "":
+			current.WriteString(foldedLetters[r])
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			current.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
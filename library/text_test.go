@@ -0,0 +1,24 @@
+package library
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	data := []struct {
+		text     string
+		expected []string
+	}{
+		{"", nil},
+		{"  -- ", nil},
+		{"IMG_2019-07-14", []string{"img", "2019", "07", "14"}},
+		{"Zürich, Île-de-France", []string{"zurich", "ile", "de", "france"}},
+		{"Straße Œuvre", []string{"strasse", "oeuvre"}},
+		{"beach,holidays", []string{"beach", "holidays"}},
+	}
+	for _, d := range data {
+		assert.Equal(t, d.expected, Tokenize(d.text), d.text)
+	}
+}
@@ -0,0 +1,272 @@
+package library
+
+import (
+	"container/list"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// ThumbCacheStats describes the content and the activity of the thumb cache
+type ThumbCacheStats struct {
+	Files     int   `json:"files"`
+	Bytes     int64 `json:"bytes"`
+	MaxBytes  int64 `json:"maxBytes"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Purged    int64 `json:"purged"`
+}
+
+var (
+	thumbCacheFilesDesc = prometheus.NewDesc("thumbs_cache_files",
+		"Number of thumbs stored on disk", nil, nil)
+	thumbCacheBytesDesc = prometheus.NewDesc("thumbs_cache_bytes",
+		"Total size of the thumbs stored on disk", nil, nil)
+	thumbCacheMaxBytesDesc = prometheus.NewDesc("thumbs_cache_max_bytes",
+		"Maximum total size of the thumbs stored on disk, 0 if unlimited", nil, nil)
+	thumbCacheHitsDesc = prometheus.NewDesc("thumbs_cache_hits_total",
+		"Number of thumbs served from disk", nil, nil)
+	thumbCacheMissesDesc = prometheus.NewDesc("thumbs_cache_misses_total",
+		"Number of thumbs created", nil, nil)
+	thumbCacheEvictionsDesc = prometheus.NewDesc("thumbs_cache_evictions_total",
+		"Number of thumbs removed to keep the cache below its maximum size", nil, nil)
+	thumbCachePurgedDesc = prometheus.NewDesc("thumbs_cache_purged_total",
+		"Number of photos whose thumbs were removed because the photo no longer exists", nil, nil)
+)
+
+type thumbEntry struct {
+	path string
+	size int64
+}
+
+// ThumbCache keeps track of the thumbs stored on disk and removes the least
+// recently used ones when their total size exceeds the configured maximum.
+// It implements prometheus.Collector to export its stats.
+type ThumbCache struct {
+	mutex   sync.Mutex
+	dir     string
+	stats   ThumbCacheStats
+	entries map[string]*list.Element
+	// lru holds the thumbEntries, most recently used first
+	lru *list.List
+}
+
+// newThumbCache creates a cache for the thumbs in dir, the thumbs already on
+// disk are ordered by their modification time which is updated whenever a
+// thumb is used. Temporary files left over by interrupted thumb creations are
+// removed.
+func newThumbCache(dir string) (*ThumbCache, error) {
+	c := &ThumbCache{
+		dir:     dir,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	var files []os.FileInfo
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			os.Remove(path)
+			return nil
+		}
+		files = append(files, info)
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return files[order[i]].ModTime().Before(files[order[j]].ModTime())
+	})
+	for _, i := range order {
+		c.entries[paths[i]] = c.lru.PushFront(&thumbEntry{path: paths[i], size: files[i].Size()})
+		c.stats.Bytes += files[i].Size()
+	}
+	c.stats.Files = c.lru.Len()
+	return c, nil
+}
+
+// SetMaxBytes sets the maximum total size of the thumbs, thumbs are evicted
+// immediately if the cache is larger. A maximum of 0 disables eviction.
+func (c *ThumbCache) SetMaxBytes(ctx context.Context, maxBytes int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.MaxBytes = maxBytes
+	c.evict(ctx)
+}
+
+// Stats returns the current stats of the cache
+func (c *ThumbCache) Stats() ThumbCacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats
+}
+
+// accessed marks the thumb at path as the most recently used one, its
+// modification time is updated so that the order survives a restart
+func (c *ThumbCache) accessed(path string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.Hits++
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	if e, found := c.entries[path]; found {
+		c.lru.MoveToFront(e)
+		return
+	}
+	// Thumbs copied into the directory while running are tracked on first use
+	if info, err := os.Stat(path); err == nil {
+		c.put(path, info.Size())
+	}
+}
+
+// added records the creation of the thumb at path and evicts the least
+// recently used thumbs if the cache has grown too large
+func (c *ThumbCache) added(ctx context.Context, path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.Misses++
+	c.put(path, info.Size())
+	c.evict(ctx)
+}
+
+// prerendered records the creation of the thumb at path ahead of any request
+// for it. The thumb is added as the least recently used one so that it does
+// not push out the thumbs actually in use, its modification time is set
+// accordingly.
+func (c *ThumbCache) prerendered(ctx context.Context, path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats.Misses++
+	if _, found := c.entries[path]; found {
+		return
+	}
+	if back := c.lru.Back(); back != nil {
+		if coldest, err := os.Stat(back.Value.(*thumbEntry).path); err == nil {
+			os.Chtimes(path, coldest.ModTime(), coldest.ModTime())
+		}
+	}
+	c.entries[path] = c.lru.PushBack(&thumbEntry{path: path, size: info.Size()})
+	c.stats.Bytes += info.Size()
+	c.stats.Files++
+	c.evict(ctx)
+}
+
+// createTemp creates a temporary file in the directory dir, creating it if
+// needed. Holding the lock keeps evict from removing the directory between
+// its creation and the creation of the file, the directory is not empty
+// afterwards.
+func (c *ThumbCache) createTemp(dir, pattern string) (*os.File, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := os.MkdirAll(dir, defaultDirMode); err != nil {
+		return nil, err
+	}
+	return ioutil.TempFile(dir, pattern)
+}
+
+func (c *ThumbCache) put(path string, size int64) {
+	if e, found := c.entries[path]; found {
+		entry := e.Value.(*thumbEntry)
+		c.stats.Bytes += size - entry.size
+		entry.size = size
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[path] = c.lru.PushFront(&thumbEntry{path: path, size: size})
+	c.stats.Bytes += size
+	c.stats.Files++
+}
+
+func (c *ThumbCache) remove(e *list.Element) {
+	entry := c.lru.Remove(e).(*thumbEntry)
+	delete(c.entries, entry.path)
+	c.stats.Bytes -= entry.size
+	c.stats.Files--
+}
+
+// evict removes the least recently used thumbs until the cache fits its
+// maximum size, the most recently used thumb is always kept as it is about
+// to be served
+func (c *ThumbCache) evict(ctx context.Context) {
+	if c.stats.MaxBytes <= 0 {
+		return
+	}
+	logger := logging.From(ctx)
+	for c.stats.Bytes > c.stats.MaxBytes && c.lru.Len() > 1 {
+		e := c.lru.Back()
+		path := e.Value.(*thumbEntry).path
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logger.Warn("Failed to evict thumb", zap.String("thumb", path), zap.Error(err))
+		}
+		// Remove the directory of the photo once its last thumb is gone
+		os.Remove(filepath.Dir(path))
+		c.remove(e)
+		c.stats.Evictions++
+	}
+}
+
+// removeDir removes the given directory of thumbs and its entries
+func (c *ThumbCache) removeDir(dir string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	prefix := dir + string(filepath.Separator)
+	for path, e := range c.entries {
+		if strings.HasPrefix(path, prefix) {
+			c.remove(e)
+		}
+	}
+	c.stats.Purged++
+	return nil
+}
+
+// Describe implements prometheus.Collector
+func (c *ThumbCache) Describe(ch chan<- *prometheus.Desc) {
+	ch <- thumbCacheFilesDesc
+	ch <- thumbCacheBytesDesc
+	ch <- thumbCacheMaxBytesDesc
+	ch <- thumbCacheHitsDesc
+	ch <- thumbCacheMissesDesc
+	ch <- thumbCacheEvictionsDesc
+	ch <- thumbCachePurgedDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *ThumbCache) Collect(ch chan<- prometheus.Metric) {
+	stats := c.Stats()
+	ch <- prometheus.MustNewConstMetric(thumbCacheFilesDesc, prometheus.GaugeValue, float64(stats.Files))
+	ch <- prometheus.MustNewConstMetric(thumbCacheBytesDesc, prometheus.GaugeValue, float64(stats.Bytes))
+	ch <- prometheus.MustNewConstMetric(thumbCacheMaxBytesDesc, prometheus.GaugeValue, float64(stats.MaxBytes))
+	ch <- prometheus.MustNewConstMetric(thumbCacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(thumbCacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(thumbCacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(thumbCachePurgedDesc, prometheus.CounterValue, float64(stats.Purged))
+}
@@ -0,0 +1,181 @@
+package library
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func openThumb(t *testing.T, lib *BasicPhotoLibrary, size domain.ThumbSize) {
+	thumb, err := lib.OpenThumb(context.Background(), "1234", size, domain.JPEG)
+	if assert.NoError(t, err) {
+		thumb.Close()
+	}
+}
+
+func TestThumbCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	lib, _, cleanup := newThumbTestLibrary(t)
+	defer cleanup()
+	cache := lib.ThumbCache()
+
+	openThumb(t, lib, domain.Small)
+	openThumb(t, lib, domain.Medium)
+	stats := cache.Stats()
+	assert.Equal(t, 2, stats.Files)
+	assert.Equal(t, int64(2), stats.Misses)
+	thumbSize := stats.Bytes / 2
+
+	openThumb(t, lib, domain.Small)
+	assert.Equal(t, int64(1), cache.Stats().Hits)
+
+	cache.SetMaxBytes(context.Background(), 2*thumbSize)
+	openThumb(t, lib, domain.Large)
+
+	stats = cache.Stats()
+	assert.Equal(t, 2, stats.Files)
+	assert.Equal(t, 2*thumbSize, stats.Bytes)
+	assert.Equal(t, int64(1), stats.Evictions)
+	_, err := os.Stat(lib.thumbPath("1234", domain.Medium, domain.JPEG))
+	assert.True(t, os.IsNotExist(err), "Least recently used thumb must be evicted")
+	for _, size := range []domain.ThumbSize{domain.Small, domain.Large} {
+		_, err := os.Stat(lib.thumbPath("1234", size, domain.JPEG))
+		assert.NoError(t, err, "Thumb %s must be kept", size.Name)
+	}
+
+	// Evicted thumbs are created again when requested
+	openThumb(t, lib, domain.Medium)
+	assert.Equal(t, int64(2), cache.Stats().Evictions)
+}
+
+func TestPurgeThumbs(t *testing.T) {
+	lib, _, cleanup := newThumbTestLibrary(t)
+	defer cleanup()
+	openThumb(t, lib, domain.Small)
+
+	deleted := filepath.Join(lib.thumbdir, "5678")
+	if err := os.MkdirAll(deleted, defaultDirMode); err != nil {
+		t.Fatalf("Failed to create thumb directory: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(deleted, "S.jpg"), []byte("thumb"), 0644); err != nil {
+		t.Fatalf("Failed to create thumb: %s", err)
+	}
+	leftover := filepath.Join(lib.thumbdir, "1234", ".S-jpg-1.tmp")
+	if err := ioutil.WriteFile(leftover, nil, 0644); err != nil {
+		t.Fatalf("Failed to create temporary thumb: %s", err)
+	}
+
+	// Existing thumbs are loaded when opening the library
+	reopened, err := NewBasicPhotoLibrary(lib.basedir, lib.db, lib.thumber)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 2, reopened.ThumbCache().Stats().Files)
+	_, err = os.Stat(leftover)
+	assert.True(t, os.IsNotExist(err), "Temporary files must be removed")
+
+	purged, err := reopened.PurgeThumbs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	_, err = os.Stat(deleted)
+	assert.True(t, os.IsNotExist(err), "Thumbs of deleted photos must be removed")
+	_, err = os.Stat(reopened.thumbPath("1234", domain.Small, domain.JPEG))
+	assert.NoError(t, err, "Thumbs of existing photos must be kept")
+
+	stats := reopened.ThumbCache().Stats()
+	assert.Equal(t, 1, stats.Files)
+	assert.Equal(t, int64(1), stats.Purged)
+}
+
+func TestThumbCacheKeepsOrderAcrossRestarts(t *testing.T) {
+	lib, _, cleanup := newThumbTestLibrary(t)
+	defer cleanup()
+
+	openThumb(t, lib, domain.Small)
+	openThumb(t, lib, domain.Medium)
+	thumbSize := lib.ThumbCache().Stats().Bytes / 2
+	openThumb(t, lib, domain.Small)
+
+	reopened, err := NewBasicPhotoLibrary(lib.basedir, lib.db, lib.thumber)
+	if !assert.NoError(t, err) {
+		return
+	}
+	reopened.ThumbCache().SetMaxBytes(context.Background(), thumbSize)
+	_, err = os.Stat(lib.thumbPath("1234", domain.Medium, domain.JPEG))
+	assert.True(t, os.IsNotExist(err), "Least recently used thumb must be evicted after a restart")
+	_, err = os.Stat(lib.thumbPath("1234", domain.Small, domain.JPEG))
+	assert.NoError(t, err, "Recently used thumb must be kept after a restart")
+}
+
+func TestThumbCachePrerenderedThumbsAreColdest(t *testing.T) {
+	lib, _, cleanup := newThumbTestLibrary(t)
+	defer cleanup()
+	ctx := context.Background()
+	cache := lib.ThumbCache()
+
+	openThumb(t, lib, domain.Small)
+	openThumb(t, lib, domain.Medium)
+	assert.NoError(t, lib.RenderThumb(ctx, "1234", domain.Large, domain.JPEG))
+	assert.NoError(t, lib.RenderThumb(ctx, "1234", domain.Small, domain.JPEG))
+	stats := cache.Stats()
+	assert.Equal(t, 3, stats.Files)
+	assert.Equal(t, int64(3), stats.Misses)
+	thumbSize := stats.Bytes / 3
+
+	// The pre-rendered thumb goes first, then the least recently used one
+	cache.SetMaxBytes(ctx, 2*thumbSize)
+	_, err := os.Stat(lib.thumbPath("1234", domain.Large, domain.JPEG))
+	assert.True(t, os.IsNotExist(err), "Pre-rendered thumb must be evicted first")
+	for _, size := range []domain.ThumbSize{domain.Small, domain.Medium} {
+		_, err := os.Stat(lib.thumbPath("1234", size, domain.JPEG))
+		assert.NoError(t, err, "Thumb %s must be kept", size.Name)
+	}
+}
+
+func TestThumbCachePrerenderedThumbsStayColdAcrossRestarts(t *testing.T) {
+	lib, _, cleanup := newThumbTestLibrary(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	openThumb(t, lib, domain.Small)
+	thumbSize := lib.ThumbCache().Stats().Bytes
+	assert.NoError(t, lib.RenderThumb(ctx, "1234", domain.Large, domain.JPEG))
+
+	reopened, err := NewBasicPhotoLibrary(lib.basedir, lib.db, lib.thumber)
+	if !assert.NoError(t, err) {
+		return
+	}
+	reopened.ThumbCache().SetMaxBytes(ctx, thumbSize)
+	_, err = os.Stat(lib.thumbPath("1234", domain.Large, domain.JPEG))
+	assert.True(t, os.IsNotExist(err), "Pre-rendered thumb must be evicted first after a restart")
+	_, err = os.Stat(lib.thumbPath("1234", domain.Small, domain.JPEG))
+	assert.NoError(t, err, "Used thumb must be kept after a restart")
+}
+
+func TestThumbCacheCreatesRemovedDirectories(t *testing.T) {
+	lib, _, cleanup := newThumbTestLibrary(t)
+	defer cleanup()
+	ctx := context.Background()
+	cache := lib.ThumbCache()
+
+	// The pre-rendered thumb is evicted right away, the directory is kept
+	// for the thumb in use
+	openThumb(t, lib, domain.Small)
+	cache.SetMaxBytes(ctx, 1)
+	assert.NoError(t, lib.RenderThumb(ctx, "1234", domain.Medium, domain.JPEG))
+	assert.Equal(t, 1, cache.Stats().Files)
+	_, err := os.Stat(lib.thumbPath("1234", domain.Small, domain.JPEG))
+	assert.NoError(t, err, "Thumb in use must be kept")
+
+	// Thumbs are created in directories removed by evictions
+	if err := os.RemoveAll(filepath.Join(lib.thumbdir, "1234")); err != nil {
+		t.Fatalf("Failed to remove thumb directory: %s", err)
+	}
+	openThumb(t, lib, domain.Large)
+	_, err = os.Stat(lib.thumbPath("1234", domain.Large, domain.JPEG))
+	assert.NoError(t, err)
+}
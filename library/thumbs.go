@@ -0,0 +1,216 @@
+package library
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"go.uber.org/zap"
+)
+
+// thumbFlights deduplicates concurrent creations of the same thumb, the zero
+// value is ready to use
+type thumbFlights struct {
+	mutex   sync.Mutex
+	flights map[string]*thumbFlight
+}
+
+type thumbFlight struct {
+	done chan struct{}
+	err  error
+}
+
+// Do calls create unless a creation for the same key is already in progress,
+// in which case it waits for that creation and returns its result
+func (f *thumbFlights) Do(key string, create func() error) error {
+	f.mutex.Lock()
+	if flight, inProgress := f.flights[key]; inProgress {
+		f.mutex.Unlock()
+		<-flight.done
+		return flight.err
+	}
+	if f.flights == nil {
+		f.flights = make(map[string]*thumbFlight)
+	}
+	flight := &thumbFlight{done: make(chan struct{})}
+	f.flights[key] = flight
+	f.mutex.Unlock()
+
+	flight.err = create()
+
+	f.mutex.Lock()
+	delete(f.flights, key)
+	f.mutex.Unlock()
+	close(flight.done)
+	return flight.err
+}
+
+// ErrUnsupportedThumbFormat is returned when requesting a thumb in a format
+// thumbs cannot be encoded in
+type ErrUnsupportedThumbFormat string
+
+func (e ErrUnsupportedThumbFormat) Error() string {
+	return fmt.Sprintf("Thumbs cannot be encoded as %s", string(e))
+}
+
+func (lib *BasicPhotoLibrary) isThumbFormat(format domain.Format) bool {
+	for _, f := range lib.thumbFormats {
+		if f.ID() == format.ID() {
+			return true
+		}
+	}
+	return false
+}
+
+// thumbPath returns the path of a thumb, the thumbs of all sizes and formats
+// of a photo are stored in the same directory
+func (lib *BasicPhotoLibrary) thumbPath(id PhotoID, size domain.ThumbSize, format domain.Format) string {
+	return filepath.Join(lib.thumbdir, string(id), size.Name+"."+format.ID())
+}
+
+// createThumb renders the thumb of the given photo into a temporary file which
+// is then renamed to path, so that readers never see a partially written
+// thumb. The caller records the thumb in the cache.
+func (lib *BasicPhotoLibrary) createThumb(ctx context.Context, photo *Photo, size domain.ThumbSize, format domain.Format, path string) error {
+	logger := logging.From(ctx).With(zap.String("thumb", path))
+	start := time.Now()
+	logger.Debug("Creating thumbnail")
+	baseImage, err := lib.openPhoto(photo.Path)
+	if err != nil {
+		logger.Error("Failed to open image content", zap.Error(err))
+		return err
+	}
+	defer baseImage.Close()
+	thumb, err := lib.thumber.CreateThumb(baseImage, photo.Format, photo.Orientation, size)
+	if err != nil {
+		logger.Error("Failed to created thumb", zap.Error(err))
+		return err
+	}
+	out, err := lib.thumbCache.createTemp(filepath.Dir(path), "."+size.Name+"-"+format.ID()+"-*.tmp")
+	if err != nil {
+		logger.Error("Failed to save thumb", zap.Error(err))
+		return err
+	}
+	if err := format.Encode(thumb, out); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		logger.Error("Failed to encode thumb", zap.Error(err))
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		logger.Error("Failed to save thumb", zap.Error(err))
+		return err
+	}
+	if err := os.Rename(out.Name(), path); err != nil {
+		os.Remove(out.Name())
+		logger.Error("Failed to save thumb", zap.Error(err))
+		return err
+	}
+	logger.Info("Created thumb", zap.Duration("duration", time.Since(start)))
+	return nil
+}
+
+// VerifyThumb checks that the existing thumbs of the given photo can be
+// decoded and creates them again if they cannot, e.g. because they were
+// truncated. Returns true if a thumb has been created again, missing thumbs
+// are not created.
+func (lib *BasicPhotoLibrary) VerifyThumb(ctx context.Context, id PhotoID, size domain.ThumbSize) (bool, error) {
+	logger, ctx := logging.FromWithNameAndFields(ctx, "library", zap.String("photo", string(id)))
+	photo, err := lib.Get(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	regenerated := false
+	for _, format := range lib.thumbFormats {
+		path := lib.thumbPath(photo.ID, size, format)
+		in, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return regenerated, err
+		}
+		_, err = format.Decode(in)
+		in.Close()
+		if err == nil {
+			continue
+		}
+		logger.Warn("Corrupt thumb", zap.String("thumb", path), zap.Error(err))
+		format := format
+		if err := lib.thumbFlights.Do(path, func() error {
+			if err := lib.createThumb(ctx, photo, size, format, path); err != nil {
+				return err
+			}
+			lib.thumbCache.added(ctx, path)
+			return nil
+		}); err != nil {
+			return regenerated, err
+		}
+		regenerated = true
+	}
+	return regenerated, nil
+}
+
+// RenderThumb creates the thumb of the given photo ahead of any request for
+// it, existing thumbs are left untouched. Pre-rendered thumbs are the first
+// ones to be evicted until they are requested.
+func (lib *BasicPhotoLibrary) RenderThumb(ctx context.Context, id PhotoID, size domain.ThumbSize, format domain.Format) error {
+	if !lib.isThumbFormat(format) {
+		return ErrUnsupportedThumbFormat(format.ID())
+	}
+	_, ctx = logging.FromWithNameAndFields(ctx, "library", zap.String("photo", string(id)))
+	photo, err := lib.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	path := lib.thumbPath(photo.ID, size, format)
+	return lib.thumbFlights.Do(path, func() error {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		if err := lib.createThumb(ctx, photo, size, format, path); err != nil {
+			return err
+		}
+		lib.thumbCache.prerendered(ctx, path)
+		return nil
+	})
+}
+
+// ThumbCache returns the cache keeping track of the thumbs stored on disk
+func (lib *BasicPhotoLibrary) ThumbCache() *ThumbCache {
+	return lib.thumbCache
+}
+
+// PurgeThumbs removes the thumbs of photos which are no longer part of the
+// library and returns the number of photos whose thumbs have been removed
+func (lib *BasicPhotoLibrary) PurgeThumbs(ctx context.Context) (int, error) {
+	logger, ctx := logging.SubFrom(ctx, "library")
+	dirs, err := ioutil.ReadDir(lib.thumbdir)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		id := PhotoID(dir.Name())
+		if _, err := lib.Get(ctx, id); err == nil {
+			continue
+		} else if _, notFound := err.(ErrNotFound); !notFound {
+			return purged, err
+		}
+		if err := lib.thumbCache.removeDir(filepath.Join(lib.thumbdir, dir.Name())); err != nil {
+			return purged, err
+		}
+		logger.Info("Purged thumbs of deleted photo", zap.String("photo", string(id)))
+		purged++
+	}
+	return purged, nil
+}
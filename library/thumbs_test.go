@@ -0,0 +1,143 @@
+package library
+
+import (
+	"context"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+type singlePhotoStore struct {
+	ClosableStore
+	photo *Photo
+}
+
+func (s singlePhotoStore) Get(id PhotoID) (*Photo, error) {
+	if id != s.photo.ID {
+		return nil, NotFound(id)
+	}
+	return s.photo, nil
+}
+
+// slowThumber creates blank thumbs slowly enough for requests to overlap
+type slowThumber struct {
+	created int32
+}
+
+func (t *slowThumber) CreateThumb(in io.Reader, format domain.Format, orientation domain.Orientation, size domain.ThumbSize) (image.Image, error) {
+	atomic.AddInt32(&t.created, 1)
+	time.Sleep(50 * time.Millisecond)
+	return image.NewRGBA(image.Rect(0, 0, 16, 16)), nil
+}
+
+func newThumbTestLibrary(t *testing.T) (*BasicPhotoLibrary, *slowThumber, func()) {
+	dir, err := ioutil.TempDir("", "thumbs")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	photo := &Photo{
+		ExtendedPhotoID: ExtendedPhotoID{ID: "1234"},
+		Path:            "photo.jpg",
+		Format:          domain.MustFormatForExt("jpg"),
+	}
+	thumber := &slowThumber{}
+	lib, err := NewBasicPhotoLibrary(dir, singlePhotoStore{photo: photo}, thumber)
+	if err != nil {
+		t.Fatalf("Failed to create library: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(lib.photodir, photo.Path), nil, 0644); err != nil {
+		t.Fatalf("Failed to create photo: %s", err)
+	}
+	return lib, thumber, func() { os.RemoveAll(dir) }
+}
+
+func TestOpenThumbConcurrently(t *testing.T) {
+	lib, thumber, cleanup := newThumbTestLibrary(t)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			thumb, err := lib.OpenThumb(context.Background(), "1234", domain.Small, domain.JPEG)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer thumb.Close()
+			_, err = domain.JPEG.Decode(thumb)
+			assert.NoError(t, err, "Thumb must be complete")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), thumber.created)
+}
+
+func TestVerifyThumb(t *testing.T) {
+	lib, thumber, cleanup := newThumbTestLibrary(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	regenerated, err := lib.VerifyThumb(ctx, "1234", domain.Small)
+	assert.NoError(t, err)
+	assert.False(t, regenerated, "Missing thumbs must not be created")
+
+	thumb, err := lib.OpenThumb(ctx, "1234", domain.Small, domain.JPEG)
+	if !assert.NoError(t, err) {
+		return
+	}
+	thumb.Close()
+	regenerated, err = lib.VerifyThumb(ctx, "1234", domain.Small)
+	assert.NoError(t, err)
+	assert.False(t, regenerated, "Valid thumbs must be kept")
+
+	path := lib.thumbPath("1234", domain.Small, domain.JPEG)
+	info, err := os.Stat(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, os.Truncate(path, info.Size()/2))
+	regenerated, err = lib.VerifyThumb(ctx, "1234", domain.Small)
+	assert.NoError(t, err)
+	assert.True(t, regenerated, "Truncated thumbs must be regenerated")
+	assert.Equal(t, int32(2), thumber.created)
+
+	in, err := os.Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer in.Close()
+	_, err = domain.JPEG.Decode(in)
+	assert.NoError(t, err)
+}
+
+func TestOpenThumbFormats(t *testing.T) {
+	lib, thumber, cleanup := newThumbTestLibrary(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for _, format := range domain.ThumbFormats() {
+		thumb, err := lib.OpenThumb(ctx, "1234", domain.Medium, format)
+		if !assert.NoError(t, err, "format %s", format.ID()) {
+			continue
+		}
+		_, err = format.Decode(thumb)
+		thumb.Close()
+		assert.NoError(t, err, "Thumb must be encoded as %s", format.ID())
+		_, err = os.Stat(lib.thumbPath("1234", domain.Medium, format))
+		assert.NoError(t, err, "Thumb must be stored as %s", format.ID())
+	}
+	assert.Equal(t, int32(len(domain.ThumbFormats())), thumber.created)
+
+	_, err := lib.OpenThumb(ctx, "1234", domain.Medium, domain.MOV)
+	assert.Equal(t, ErrUnsupportedThumbFormat("mov"), err)
+}
@@ -0,0 +1,86 @@
+package library
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorrectTimezone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timezone")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	// Elvas, Portugal, is closer to the Spanish reference locations
+	madrid, _ := time.LoadLocation("Europe/Madrid")
+	guessed := time.Date(2019, 7, 14, 0, 30, 0, 0, madrid)
+	photo := &Photo{
+		ExtendedPhotoID: ExtendedPhotoID{ID: "1234", SortID: orderedIDOf(guessed, "1234")},
+		Path:            "2019/07/14/elvas.jpg",
+		Format:          domain.MustFormatForExt("jpg"),
+		DateTaken:       guessed.UTC(),
+		LocalDateTaken:  guessed,
+		Location:        &gps.Coordinates{Lat: 38.88, Long: -7.16},
+	}
+	lib, err := NewBasicPhotoLibrary(dir, updatablePhotoStore{singlePhotoStore{photo: photo}}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create library: %s", err)
+	}
+	if err := os.MkdirAll(filepath.Join(lib.photodir, "2019/07/14"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(lib.photodir, photo.Path), nil, 0644); err != nil {
+		t.Fatalf("Failed to create photo: %s", err)
+	}
+	var moved []*Photo
+	lib.AddDateChangedCallback(func(ctx context.Context, before, after *Photo) error {
+		moved = append(moved, after)
+		return nil
+	})
+
+	_, err = lib.CorrectTimezone(ctx, "1234", "es")
+	assert.NoError(t, err)
+	assert.Empty(t, moved, "Photos in the guessed country must be left untouched")
+
+	corrected, err := lib.CorrectTimezone(ctx, "1234", "pt")
+	assert.NoError(t, err)
+	assert.Equal(t, "2019-07-14T00:30:00+01:00", corrected.LocalDateTaken.Format(time.RFC3339), "Wall clock time must be kept")
+	assert.Equal(t, "2019-07-13T23:30:00Z", corrected.DateTaken.Format(time.RFC3339))
+	assert.Equal(t, orderedIDOf(corrected.DateTaken, "1234"), corrected.SortID)
+	assert.Equal(t, "2019/07/14/elvas.jpg", corrected.Path)
+	assert.Len(t, moved, 1)
+
+	photo.Exif = &domain.ExifInfo{TimeOffset: "+02:00"}
+	photo.LocalDateTaken = guessed
+	_, err = lib.CorrectTimezone(ctx, "1234", "pt")
+	assert.NoError(t, err)
+	assert.Len(t, moved, 1, "Offsets recorded by the camera must be kept")
+}
+
+func TestAddLocalTimeUpdatesSortID(t *testing.T) {
+	stale := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := Photo{
+		ExtendedPhotoID: ExtendedPhotoID{ID: "1234", SortID: orderedIDOf(stale, "1234")},
+		Format:          domain.MustFormatForExt("jpg"),
+		DateTaken:       stale,
+	}
+	migrated, err := addLocalTime(context.Background(), p, func() (io.ReadCloser, error) {
+		return os.Open("../domain/testdata/Canon_40D.jpg")
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEqual(t, stale, migrated.DateTaken)
+	assert.Equal(t, orderedIDOf(migrated.DateTaken, "1234"), migrated.SortID)
+}
@@ -0,0 +1,148 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+)
+
+var (
+	ErrNoGeofence      = errors.New("Place needs either a circle or a polygon")
+	ErrInvalidGeofence = errors.New("Place needs a positive radius or a polygon of at least 3 points")
+	ErrNoPlaceName     = errors.New("Place needs a name")
+)
+
+// UserPlaceID identifies a user-defined place
+type UserPlaceID string
+
+// Geofence is the area of a user-defined place, either a circle given by its
+// center and radius in meters or a polygon
+type Geofence struct {
+	Center  *gps.Coordinates  `json:"center,omitempty"`
+	Radius  float64           `json:"radius,omitempty"`
+	Polygon []gps.Coordinates `json:"polygon,omitempty"`
+}
+
+// Validate returns an error if the geofence is neither a valid circle nor a
+// valid polygon
+func (g Geofence) Validate() error {
+	switch {
+	case g.Center != nil && len(g.Polygon) > 0:
+		return ErrNoGeofence
+	case g.Center != nil:
+		if !g.Center.IsValid() || g.Radius <= 0 {
+			return ErrInvalidGeofence
+		}
+	case len(g.Polygon) > 0:
+		if len(g.Polygon) < 3 {
+			return ErrInvalidGeofence
+		}
+		for _, c := range g.Polygon {
+			if !c.IsValid() {
+				return ErrInvalidGeofence
+			}
+		}
+	default:
+		return ErrNoGeofence
+	}
+	return nil
+}
+
+// Contains returns true if the given coordinates are within the geofence
+func (g Geofence) Contains(c gps.Coordinates) bool {
+	if g.Center != nil {
+		return g.Center.DistanceTo(&c) <= g.Radius
+	}
+	// Ray casting: a point is inside if a ray from it crosses an odd number
+	// of edges
+	inside := false
+	for i, j := 0, len(g.Polygon)-1; i < len(g.Polygon); j, i = i, i+1 {
+		a, b := g.Polygon[i], g.Polygon[j]
+		if (a.Lat > c.Lat) != (b.Lat > c.Lat) &&
+			c.Long < (b.Long-a.Long)*(c.Lat-a.Lat)/(b.Lat-a.Lat)+a.Long {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Bounds returns the rectangle containing the geofence
+func (g Geofence) Bounds() gps.Rect {
+	if g.Center != nil {
+		return RectAround(*g.Center, g.Radius)
+	}
+	bounds := gps.Rect{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, c := range g.Polygon {
+		bounds = gps.Rect{
+			math.Min(bounds[0], c.Long),
+			math.Min(bounds[1], c.Lat),
+			math.Max(bounds[2], c.Long),
+			math.Max(bounds[3], c.Lat),
+		}
+	}
+	return bounds
+}
+
+// center returns a point representative of the geofence
+func (g Geofence) center() gps.Coordinates {
+	if g.Center != nil {
+		return *g.Center
+	}
+	p := g.Bounds().Center()
+	return gps.Coordinates{Lat: p.Lat(), Long: p.Lon()}
+}
+
+// UserPlace is a named place defined by the user, overriding the address
+// resolved by reverse geocoding for the photos taken within its area
+type UserPlace struct {
+	ID   UserPlaceID `json:"id"`
+	Name string      `json:"name"`
+	// Private places do not disclose the coordinates of their photos when
+	// photos are shared or exported
+	Private bool     `json:"private"`
+	Area    Geofence `json:"area"`
+}
+
+// Validate returns an error if the place has no name or no valid area
+func (p *UserPlace) Validate() error {
+	if p.Name == "" {
+		return ErrNoPlaceName
+	}
+	return p.Area.Validate()
+}
+
+// Center returns the point used to resolve the address around the place
+func (p *UserPlace) Center() gps.Coordinates {
+	return p.Area.center()
+}
+
+// UserPlaceAt returns the place containing the given coordinates, the
+// smallest one if places overlap
+func UserPlaceAt(places []UserPlace, c gps.Coordinates) (*UserPlace, bool) {
+	var found *UserPlace
+	var foundSize float64
+	for i := range places {
+		p := &places[i]
+		if !p.Area.Contains(c) {
+			continue
+		}
+		bounds := p.Area.Bounds()
+		if size := bounds.W() * bounds.H(); found == nil || size < foundSize {
+			found, foundSize = p, size
+		}
+	}
+	return found, found != nil
+}
+
+// UserPlaces stores the places defined by the user
+type UserPlaces interface {
+	List(context.Context) ([]UserPlace, error)
+	Get(context.Context, UserPlaceID) (*UserPlace, bool, error)
+	// Put creates or updates the given place, a new place is given an ID
+	Put(context.Context, *UserPlace) error
+	Delete(context.Context, UserPlaceID) error
+	// At returns the place containing the given coordinates
+	At(context.Context, gps.Coordinates) (*UserPlace, bool, error)
+}
@@ -0,0 +1,51 @@
+package library
+
+import (
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeofenceContains(t *testing.T) {
+	circle := Geofence{Center: &gps.Coordinates{Lat: 48.2, Long: 16.37}, Radius: 500}
+	square := Geofence{Polygon: []gps.Coordinates{
+		{Lat: 48.0, Long: 16.0}, {Lat: 48.0, Long: 17.0}, {Lat: 49.0, Long: 17.0}, {Lat: 49.0, Long: 16.0},
+	}}
+	triangle := Geofence{Polygon: []gps.Coordinates{
+		{Lat: 0, Long: 0}, {Lat: 0, Long: 10}, {Lat: 10, Long: 0},
+	}}
+	assert.True(t, circle.Contains(gps.Coordinates{Lat: 48.2, Long: 16.371}))
+	assert.False(t, circle.Contains(gps.Coordinates{Lat: 48.21, Long: 16.37}))
+	assert.True(t, square.Contains(gps.Coordinates{Lat: 48.5, Long: 16.5}))
+	assert.False(t, square.Contains(gps.Coordinates{Lat: 49.5, Long: 16.5}))
+	assert.True(t, triangle.Contains(gps.Coordinates{Lat: 2, Long: 2}))
+	assert.False(t, triangle.Contains(gps.Coordinates{Lat: 6, Long: 6}))
+	assert.Equal(t, gps.Rect{0, 0, 10, 10}, triangle.Bounds())
+}
+
+func TestGeofenceValidate(t *testing.T) {
+	center := gps.Coordinates{Lat: 48.2, Long: 16.37}
+	assert.NoError(t, Geofence{Center: &center, Radius: 10}.Validate())
+	assert.Equal(t, ErrNoGeofence, Geofence{}.Validate())
+	assert.Equal(t, ErrInvalidGeofence, Geofence{Center: &center}.Validate())
+	assert.Equal(t, ErrInvalidGeofence, Geofence{Polygon: []gps.Coordinates{center, center}}.Validate())
+	assert.Equal(t, ErrNoGeofence, Geofence{Center: &center, Radius: 10, Polygon: []gps.Coordinates{center, center, center}}.Validate())
+}
+
+func TestUserPlaceAt(t *testing.T) {
+	places := []UserPlace{
+		{ID: "garden", Name: "Garden", Area: Geofence{Center: &gps.Coordinates{Lat: 48.2, Long: 16.37}, Radius: 2000}},
+		{ID: "home", Name: "Home", Area: Geofence{Center: &gps.Coordinates{Lat: 48.2, Long: 16.37}, Radius: 100}},
+	}
+	place, found := UserPlaceAt(places, gps.Coordinates{Lat: 48.2, Long: 16.3701})
+	if assert.True(t, found) {
+		assert.Equal(t, UserPlaceID("home"), place.ID, "Smallest place must win")
+	}
+	place, found = UserPlaceAt(places, gps.Coordinates{Lat: 48.21, Long: 16.37})
+	if assert.True(t, found) {
+		assert.Equal(t, UserPlaceID("garden"), place.ID)
+	}
+	_, found = UserPlaceAt(places, gps.Coordinates{Lat: 47, Long: 16.37})
+	assert.False(t, found)
+}
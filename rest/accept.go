@@ -0,0 +1,86 @@
+package rest
+
+import (
+	"strconv"
+	"strings"
+
+	"bitbucket.org/kleinnic74/photos/domain"
+)
+
+// mediaRange is an entry of an Accept header
+type mediaRange struct {
+	mime    string
+	quality float64
+}
+
+func parseAccept(accept string) (ranges []mediaRange) {
+	for _, entry := range strings.Split(accept, ",") {
+		params := strings.Split(entry, ";")
+		r := mediaRange{mime: strings.ToLower(strings.TrimSpace(params[0])), quality: 1}
+		if r.mime == "" {
+			continue
+		}
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					r.quality = q
+				}
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return
+}
+
+// qualityOf returns the quality and the specificity of the most specific media
+// range matching the given MIME type, 0 if none matches
+func qualityOf(ranges []mediaRange, mime string) (float64, int) {
+	mainType := strings.SplitN(mime, "/", 2)[0]
+	quality, specificity := 0., 0
+	for _, r := range ranges {
+		var s int
+		switch r.mime {
+		case mime:
+			s = 3
+		case mainType + "/*":
+			s = 2
+		case "*/*":
+			s = 1
+		default:
+			continue
+		}
+		if s > specificity {
+			quality, specificity = r.quality, s
+		}
+	}
+	return quality, specificity
+}
+
+// negotiateFormat returns the format preferred by the client among the
+// offered ones according to the given Accept header. On equal quality, the
+// format matched by the most specific media range is chosen, as in
+// RFC 7231 section 5.3.2, then the first offered one.
+func negotiateFormat(accept string, offers []domain.Format) (domain.Format, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0], true
+	}
+	ranges := parseAccept(accept)
+	var best domain.Format
+	bestQuality, bestSpecificity := 0., 0
+	for _, f := range offers {
+		q, s := qualityOf(ranges, f.Mime())
+		if q > bestQuality || (q == bestQuality && q > 0 && s > bestSpecificity) {
+			best, bestQuality, bestSpecificity = f, q, s
+		}
+	}
+	return best, best != nil
+}
+
+func mimesOf(formats []domain.Format) string {
+	mimes := make([]string, len(formats))
+	for i, f := range formats {
+		mimes[i] = f.Mime()
+	}
+	return strings.Join(mimes, ", ")
+}
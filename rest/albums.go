@@ -0,0 +1,232 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"bitbucket.org/kleinnic74/photos/rest/cursor"
+	"bitbucket.org/kleinnic74/photos/rest/views"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// AlbumsHandler manages the albums curated by the user
+type AlbumsHandler struct {
+	albums library.Albums
+	lib    library.PhotoLibrary
+}
+
+func NewAlbumsHandler(albums library.Albums, lib library.PhotoLibrary) *AlbumsHandler {
+	return &AlbumsHandler{albums: albums, lib: lib}
+}
+
+func (h *AlbumsHandler) InitRoutes(r *mux.Router) {
+	r.HandleFunc("/albums", h.listAlbums).Methods(http.MethodGet)
+	r.HandleFunc("/albums", h.createAlbum).Methods(http.MethodPost)
+	r.HandleFunc("/albums/{id}", h.getAlbum).Methods(http.MethodGet)
+	r.HandleFunc("/albums/{id}", h.updateAlbum).Methods(http.MethodPut)
+	r.HandleFunc("/albums/{id}", h.deleteAlbum).Methods(http.MethodDelete)
+	r.HandleFunc("/albums/{id}/photos", h.listPhotos).Methods(http.MethodGet)
+	r.HandleFunc("/albums/{id}/photos", h.addPhotos).Methods(http.MethodPost)
+	r.HandleFunc("/albums/{id}/photos/{photo}", h.removePhoto).Methods(http.MethodDelete)
+	r.HandleFunc("/albums/{id}/photos/{photo}/position", h.movePhoto).Methods(http.MethodPut)
+}
+
+type albumPhotos struct {
+	Photos []library.PhotoID `json:"photos"`
+}
+
+// albumPosition gives the photo after which a photo is moved, an empty
+// photo moves it to the start of the album
+type albumPosition struct {
+	After library.PhotoID `json:"after"`
+}
+
+// respondWithAlbumError writes the response for an error of the album store
+func respondWithAlbumError(w http.ResponseWriter, r *http.Request, err error) {
+	responder := Respond(r)
+	switch err.(type) {
+	case library.ErrAlbumNotFound, library.ErrNotFound:
+		responder.WithError(w, http.StatusNotFound, err)
+	case library.ErrNotInAlbum:
+		responder.WithError(w, http.StatusBadRequest, err)
+	default:
+		responder.WithError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// viewOf returns the view of the given album, with its first photo as cover
+// if it has none
+func (h *AlbumsHandler) viewOf(r *http.Request, a *library.Album) views.Album {
+	cover := a.Cover
+	if cover == "" && a.Count > 0 {
+		if photos, _, err := h.albums.PhotosPaged(r.Context(), a.ID, 0, 1); err == nil && len(photos) > 0 {
+			cover = photos[0]
+		}
+	}
+	return views.AlbumFrom(a, cover)
+}
+
+func (h *AlbumsHandler) listAlbums(w http.ResponseWriter, r *http.Request) {
+	page := cursor.DecodeFromRequest(r)
+	responder := Respond(r)
+	albums, hasMore, err := h.albums.FindPaged(r.Context(), page.Start, page.PageSize)
+	if err != nil {
+		responder.WithError(w, http.StatusInternalServerError, err)
+		return
+	}
+	v := make([]views.Album, len(albums))
+	for i := range albums {
+		v[i] = h.viewOf(r, &albums[i])
+	}
+	responder.WithJSON(w, http.StatusOK, cursor.PageFor(v, page, hasMore))
+}
+
+func (h *AlbumsHandler) getAlbum(w http.ResponseWriter, r *http.Request) {
+	if album, found := h.lookup(w, r); found {
+		Respond(r).WithJSON(w, http.StatusOK, h.viewOf(r, album))
+	}
+}
+
+func (h *AlbumsHandler) createAlbum(w http.ResponseWriter, r *http.Request) {
+	var album library.Album
+	if err := json.NewDecoder(r.Body).Decode(&album); err != nil {
+		Respond(r).WithError(w, http.StatusBadRequest, err)
+		return
+	}
+	album.ID = ""
+	if h.store(w, r, &album) {
+		Respond(r).WithJSON(w, http.StatusCreated, h.viewOf(r, &album))
+	}
+}
+
+func (h *AlbumsHandler) updateAlbum(w http.ResponseWriter, r *http.Request) {
+	previous, found := h.lookup(w, r)
+	if !found {
+		return
+	}
+	var album library.Album
+	if err := json.NewDecoder(r.Body).Decode(&album); err != nil {
+		Respond(r).WithError(w, http.StatusBadRequest, err)
+		return
+	}
+	album.ID = previous.ID
+	if h.store(w, r, &album) {
+		Respond(r).WithJSON(w, http.StatusOK, h.viewOf(r, &album))
+	}
+}
+
+func (h *AlbumsHandler) deleteAlbum(w http.ResponseWriter, r *http.Request) {
+	album, found := h.lookup(w, r)
+	if !found {
+		return
+	}
+	if err := h.albums.Delete(r.Context(), album.ID); err != nil {
+		Respond(r).WithError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AlbumsHandler) listPhotos(w http.ResponseWriter, r *http.Request) {
+	log, ctx := logging.SubFrom(r.Context(), "albums")
+	page := cursor.DecodeFromRequest(r)
+	id := library.AlbumID(mux.Vars(r)["id"])
+	photoIDs, hasMore, err := h.albums.PhotosPaged(ctx, id, page.Start, page.PageSize)
+	if err != nil {
+		respondWithAlbumError(w, r, err)
+		return
+	}
+	v := make([]views.Photo, 0, len(photoIDs))
+	for _, p := range photoIDs {
+		if photo, err := h.lib.Get(ctx, p); err == nil {
+			v = append(v, views.PhotoFrom(photo))
+		} else {
+			log.Warn("Unknown photo referenced in album", zap.String("id", string(p)))
+		}
+	}
+	Respond(r).WithJSON(w, http.StatusOK, cursor.PageFor(v, page, hasMore))
+}
+
+// addPhotos appends the photos in the request body to the album
+func (h *AlbumsHandler) addPhotos(w http.ResponseWriter, r *http.Request) {
+	album, found := h.lookup(w, r)
+	if !found {
+		return
+	}
+	var edit albumPhotos
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		Respond(r).WithError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(edit.Photos) == 0 {
+		Respond(r).WithError(w, http.StatusBadRequest, errorNoPhotos)
+		return
+	}
+	for _, p := range edit.Photos {
+		if _, err := h.lib.Get(r.Context(), p); err != nil {
+			respondWithAlbumError(w, r, err)
+			return
+		}
+	}
+	if err := h.albums.AddPhotos(r.Context(), album.ID, edit.Photos); err != nil {
+		respondWithAlbumError(w, r, err)
+		return
+	}
+	h.getAlbum(w, r)
+}
+
+func (h *AlbumsHandler) removePhoto(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, photo := library.AlbumID(vars["id"]), library.PhotoID(vars["photo"])
+	if err := h.albums.RemovePhotos(r.Context(), id, []library.PhotoID{photo}); err != nil {
+		respondWithAlbumError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// movePhoto moves the photo right after the photo given by 'after' in the
+// request body
+func (h *AlbumsHandler) movePhoto(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, photo := library.AlbumID(vars["id"]), library.PhotoID(vars["photo"])
+	var position albumPosition
+	if err := json.NewDecoder(r.Body).Decode(&position); err != nil {
+		Respond(r).WithError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.albums.MovePhoto(r.Context(), id, photo, position.After); err != nil {
+		respondWithAlbumError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookup returns the album with the ID given in the path, an error response
+// is written if there is no such album
+func (h *AlbumsHandler) lookup(w http.ResponseWriter, r *http.Request) (*library.Album, bool) {
+	id := library.AlbumID(mux.Vars(r)["id"])
+	album, found, err := h.albums.Get(r.Context(), id)
+	switch {
+	case err != nil:
+		Respond(r).WithError(w, http.StatusInternalServerError, err)
+	case !found:
+		Respond(r).WithError(w, http.StatusNotFound, library.ErrAlbumNotFound(id))
+	}
+	return album, err == nil && found
+}
+
+func (h *AlbumsHandler) store(w http.ResponseWriter, r *http.Request, album *library.Album) bool {
+	if err := album.Validate(); err != nil {
+		Respond(r).WithError(w, http.StatusBadRequest, err)
+		return false
+	}
+	if err := h.albums.Put(r.Context(), album); err != nil {
+		respondWithAlbumError(w, r, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package rest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+	"bitbucket.org/kleinnic74/photos/rest/cursor"
+	"bitbucket.org/kleinnic74/photos/rest/views"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func newAlbumsRouter(t *testing.T) (*mux.Router, func()) {
+	dir, err := ioutil.TempDir("", "albums")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	albums, err := boltstore.NewAlbumStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib := &testLib{}
+	for _, id := range []library.PhotoID{"1", "2", "3"} {
+		lib.photos = append(lib.photos, &library.Photo{ExtendedPhotoID: library.ExtendedPhotoID{ID: id}})
+	}
+	router := mux.NewRouter()
+	NewAlbumsHandler(albums, lib).InitRoutes(router)
+	return router, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func serve(router *mux.Router, method, url, payload string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, url, strings.NewReader(payload))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func albumPhotoIDs(t *testing.T, router *mux.Router, album views.Album) (ids []library.PhotoID) {
+	rr := serve(router, http.MethodGet, "/albums/"+string(album.ID)+"/photos", "")
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var photos []views.Photo
+	page := cursor.Page{Data: &photos}
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatalf("Bad JSON response: %s", err)
+	}
+	for _, p := range photos {
+		ids = append(ids, library.PhotoID(p.ID))
+	}
+	return
+}
+
+func TestAlbums(t *testing.T) {
+	router, cleanup := newAlbumsRouter(t)
+	defer cleanup()
+
+	rr := serve(router, http.MethodPost, "/albums", `{"title":" "}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = serve(router, http.MethodPost, "/albums", `{"title":"Holidays"}`)
+	assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var album views.Album
+	if err := json.NewDecoder(rr.Body).Decode(&album); err != nil {
+		t.Fatalf("Bad JSON response: %s", err)
+	}
+	assert.Equal(t, "Holidays", album.Title)
+	url := "/albums/" + string(album.ID)
+
+	data := []struct {
+		method  string
+		url     string
+		payload string
+		status  int
+	}{
+		{http.MethodPost, url + "/photos", `{"photos":["1","2","3"]}`, http.StatusOK},
+		{http.MethodPost, url + "/photos", `{"photos":["4"]}`, http.StatusNotFound},
+		{http.MethodPost, url + "/photos", `{"photos":[]}`, http.StatusBadRequest},
+		{http.MethodPost, "/albums/unknown/photos", `{"photos":["1"]}`, http.StatusNotFound},
+		{http.MethodPut, url + "/photos/3/position", `{"after":""}`, http.StatusNoContent},
+		{http.MethodPut, url + "/photos/2/position", `{"after":"3"}`, http.StatusNoContent},
+		{http.MethodPut, url + "/photos/2/position", `{"after":"4"}`, http.StatusBadRequest},
+		{http.MethodPut, url, `{"title":"Summer","cover":"1"}`, http.StatusOK},
+		{http.MethodPut, url, `{"title":"Summer","cover":"4"}`, http.StatusBadRequest},
+	}
+	for _, d := range data {
+		rr := serve(router, d.method, d.url, d.payload)
+		assert.Equal(t, d.status, rr.Code, "%s %s %s: %s", d.method, d.url, d.payload, rr.Body)
+	}
+	assert.Equal(t, []library.PhotoID{"3", "2", "1"}, albumPhotoIDs(t, router, album))
+
+	rr = serve(router, http.MethodGet, url, "")
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	if err := json.NewDecoder(rr.Body).Decode(&album); err != nil {
+		t.Fatalf("Bad JSON response: %s", err)
+	}
+	assert.Equal(t, "Summer", album.Title)
+	assert.Equal(t, 3, album.Count)
+
+	rr = serve(router, http.MethodDelete, url+"/photos/3", "")
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	assert.Equal(t, []library.PhotoID{"2", "1"}, albumPhotoIDs(t, router, album))
+
+	rr = serve(router, http.MethodDelete, url, "")
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	rr = serve(router, http.MethodGet, url, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+}
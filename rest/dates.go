@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"bitbucket.org/kleinnic74/photos/correction"
+	"bitbucket.org/kleinnic74/photos/tasks"
+	"github.com/gorilla/mux"
+)
+
+// DatesHandler provides the endpoint to correct the capture time of photos
+type DatesHandler struct {
+	tasks    *tasks.TaskRepository
+	executor tasks.TaskExecutor
+}
+
+func NewDatesHandler(repo *tasks.TaskRepository, executor tasks.TaskExecutor) *DatesHandler {
+	return &DatesHandler{tasks: repo, executor: executor}
+}
+
+func (h *DatesHandler) InitRoutes(r *mux.Router) {
+	r.HandleFunc("/photos/dates", h.shiftDates).Methods(http.MethodPost).Name("/photos/dates")
+}
+
+func (h *DatesHandler) shiftDates(w http.ResponseWriter, r *http.Request) {
+	responder := Respond(r)
+	t, err := h.tasks.CreateTask(correction.ShiftDatesTaskType)
+	if err != nil {
+		responder.WithError(w, http.StatusInternalServerError, err)
+		return
+	}
+	task := t.(*correction.ShiftDatesTask)
+	if err := json.NewDecoder(r.Body).Decode(task); err != nil {
+		responder.WithError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := task.Validate(); err != nil {
+		responder.WithError(w, http.StatusBadRequest, err)
+		return
+	}
+	execution, err := h.executor.Submit(r.Context(), task)
+	if err != nil {
+		responder.WithError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	responder.WithJSON(w, http.StatusAccepted, execution)
+}
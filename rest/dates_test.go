@@ -0,0 +1,42 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/correction"
+	"bitbucket.org/kleinnic74/photos/tasks"
+	"github.com/gorilla/mux"
+)
+
+func TestShiftDates(t *testing.T) {
+	repo := tasks.NewTaskRepository()
+	correction.RegisterTasks(repo, nil)
+	api := NewDatesHandler(repo, tasks.NewDummyTaskExecutor())
+	router := mux.NewRouter()
+	api.InitRoutes(router)
+
+	data := []struct {
+		payload string
+		status  int
+	}{
+		{`{"photos":["1","2"],"offset":"-1h"}`, http.StatusAccepted},
+		{`{"from":"2019-08-01","to":"2019-08-03","reference":"1","referenceTime":"2019-08-01T10:00:00+02:00"}`, http.StatusAccepted},
+		{`{"event":"e1","offset":"1h30m"}`, http.StatusAccepted},
+		{`{"offset":"1h"}`, http.StatusBadRequest},
+		{`{"photos":["1"]}`, http.StatusBadRequest},
+		{`{"photos":["1"],"offset":"one hour"}`, http.StatusBadRequest},
+		{`{"from":"01.08.2019","offset":"1h"}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+	}
+	for _, d := range data {
+		req, _ := http.NewRequest(http.MethodPost, "/photos/dates", strings.NewReader(d.payload))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != d.status {
+			t.Errorf("%s: expected status %d, got %d: %s", d.payload, d.status, rr.Code, rr.Body)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package rest
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 
+	"bitbucket.org/kleinnic74/photos/classification"
 	"bitbucket.org/kleinnic74/photos/library"
 	"bitbucket.org/kleinnic74/photos/library/boltstore"
 	"bitbucket.org/kleinnic74/photos/rest/cursor"
@@ -18,15 +20,102 @@ var (
 type EventsHandler struct {
 	events *boltstore.EventIndex
 	lib    library.PhotoLibrary
+	editor *classification.EventEditor
 }
 
 func NewEventsHandler(events *boltstore.EventIndex, lib library.PhotoLibrary) *EventsHandler {
-	return &EventsHandler{events, lib}
+	return &EventsHandler{events, lib, classification.NewEventEditor(events, lib)}
 }
 
 func (h *EventsHandler) InitRoutes(r *mux.Router) {
 	r.HandleFunc("/events", h.listEvents).Methods(http.MethodGet)
+	r.HandleFunc("/events", h.createEvent).Methods(http.MethodPost)
+	r.HandleFunc("/events/merge", h.mergeEvents).Methods(http.MethodPost)
 	r.HandleFunc("/events/{id}", h.photosForEvent).Methods(http.MethodGet)
+	r.HandleFunc("/events/{id}", h.renameEvent).Methods(http.MethodPut)
+	r.HandleFunc("/events/{id}/split", h.splitEvent).Methods(http.MethodPost)
+	r.HandleFunc("/events/{id}/photos", h.movePhotos).Methods(http.MethodPut)
+}
+
+type eventEdit struct {
+	Name   string              `json:"name"`
+	Photos []library.PhotoID   `json:"photos"`
+	Events []boltstore.EventID `json:"events"`
+	At     library.PhotoID     `json:"at"`
+}
+
+func decodeEventEdit(w http.ResponseWriter, r *http.Request) (edit eventEdit, ok bool) {
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		Respond(r).WithError(w, http.StatusBadRequest, err)
+		return edit, false
+	}
+	return edit, true
+}
+
+// respondWithEdit responds with the result of an edit of events
+func respondWithEdit(w http.ResponseWriter, r *http.Request, status int, result interface{}, err error) {
+	responder := Respond(r)
+	if err != nil {
+		switch err.(type) {
+		case boltstore.ErrEventNotFound, library.ErrNotFound:
+			responder.WithError(w, http.StatusNotFound, err)
+		case classification.EditError:
+			responder.WithError(w, http.StatusBadRequest, err)
+		default:
+			responder.WithError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	responder.WithJSON(w, status, result)
+}
+
+// createEvent creates an event from the photos in the request body
+func (h *EventsHandler) createEvent(w http.ResponseWriter, r *http.Request) {
+	edit, ok := decodeEventEdit(w, r)
+	if !ok {
+		return
+	}
+	e, err := h.editor.Create(r.Context(), edit.Name, edit.Photos)
+	respondWithEdit(w, r, http.StatusCreated, e, err)
+}
+
+// mergeEvents merges the adjacent events in the request body
+func (h *EventsHandler) mergeEvents(w http.ResponseWriter, r *http.Request) {
+	edit, ok := decodeEventEdit(w, r)
+	if !ok {
+		return
+	}
+	e, err := h.editor.Merge(r.Context(), edit.Events)
+	respondWithEdit(w, r, http.StatusOK, e, err)
+}
+
+func (h *EventsHandler) renameEvent(w http.ResponseWriter, r *http.Request) {
+	edit, ok := decodeEventEdit(w, r)
+	if !ok {
+		return
+	}
+	e, err := h.editor.Rename(r.Context(), boltstore.EventID(mux.Vars(r)["id"]), edit.Name)
+	respondWithEdit(w, r, http.StatusOK, e, err)
+}
+
+// splitEvent splits the event at the photo given by 'at' in the request body
+func (h *EventsHandler) splitEvent(w http.ResponseWriter, r *http.Request) {
+	edit, ok := decodeEventEdit(w, r)
+	if !ok {
+		return
+	}
+	events, err := h.editor.Split(r.Context(), boltstore.EventID(mux.Vars(r)["id"]), edit.At)
+	respondWithEdit(w, r, http.StatusOK, cursor.Unpaged(events), err)
+}
+
+// movePhotos moves the photos in the request body to the event
+func (h *EventsHandler) movePhotos(w http.ResponseWriter, r *http.Request) {
+	edit, ok := decodeEventEdit(w, r)
+	if !ok {
+		return
+	}
+	e, err := h.editor.Move(r.Context(), edit.Photos, boltstore.EventID(mux.Vars(r)["id"]))
+	respondWithEdit(w, r, http.StatusOK, e, err)
 }
 
 func (h *EventsHandler) listEvents(w http.ResponseWriter, r *http.Request) {
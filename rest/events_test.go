@@ -0,0 +1,136 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+	"bitbucket.org/kleinnic74/photos/rest/cursor"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+// newEventsRouter returns a router over the events e1 with photos 1 to 3 and
+// e2 with photos 4 and 5, taken an hour apart
+func newEventsRouter(t *testing.T) (*mux.Router, *boltstore.EventIndex, func()) {
+	dir, err := ioutil.TempDir("", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := boltstore.NewEventIndex(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	lib := &testLib{}
+	var photos []library.ExtendedPhotoID
+	for i, id := range []library.PhotoID{"1", "2", "3", "4", "5"} {
+		p := &library.Photo{
+			ExtendedPhotoID: library.ExtendedPhotoID{ID: id, SortID: library.OrderedID(id)},
+			DateTaken:       start.Add(time.Duration(i) * time.Hour),
+		}
+		lib.photos = append(lib.photos, p)
+		photos = append(photos, p.ExtendedPhotoID)
+	}
+	ctx := context.Background()
+	if err := index.AddPhotosToEvent(ctx, boltstore.Event{ID: "e1"}, photos[:3]); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.AddPhotosToEvent(ctx, boltstore.Event{ID: "e2"}, photos[3:]); err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	NewEventsHandler(index, lib).InitRoutes(router)
+	return router, index, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func decodeEvent(t *testing.T, body []byte) (e boltstore.Event) {
+	if err := json.Unmarshal(body, &e); err != nil {
+		t.Fatalf("Bad JSON response: %s", err)
+	}
+	return
+}
+
+func TestEditEvents(t *testing.T) {
+	router, index, cleanup := newEventsRouter(t)
+	defer cleanup()
+	hour := func(h int) time.Time {
+		return time.Date(2020, 5, 1, 10+h, 0, 0, 0, time.UTC)
+	}
+
+	rr := serve(router, http.MethodPut, "/events/e1", `{"name":"Holidays"}`)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	e := decodeEvent(t, rr.Body.Bytes())
+	assert.Equal(t, "Holidays", e.Name)
+	assert.True(t, e.Manual)
+
+	rr = serve(router, http.MethodPost, "/events/e1/split", `{"at":"3"}`)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var events []boltstore.Event
+	if err := json.Unmarshal(rr.Body.Bytes(), &cursor.Page{Data: &events}); err != nil {
+		t.Fatalf("Bad JSON response: %s", err)
+	}
+	if assert.Len(t, events, 2) {
+		assert.True(t, events[0].To.Equal(hour(1)), "Bad end of first part: %s", events[0].To)
+		assert.True(t, events[1].From.Equal(hour(2)), "Bad start of second part: %s", events[1].From)
+	}
+
+	rr = serve(router, http.MethodPut, "/events/e1/photos", `{"photos":["4"]}`)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	e = decodeEvent(t, rr.Body.Bytes())
+	assert.True(t, e.To.Equal(hour(3)), "Bad end after move: %s", e.To)
+
+	rr = serve(router, http.MethodPost, "/events", `{"name":"Evening","photos":["5"]}`)
+	assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	created := decodeEvent(t, rr.Body.Bytes())
+	assert.Equal(t, "Evening", created.Name)
+	_, found, err := index.Get(context.Background(), "e2")
+	assert.NoError(t, err)
+	assert.False(t, found, "Events left without photos must be deleted")
+
+	rr = serve(router, http.MethodPost, "/events/merge", `{"events":["e1","`+string(events[1].ID)+`"]}`)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	e = decodeEvent(t, rr.Body.Bytes())
+	assert.True(t, e.From.Equal(hour(0)) && e.To.Equal(hour(3)), "Bad span after merge: %s - %s", e.From, e.To)
+}
+
+func TestEditEventsErrors(t *testing.T) {
+	router, _, cleanup := newEventsRouter(t)
+	defer cleanup()
+
+	data := []struct {
+		method, url, payload string
+		status               int
+	}{
+		{http.MethodPut, "/events/e1", `{"name":`, http.StatusBadRequest},
+		{http.MethodPut, "/events/unknown", `{"name":"Nothing"}`, http.StatusNotFound},
+		{http.MethodPost, "/events/e1/split", `{"at":"1"}`, http.StatusBadRequest},
+		{http.MethodPost, "/events/e1/split", `{"at":"4"}`, http.StatusBadRequest},
+		{http.MethodPost, "/events/unknown/split", `{"at":"1"}`, http.StatusNotFound},
+		{http.MethodPost, "/events/merge", `{"events":["e1"]}`, http.StatusBadRequest},
+		{http.MethodPost, "/events/merge", `{"events":["e1","unknown"]}`, http.StatusNotFound},
+		{http.MethodPost, "/events", `{"name":"Nothing"}`, http.StatusBadRequest},
+		{http.MethodPost, "/events", `{"photos":["unknown"]}`, http.StatusNotFound},
+		{http.MethodPut, "/events/e1/photos", `{"photos":["unknown"]}`, http.StatusNotFound},
+		{http.MethodPut, "/events/unknown/photos", `{"photos":["4"]}`, http.StatusNotFound},
+	}
+	for _, d := range data {
+		rr := serve(router, d.method, d.url, d.payload)
+		assert.Equal(t, d.status, rr.Code, "%s %s %s: %s", d.method, d.url, d.payload, rr.Body.String())
+	}
+}
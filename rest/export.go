@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/geoexport"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// ExportHandler exports the locations of photos and the tracks of events
+type ExportHandler struct {
+	exporter *geoexport.Exporter
+}
+
+func NewExportHandler(exporter *geoexport.Exporter) *ExportHandler {
+	return &ExportHandler{exporter: exporter}
+}
+
+func (h *ExportHandler) InitRoutes(r *mux.Router) {
+	r.HandleFunc("/geo/export.geojson", h.export(geoexport.GeoJSONContentType, "geojson", geoexport.WriteGeoJSON)).Methods("GET")
+	r.HandleFunc("/geo/export.kml", h.export(geoexport.KMLContentType, "kml", geoexport.WriteKML)).Methods("GET")
+}
+
+type collectionWriter func(io.Writer, *geoexport.Collection) error
+
+// export returns a handler writing the photos matching the filter given by
+// the 'from', 'to', 'country' and 'event' parameters in the given format
+func (h *ExportHandler) export(contentType, extension string, write collectionWriter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log, ctx := logging.SubFrom(r.Context(), "export")
+		filter := geoexport.Filter{
+			From:    r.FormValue("from"),
+			To:      r.FormValue("to"),
+			Country: gps.CountryIDFromString(r.FormValue("country")),
+			Event:   r.FormValue("event"),
+		}
+		if err := filter.Validate(); err != nil {
+			Respond(r).WithError(w, http.StatusBadRequest, err)
+			return
+		}
+		c, err := h.exporter.Export(ctx, filter)
+		if err != nil {
+			Respond(r).WithError(w, http.StatusInternalServerError, err)
+			return
+		}
+		// Exported files are opened outside of the browser, links must be absolute
+		base := baseURLOf(r)
+		for _, p := range c.Points {
+			for name, link := range p.Links {
+				p.Links[name] = base + link
+			}
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"photos.%s\"", extension))
+		w.WriteHeader(http.StatusOK)
+		if err := write(w, c); err != nil {
+			log.Warn("Failed to write export", zap.String("format", extension), zap.Error(err))
+		}
+	}
+}
+
+// baseURLOf returns the scheme and host under which the client reached this
+// server
+func baseURLOf(r *http.Request) string {
+	scheme := r.Header.Get("X-Forwarded-Proto")
+	if scheme == "" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+	return scheme + "://" + r.Host
+}
@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"fmt"
 	"net/http"
 
 	"bitbucket.org/kleinnic74/photos/domain/gps"
@@ -26,11 +27,14 @@ func NewGeoHandler(index library.GeoIndex, photos library.PhotoLibrary) *GeoHand
 }
 
 func (g *GeoHandler) InitRoutes(r *mux.Router) {
-	r.HandleFunc("/geo/photos/byplace/{placeID}", g.getPhotosByPlace).Methods("GET")
+	// Place IDs contain the IDs of their parent places separated by '/'
+	r.HandleFunc("/geo/photos/byplace/{placeID:.+}", g.getPhotosByPlace).Methods("GET")
 	r.HandleFunc("/geo/photos/bycountry/{countryID}", g.getPhotosByCountry).Methods("GET")
 	r.HandleFunc("/geo/index", g.getGeoIndex).Methods("GET")
 }
 
+// getGeoIndex returns the tree of all places, or the sub-tree of the place
+// given by the 'place' query parameter
 func (g *GeoHandler) getGeoIndex(w http.ResponseWriter, r *http.Request) {
 	responder := Respond(r)
 	locations, err := g.index.Locations(r.Context())
@@ -38,6 +42,15 @@ func (g *GeoHandler) getGeoIndex(w http.ResponseWriter, r *http.Request) {
 		responder.WithError(w, http.StatusInternalServerError, err)
 		return
 	}
+	if placeID := r.FormValue("place"); placeID != "" {
+		place, found := locations.Find(gps.PlaceID(placeID))
+		if !found {
+			responder.WithError(w, http.StatusNotFound, fmt.Errorf("No place with id %s", placeID))
+			return
+		}
+		responder.WithJSON(w, http.StatusOK, place)
+		return
+	}
 	responder.WithJSON(w, http.StatusOK, locations)
 }
 
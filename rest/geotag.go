@@ -0,0 +1,112 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/geocoding"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/rest/cursor"
+	"bitbucket.org/kleinnic74/photos/rest/views"
+	"bitbucket.org/kleinnic74/photos/tasks"
+	"github.com/gorilla/mux"
+)
+
+var errorNoPhotos = errors.New("Missing 'photos'")
+
+// GeotagHandler provides the endpoints to set the location of photos manually
+// or from a GPX track
+type GeotagHandler struct {
+	lib      library.PhotoLibrary
+	geocoder *geocoding.Geocoder
+	executor tasks.TaskExecutor
+}
+
+func NewGeotagHandler(lib library.PhotoLibrary, geocoder *geocoding.Geocoder, executor tasks.TaskExecutor) *GeotagHandler {
+	return &GeotagHandler{
+		lib:      lib,
+		geocoder: geocoder,
+		executor: executor,
+	}
+}
+
+func (h *GeotagHandler) InitRoutes(r *mux.Router) {
+	r.HandleFunc("/photos/location", h.setLocation).Methods(http.MethodPut).Name("/photos/location")
+	r.HandleFunc("/photos/location/gpx", h.matchTrack).Methods(http.MethodPost).Name("/photos/location/gpx")
+}
+
+type locationUpdate struct {
+	Photos   []library.PhotoID `json:"photos"`
+	Location *gps.Coordinates  `json:"location"`
+}
+
+// setLocation sets the location of the given photos, a null location clears it
+func (h *GeotagHandler) setLocation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	responder := Respond(r)
+	var update locationUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		responder.WithError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(update.Photos) == 0 {
+		responder.WithError(w, http.StatusBadRequest, errorNoPhotos)
+		return
+	}
+	if update.Location != nil && !update.Location.IsValid() {
+		responder.WithError(w, http.StatusBadRequest, gps.InvalidGPSCoordinates)
+		return
+	}
+	updated := make([]views.Photo, 0, len(update.Photos))
+	for _, id := range update.Photos {
+		p, err := h.lib.SetLocation(ctx, id, update.Location)
+		if err != nil {
+			switch err.(type) {
+			case library.ErrNotFound:
+				responder.WithError(w, http.StatusNotFound, fmt.Errorf("No photo with id %s", id))
+			default:
+				responder.WithError(w, http.StatusInternalServerError, err)
+			}
+			return
+		}
+		lookup, ok, err := h.geocoder.LookupPhotoOnChange(ctx, p)
+		if err != nil {
+			responder.WithError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if ok {
+			h.executor.Submit(ctx, lookup)
+		}
+		updated = append(updated, views.PhotoFrom(p))
+	}
+	responder.WithJSON(w, http.StatusOK, cursor.Unpaged(updated))
+}
+
+// matchTrack launches the task geotagging the photos taken during the GPX
+// track in the request body
+func (h *GeotagHandler) matchTrack(w http.ResponseWriter, r *http.Request) {
+	responder := Respond(r)
+	track, err := gps.ReadGPX(r.Body)
+	if err != nil {
+		responder.WithError(w, http.StatusBadRequest, err)
+		return
+	}
+	task := geocoding.NewMatchTrackTask(h.geocoder)
+	task.Track = track
+	task.MaxGap = r.FormValue("maxGap")
+	task.Offset = r.FormValue("offset")
+	task.Overwrite = r.FormValue("overwrite") == "true"
+	if err := task.Validate(); err != nil {
+		responder.WithError(w, http.StatusBadRequest, err)
+		return
+	}
+	execution, err := h.executor.Submit(r.Context(), task)
+	if err != nil {
+		responder.WithError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	responder.WithJSON(w, http.StatusAccepted, execution)
+}
@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/tasks"
+	"github.com/gorilla/mux"
+)
+
+const testTrack = `<?xml version="1.0"?>
+<gpx version="1.1" creator="test">
+  <trk><trkseg>
+    <trkpt lat="46.5" lon="6.6"><time>2019-08-01T10:00:00Z</time></trkpt>
+    <trkpt lat="46.6" lon="6.7"><time>2019-08-01T11:00:00Z</time></trkpt>
+  </trkseg></trk>
+</gpx>`
+
+func TestMatchTrack(t *testing.T) {
+	executor := tasks.NewDummyTaskExecutor()
+	api := NewGeotagHandler(nil, nil, executor)
+	router := mux.NewRouter()
+	api.InitRoutes(router)
+
+	data := []struct {
+		query   string
+		payload string
+		status  int
+	}{
+		{"", testTrack, http.StatusAccepted},
+		{"?maxGap=1h&offset=-2h&overwrite=true", testTrack, http.StatusAccepted},
+		{"?maxGap=long", testTrack, http.StatusBadRequest},
+		{"", `<gpx version="1.1"></gpx>`, http.StatusBadRequest},
+		{"", `not a track`, http.StatusBadRequest},
+	}
+	for _, d := range data {
+		req, _ := http.NewRequest(http.MethodPost, "/photos/location/gpx"+d.query, strings.NewReader(d.payload))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != d.status {
+			t.Errorf("%s: expected status %d, got %d: %s", d.query, d.status, rr.Code, rr.Body)
+		}
+	}
+	if executions := executor.ListTasks(context.Background()); len(executions) != 2 {
+		t.Errorf("Expected 2 submitted tasks, got %d", len(executions))
+	}
+}
+
+// stoppedExecutor refuses all tasks
+type stoppedExecutor struct {
+	tasks.TaskExecutor
+}
+
+func (stoppedExecutor) Submit(ctx context.Context, t tasks.Task) (tasks.Execution, error) {
+	return tasks.Execution{}, tasks.ErrExecutorNotRunning
+}
+
+func TestMatchTrackNotSubmitted(t *testing.T) {
+	api := NewGeotagHandler(nil, nil, stoppedExecutor{})
+	router := mux.NewRouter()
+	api.InitRoutes(router)
+
+	req, _ := http.NewRequest(http.MethodPost, "/photos/location/gpx", strings.NewReader(testTrack))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusServiceUnavailable, rr.Code, rr.Body)
+	}
+}
+
+func TestSetLocationInvalid(t *testing.T) {
+	api := NewGeotagHandler(nil, nil, tasks.NewDummyTaskExecutor())
+	router := mux.NewRouter()
+	api.InitRoutes(router)
+
+	for _, payload := range []string{
+		`{"photos":[],"location":null}`,
+		`{"photos":["1"],"location":{"lat":91,"long":0}}`,
+		`not json`,
+	} {
+		req, _ := http.NewRequest(http.MethodPut, "/photos/location", strings.NewReader(payload))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d: %s", payload, http.StatusBadRequest, rr.Code, rr.Body)
+		}
+	}
+}
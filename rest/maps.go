@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"bitbucket.org/kleinnic74/photos/rest/cursor"
+	"bitbucket.org/kleinnic74/photos/rest/views"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const defaultRadius = 1000.
+
+var errorNoArea = errors.New("One of 'bbox' or 'near' is required")
+
+// MapHandler provides the spatial queries needed to show photos on a map
+type MapHandler struct {
+	index  library.SpatialIndex
+	photos library.PhotoLibrary
+}
+
+func NewMapHandler(index library.SpatialIndex, photos library.PhotoLibrary) *MapHandler {
+	return &MapHandler{
+		index:  index,
+		photos: photos,
+	}
+}
+
+func (h *MapHandler) InitRoutes(r *mux.Router) {
+	r.HandleFunc("/geo/photos", h.getPhotos).Methods("GET").Name("/geo/photos")
+	r.HandleFunc("/geo/clusters", h.getClusters).Methods("GET").Name("/geo/clusters")
+}
+
+// getPhotos returns the photos in the bounding box given by 'bbox' or within
+// 'radius' meters of the location given by 'near'
+func (h *MapHandler) getPhotos(w http.ResponseWriter, r *http.Request) {
+	log, ctx := logging.SubFrom(r.Context(), "maps")
+	c := cursor.DecodeFromRequest(r)
+	responder := Respond(r)
+	var photos []library.GeoPhoto
+	var hasMore bool
+	var err error
+	switch {
+	case r.FormValue("bbox") != "":
+		var bbox []gps.Rect
+		if bbox, err = parseBoundingBox(r.FormValue("bbox")); err != nil {
+			responder.WithError(w, http.StatusBadRequest, err)
+			return
+		}
+		photos, hasMore, err = h.findInRects(ctx, bbox, c.Start, c.PageSize)
+	case r.FormValue("near") != "":
+		var near *gps.Coordinates
+		if near, err = parseCoordinates(r.FormValue("near")); err != nil {
+			responder.WithError(w, http.StatusBadRequest, err)
+			return
+		}
+		radius := defaultRadius
+		if value := r.FormValue("radius"); value != "" {
+			if radius, err = strconv.ParseFloat(value, 64); err != nil || radius <= 0 {
+				responder.WithError(w, http.StatusBadRequest, fmt.Errorf("Invalid radius '%s'", value))
+				return
+			}
+		}
+		photos, hasMore, err = h.index.FindNearPaged(ctx, *near, radius, c.Start, c.PageSize)
+	default:
+		responder.WithError(w, http.StatusBadRequest, errorNoArea)
+		return
+	}
+	if err != nil {
+		responder.WithError(w, http.StatusInternalServerError, err)
+		return
+	}
+	v := make([]views.Photo, 0, len(photos))
+	for _, p := range photos {
+		if photo, err := h.photos.Get(ctx, p.ID); err == nil {
+			v = append(v, views.PhotoFrom(photo))
+		} else {
+			log.Warn("Unknown photo referenced in spatial index", zap.String("id", string(p.ID)))
+		}
+	}
+	responder.WithJSON(w, http.StatusOK, cursor.PageFor(v, c, hasMore))
+}
+
+// findInRects returns a page of the photos in the given rectangles, the photos
+// of each rectangle following those of the previous one
+func (h *MapHandler) findInRects(ctx context.Context, rects []gps.Rect, start, maxCount int) ([]library.GeoPhoto, bool, error) {
+	if len(rects) == 1 {
+		return h.index.FindInRectPaged(ctx, rects[0], start, maxCount)
+	}
+	var photos []library.GeoPhoto
+	for _, r := range rects {
+		found, _, err := h.index.FindInRectPaged(ctx, r, 0, start+maxCount+1-len(photos))
+		if err != nil {
+			return nil, false, err
+		}
+		photos = append(photos, found...)
+	}
+	if start >= len(photos) {
+		return nil, false, nil
+	}
+	photos = photos[start:]
+	if len(photos) > maxCount {
+		return photos[:maxCount], true, nil
+	}
+	return photos, false, nil
+}
+
+// getClusters returns the clusters of photos in the bounding box given by
+// 'bbox', or in the whole world, at the zoom level given by 'zoom'
+func (h *MapHandler) getClusters(w http.ResponseWriter, r *http.Request) {
+	responder := Respond(r)
+	bbox := []gps.Rect{gps.WorldBounds}
+	if value := r.FormValue("bbox"); value != "" {
+		var err error
+		if bbox, err = parseBoundingBox(value); err != nil {
+			responder.WithError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	zoom, err := strconv.Atoi(r.FormValue("zoom"))
+	if err != nil || zoom < 0 || zoom > library.MaxZoom {
+		responder.WithError(w, http.StatusBadRequest, fmt.Errorf("Invalid zoom '%s', must be between 0 and %d", r.FormValue("zoom"), library.MaxZoom))
+		return
+	}
+	clusters := []library.Cluster{}
+	for _, rect := range bbox {
+		found, err := h.index.Clusters(r.Context(), rect, zoom)
+		if err != nil {
+			responder.WithError(w, http.StatusInternalServerError, err)
+			return
+		}
+		clusters = append(clusters, found...)
+	}
+	responder.WithJSON(w, http.StatusOK, cursor.Unpaged(clusters))
+}
+
+// parseBoundingBox parses a bounding box given as 'lon0,lat0,lon1,lat1' from
+// its west to its east side. A bounding box whose west side is east of its east
+// side crosses the antimeridian and is split into one rectangle on each side.
+func parseBoundingBox(value string) ([]gps.Rect, error) {
+	values, err := parseFloats(value, 4)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid bounding box '%s', expected 'lon0,lat0,lon1,lat1'", value)
+	}
+	for _, c := range []gps.Coordinates{{Lat: values[1], Long: values[0]}, {Lat: values[3], Long: values[2]}} {
+		if !c.IsValid() {
+			return nil, gps.InvalidGPSCoordinates
+		}
+	}
+	if values[0] > values[2] {
+		return []gps.Rect{
+			gps.RectFrom(values[0], values[1], gps.LonMax, values[3]),
+			gps.RectFrom(gps.LonMin, values[1], values[2], values[3]),
+		}, nil
+	}
+	return []gps.Rect{gps.RectFrom(values[0], values[1], values[2], values[3])}, nil
+}
+
+// parseCoordinates parses coordinates given as 'lat,lon'
+func parseCoordinates(value string) (*gps.Coordinates, error) {
+	values, err := parseFloats(value, 2)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid coordinates '%s', expected 'lat,lon'", value)
+	}
+	return gps.NewCoordinates(values[0], values[1])
+}
+
+func parseFloats(value string, count int) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != count {
+		return nil, fmt.Errorf("expected %d values, got %d", count, len(parts))
+	}
+	values := make([]float64, count)
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
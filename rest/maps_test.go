@@ -0,0 +1,130 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+	"bitbucket.org/kleinnic74/photos/rest/views"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMapQueryValidation(t *testing.T) {
+	api := NewMapHandler(nil, nil)
+	router := mux.NewRouter()
+	api.InitRoutes(router)
+
+	for _, url := range []string{
+		"/geo/photos",
+		"/geo/photos?bbox=16.18,48.11,16.58",
+		"/geo/photos?bbox=16.18,48.11,196.58,48.33",
+		"/geo/photos?near=48.2,north",
+		"/geo/photos?near=48.2,16.37&radius=-1",
+		"/geo/clusters",
+		"/geo/clusters?zoom=23",
+		"/geo/clusters?zoom=4&bbox=a,b,c,d",
+	} {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d: %s", url, http.StatusBadRequest, rr.Code, rr.Body)
+		}
+	}
+}
+
+func TestMapAcrossAntimeridian(t *testing.T) {
+	dir, err := ioutil.TempDir("", "maps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	index, err := boltstore.NewSpatialIndex(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	lib := &testLib{}
+	for _, p := range []struct {
+		id       library.PhotoID
+		lat, lon float64
+	}{
+		{"west", -16.7, 179.95},
+		{"east", -16.7, -179.95},
+		{"vienna", 48.2, 16.37},
+	} {
+		photo := &library.Photo{
+			ExtendedPhotoID: library.ExtendedPhotoID{ID: p.id, SortID: library.OrderedID(p.id)},
+			Location:        gps.MustNewCoordinates(p.lat, p.lon),
+		}
+		lib.photos = append(lib.photos, photo)
+		if err := index.Add(ctx, photo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	api := NewMapHandler(index, lib)
+	router := mux.NewRouter()
+	api.InitRoutes(router)
+
+	rr := serve(router, http.MethodGet, "/geo/photos?bbox=179,-17,-179,-16", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var photos struct {
+		Data []views.Photo `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &photos))
+	var ids []library.PhotoID
+	for _, p := range photos.Data {
+		ids = append(ids, p.ID)
+	}
+	assert.Equal(t, []library.PhotoID{"west", "east"}, ids)
+
+	for _, d := range []struct {
+		start, max int
+		expected   []library.PhotoID
+		hasMore    bool
+	}{
+		{0, 1, []library.PhotoID{"west"}, true},
+		{1, 1, []library.PhotoID{"east"}, false},
+		{0, 5, []library.PhotoID{"west", "east"}, false},
+		{2, 5, nil, false},
+	} {
+		found, hasMore, err := api.findInRects(ctx, []gps.Rect{
+			gps.RectFrom(179, -17, gps.LonMax, -16),
+			gps.RectFrom(gps.LonMin, -17, -179, -16),
+		}, d.start, d.max)
+		assert.NoError(t, err)
+		var ids []library.PhotoID
+		for _, p := range found {
+			ids = append(ids, p.ID)
+		}
+		assert.Equal(t, d.expected, ids, "start %d", d.start)
+		assert.Equal(t, d.hasMore, hasMore, "start %d", d.start)
+	}
+
+	rr = serve(router, http.MethodGet, "/geo/clusters?zoom=10&bbox=179,-17,-179,-16", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var clusters struct {
+		Data []library.Cluster `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &clusters))
+	count := 0
+	for _, c := range clusters.Data {
+		count += c.Count
+	}
+	assert.Equal(t, 2, count)
+}
@@ -2,6 +2,7 @@ package rest
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 
 	"bitbucket.org/kleinnic74/photos/domain"
@@ -17,6 +18,9 @@ var (
 	jpg = domain.MustFormatForExt("jpg")
 )
 
+// thumbCacheControl lets browsers cache thumbs, which never change
+const thumbCacheControl = "private, max-age=31536000, immutable"
+
 // App is the REST API that can be used as an http.HandlerFunc
 type App struct {
 	router *mux.Router
@@ -31,7 +35,7 @@ func NewApp(lib library.PhotoLibrary) (a *App) {
 }
 
 func (a *App) InitRoutes(r *mux.Router) {
-	r.HandleFunc("/photos/{id}/view", a.getPhotoImage).Methods("GET").Name("/photos/{id}/view")
+	r.HandleFunc("/photos/{id}/view", a.getPhotoImage).Methods("GET", "HEAD").Name("/photos/{id}/view")
 	r.HandleFunc("/photos/{id}/thumb", a.getThumb).Methods("GET").Name("/photos/{id}/thumb")
 	r.HandleFunc("/photos/{id}", a.getPhoto).Methods("GET").Name("/photos/{id}")
 	r.HandleFunc("/photos", a.getPhotos).Methods("GET").Name("/photos")
@@ -92,6 +96,10 @@ func (a *App) getPhotoImage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer binary.Close()
+	if content, ok := binary.(io.ReadSeeker); ok {
+		respondWithContent(w, r, photo, content)
+		return
+	}
 	respondWithBinary(w, photo.Format.Mime(), photo.Size, binary)
 }
 
@@ -99,7 +107,22 @@ func (a *App) getThumb(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := library.PhotoID(vars["id"])
 	responder := Respond(r)
-	thumb, format, err := a.lib.OpenThumb(r.Context(), id, domain.Small)
+	size := domain.Small
+	if name := r.URL.Query().Get("size"); name != "" {
+		var ok bool
+		if size, ok = domain.ThumbSizeFromName(name); !ok {
+			responder.WithError(w, http.StatusBadRequest, fmt.Errorf("Unknown thumb size %s", name))
+			return
+		}
+	}
+	// WebP thumbs are smaller than JPEG ones, they are served to the clients
+	// naming WebP explicitly
+	format, acceptable := negotiateFormat(r.Header.Get("Accept"), domain.ThumbFormats())
+	if !acceptable {
+		responder.WithError(w, http.StatusNotAcceptable, fmt.Errorf("Thumbs are only available as %s", mimesOf(domain.ThumbFormats())))
+		return
+	}
+	thumb, err := a.lib.OpenThumb(r.Context(), id, size, format)
 	if err != nil {
 		switch err.(type) {
 		case library.ErrNotFound:
@@ -113,6 +136,8 @@ func (a *App) getThumb(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer thumb.Close()
+	w.Header().Set("Vary", "Accept")
+	w.Header().Set("Cache-Control", thumbCacheControl)
 	respondWithBinary(w, format.Mime(), 0, thumb)
 	return
 }
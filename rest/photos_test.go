@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"io"
@@ -11,8 +12,10 @@ import (
 
 	"bitbucket.org/kleinnic74/photos/consts"
 	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/domain/gps"
 	"bitbucket.org/kleinnic74/photos/library"
 	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
 )
 
 var (
@@ -35,6 +38,111 @@ func TestGetAll(t *testing.T) {
 	checkResponseCode(t, http.StatusOK, response)
 }
 
+func TestGetPhotoImageRange(t *testing.T) {
+	testlib := &testLib{contents: make(map[library.PhotoID][]byte)}
+	testlib.photos = append(testlib.photos, &library.Photo{
+		ExtendedPhotoID: library.ExtendedPhotoID{ID: "1234"},
+		Path:            "2020/01/02/movie.mov",
+		Format:          domain.MustFormatForExt("mov"),
+		Hash:            library.BinaryHash("abcd"),
+	})
+	testlib.contents["1234"] = []byte("0123456789")
+	router := mux.NewRouter()
+	NewApp(testlib).InitRoutes(router)
+
+	data := []struct {
+		headers map[string]string
+		status  int
+		body    string
+	}{
+		{map[string]string{}, http.StatusOK, "0123456789"},
+		{map[string]string{"Range": "bytes=2-5"}, http.StatusPartialContent, "2345"},
+		{map[string]string{"Range": "bytes=2-5", "If-Range": `"other"`}, http.StatusOK, "0123456789"},
+		{map[string]string{"If-None-Match": `"abcd"`}, http.StatusNotModified, ""},
+	}
+	for i, d := range data {
+		req, _ := http.NewRequest(http.MethodGet, "/photos/1234/view", nil)
+		for k, v := range d.headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, d.status, rr.Code, "#%d: bad status", i)
+		assert.Equal(t, d.body, rr.Body.String(), "#%d: bad body", i)
+		assert.Equal(t, `"abcd"`, rr.Header().Get("ETag"), "#%d: bad ETag", i)
+	}
+}
+
+func TestGetThumbSize(t *testing.T) {
+	testlib := &testLib{contents: make(map[library.PhotoID][]byte)}
+	testlib.photos = append(testlib.photos, &library.Photo{
+		ExtendedPhotoID: library.ExtendedPhotoID{ID: "1234"},
+		Format:          domain.MustFormatForExt("jpg"),
+	})
+	router := mux.NewRouter()
+	NewApp(testlib).InitRoutes(router)
+
+	data := []struct {
+		url    string
+		status int
+		body   string
+	}{
+		{"/photos/1234/thumb", http.StatusOK, "S"},
+		{"/photos/1234/thumb?size=M", http.StatusOK, "M"},
+		{"/photos/1234/thumb?size=L", http.StatusOK, "L"},
+		{"/photos/1234/thumb?size=P", http.StatusOK, "P"},
+		{"/photos/1234/thumb?size=XL", http.StatusBadRequest, ""},
+	}
+	for _, d := range data {
+		req, _ := http.NewRequest(http.MethodGet, d.url, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, d.status, rr.Code, "%s: bad status", d.url)
+		if d.status == http.StatusOK {
+			assert.Equal(t, d.body, rr.Body.String(), "%s: bad body", d.url)
+		}
+	}
+}
+
+func TestGetThumbFormat(t *testing.T) {
+	testlib := &testLib{contents: make(map[library.PhotoID][]byte)}
+	testlib.photos = append(testlib.photos, &library.Photo{
+		ExtendedPhotoID: library.ExtendedPhotoID{ID: "1234"},
+		Format:          domain.MustFormatForExt("jpg"),
+	})
+	router := mux.NewRouter()
+	NewApp(testlib).InitRoutes(router)
+
+	data := []struct {
+		accept      string
+		status      int
+		contentType string
+	}{
+		{"", http.StatusOK, "image/jpeg"},
+		{"*/*", http.StatusOK, "image/jpeg"},
+		{"image/webp,image/*;q=0.8", http.StatusOK, "image/webp"},
+		{"image/webp", http.StatusOK, "image/webp"},
+		{"image/*", http.StatusOK, "image/jpeg"},
+		{"image/avif,image/webp,image/apng,image/*,*/*;q=0.8", http.StatusOK, "image/webp"},
+		{"image/avif,image/webp,*/*", http.StatusOK, "image/webp"},
+		{"image/webp;q=0.5,*/*", http.StatusOK, "image/jpeg"},
+		{"image/jpeg;q=0.5, image/webp", http.StatusOK, "image/webp"},
+		{"image/png", http.StatusNotAcceptable, ""},
+	}
+	for _, d := range data {
+		req, _ := http.NewRequest(http.MethodGet, "/photos/1234/thumb", nil)
+		req.Header.Set("Accept", d.accept)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, d.status, rr.Code, "%s: bad status", d.accept)
+		if d.status == http.StatusOK {
+			assert.Equal(t, d.contentType, rr.Header().Get("Content-Type"), "%s: bad format", d.accept)
+			assert.Equal(t, "Accept", rr.Header().Get("Vary"))
+			assert.Contains(t, rr.Header().Get("Cache-Control"), "max-age")
+		}
+	}
+}
+
 func checkResponseCode(t *testing.T, expected int, response *http.Response) {
 	if expected != response.StatusCode {
 		t.Fatalf("Bad response code: expected %d, got %d (%s)", expected, response.StatusCode, response.Status)
@@ -42,7 +150,16 @@ func checkResponseCode(t *testing.T, expected int, response *http.Response) {
 }
 
 type testLib struct {
-	photos []*library.Photo
+	photos   []*library.Photo
+	contents map[library.PhotoID][]byte
+}
+
+type contentReader struct {
+	*bytes.Reader
+}
+
+func (r contentReader) Close() error {
+	return nil
 }
 
 func (lib *testLib) Add(ctx context.Context, p domain.Photo, content io.Reader) error {
@@ -80,14 +197,53 @@ func (lib *testLib) Get(ctx context.Context, id library.PhotoID) (*library.Photo
 	return nil, library.NotFound(id)
 }
 
+func (lib *testLib) ChangeDateTaken(ctx context.Context, id library.PhotoID, taken time.Time) (*library.Photo, error) {
+	p, err := lib.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	p.DateTaken, p.LocalDateTaken = taken.UTC(), taken
+	return p, nil
+}
+
+func (lib *testLib) SetLocation(ctx context.Context, id library.PhotoID, location *gps.Coordinates) (*library.Photo, error) {
+	p, err := lib.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	p.Location = location
+	return p, nil
+}
+
+func (lib *testLib) UpdateTags(ctx context.Context, id library.PhotoID, add, remove []string) (*library.Photo, error) {
+	p, err := lib.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	p.Tags = library.NormalizeTags(append(p.Tags, add...))
+	return p, nil
+}
+
 func (lib *testLib) OpenContent(ctx context.Context, id library.PhotoID) (io.ReadCloser, *library.Photo, error) {
-	return nil, nil, errors.New("Not implemented")
+	p, err := lib.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, found := lib.contents[id]
+	if !found {
+		return nil, nil, errors.New("Not implemented")
+	}
+	return contentReader{bytes.NewReader(content)}, p, nil
 }
 
-func (lib *testLib) OpenThumb(ctx context.Context, id library.PhotoID, size domain.ThumbSize) (io.ReadCloser, domain.Format, error) {
-	return nil, nil, errors.New("Not implemented")
+func (lib *testLib) OpenThumb(ctx context.Context, id library.PhotoID, size domain.ThumbSize, format domain.Format) (io.ReadCloser, error) {
+	if _, err := lib.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	// The content of test thumbs is the name of their size
+	return contentReader{bytes.NewReader([]byte(size.Name))}, nil
 }
 
 func newPhotoLib() library.PhotoLibrary {
-	return &testLib{photos: make([]*library.Photo, 0)}
+	return &testLib{photos: make([]*library.Photo, 0), contents: make(map[library.PhotoID][]byte)}
 }
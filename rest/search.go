@@ -0,0 +1,131 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/rest/cursor"
+	"bitbucket.org/kleinnic74/photos/rest/views"
+	"bitbucket.org/kleinnic74/photos/search"
+	"github.com/gorilla/mux"
+)
+
+// SearchHandler finds photos matching a combination of criteria
+type SearchHandler struct {
+	planner *search.Planner
+	text    library.TextIndex
+	lib     library.PhotoLibrary
+}
+
+func NewSearchHandler(planner *search.Planner, text library.TextIndex, lib library.PhotoLibrary) *SearchHandler {
+	return &SearchHandler{planner: planner, text: text, lib: lib}
+}
+
+func (h *SearchHandler) InitRoutes(r *mux.Router) {
+	r.HandleFunc("/search", h.search).Methods(http.MethodGet)
+}
+
+// indexedParams are the search parameters looked up in the indexes, named
+// like the fields of queries
+var indexedParams = []string{"country", "place", "event", "tag"}
+
+// criteriaFrom returns the query and the filters given by the parameters of
+// the request
+func criteriaFrom(r *http.Request) (library.Query, []search.Filter, error) {
+	params := r.URL.Query()
+	var terms library.And
+	if from, to := params.Get("from"), params.Get("to"); from != "" || to != "" {
+		terms = append(terms, library.Term{Field: "date", Value: from + ".." + to})
+	}
+	for _, field := range indexedParams {
+		for _, v := range params[field] {
+			terms = append(terms, library.Term{Field: field, Value: v})
+		}
+	}
+	var filters []search.Filter
+	if v := params.Get("format"); v != "" {
+		filters = append(filters, search.FormatFilter(v))
+	}
+	switch v := params.Get("type"); strings.ToLower(v) {
+	case "":
+	case "photo", "picture":
+		filters = append(filters, search.MediaTypeFilter(domain.Picture))
+	case "video":
+		filters = append(filters, search.MediaTypeFilter(domain.Video))
+	default:
+		return nil, nil, search.ErrInvalidValue{Field: "type", Value: v}
+	}
+	if v := params.Get("camera"); v != "" {
+		filters = append(filters, search.CameraFilter(v))
+	}
+	if v := params.Get("location"); v != "" {
+		hasLocation, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, nil, search.ErrInvalidValue{Field: "location", Value: v}
+		}
+		// Photos with coordinates are looked up in the spatial index, the
+		// others are only found by checking all photos
+		if hasLocation {
+			terms = append(terms, library.Term{Field: "location", Value: "true"})
+		} else {
+			filters = append(filters, search.HasLocationFilter(false))
+		}
+	}
+	if v := params.Get("name"); v != "" {
+		filters = append(filters, search.NameFilter(v))
+	}
+	switch len(terms) {
+	case 0:
+		return nil, filters, nil
+	case 1:
+		return terms[0], filters, nil
+	}
+	return terms, filters, nil
+}
+
+// search returns the photos matching all criteria given as parameters:
+// from and to for the capture dates, country, place, event and tag looked up
+// in the indexes, and format, type, camera, location and name checked on
+// each photo. With q, the photos are looked up in the full-text index and
+// returned most relevant first.
+func (h *SearchHandler) search(w http.ResponseWriter, r *http.Request) {
+	page := cursor.DecodeFromRequest(r)
+	q, filters, err := criteriaFrom(r)
+	if err != nil {
+		respondWithQueryError(w, r, err)
+		return
+	}
+	if text := r.URL.Query().Get("q"); text != "" {
+		photos, err := h.planner.SearchText(r.Context(), h.lib, h.text, text, q, filters...)
+		if err != nil {
+			respondWithQueryError(w, r, err)
+			return
+		}
+		respondWithPhotoPage(w, r, photos, page)
+		return
+	}
+	photos, hasMore, err := h.planner.SearchPaged(r.Context(), h.lib, q, page.Start, page.PageSize, page.Order, filters...)
+	if err != nil {
+		respondWithQueryError(w, r, err)
+		return
+	}
+	respondWithPhotos(w, r, photos, page, hasMore)
+}
+
+// respondWithPhotoPage responds with the page of the given photos
+func respondWithPhotoPage(w http.ResponseWriter, r *http.Request, photos []*library.Photo, page cursor.Cursor) {
+	photos, hasMore := search.Page(photos, page.Start, page.PageSize)
+	respondWithPhotos(w, r, photos, page, hasMore)
+}
+
+// respondWithPhotos responds with the given page of photos
+func respondWithPhotos(w http.ResponseWriter, r *http.Request, photos []*library.Photo, page cursor.Cursor, hasMore bool) {
+	v := make([]views.Photo, len(photos))
+	for i, p := range photos {
+		v[i] = views.PhotoFrom(p)
+	}
+	Respond(r).WithJSON(w, http.StatusOK, cursor.PageFor(v, page, hasMore))
+}
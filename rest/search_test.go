@@ -0,0 +1,99 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/search"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSearchRouter() *mux.Router {
+	lib := &testLib{}
+	for _, p := range []struct {
+		id     library.PhotoID
+		format string
+		camera string
+	}{
+		{"1", "jpg", "Canon"},
+		{"2", "jpg", "Nikon"},
+		{"3", "mov", ""},
+	} {
+		photo := &library.Photo{
+			ExtendedPhotoID: library.ExtendedPhotoID{ID: p.id, SortID: library.OrderedID(p.id)},
+			Path:            "IMG_" + string(p.id) + "." + p.format,
+			Format:          domain.FormatSpec(p.format),
+		}
+		if p.camera != "" {
+			photo.Exif = &domain.ExifInfo{Make: p.camera}
+		}
+		lib.photos = append(lib.photos, photo)
+	}
+	planner := search.NewPlanner()
+	planner.Register("date", search.SourceFunc(func(ctx context.Context, value string) ([]library.PhotoID, error) {
+		if _, _, ok := search.ParseDateRange(value); !ok {
+			return nil, search.ErrInvalidValue{Field: "date", Value: value}
+		}
+		return []library.PhotoID{"2", "3"}, nil
+	}))
+	planner.Register("country", fixedSource(map[string][]library.PhotoID{"fr": {"1", "2"}}))
+	planner.Register("event", fixedSource(map[string][]library.PhotoID{"e1": {"1", "3"}}))
+	planner.Register("location", fixedSource(map[string][]library.PhotoID{"true": {"2"}}))
+	router := mux.NewRouter()
+	text := fixedTextIndex{
+		"beach":      {{ID: "3", Score: 2}, {ID: "1", Score: 1}},
+		"beach 2019": {{ID: "3", Score: 3}},
+	}
+	NewSearchHandler(planner, text, lib).InitRoutes(router)
+	return router
+}
+
+// fixedTextIndex returns the matches registered for the given terms
+type fixedTextIndex map[string][]library.TextMatch
+
+func (idx fixedTextIndex) Find(ctx context.Context, terms []string) ([]library.TextMatch, error) {
+	return idx[strings.Join(terms, " ")], nil
+}
+
+func TestSearch(t *testing.T) {
+	router := newSearchRouter()
+
+	data := []struct {
+		query    string
+		status   int
+		expected []library.PhotoID
+	}{
+		{"", http.StatusOK, []library.PhotoID{"1", "2", "3"}},
+		{"?from=2019-01-01", http.StatusOK, []library.PhotoID{"2", "3"}},
+		{"?from=2019-01-01&country=fr", http.StatusOK, []library.PhotoID{"2"}},
+		{"?event=e1&o=desc", http.StatusOK, []library.PhotoID{"3", "1"}},
+		{"?event=e1&type=video", http.StatusOK, []library.PhotoID{"3"}},
+		{"?country=fr&camera=canon", http.StatusOK, []library.PhotoID{"1"}},
+		{"?format=jpg&location=false", http.StatusOK, []library.PhotoID{"1", "2"}},
+		{"?location=true", http.StatusOK, []library.PhotoID{"2"}},
+		{"?type=photo&p=1", http.StatusOK, []library.PhotoID{"1"}},
+		{"?name=img_3", http.StatusOK, []library.PhotoID{"3"}},
+		{"?country=fr&p=1", http.StatusOK, []library.PhotoID{"1"}},
+		{"?q=Beach", http.StatusOK, []library.PhotoID{"3", "1"}},
+		{"?q=beach,+2019", http.StatusOK, []library.PhotoID{"3"}},
+		{"?q=beach&country=fr", http.StatusOK, []library.PhotoID{"1"}},
+		{"?q=beach&format=jpg", http.StatusOK, []library.PhotoID{"1"}},
+		{"?q=sea", http.StatusOK, nil},
+		{"?q=...", http.StatusBadRequest, nil},
+		{"?from=yesterday", http.StatusBadRequest, nil},
+		{"?type=audio", http.StatusBadRequest, nil},
+		{"?location=maybe", http.StatusBadRequest, nil},
+		{"?place=fr", http.StatusBadRequest, nil},
+	}
+	for _, d := range data {
+		rr := serve(router, http.MethodGet, "/search"+d.query, "")
+		if assert.Equal(t, d.status, rr.Code, "%s: %s", d.query, rr.Body) && d.status == http.StatusOK {
+			assert.Equal(t, d.expected, photoIDsOf(t, rr.Body.Bytes()), d.query)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/rest/cursor"
+	"bitbucket.org/kleinnic74/photos/rest/views"
+	"bitbucket.org/kleinnic74/photos/search"
+	"github.com/gorilla/mux"
+)
+
+var errorNoQuery = errors.New("Missing 'q'")
+
+// SmartAlbumsHandler manages the albums defined by a query
+type SmartAlbumsHandler struct {
+	albums  library.SmartAlbums
+	planner *search.Planner
+	lib     library.PhotoLibrary
+}
+
+func NewSmartAlbumsHandler(albums library.SmartAlbums, planner *search.Planner, lib library.PhotoLibrary) *SmartAlbumsHandler {
+	return &SmartAlbumsHandler{albums: albums, planner: planner, lib: lib}
+}
+
+func (h *SmartAlbumsHandler) InitRoutes(r *mux.Router) {
+	r.HandleFunc("/smartalbums", h.listAlbums).Methods(http.MethodGet)
+	r.HandleFunc("/smartalbums", h.createAlbum).Methods(http.MethodPost)
+	r.HandleFunc("/smartalbums/preview", h.preview).Methods(http.MethodGet)
+	r.HandleFunc("/smartalbums/{id}", h.getAlbum).Methods(http.MethodGet)
+	r.HandleFunc("/smartalbums/{id}", h.updateAlbum).Methods(http.MethodPut)
+	r.HandleFunc("/smartalbums/{id}", h.deleteAlbum).Methods(http.MethodDelete)
+	r.HandleFunc("/smartalbums/{id}/photos", h.listPhotos).Methods(http.MethodGet)
+}
+
+// respondWithQueryError writes the response for an error of a smart album or
+// of the evaluation of its query
+func respondWithQueryError(w http.ResponseWriter, r *http.Request, err error) {
+	responder := Respond(r)
+	switch err.(type) {
+	case library.ErrSmartAlbumNotFound:
+		responder.WithError(w, http.StatusNotFound, err)
+	case library.QuerySyntaxError, search.ErrUnknownField, search.ErrInvalidValue:
+		responder.WithError(w, http.StatusBadRequest, err)
+	default:
+		switch err {
+		case search.ErrUnbounded, library.ErrNoAlbumTitle:
+			responder.WithError(w, http.StatusBadRequest, err)
+		default:
+			responder.WithError(w, http.StatusInternalServerError, err)
+		}
+	}
+}
+
+func (h *SmartAlbumsHandler) parse(query string) (library.Query, error) {
+	q, err := library.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return q, h.planner.Check(q)
+}
+
+func (h *SmartAlbumsHandler) listAlbums(w http.ResponseWriter, r *http.Request) {
+	albums, err := h.albums.List(r.Context())
+	if err != nil {
+		respondWithQueryError(w, r, err)
+		return
+	}
+	v := make([]views.SmartAlbum, len(albums))
+	for i := range albums {
+		v[i] = views.SmartAlbumFrom(&albums[i])
+	}
+	Respond(r).WithJSON(w, http.StatusOK, cursor.Unpaged(v))
+}
+
+func (h *SmartAlbumsHandler) getAlbum(w http.ResponseWriter, r *http.Request) {
+	if album, found := h.lookup(w, r); found {
+		Respond(r).WithJSON(w, http.StatusOK, views.SmartAlbumFrom(album))
+	}
+}
+
+func (h *SmartAlbumsHandler) createAlbum(w http.ResponseWriter, r *http.Request) {
+	var album library.SmartAlbum
+	if err := json.NewDecoder(r.Body).Decode(&album); err != nil {
+		Respond(r).WithError(w, http.StatusBadRequest, err)
+		return
+	}
+	album.ID = ""
+	if h.store(w, r, &album) {
+		Respond(r).WithJSON(w, http.StatusCreated, views.SmartAlbumFrom(&album))
+	}
+}
+
+func (h *SmartAlbumsHandler) updateAlbum(w http.ResponseWriter, r *http.Request) {
+	previous, found := h.lookup(w, r)
+	if !found {
+		return
+	}
+	var album library.SmartAlbum
+	if err := json.NewDecoder(r.Body).Decode(&album); err != nil {
+		Respond(r).WithError(w, http.StatusBadRequest, err)
+		return
+	}
+	album.ID = previous.ID
+	if h.store(w, r, &album) {
+		Respond(r).WithJSON(w, http.StatusOK, views.SmartAlbumFrom(&album))
+	}
+}
+
+func (h *SmartAlbumsHandler) deleteAlbum(w http.ResponseWriter, r *http.Request) {
+	album, found := h.lookup(w, r)
+	if !found {
+		return
+	}
+	if err := h.albums.Delete(r.Context(), album.ID); err != nil {
+		respondWithQueryError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *SmartAlbumsHandler) listPhotos(w http.ResponseWriter, r *http.Request) {
+	if album, found := h.lookup(w, r); found {
+		h.respondWithPhotos(w, r, album.Query)
+	}
+}
+
+// preview returns the photos matching the query given in 'q' without saving
+// it as smart album
+func (h *SmartAlbumsHandler) preview(w http.ResponseWriter, r *http.Request) {
+	query := r.FormValue("q")
+	if query == "" {
+		Respond(r).WithError(w, http.StatusBadRequest, errorNoQuery)
+		return
+	}
+	h.respondWithPhotos(w, r, query)
+}
+
+func (h *SmartAlbumsHandler) respondWithPhotos(w http.ResponseWriter, r *http.Request, query string) {
+	page := cursor.DecodeFromRequest(r)
+	q, err := h.parse(query)
+	if err != nil {
+		respondWithQueryError(w, r, err)
+		return
+	}
+	photos, err := h.planner.Search(r.Context(), h.lib, q, page.Order)
+	if err != nil {
+		respondWithQueryError(w, r, err)
+		return
+	}
+	respondWithPhotoPage(w, r, photos, page)
+}
+
+// lookup returns the smart album with the ID given in the path, an error
+// response is written if there is no such album
+func (h *SmartAlbumsHandler) lookup(w http.ResponseWriter, r *http.Request) (*library.SmartAlbum, bool) {
+	id := library.SmartAlbumID(mux.Vars(r)["id"])
+	album, found, err := h.albums.Get(r.Context(), id)
+	switch {
+	case err != nil:
+		respondWithQueryError(w, r, err)
+	case !found:
+		respondWithQueryError(w, r, library.ErrSmartAlbumNotFound(id))
+	}
+	return album, err == nil && found
+}
+
+func (h *SmartAlbumsHandler) store(w http.ResponseWriter, r *http.Request, album *library.SmartAlbum) bool {
+	if err := album.Validate(); err != nil {
+		respondWithQueryError(w, r, err)
+		return false
+	}
+	if _, err := h.parse(album.Query); err != nil {
+		respondWithQueryError(w, r, err)
+		return false
+	}
+	if err := h.albums.Put(r.Context(), album); err != nil {
+		respondWithQueryError(w, r, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,130 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+	"bitbucket.org/kleinnic74/photos/rest/cursor"
+	"bitbucket.org/kleinnic74/photos/rest/views"
+	"bitbucket.org/kleinnic74/photos/search"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func fixedSource(photos map[string][]library.PhotoID) search.Source {
+	return search.SourceFunc(func(ctx context.Context, value string) ([]library.PhotoID, error) {
+		return photos[value], nil
+	})
+}
+
+func newSmartAlbumsRouter(t *testing.T) (*mux.Router, func()) {
+	dir, err := ioutil.TempDir("", "smartalbums")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	albums, err := boltstore.NewSmartAlbumStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib := &testLib{}
+	for _, id := range []library.PhotoID{"1", "2", "3"} {
+		lib.photos = append(lib.photos, &library.Photo{ExtendedPhotoID: library.ExtendedPhotoID{ID: id, SortID: library.OrderedID(id)}})
+	}
+	planner := search.NewPlanner()
+	planner.Register("tag", fixedSource(map[string][]library.PhotoID{"beach": {"1", "2", "3"}, "work": {"2"}}))
+	planner.Register("country", fixedSource(map[string][]library.PhotoID{"fr": {"2", "3"}}))
+	router := mux.NewRouter()
+	NewSmartAlbumsHandler(albums, planner, lib).InitRoutes(router)
+	return router, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func photoIDsOf(t *testing.T, body []byte) (ids []library.PhotoID) {
+	var photos []views.Photo
+	page := cursor.Page{Data: &photos}
+	if err := json.Unmarshal(body, &page); err != nil {
+		t.Fatalf("Bad JSON response: %s", err)
+	}
+	for _, p := range photos {
+		ids = append(ids, p.ID)
+	}
+	return
+}
+
+func TestPreviewSmartAlbum(t *testing.T) {
+	router, cleanup := newSmartAlbumsRouter(t)
+	defer cleanup()
+
+	data := []struct {
+		query    string
+		status   int
+		expected []library.PhotoID
+	}{
+		{"?q=tag%3Dbeach", http.StatusOK, []library.PhotoID{"1", "2", "3"}},
+		{"?q=tag%3Dbeach&o=desc", http.StatusOK, []library.PhotoID{"3", "2", "1"}},
+		{"?q=tag%3Dbeach+country%3Dfr+NOT+tag%3Dwork", http.StatusOK, []library.PhotoID{"3"}},
+		{"?q=tag%3Dbeach&p=2", http.StatusOK, []library.PhotoID{"1", "2"}},
+		{"?q=NOT+tag%3Dwork", http.StatusBadRequest, nil},
+		{"?q=camera%3Dnikon", http.StatusBadRequest, nil},
+		{"?q=tag%3D", http.StatusBadRequest, nil},
+		{"", http.StatusBadRequest, nil},
+	}
+	for _, d := range data {
+		rr := serve(router, http.MethodGet, "/smartalbums/preview"+d.query, "")
+		if assert.Equal(t, d.status, rr.Code, "%s: %s", d.query, rr.Body) && d.status == http.StatusOK {
+			assert.Equal(t, d.expected, photoIDsOf(t, rr.Body.Bytes()), d.query)
+		}
+	}
+}
+
+func TestSmartAlbums(t *testing.T) {
+	router, cleanup := newSmartAlbumsRouter(t)
+	defer cleanup()
+
+	rr := serve(router, http.MethodPost, "/smartalbums", `{"title":"Beach","query":"NOT tag=beach"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+
+	rr = serve(router, http.MethodPost, "/smartalbums", `{"title":"Beach","query":"tag=beach"}`)
+	assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var album views.SmartAlbum
+	if err := json.NewDecoder(rr.Body).Decode(&album); err != nil {
+		t.Fatalf("Bad JSON response: %s", err)
+	}
+	url := "/smartalbums/" + string(album.ID)
+
+	rr = serve(router, http.MethodPut, url, `{"title":"Beach in France","query":"tag=beach country=fr"}`)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = serve(router, http.MethodGet, url+"/photos", "")
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, []library.PhotoID{"2", "3"}, photoIDsOf(t, rr.Body.Bytes()))
+
+	rr = serve(router, http.MethodGet, "/smartalbums", "")
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var albums []views.SmartAlbum
+	if err := json.Unmarshal(rr.Body.Bytes(), &cursor.Page{Data: &albums}); err != nil {
+		t.Fatalf("Bad JSON response: %s", err)
+	}
+	if assert.Len(t, albums, 1) {
+		assert.Equal(t, "Beach in France", albums[0].Title)
+	}
+
+	rr = serve(router, http.MethodDelete, url, "")
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+	rr = serve(router, http.MethodGet, url+"/photos", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code, rr.Body.String())
+}
@@ -0,0 +1,123 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"bitbucket.org/kleinnic74/photos/rest/cursor"
+	"bitbucket.org/kleinnic74/photos/rest/views"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// defaultTagSuggestions is the number of tags returned for autocompletion
+// when no limit is given
+const defaultTagSuggestions = 10
+
+var errorNoTagChanges = errors.New("Missing 'add' or 'remove'")
+
+// TagsHandler provides the endpoints to tag photos and to browse photos by tag
+type TagsHandler struct {
+	lib   library.PhotoLibrary
+	index library.TagIndex
+}
+
+func NewTagsHandler(lib library.PhotoLibrary, index library.TagIndex) *TagsHandler {
+	return &TagsHandler{lib: lib, index: index}
+}
+
+func (h *TagsHandler) InitRoutes(r *mux.Router) {
+	r.HandleFunc("/photos/tags", h.updateTags).Methods(http.MethodPut).Name("/photos/tags")
+	r.HandleFunc("/tags", h.getTags).Methods(http.MethodGet)
+	r.HandleFunc("/tags/photos/{tag:.+}", h.getPhotosByTag).Methods(http.MethodGet)
+}
+
+type tagsUpdate struct {
+	Photos []library.PhotoID `json:"photos"`
+	Add    []string          `json:"add"`
+	Remove []string          `json:"remove"`
+}
+
+// updateTags adds and removes tags to and from the given photos
+func (h *TagsHandler) updateTags(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	responder := Respond(r)
+	var update tagsUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		responder.WithError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(update.Photos) == 0 {
+		responder.WithError(w, http.StatusBadRequest, errorNoPhotos)
+		return
+	}
+	if len(library.NormalizeTags(update.Add)) == 0 && len(library.NormalizeTags(update.Remove)) == 0 {
+		responder.WithError(w, http.StatusBadRequest, errorNoTagChanges)
+		return
+	}
+	updated := make([]views.Photo, 0, len(update.Photos))
+	for _, id := range update.Photos {
+		p, err := h.lib.UpdateTags(ctx, id, update.Add, update.Remove)
+		if err != nil {
+			switch err.(type) {
+			case library.ErrNotFound:
+				responder.WithError(w, http.StatusNotFound, fmt.Errorf("No photo with id %s", id))
+			default:
+				responder.WithError(w, http.StatusInternalServerError, err)
+			}
+			return
+		}
+		updated = append(updated, views.PhotoFrom(p))
+	}
+	responder.WithJSON(w, http.StatusOK, cursor.Unpaged(updated))
+}
+
+// getTags returns the tags starting with the 'q' query parameter, most used
+// first, for autocompletion. Without 'q' all tags are returned.
+func (h *TagsHandler) getTags(w http.ResponseWriter, r *http.Request) {
+	responder := Respond(r)
+	prefix := r.FormValue("q")
+	limit := 0
+	if prefix != "" {
+		limit = defaultTagSuggestions
+	}
+	if value := r.FormValue("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			responder.WithError(w, http.StatusBadRequest, fmt.Errorf("Invalid limit %s", value))
+			return
+		}
+	}
+	tags, err := h.index.Tags(r.Context(), prefix, limit)
+	if err != nil {
+		responder.WithError(w, http.StatusInternalServerError, err)
+		return
+	}
+	responder.WithJSON(w, http.StatusOK, cursor.Unpaged(tags))
+}
+
+func (h *TagsHandler) getPhotosByTag(w http.ResponseWriter, r *http.Request) {
+	log, ctx := logging.SubFrom(r.Context(), "tags")
+	c := cursor.DecodeFromRequest(r)
+	tag := mux.Vars(r)["tag"]
+	responder := Respond(r)
+	ids, hasMore, err := h.index.FindByTagPaged(ctx, tag, c.Start, c.PageSize, c.Order)
+	if err != nil {
+		responder.WithError(w, http.StatusInternalServerError, err)
+		return
+	}
+	v := make([]views.Photo, 0, len(ids))
+	for _, id := range ids {
+		if photo, err := h.lib.Get(ctx, id); err == nil {
+			v = append(v, views.PhotoFrom(photo))
+		} else {
+			log.Warn("Unknown photo referenced in tag index", zap.String("id", string(id)))
+		}
+	}
+	responder.WithJSON(w, http.StatusOK, cursor.PageFor(v, c, hasMore))
+}
@@ -0,0 +1,110 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTagIndex struct {
+	tag    string
+	prefix string
+	limit  int
+}
+
+func (idx *testTagIndex) FindByTagPaged(ctx context.Context, tag string, start, maxCount int, order consts.SortOrder) ([]library.PhotoID, bool, error) {
+	idx.tag = tag
+	return []library.PhotoID{"1"}, false, nil
+}
+
+func (idx *testTagIndex) Tags(ctx context.Context, prefix string, maxCount int) ([]library.TagCount, error) {
+	idx.prefix, idx.limit = prefix, maxCount
+	return []library.TagCount{{Name: "beach", Count: 2}}, nil
+}
+
+func newTagsRouter() (*mux.Router, *testLib, *testTagIndex) {
+	lib := &testLib{photos: []*library.Photo{
+		{ExtendedPhotoID: library.ExtendedPhotoID{ID: "1"}, Tags: []string{"beach"}},
+		{ExtendedPhotoID: library.ExtendedPhotoID{ID: "2"}},
+	}}
+	index := &testTagIndex{}
+	router := mux.NewRouter()
+	NewTagsHandler(lib, index).InitRoutes(router)
+	return router, lib, index
+}
+
+func TestUpdateTags(t *testing.T) {
+	router, lib, _ := newTagsRouter()
+
+	data := []struct {
+		payload string
+		status  int
+	}{
+		{`{"photos":["1","2"],"add":["Sunset"]}`, http.StatusOK},
+		{`{"photos":["3"],"add":["sunset"]}`, http.StatusNotFound},
+		{`{"photos":[],"add":["sunset"]}`, http.StatusBadRequest},
+		{`{"photos":["1"],"add":[" "]}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+	}
+	for _, d := range data {
+		req, _ := http.NewRequest(http.MethodPut, "/photos/tags", strings.NewReader(d.payload))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, d.status, rr.Code, "%s: %s", d.payload, rr.Body)
+	}
+	assert.Equal(t, []string{"beach", "sunset"}, lib.photos[0].Tags)
+	assert.Equal(t, []string{"sunset"}, lib.photos[1].Tags)
+}
+
+func TestGetTags(t *testing.T) {
+	router, _, index := newTagsRouter()
+
+	data := []struct {
+		query  string
+		status int
+		prefix string
+		limit  int
+	}{
+		{"", http.StatusOK, "", 0},
+		{"?q=be", http.StatusOK, "be", defaultTagSuggestions},
+		{"?q=be&limit=3", http.StatusOK, "be", 3},
+		{"?limit=-1", http.StatusBadRequest, "", 0},
+	}
+	for _, d := range data {
+		*index = testTagIndex{}
+		req, _ := http.NewRequest(http.MethodGet, "/tags"+d.query, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, d.status, rr.Code, d.query)
+		assert.Equal(t, d.prefix, index.prefix, d.query)
+		assert.Equal(t, d.limit, index.limit, d.query)
+	}
+}
+
+func TestGetPhotosByTag(t *testing.T) {
+	router, _, index := newTagsRouter()
+
+	req, _ := http.NewRequest(http.MethodGet, "/tags/photos/new%20york", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "new york", index.tag)
+	var page struct {
+		Data []struct {
+			ID   library.PhotoID `json:"id"`
+			Tags []string        `json:"tags"`
+		} `json:"data"`
+	}
+	if assert.NoError(t, json.NewDecoder(rr.Body).Decode(&page)) && assert.Len(t, page.Data, 1) {
+		assert.Equal(t, library.PhotoID("1"), page.Data[0].ID)
+		assert.Equal(t, []string{"beach"}, page.Data[0].Tags)
+	}
+}
@@ -0,0 +1,29 @@
+package rest
+
+import (
+	"net/http"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/gorilla/mux"
+)
+
+// ThumbCacheStatser provides the stats of the thumb cache
+type ThumbCacheStatser interface {
+	Stats() library.ThumbCacheStats
+}
+
+type ThumbsHandler struct {
+	cache ThumbCacheStatser
+}
+
+func NewThumbsHandler(cache ThumbCacheStatser) *ThumbsHandler {
+	return &ThumbsHandler{cache: cache}
+}
+
+func (h *ThumbsHandler) InitRoutes(r *mux.Router) {
+	r.HandleFunc("/thumbs/stats", h.getStats).Methods("GET")
+}
+
+func (h *ThumbsHandler) getStats(w http.ResponseWriter, r *http.Request) {
+	Respond(r).WithJSON(w, http.StatusOK, h.cache.Stats())
+}
@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/geocoding"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"bitbucket.org/kleinnic74/photos/rest/cursor"
+	"bitbucket.org/kleinnic74/photos/tasks"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// UserPlacesHandler manages the places defined by the user
+type UserPlacesHandler struct {
+	places   library.UserPlaces
+	geocoder *geocoding.Geocoder
+	executor tasks.TaskExecutor
+}
+
+func NewUserPlacesHandler(places library.UserPlaces, geocoder *geocoding.Geocoder, executor tasks.TaskExecutor) *UserPlacesHandler {
+	return &UserPlacesHandler{
+		places:   places,
+		geocoder: geocoder,
+		executor: executor,
+	}
+}
+
+func (h *UserPlacesHandler) InitRoutes(r *mux.Router) {
+	r.HandleFunc("/userplaces", h.listPlaces).Methods(http.MethodGet)
+	r.HandleFunc("/userplaces", h.createPlace).Methods(http.MethodPost)
+	r.HandleFunc("/userplaces/{id}", h.getPlace).Methods(http.MethodGet)
+	r.HandleFunc("/userplaces/{id}", h.updatePlace).Methods(http.MethodPut)
+	r.HandleFunc("/userplaces/{id}", h.deletePlace).Methods(http.MethodDelete)
+}
+
+func (h *UserPlacesHandler) listPlaces(w http.ResponseWriter, r *http.Request) {
+	responder := Respond(r)
+	places, err := h.places.List(r.Context())
+	if err != nil {
+		responder.WithError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if places == nil {
+		places = []library.UserPlace{}
+	}
+	responder.WithJSON(w, http.StatusOK, cursor.Unpaged(places))
+}
+
+func (h *UserPlacesHandler) getPlace(w http.ResponseWriter, r *http.Request) {
+	place, found := h.lookup(w, r)
+	if found {
+		Respond(r).WithJSON(w, http.StatusOK, place)
+	}
+}
+
+func (h *UserPlacesHandler) createPlace(w http.ResponseWriter, r *http.Request) {
+	responder := Respond(r)
+	var place library.UserPlace
+	if err := json.NewDecoder(r.Body).Decode(&place); err != nil {
+		responder.WithError(w, http.StatusBadRequest, err)
+		return
+	}
+	place.ID = ""
+	if !h.store(w, r, &place) {
+		return
+	}
+	h.resolveArea(r, place.Area.Bounds())
+	responder.WithJSON(w, http.StatusCreated, &place)
+}
+
+func (h *UserPlacesHandler) updatePlace(w http.ResponseWriter, r *http.Request) {
+	responder := Respond(r)
+	previous, found := h.lookup(w, r)
+	if !found {
+		return
+	}
+	var place library.UserPlace
+	if err := json.NewDecoder(r.Body).Decode(&place); err != nil {
+		responder.WithError(w, http.StatusBadRequest, err)
+		return
+	}
+	place.ID = previous.ID
+	if !h.store(w, r, &place) {
+		return
+	}
+	// Changing only the privacy of a place does not change addresses
+	if place.Name != previous.Name || !reflect.DeepEqual(place.Area, previous.Area) {
+		h.resolveArea(r, previous.Area.Bounds())
+		h.resolveArea(r, place.Area.Bounds())
+	}
+	responder.WithJSON(w, http.StatusOK, &place)
+}
+
+func (h *UserPlacesHandler) deletePlace(w http.ResponseWriter, r *http.Request) {
+	place, found := h.lookup(w, r)
+	if !found {
+		return
+	}
+	if err := h.places.Delete(r.Context(), place.ID); err != nil {
+		Respond(r).WithError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.resolveArea(r, place.Area.Bounds())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookup returns the place with the ID given in the path, an error response
+// is written if there is no such place
+func (h *UserPlacesHandler) lookup(w http.ResponseWriter, r *http.Request) (*library.UserPlace, bool) {
+	id := library.UserPlaceID(mux.Vars(r)["id"])
+	place, found, err := h.places.Get(r.Context(), id)
+	switch {
+	case err != nil:
+		Respond(r).WithError(w, http.StatusInternalServerError, err)
+	case !found:
+		Respond(r).WithError(w, http.StatusNotFound, fmt.Errorf("No place with id %s", id))
+	}
+	return place, err == nil && found
+}
+
+func (h *UserPlacesHandler) store(w http.ResponseWriter, r *http.Request, place *library.UserPlace) bool {
+	if err := place.Validate(); err != nil {
+		Respond(r).WithError(w, http.StatusBadRequest, err)
+		return false
+	}
+	if err := h.places.Put(r.Context(), place); err != nil {
+		Respond(r).WithError(w, http.StatusInternalServerError, err)
+		return false
+	}
+	return true
+}
+
+// resolveArea launches the resolution of the photos within the given area,
+// whose address may have changed with the places
+func (h *UserPlacesHandler) resolveArea(r *http.Request, area gps.Rect) {
+	task := geocoding.NewResolveAreaTaskWith(h.geocoder, area)
+	if _, err := h.executor.Submit(r.Context(), task); err != nil {
+		logging.From(r.Context()).Warn("Failed to submit task", zap.String("task", task.Describe()), zap.Error(err))
+	}
+}
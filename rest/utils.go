@@ -6,8 +6,11 @@ import (
 	"image"
 	"io"
 	"net/http"
+	"os"
+	"time"
 
 	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/library"
 )
 
 var ()
@@ -63,6 +66,26 @@ func respondWithBinary(w http.ResponseWriter, mime string, size int64, data io.R
 	io.Copy(w, data)
 }
 
+// respondWithContent serves the binary content of a photo, supporting range
+// requests and conditional requests based on the hash and the modification
+// time of the photo
+func respondWithContent(w http.ResponseWriter, r *http.Request, photo *library.Photo, content io.ReadSeeker) {
+	w.Header().Set("Content-Type", photo.Format.Mime())
+	if photo.HasHash() {
+		w.Header().Set("ETag", fmt.Sprintf("\"%s\"", photo.Hash))
+	}
+	http.ServeContent(w, r, photo.Name(), modTimeOf(content, photo.DateTaken), content)
+}
+
+func modTimeOf(content interface{}, fallback time.Time) time.Time {
+	if f, ok := content.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if info, err := f.Stat(); err == nil {
+			return info.ModTime()
+		}
+	}
+	return fallback
+}
+
 func respondWithImage(w http.ResponseWriter, format domain.Format, image image.Image) {
 	w.Header().Set("Content-Type", format.Mime())
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,62 @@
+package views
+
+import (
+	"fmt"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/library"
+)
+
+type Album struct {
+	ID          library.AlbumID `json:"id"`
+	Links       Links           `json:"links"`
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	Cover       library.PhotoID `json:"cover,omitempty"`
+	Created     time.Time       `json:"created"`
+	Count       int             `json:"count"`
+}
+
+// AlbumFrom returns the view of the given album, cover is the photo
+// representing the album
+func AlbumFrom(a *library.Album, cover library.PhotoID) Album {
+	links := Links{
+		"self":   fmt.Sprintf("/albums/%s", a.ID),
+		"photos": fmt.Sprintf("/albums/%s/photos", a.ID),
+	}
+	if cover != "" {
+		links.Add("cover", fmt.Sprintf("/photos/%s/thumb", cover))
+	}
+	return Album{
+		ID:          a.ID,
+		Links:       links,
+		Title:       a.Title,
+		Description: a.Description,
+		Cover:       cover,
+		Created:     a.Created,
+		Count:       a.Count,
+	}
+}
+
+type SmartAlbum struct {
+	ID          library.SmartAlbumID `json:"id"`
+	Links       Links                `json:"links"`
+	Title       string               `json:"title"`
+	Description string               `json:"description,omitempty"`
+	Query       string               `json:"query"`
+	Created     time.Time            `json:"created"`
+}
+
+func SmartAlbumFrom(a *library.SmartAlbum) SmartAlbum {
+	return SmartAlbum{
+		ID: a.ID,
+		Links: Links{
+			"self":   fmt.Sprintf("/smartalbums/%s", a.ID),
+			"photos": fmt.Sprintf("/smartalbums/%s/photos", a.ID),
+		},
+		Title:       a.Title,
+		Description: a.Description,
+		Query:       a.Query,
+		Created:     a.Created,
+	}
+}
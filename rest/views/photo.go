@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"bitbucket.org/kleinnic74/photos/domain"
 	"bitbucket.org/kleinnic74/photos/domain/gps"
 	"bitbucket.org/kleinnic74/photos/library"
 )
@@ -23,11 +24,14 @@ func (l Links) AddAll(links Links) Links {
 }
 
 type Photo struct {
-	ID        library.PhotoID  `json:"id"`
-	Links     Links            `json:"links"`
-	Name      string           `json:"name"`
-	DateTaken time.Time        `json:"dateTaken,omitempty"`
-	Location  *gps.Coordinates `json:"location,omitempty"`
+	ID        library.PhotoID   `json:"id"`
+	Links     Links             `json:"links"`
+	Name      string            `json:"name"`
+	DateTaken time.Time         `json:"dateTaken,omitempty"`
+	Location  *gps.Coordinates  `json:"location,omitempty"`
+	Video     *domain.VideoInfo `json:"video,omitempty"`
+	Exif      *domain.ExifInfo  `json:"exif,omitempty"`
+	Tags      []string          `json:"tags,omitempty"`
 }
 
 type LinkProvider struct {
@@ -47,8 +51,11 @@ func PhotoFrom(p *library.Photo) Photo {
 		ID:        p.ID,
 		Links:     PhotoLinksFor(p.ID),
 		Name:      p.Name(),
-		DateTaken: p.DateTaken,
+		DateTaken: p.LocalTime(),
 		Location:  p.Location,
+		Video:     p.Video,
+		Exif:      p.Exif,
+		Tags:      p.Tags,
 	}
 }
 
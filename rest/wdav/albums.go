@@ -0,0 +1,311 @@
+package wdav
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"golang.org/x/net/webdav"
+)
+
+// albumsDir is the directory of the WebDAV tree containing one read-only
+// directory per album
+const albumsDir = "albums"
+
+const albumPageSize = 100
+
+// listingTTL is how long the listing of an album is reused: clients list a
+// directory before they stat and open its files one by one
+const listingTTL = 30 * time.Second
+
+// albumsFolder exposes the albums as directories containing their photos,
+// named after their position in the album
+type albumsFolder struct {
+	albums library.Albums
+	lib    library.PhotoLibrary
+
+	mutex    sync.Mutex
+	listings map[library.AlbumID]*albumListing
+	now      func() time.Time
+}
+
+// albumListing holds the photos of an album directory
+type albumListing struct {
+	names  []string
+	photos []*library.Photo
+	// count is the number of photos of the album when it was listed, adding
+	// or removing photos makes the listing stale
+	count  int
+	loaded time.Time
+}
+
+// ExposeAlbums adds the albums directory to the WebDAV tree
+func (dav *WebDavAdapter) ExposeAlbums(albums library.Albums, lib library.PhotoLibrary) {
+	dav.albums = &albumsFolder{
+		albums:   albums,
+		lib:      lib,
+		listings: make(map[library.AlbumID]*albumListing),
+		now:      time.Now,
+	}
+	dav.root.Add(NewDirNode(albumsDir, dav.root))
+}
+
+// inAlbums returns the path within the albums directory if the given path is
+// part of it
+func (dav *WebDavAdapter) inAlbums(parts []string) ([]string, bool) {
+	if dav.albums == nil || len(parts) == 0 || parts[0] != albumsDir {
+		return nil, false
+	}
+	return parts[1:], true
+}
+
+func (f *albumsFolder) open(ctx context.Context, path []string) (webdav.File, error) {
+	switch len(path) {
+	case 0:
+		return f.openRoot(ctx)
+	case 1:
+		album, found, err := f.albumNamed(ctx, path[0])
+		if err != nil || !found {
+			return nil, notExist(err)
+		}
+		return f.openAlbum(ctx, album)
+	case 2:
+		album, found, err := f.albumNamed(ctx, path[0])
+		if err != nil || !found {
+			return nil, notExist(err)
+		}
+		return f.openPhoto(ctx, album, path[1])
+	}
+	return nil, os.ErrNotExist
+}
+
+func notExist(err error) error {
+	if err != nil {
+		return err
+	}
+	return os.ErrNotExist
+}
+
+func (f *albumsFolder) allAlbums(ctx context.Context) ([]library.Album, error) {
+	var albums []library.Album
+	for start, hasMore := 0, true; hasMore; start += albumPageSize {
+		var page []library.Album
+		var err error
+		if page, hasMore, err = f.albums.FindPaged(ctx, start, albumPageSize); err != nil {
+			return nil, err
+		}
+		albums = append(albums, page...)
+		hasMore = hasMore && len(page) > 0
+	}
+	return albums, nil
+}
+
+// albumNames returns the directory names of the given albums: their titles,
+// made unique by appending a counter
+func albumNames(albums []library.Album) []string {
+	names := make([]string, len(albums))
+	used := make(map[string]bool)
+	for i, a := range albums {
+		base := strings.Replace(strings.TrimSpace(a.Title), string(pathSeperator), "_", -1)
+		name := base
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s (%d)", base, n)
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+func (f *albumsFolder) albumNamed(ctx context.Context, name string) (library.Album, bool, error) {
+	albums, err := f.allAlbums(ctx)
+	if err != nil {
+		return library.Album{}, false, err
+	}
+	for i, n := range albumNames(albums) {
+		if n == name {
+			return albums[i], true, nil
+		}
+	}
+	return library.Album{}, false, nil
+}
+
+func (f *albumsFolder) openRoot(ctx context.Context) (webdav.File, error) {
+	albums, err := f.allAlbums(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dir := &virtualDir{info: fileInfo{name: albumsDir, dir: true, modTime: time.Now()}}
+	for i, name := range albumNames(albums) {
+		dir.entries = append(dir.entries, fileInfo{name: name, dir: true, modTime: albums[i].Created})
+	}
+	return dir, nil
+}
+
+// photosOf returns the photos of the given album with their file names. The
+// listing is reused for a while, so that opening the photos of an album one
+// by one does not load all of them every time.
+func (f *albumsFolder) photosOf(ctx context.Context, album library.Album) ([]string, []*library.Photo, error) {
+	now := f.now()
+	f.mutex.Lock()
+	listing, found := f.listings[album.ID]
+	f.mutex.Unlock()
+	if found && listing.count == album.Count && now.Sub(listing.loaded) < listingTTL {
+		return listing.names, listing.photos, nil
+	}
+
+	var ids []library.PhotoID
+	for start, hasMore := 0, true; hasMore; start += albumPageSize {
+		var page []library.PhotoID
+		var err error
+		if page, hasMore, err = f.albums.PhotosPaged(ctx, album.ID, start, albumPageSize); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, page...)
+		hasMore = hasMore && len(page) > 0
+	}
+	var photos []*library.Photo
+	var missing []library.PhotoID
+	for _, id := range ids {
+		photo, err := f.lib.Get(ctx, id)
+		if _, notFound := err.(library.ErrNotFound); notFound {
+			missing = append(missing, id)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		photos = append(photos, photo)
+	}
+	// Photos deleted from the library are removed from the album
+	if len(missing) > 0 {
+		if err := f.albums.RemovePhotos(ctx, album.ID, missing); err != nil {
+			return nil, nil, err
+		}
+	}
+	width := len(fmt.Sprint(len(photos)))
+	names := make([]string, len(photos))
+	for i, photo := range photos {
+		// Prefixing the position keeps the order of the album in file browsers
+		names[i] = fmt.Sprintf("%0*d %s", width, i+1, photo.Name())
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for id, l := range f.listings {
+		if now.Sub(l.loaded) >= listingTTL {
+			delete(f.listings, id)
+		}
+	}
+	f.listings[album.ID] = &albumListing{names: names, photos: photos, count: album.Count - len(missing), loaded: now}
+	return names, photos, nil
+}
+
+func (f *albumsFolder) openAlbum(ctx context.Context, album library.Album) (webdav.File, error) {
+	names, photos, err := f.photosOf(ctx, album)
+	if err != nil {
+		return nil, err
+	}
+	dir := &virtualDir{info: fileInfo{name: albumNames([]library.Album{album})[0], dir: true, modTime: album.Created}}
+	for i, name := range names {
+		dir.entries = append(dir.entries, fileInfo{name: name, size: photos[i].Size, modTime: photos[i].DateTaken})
+	}
+	return dir, nil
+}
+
+func (f *albumsFolder) openPhoto(ctx context.Context, album library.Album, name string) (webdav.File, error) {
+	names, photos, err := f.photosOf(ctx, album)
+	if err != nil {
+		return nil, err
+	}
+	for i, n := range names {
+		if n != name {
+			continue
+		}
+		content, photo, err := f.lib.OpenContent(ctx, photos[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		seeker, ok := content.(io.ReadSeeker)
+		if !ok {
+			content.Close()
+			return nil, fmt.Errorf("Content of photo %s cannot be seeked", photo.ID)
+		}
+		return &photoFile{
+			info:    fileInfo{name: name, size: photo.Size, modTime: photo.DateTaken},
+			content: seeker,
+			closer:  content,
+		}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// fileInfo describes the entries of the albums directory
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) ModTime() time.Time { return i.modTime }
+func (i fileInfo) IsDir() bool        { return i.dir }
+func (i fileInfo) Sys() interface{}   { return nil }
+
+func (i fileInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+
+// virtualDir is a read-only directory of the albums directory
+type virtualDir struct {
+	info    fileInfo
+	entries []os.FileInfo
+}
+
+func (d *virtualDir) Close() error                       { return nil }
+func (d *virtualDir) Read(p []byte) (int, error)         { return 0, os.ErrInvalid }
+func (d *virtualDir) Write(p []byte) (int, error)        { return 0, os.ErrPermission }
+func (d *virtualDir) Seek(int64, int) (int64, error)     { return 0, os.ErrInvalid }
+func (d *virtualDir) Readdir(int) ([]os.FileInfo, error) { return d.entries, nil }
+func (d *virtualDir) Stat() (os.FileInfo, error)         { return d.info, nil }
+
+// photoFile is the read-only content of a photo within an album
+type photoFile struct {
+	info    fileInfo
+	content io.ReadSeeker
+	closer  io.Closer
+}
+
+func (f *photoFile) Close() error {
+	return f.closer.Close()
+}
+
+func (f *photoFile) Read(p []byte) (int, error) {
+	return f.content.Read(p)
+}
+
+func (f *photoFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+func (f *photoFile) Seek(offset int64, whence int) (int64, error) {
+	return f.content.Seek(offset, whence)
+}
+
+func (f *photoFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (f *photoFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
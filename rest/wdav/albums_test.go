@@ -0,0 +1,154 @@
+package wdav
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+type contentReader struct {
+	*bytes.Reader
+}
+
+func (contentReader) Close() error {
+	return nil
+}
+
+// albumLib serves photos whose content is their path
+type albumLib struct {
+	library.PhotoLibrary
+	gets    int
+	deleted map[library.PhotoID]bool
+}
+
+func photoOf(id library.PhotoID) *library.Photo {
+	path := string(id) + ".jpg"
+	return &library.Photo{ExtendedPhotoID: library.ExtendedPhotoID{ID: id}, Path: path, Size: int64(len(path))}
+}
+
+func (lib *albumLib) Get(ctx context.Context, id library.PhotoID) (*library.Photo, error) {
+	lib.gets++
+	if lib.deleted[id] {
+		return nil, library.ErrNotFound(id)
+	}
+	return photoOf(id), nil
+}
+
+func (lib *albumLib) OpenContent(ctx context.Context, id library.PhotoID) (io.ReadCloser, *library.Photo, error) {
+	p := photoOf(id)
+	return contentReader{bytes.NewReader([]byte(p.Path))}, p, nil
+}
+
+func names(entries []os.FileInfo) (names []string) {
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return
+}
+
+func TestAlbumsFolder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wdav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	albums, err := boltstore.NewAlbumStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, title := range []string{"Holidays", "Holidays", "A/B"} {
+		album := library.Album{Title: title}
+		assert.NoError(t, albums.Put(ctx, &album))
+		assert.NoError(t, albums.AddPhotos(ctx, album.ID, []library.PhotoID{"b", "a"}))
+	}
+
+	dav, err := NewWebDavAdapter(filepath.Join(dir, "tmp"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib := &albumLib{deleted: make(map[library.PhotoID]bool)}
+	dav.ExposeAlbums(albums, lib)
+
+	root, err := dav.OpenFile(ctx, "/", os.O_RDONLY, 0)
+	if assert.NoError(t, err) {
+		entries, _ := root.Readdir(0)
+		assert.Equal(t, []string{albumsDir}, names(entries))
+	}
+	folder, err := dav.OpenFile(ctx, "/albums", os.O_RDONLY, 0)
+	if assert.NoError(t, err) {
+		entries, _ := folder.Readdir(0)
+		assert.ElementsMatch(t, []string{"Holidays", "Holidays (2)", "A_B"}, names(entries))
+	}
+	album, err := dav.OpenFile(ctx, "/albums/A_B", os.O_RDONLY, 0)
+	if assert.NoError(t, err) {
+		entries, _ := album.Readdir(0)
+		assert.Equal(t, []string{"1 b.jpg", "2 a.jpg"}, names(entries))
+	}
+
+	info, err := dav.Stat(ctx, "/albums/A_B/2 a.jpg")
+	if assert.NoError(t, err) {
+		assert.False(t, info.IsDir())
+		assert.Equal(t, int64(5), info.Size())
+	}
+	photo, err := dav.OpenFile(ctx, "/albums/A_B/2 a.jpg", os.O_RDONLY, 0)
+	if assert.NoError(t, err) {
+		content, err := ioutil.ReadAll(photo)
+		assert.NoError(t, err)
+		assert.Equal(t, "a.jpg", string(content))
+		photo.Close()
+	}
+
+	assert.Equal(t, 2, lib.gets, "The listing of the album must be reused")
+
+	// Deleted photos are removed from the album, adding photos is seen at once
+	lib.deleted["b"] = true
+	var id library.AlbumID
+	all, _, _ := albums.FindPaged(ctx, 0, 10)
+	for _, a := range all {
+		if a.Title == "A/B" {
+			id = a.ID
+		}
+	}
+	assert.NoError(t, albums.AddPhotos(ctx, id, []library.PhotoID{"c"}))
+	album, err = dav.OpenFile(ctx, "/albums/A_B", os.O_RDONLY, 0)
+	if assert.NoError(t, err) {
+		entries, _ := album.Readdir(0)
+		assert.Equal(t, []string{"1 a.jpg", "2 c.jpg"}, names(entries))
+	}
+	stored, _, err := albums.Get(ctx, id)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, stored.Count)
+	}
+
+	// Listings expire
+	gets := lib.gets
+	_, err = dav.Stat(ctx, "/albums/A_B/2 c.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, gets, lib.gets)
+	dav.albums.now = func() time.Time { return time.Now().Add(listingTTL) }
+	_, err = dav.Stat(ctx, "/albums/A_B/2 c.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, gets+2, lib.gets)
+
+	_, err = dav.Stat(ctx, "/albums/Unknown")
+	assert.True(t, os.IsNotExist(err))
+	_, err = dav.OpenFile(ctx, "/albums/A_B/new.jpg", os.O_CREATE|os.O_WRONLY, 0644)
+	assert.Equal(t, os.ErrPermission, err)
+	assert.Equal(t, os.ErrPermission, dav.Mkdir(ctx, "/albums/New", 0755))
+}
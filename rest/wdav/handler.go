@@ -3,14 +3,20 @@ package wdav
 import (
 	"net/http"
 
+	"bitbucket.org/kleinnic74/photos/library"
 	"golang.org/x/net/webdav"
 )
 
-func NewWebDavHandler(tmpdir string, callback UploadedFunc) (http.Handler, error) {
+// NewWebDavHandler returns the handler of the WebDAV tree, uploaded files are
+// passed to callback. The given albums are exposed unless nil.
+func NewWebDavHandler(tmpdir string, callback UploadedFunc, albums library.Albums, lib library.PhotoLibrary) (http.Handler, error) {
 	wdav, err := NewWebDavAdapter(tmpdir, callback)
 	if err != nil {
 		return nil, err
 	}
+	if albums != nil {
+		wdav.ExposeAlbums(albums, lib)
+	}
 	return &webdav.Handler{
 		Prefix:     "/dav/",
 		LockSystem: webdav.NewMemLS(),
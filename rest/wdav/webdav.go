@@ -18,6 +18,7 @@ type WebDavAdapter struct {
 	root           *fsNode
 	tmpDir         string
 	uploadCallback UploadedFunc
+	albums         *albumsFolder
 }
 
 func NewWebDavAdapter(tmpdir string, callback UploadedFunc) (*WebDavAdapter, error) {
@@ -40,6 +41,9 @@ func (dav *WebDavAdapter) Mkdir(ctx context.Context, name string, perm os.FileMo
 	// Always succeed
 	parts := splitPath(name)
 	logging.From(ctx).Info("Mkdir", zap.String("name", name), zap.Strings("parts", parts))
+	if _, inAlbums := dav.inAlbums(parts); inAlbums {
+		return os.ErrPermission
+	}
 	p := dav.root
 	for _, part := range parts {
 		if child, exists := p.Child(part); exists {
@@ -55,6 +59,12 @@ func (dav *WebDavAdapter) Mkdir(ctx context.Context, name string, perm os.FileMo
 }
 
 func (dav *WebDavAdapter) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if path, inAlbums := dav.inAlbums(splitPath(name)); inAlbums {
+		if flag&(os.O_CREATE|os.O_WRONLY|os.O_RDWR) != 0 {
+			return nil, os.ErrPermission
+		}
+		return dav.albums.open(ctx, path)
+	}
 	if (flag & os.O_CREATE) != 0 {
 		path := splitPath(name)
 		dir, filename := splitDirFromName(path)
@@ -96,6 +106,14 @@ func (dav *WebDavAdapter) Rename(ctx context.Context, oldName, newName string) e
 func (dav *WebDavAdapter) Stat(ctx context.Context, name string) (os.FileInfo, error) {
 	parts := splitPath(name)
 	logging.From(ctx).Info("Stat", zap.String("name", name), zap.Strings("path", parts))
+	if path, inAlbums := dav.inAlbums(parts); inAlbums {
+		f, err := dav.albums.open(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return f.Stat()
+	}
 	p := dav.root
 	for _, name := range parts {
 		child, exists := p.Child(name)
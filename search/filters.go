@@ -0,0 +1,136 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/library"
+)
+
+// scanPageSize is the number of photos read at once when filtering all the
+// photos of the library
+const scanPageSize = 500
+
+// Filter is a condition on the properties of a photo which are not indexed
+type Filter func(*library.Photo) bool
+
+func matchesAll(p *library.Photo, filters []Filter) bool {
+	for _, f := range filters {
+		if !f(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatFilter keeps the photos of the format with the given ID, e.g. jpg
+func FormatFilter(format string) Filter {
+	format = strings.ToLower(format)
+	return func(p *library.Photo) bool {
+		return p.Format.ID() == format
+	}
+}
+
+// MediaTypeFilter keeps the pictures or the videos
+func MediaTypeFilter(t domain.MediaType) Filter {
+	return func(p *library.Photo) bool {
+		return p.Format.Type() == t
+	}
+}
+
+// CameraFilter keeps the photos taken with a camera whose make or model
+// contains the given text
+func CameraFilter(camera string) Filter {
+	camera = strings.ToLower(camera)
+	return func(p *library.Photo) bool {
+		if p.Exif == nil {
+			return false
+		}
+		return strings.Contains(strings.ToLower(p.Exif.Make+" "+p.Exif.Model), camera)
+	}
+}
+
+// HasLocationFilter keeps the photos with or without coordinates
+func HasLocationFilter(hasLocation bool) Filter {
+	return func(p *library.Photo) bool {
+		return (p.Location != nil) == hasLocation
+	}
+}
+
+// NameFilter keeps the photos whose file name contains the given text, or
+// matches it if it is a pattern with '*' or '?'
+func NameFilter(name string) Filter {
+	name = strings.ToLower(name)
+	if !strings.ContainsAny(name, "*?") {
+		return func(p *library.Photo) bool {
+			return strings.Contains(strings.ToLower(p.Name()), name)
+		}
+	}
+	return func(p *library.Photo) bool {
+		matches, _ := filepath.Match(name, strings.ToLower(p.Name()))
+		return matches
+	}
+}
+
+// Search returns the photos matching the given query and filters in the
+// given order. Without query, all photos of the library are filtered.
+func (p *Planner) Search(ctx context.Context, lib library.PhotoLibrary, q library.Query, order consts.SortOrder, filters ...Filter) ([]*library.Photo, error) {
+	if q == nil {
+		all, err := lib.FindAll(ctx, order)
+		if err != nil {
+			return nil, err
+		}
+		found := make([]*library.Photo, 0, len(all))
+		for _, photo := range all {
+			if matchesAll(photo, filters) {
+				found = append(found, photo)
+			}
+		}
+		return found, nil
+	}
+	ids, err := p.Find(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return Sorted(ctx, lib, ids, order, filters...)
+}
+
+// SearchPaged returns the page starting at start of the photos matching the
+// given query and filters in the given order, and whether there are more.
+// Without query, the photos of the library are filtered in order until the
+// page is full.
+func (p *Planner) SearchPaged(ctx context.Context, lib library.PhotoLibrary, q library.Query, start, maxCount int, order consts.SortOrder, filters ...Filter) ([]*library.Photo, bool, error) {
+	if q != nil {
+		photos, err := p.Search(ctx, lib, q, order, filters...)
+		if err != nil {
+			return nil, false, err
+		}
+		page, hasMore := Page(photos, start, maxCount)
+		return page, hasMore, nil
+	}
+	found := make([]*library.Photo, 0, maxCount)
+	matched := 0
+	for offset, hasMore := 0, true; hasMore; offset += scanPageSize {
+		var photos []*library.Photo
+		var err error
+		if photos, hasMore, err = lib.FindAllPaged(ctx, offset, scanPageSize, order); err != nil {
+			return nil, false, err
+		}
+		for _, photo := range photos {
+			if !matchesAll(photo, filters) {
+				continue
+			}
+			if matched++; matched <= start {
+				continue
+			}
+			if len(found) == maxCount {
+				return found, true, nil
+			}
+			found = append(found, photo)
+		}
+	}
+	return found, false, nil
+}
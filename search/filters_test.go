@@ -0,0 +1,105 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilters(t *testing.T) {
+	photo := &library.Photo{
+		Path:     "2019/07/IMG_1234.JPG",
+		Format:   domain.FormatSpec("jpg"),
+		Exif:     &domain.ExifInfo{Make: "NIKON CORPORATION", Model: "NIKON D750"},
+		Location: &gps.Coordinates{},
+	}
+	video := &library.Photo{Path: "clip.mov", Format: domain.FormatSpec("mov")}
+
+	data := []struct {
+		name   string
+		filter Filter
+		photo  bool
+		video  bool
+	}{
+		{"format jpg", FormatFilter("JPG"), true, false},
+		{"format mov", FormatFilter("mov"), false, true},
+		{"pictures", MediaTypeFilter(domain.Picture), true, false},
+		{"videos", MediaTypeFilter(domain.Video), false, true},
+		{"camera", CameraFilter("nikon d7"), true, false},
+		{"other camera", CameraFilter("canon"), false, false},
+		{"located", HasLocationFilter(true), true, false},
+		{"not located", HasLocationFilter(false), false, true},
+		{"name", NameFilter("img_12"), true, false},
+		{"name pattern", NameFilter("*.mov"), false, true},
+		{"name full pattern", NameFilter("img_????.jpg"), true, false},
+	}
+	for _, d := range data {
+		assert.Equal(t, d.photo, d.filter(photo), "%s on photo", d.name)
+		assert.Equal(t, d.video, d.filter(video), "%s on video", d.name)
+	}
+}
+
+// pagedLibrary holds n photos, every other one being a video, and counts the
+// pages read
+type pagedLibrary struct {
+	library.PhotoLibrary
+	photos []*library.Photo
+	pages  int
+}
+
+func newPagedLibrary(n int) *pagedLibrary {
+	lib := &pagedLibrary{}
+	for i := 0; i < n; i++ {
+		format := "jpg"
+		if i%2 == 1 {
+			format = "mov"
+		}
+		id := library.PhotoID(fmt.Sprintf("%05d", i))
+		lib.photos = append(lib.photos, &library.Photo{
+			ExtendedPhotoID: library.ExtendedPhotoID{ID: id, SortID: library.OrderedID(id)},
+			Format:          domain.FormatSpec(format),
+		})
+	}
+	return lib
+}
+
+func (lib *pagedLibrary) FindAllPaged(ctx context.Context, start, maxCount int, order consts.SortOrder) ([]*library.Photo, bool, error) {
+	lib.pages++
+	if start >= len(lib.photos) {
+		return nil, false, nil
+	}
+	end := start + maxCount
+	if end >= len(lib.photos) {
+		return lib.photos[start:], false, nil
+	}
+	return lib.photos[start:end], true, nil
+}
+
+func TestSearchPagedWithoutQuery(t *testing.T) {
+	lib := newPagedLibrary(3 * scanPageSize)
+	ctx := context.Background()
+	videos := MediaTypeFilter(domain.Video)
+
+	photos, hasMore, err := NewPlanner().SearchPaged(ctx, lib, nil, 10, 5, consts.Ascending, videos)
+	assert.NoError(t, err)
+	assert.True(t, hasMore)
+	if assert.Len(t, photos, 5) {
+		assert.Equal(t, library.PhotoID("00021"), photos[0].ID)
+		assert.Equal(t, library.PhotoID("00029"), photos[4].ID)
+	}
+	assert.Equal(t, 1, lib.pages, "Photos must only be read until the page is full")
+
+	// The last page is found after reading all photos
+	lib.pages = 0
+	photos, hasMore, err = NewPlanner().SearchPaged(ctx, lib, nil, 3*scanPageSize/2-2, 5, consts.Ascending, videos)
+	assert.NoError(t, err)
+	assert.False(t, hasMore)
+	assert.Len(t, photos, 2)
+	assert.Equal(t, 3, lib.pages)
+}
@@ -0,0 +1,258 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/library"
+)
+
+// ErrUnbounded is returned for queries only excluding photos, e.g. a single
+// NOT, which would need to scan the whole library
+var ErrUnbounded = errors.New("Query must include at least one condition that is not negated")
+
+// ErrUnknownField is returned for terms of a query on a field no source is
+// registered for
+type ErrUnknownField string
+
+func (e ErrUnknownField) Error() string {
+	return fmt.Sprintf("Unknown query field '%s'", string(e))
+}
+
+// ErrInvalidValue is returned by sources for values they cannot look up
+type ErrInvalidValue struct {
+	Field string
+	Value string
+}
+
+func (e ErrInvalidValue) Error() string {
+	return fmt.Sprintf("Invalid value '%s' for '%s'", e.Value, e.Field)
+}
+
+// Source finds the photos matching the terms of one field of a query
+type Source interface {
+	Find(ctx context.Context, value string) ([]library.PhotoID, error)
+}
+
+// SourceFunc is a function used as a Source
+type SourceFunc func(ctx context.Context, value string) ([]library.PhotoID, error)
+
+func (f SourceFunc) Find(ctx context.Context, value string) ([]library.PhotoID, error) {
+	return f(ctx, value)
+}
+
+// Set is a set of photos
+type Set map[library.PhotoID]struct{}
+
+// Planner evaluates queries by combining the photos returned by the sources
+// of their terms, so that no query needs to go through the whole library
+type Planner struct {
+	sources map[string]Source
+}
+
+func NewPlanner() *Planner {
+	return &Planner{sources: make(map[string]Source)}
+}
+
+// Register sets the source used for terms on the given field
+func (p *Planner) Register(field string, source Source) {
+	p.sources[field] = source
+}
+
+// Fields returns the fields which can be used in queries
+func (p *Planner) Fields() []string {
+	fields := make([]string, 0, len(p.sources))
+	for f := range p.sources {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Check returns an error if the given query cannot be evaluated
+func (p *Planner) Check(q library.Query) error {
+	if err := p.checkFields(q); err != nil {
+		return err
+	}
+	if !bounded(q) {
+		return ErrUnbounded
+	}
+	return nil
+}
+
+func (p *Planner) checkFields(q library.Query) error {
+	switch q := q.(type) {
+	case library.Term:
+		if _, found := p.sources[q.Field]; !found {
+			return ErrUnknownField(q.Field)
+		}
+	case library.And:
+		for _, c := range q {
+			if err := p.checkFields(c); err != nil {
+				return err
+			}
+		}
+	case library.Or:
+		for _, c := range q {
+			if err := p.checkFields(c); err != nil {
+				return err
+			}
+		}
+	case library.Not:
+		return p.checkFields(q.Query)
+	}
+	return nil
+}
+
+// bounded returns true if the photos matching the query can be found without
+// knowing all photos
+func bounded(q library.Query) bool {
+	switch q := q.(type) {
+	case library.Term:
+		return true
+	case library.And:
+		for _, c := range q {
+			if bounded(c) {
+				return true
+			}
+		}
+	case library.Or:
+		for _, c := range q {
+			if !bounded(c) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Find returns the photos matching the given query
+func (p *Planner) Find(ctx context.Context, q library.Query) (Set, error) {
+	if err := p.Check(q); err != nil {
+		return nil, err
+	}
+	return p.find(ctx, q, nil)
+}
+
+// find returns the photos matching q among the candidates, or among all
+// photos if candidates is nil
+func (p *Planner) find(ctx context.Context, q library.Query, candidates Set) (Set, error) {
+	switch q := q.(type) {
+	case library.Term:
+		ids, err := p.sources[q.Field].Find(ctx, q.Value)
+		if err != nil {
+			return nil, err
+		}
+		found := make(Set)
+		for _, id := range ids {
+			if _, isCandidate := candidates[id]; isCandidate || candidates == nil {
+				found[id] = struct{}{}
+			}
+		}
+		return found, nil
+	case library.And:
+		// Each part only keeps the photos found by the previous ones
+		for _, c := range plan(q) {
+			var err error
+			if candidates, err = p.find(ctx, c, candidates); err != nil {
+				return nil, err
+			}
+			if len(candidates) == 0 {
+				break
+			}
+		}
+		return candidates, nil
+	case library.Or:
+		found := make(Set)
+		for _, c := range q {
+			matches, err := p.find(ctx, c, candidates)
+			if err != nil {
+				return nil, err
+			}
+			for id := range matches {
+				found[id] = struct{}{}
+			}
+		}
+		return found, nil
+	case library.Not:
+		excluded, err := p.find(ctx, q.Query, candidates)
+		if err != nil {
+			return nil, err
+		}
+		found := make(Set)
+		for id := range candidates {
+			if _, isExcluded := excluded[id]; !isExcluded {
+				found[id] = struct{}{}
+			}
+		}
+		return found, nil
+	}
+	return nil, fmt.Errorf("Unsupported query %T", q)
+}
+
+// plan orders the parts of an AND so that single terms are looked up first
+// and negated parts last, when there are candidates left to exclude photos
+// from
+func plan(q library.And) []library.Query {
+	cost := func(c library.Query) int {
+		switch {
+		case !bounded(c):
+			return 2
+		case isTerm(c):
+			return 0
+		}
+		return 1
+	}
+	ordered := append([]library.Query{}, q...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return cost(ordered[i]) < cost(ordered[j])
+	})
+	return ordered
+}
+
+func isTerm(q library.Query) bool {
+	_, ok := q.(library.Term)
+	return ok
+}
+
+// Sorted returns the photos of the given set matching all filters in the
+// order of their capture time, photos which do not exist anymore are skipped
+func Sorted(ctx context.Context, lib library.PhotoLibrary, photos Set, order consts.SortOrder, filters ...Filter) ([]*library.Photo, error) {
+	sorted := make([]*library.Photo, 0, len(photos))
+	for id := range photos {
+		photo, err := lib.Get(ctx, id)
+		if _, notFound := err.(library.ErrNotFound); notFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if matchesAll(photo, filters) {
+			sorted = append(sorted, photo)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if order == consts.Descending {
+			i, j = j, i
+		}
+		return bytes.Compare(sorted[i].SortID, sorted[j].SortID) < 0
+	})
+	return sorted, nil
+}
+
+// Page returns the photos of the page starting at start
+func Page(photos []*library.Photo, start, maxCount int) ([]*library.Photo, bool) {
+	if start >= len(photos) {
+		return []*library.Photo{}, false
+	}
+	photos = photos[start:]
+	if len(photos) > maxCount {
+		return photos[:maxCount], true
+	}
+	return photos, false
+}
@@ -0,0 +1,141 @@
+package search
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/library"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSource returns the photos listed for each value and counts its lookups
+type testSource struct {
+	photos  map[string][]library.PhotoID
+	lookups int
+}
+
+func (s *testSource) Find(ctx context.Context, value string) ([]library.PhotoID, error) {
+	s.lookups++
+	return s.photos[value], nil
+}
+
+func newTestPlanner() (*Planner, map[string]*testSource) {
+	sources := map[string]*testSource{
+		"country": {photos: map[string][]library.PhotoID{
+			"fr": {"1", "2", "3", "4"},
+			"de": {"5", "6"},
+		}},
+		"tag": {photos: map[string][]library.PhotoID{
+			"beach": {"1", "3", "5"},
+			"work":  {"3", "6"},
+		}},
+		"year": {photos: map[string][]library.PhotoID{
+			"2019": {"1", "3", "5", "6"},
+			"2020": {"2", "4"},
+		}},
+	}
+	p := NewPlanner()
+	for field, s := range sources {
+		p.Register(field, s)
+	}
+	return p, sources
+}
+
+func ids(s Set) []library.PhotoID {
+	found := []library.PhotoID{}
+	for id := range s {
+		found = append(found, id)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i] < found[j] })
+	return found
+}
+
+func TestPlannerFind(t *testing.T) {
+	data := []struct {
+		query    string
+		expected []library.PhotoID
+	}{
+		{"country=fr", []library.PhotoID{"1", "2", "3", "4"}},
+		{"country=fr AND year=2019 AND tag=beach", []library.PhotoID{"1", "3"}},
+		{"tag=beach OR tag=work", []library.PhotoID{"1", "3", "5", "6"}},
+		{"year=2019 NOT tag=work", []library.PhotoID{"1", "5"}},
+		{"NOT tag=work year=2019", []library.PhotoID{"1", "5"}},
+		{"(country=de OR year=2020) NOT (tag=work OR tag=beach)", []library.PhotoID{"2", "4"}},
+		{"country=it", []library.PhotoID{}},
+	}
+	for _, d := range data {
+		p, _ := newTestPlanner()
+		q, err := library.ParseQuery(d.query)
+		if err != nil {
+			t.Fatalf("Bad query %s: %s", d.query, err)
+		}
+		found, err := p.Find(context.Background(), q)
+		if assert.NoError(t, err, d.query) {
+			assert.Equal(t, d.expected, ids(found), d.query)
+		}
+	}
+}
+
+func TestPlannerStopsOnEmptyIntersection(t *testing.T) {
+	p, sources := newTestPlanner()
+	q, _ := library.ParseQuery("country=de year=2020 tag=beach")
+	found, err := p.Find(context.Background(), q)
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+	assert.Equal(t, 0, sources["tag"].lookups)
+}
+
+func TestPlannerCheck(t *testing.T) {
+	p, _ := newTestPlanner()
+	data := []struct {
+		query string
+		err   error
+	}{
+		{"tag=beach", nil},
+		{"camera=nikon", ErrUnknownField("camera")},
+		{"tag=beach NOT camera=nikon", ErrUnknownField("camera")},
+		{"NOT tag=beach", ErrUnbounded},
+		{"tag=beach OR NOT tag=work", ErrUnbounded},
+		{"NOT (NOT tag=beach)", ErrUnbounded},
+	}
+	for _, d := range data {
+		q, err := library.ParseQuery(d.query)
+		if err != nil {
+			t.Fatalf("Bad query %s: %s", d.query, err)
+		}
+		assert.Equal(t, d.err, p.Check(q), d.query)
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	day := func(s string) time.Time {
+		t, _ := time.Parse("2006-01-02", s)
+		return t
+	}
+	data := []struct {
+		value    string
+		from, to time.Time
+		ok       bool
+	}{
+		{"2019", day("2019-01-01"), day("2019-12-31"), true},
+		{"2020-02", day("2020-02-01"), day("2020-02-29"), true},
+		{"2019-07-14", day("2019-07-14"), day("2019-07-14"), true},
+		{"2019-07..2019-08-15", day("2019-07-01"), day("2019-08-15"), true},
+		{"2019-07..", day("2019-07-01"), time.Time{}, true},
+		{"..2019-07", time.Time{}, day("2019-07-31"), true},
+		{"..", time.Time{}, time.Time{}, false},
+		{"2019..2018", time.Time{}, time.Time{}, false},
+		{"19", time.Time{}, time.Time{}, false},
+		{"2019-13", time.Time{}, time.Time{}, false},
+	}
+	for _, d := range data {
+		from, to, ok := ParseDateRange(d.value)
+		assert.Equal(t, d.ok, ok, d.value)
+		if d.ok {
+			assert.Equal(t, d.from, from, d.value)
+			assert.Equal(t, d.to, to, d.value)
+		}
+	}
+}
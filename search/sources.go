@@ -0,0 +1,222 @@
+package search
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+)
+
+// allPhotos is the count of photos requested from paged index lookups, so
+// that all photos are read at once in a single transaction instead of
+// skipping the photos already read page after page
+const allPhotos = math.MaxInt32
+
+// collect returns all photos of a paged index lookup
+func collect(findPaged func(start, maxCount int) ([]library.PhotoID, bool, error)) ([]library.PhotoID, error) {
+	photos, _, err := findPaged(0, allPhotos)
+	return photos, err
+}
+
+// RegisterSources registers the sources of the fields of smart album queries
+// backed by the given indexes:
+//
+//	date=2019, date=2019-07, date=2019-07-14 or date=2019-07-01..2019-08-15,
+//	date=2019.. or date=..2019-07
+//	year=2019
+//	location=true
+//	country=fr
+//	place=fr/Île-de-France/Paris
+//	tag=beach
+//	event=<event ID>
+func RegisterSources(p *Planner, dates library.DateIndex, places library.GeoIndex, spatial library.SpatialIndex, tags library.TagIndex, events *boltstore.EventIndex) {
+	p.Register("date", DateSource(dates, "date"))
+	p.Register("year", YearSource(dates))
+	p.Register("location", LocationSource(spatial))
+	p.Register("country", CountrySource(places))
+	p.Register("place", PlaceSource(places))
+	p.Register("tag", TagSource(tags))
+	p.Register("event", EventSource(events))
+}
+
+// dateLayouts are the layouts of dates in queries, from the most to the
+// least precise
+var dateLayouts = []struct {
+	layout string
+	end    func(time.Time) time.Time
+}{
+	{"2006-01-02", func(t time.Time) time.Time { return t }},
+	{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, -1) }},
+	{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, -1) }},
+}
+
+// ParseDateRange returns the first and the last day of the given period: a
+// day, a month, a year or a range of those separated by '..'. Either end of
+// a range may be left out, it is then returned as zero time.
+func ParseDateRange(value string) (from, to time.Time, ok bool) {
+	if parts := strings.SplitN(value, "..", 2); len(parts) == 2 {
+		if parts[0] == "" && parts[1] == "" {
+			return time.Time{}, time.Time{}, false
+		}
+		okFrom, okTo := true, true
+		if parts[0] != "" {
+			from, _, okFrom = ParseDateRange(parts[0])
+		}
+		if parts[1] != "" {
+			_, to, okTo = ParseDateRange(parts[1])
+		}
+		return from, to, okFrom && okTo && (from.IsZero() || to.IsZero() || !to.Before(from))
+	}
+	for _, l := range dateLayouts {
+		if len(value) != len(l.layout) {
+			continue
+		}
+		if t, err := time.Parse(l.layout, value); err == nil {
+			return t, l.end(t), true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// indexedDays returns the first and the last day having photos in the date
+// index
+func indexedDays(ctx context.Context, dates library.DateIndex) (first, last time.Time, found bool, err error) {
+	timeline, err := dates.Keys(ctx)
+	if err != nil {
+		return first, last, false, err
+	}
+	for _, y := range timeline.Years {
+		for _, m := range y.Months {
+			for _, d := range m.Days {
+				t, err := time.Parse("2006-01-02", d.Date)
+				if err != nil {
+					continue
+				}
+				if !found || t.Before(first) {
+					first = t
+				}
+				if !found || t.After(last) {
+					last = t
+				}
+				found = true
+			}
+		}
+	}
+	return first, last, found, nil
+}
+
+// DateSource finds the photos taken during a period, a range without start
+// or end is open on that side
+func DateSource(dates library.DateIndex, field string) Source {
+	return SourceFunc(func(ctx context.Context, value string) ([]library.PhotoID, error) {
+		from, to, ok := ParseDateRange(value)
+		if !ok {
+			return nil, ErrInvalidValue{Field: field, Value: value}
+		}
+		if from.IsZero() || to.IsZero() {
+			// The index is looked up day by day, open ends are bounded by the
+			// days having photos
+			first, last, found, err := indexedDays(ctx, dates)
+			if err != nil || !found {
+				return nil, err
+			}
+			if from.IsZero() {
+				from = first
+			}
+			if to.IsZero() {
+				to = last
+			}
+		}
+		if to.Before(from) {
+			return nil, nil
+		}
+		return collect(func(start, maxCount int) ([]library.PhotoID, bool, error) {
+			return dates.FindRangePaged(ctx, from, to, start, maxCount)
+		})
+	})
+}
+
+// LocationSource finds the photos having coordinates, the only value is true
+// as the photos without coordinates are not indexed
+func LocationSource(spatial library.SpatialIndex) Source {
+	return SourceFunc(func(ctx context.Context, value string) ([]library.PhotoID, error) {
+		if located, err := strconv.ParseBool(value); err != nil || !located {
+			return nil, ErrInvalidValue{Field: "location", Value: value}
+		}
+		photos, _, err := spatial.FindInRectPaged(ctx, gps.WorldBounds, 0, allPhotos)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]library.PhotoID, len(photos))
+		for i, p := range photos {
+			ids[i] = p.ID
+		}
+		return ids, nil
+	})
+}
+
+// YearSource finds the photos taken during a year, given with 4 digits
+func YearSource(dates library.DateIndex) Source {
+	byDate := DateSource(dates, "year")
+	return SourceFunc(func(ctx context.Context, value string) ([]library.PhotoID, error) {
+		if !isYear(value) {
+			return nil, ErrInvalidValue{Field: "year", Value: value}
+		}
+		return byDate.Find(ctx, value)
+	})
+}
+
+func isYear(value string) bool {
+	if len(value) != 4 {
+		return false
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// CountrySource finds the photos taken in a country given by its ISO code
+func CountrySource(places library.GeoIndex) Source {
+	return SourceFunc(func(ctx context.Context, value string) ([]library.PhotoID, error) {
+		country := gps.CountryIDFromString(value)
+		return collect(func(start, maxCount int) ([]library.PhotoID, bool, error) {
+			return places.FindByCountryPaged(ctx, country, start, maxCount)
+		})
+	})
+}
+
+// PlaceSource finds the photos taken at a place, including its sub-places
+func PlaceSource(places library.GeoIndex) Source {
+	return SourceFunc(func(ctx context.Context, value string) ([]library.PhotoID, error) {
+		return collect(func(start, maxCount int) ([]library.PhotoID, bool, error) {
+			return places.FindByPlacePaged(ctx, gps.PlaceID(value), start, maxCount)
+		})
+	})
+}
+
+// TagSource finds the photos having a tag
+func TagSource(tags library.TagIndex) Source {
+	return SourceFunc(func(ctx context.Context, value string) ([]library.PhotoID, error) {
+		return collect(func(start, maxCount int) ([]library.PhotoID, bool, error) {
+			return tags.FindByTagPaged(ctx, value, start, maxCount, consts.Ascending)
+		})
+	})
+}
+
+// EventSource finds the photos of an event
+func EventSource(events *boltstore.EventIndex) Source {
+	return SourceFunc(func(ctx context.Context, value string) ([]library.PhotoID, error) {
+		return collect(func(start, maxCount int) ([]library.PhotoID, bool, error) {
+			return events.FindPhotosPaged(ctx, value, start, maxCount)
+		})
+	})
+}
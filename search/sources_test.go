@@ -0,0 +1,120 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+	"github.com/stretchr/testify/assert"
+)
+
+// pagedDateIndex returns n photos taken every day of 2019 and records the
+// pages requested
+type pagedDateIndex struct {
+	library.DateIndex
+	n     int
+	pages [][2]int
+}
+
+func (idx *pagedDateIndex) FindRangePaged(ctx context.Context, from, to time.Time, start, maxCount int) ([]library.PhotoID, bool, error) {
+	idx.pages = append(idx.pages, [2]int{start, maxCount})
+	var photos []library.PhotoID
+	for i := start; i < idx.n && len(photos) < maxCount; i++ {
+		photos = append(photos, library.PhotoID(time.Date(2019, 1, 1+i, 0, 0, 0, 0, time.UTC).Format("2006-01-02")))
+	}
+	return photos, start+len(photos) < idx.n, nil
+}
+
+func TestDateSourceReadsAllPhotosAtOnce(t *testing.T) {
+	dates := &pagedDateIndex{n: 365}
+	photos, err := DateSource(dates, "date").Find(context.Background(), "2019")
+	assert.NoError(t, err)
+	assert.Len(t, photos, 365)
+	assert.Len(t, dates.pages, 1, "Photos must be read in a single lookup")
+	assert.Equal(t, 0, dates.pages[0][0])
+}
+
+func TestYearSource(t *testing.T) {
+	dates := &pagedDateIndex{n: 3}
+	photos, err := YearSource(dates).Find(context.Background(), "2019")
+	assert.NoError(t, err)
+	assert.Len(t, photos, 3)
+
+	for _, value := range []string{"19", "2019-07", "2019..2020", "..2019", "20x9", "02019"} {
+		_, err := YearSource(dates).Find(context.Background(), value)
+		assert.Equal(t, ErrInvalidValue{Field: "year", Value: value}, err, value)
+	}
+}
+
+func TestDateSourceOpenRanges(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	dates, err := boltstore.NewDateIndex(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Photos with a wrong camera clock may be taken in the future
+	future := time.Now().UTC().AddDate(2, 0, 0)
+	for id, taken := range map[library.PhotoID]time.Time{
+		"old":    time.Date(2010, 3, 1, 12, 0, 0, 0, time.UTC),
+		"2019":   time.Date(2019, 7, 14, 12, 0, 0, 0, time.UTC),
+		"future": future,
+	} {
+		photo := &library.Photo{
+			ExtendedPhotoID: library.ExtendedPhotoID{ID: id, SortID: library.OrderedID(id)},
+			DateTaken:       taken,
+		}
+		if err := dates.Add(ctx, photo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source := DateSource(dates, "date")
+	data := []struct {
+		value    string
+		expected []library.PhotoID
+	}{
+		{"2019..", []library.PhotoID{"2019", "future"}},
+		{"..2019", []library.PhotoID{"old", "2019"}},
+		{"2019", []library.PhotoID{"2019"}},
+		{future.Format("2006") + "..", []library.PhotoID{"future"}},
+		{"..2009", nil},
+	}
+	for _, d := range data {
+		photos, err := source.Find(ctx, d.value)
+		assert.NoError(t, err, d.value)
+		assert.Equal(t, d.expected, photos, d.value)
+	}
+}
+
+func TestLocationSource(t *testing.T) {
+	db, cleanup := openTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	spatial, err := boltstore.NewSpatialIndex(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, location := range map[library.PhotoID]*gps.Coordinates{
+		"paris":   {Lat: 48.8566, Long: 2.3522},
+		"sydney":  {Lat: -33.8688, Long: 151.2093},
+		"unknown": nil,
+	} {
+		photo := &library.Photo{
+			ExtendedPhotoID: library.ExtendedPhotoID{ID: id, SortID: library.OrderedID(id)},
+			Location:        location,
+		}
+		if err := spatial.Add(ctx, photo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	photos, err := LocationSource(spatial).Find(ctx, "true")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []library.PhotoID{"paris", "sydney"}, photos)
+
+	_, err = LocationSource(spatial).Find(ctx, "false")
+	assert.Equal(t, ErrInvalidValue{Field: "location", Value: "false"}, err)
+}
@@ -0,0 +1,169 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+)
+
+// Weights of the terms of a photo in the full-text index, by where they come
+// from: words chosen by the user weigh more than generated ones
+const (
+	nameWeight  = 1
+	dateWeight  = 1
+	placeWeight = 2
+	tagWeight   = 3
+	eventWeight = 3
+)
+
+// TextIndexer maintains the full-text index of the photos with the words of
+// their file name, capture date, tags, resolved address and event
+type TextIndexer struct {
+	index  *boltstore.TextIndex
+	lib    library.PhotoLibrary
+	places library.GeoIndex
+	events *boltstore.EventIndex
+}
+
+func NewTextIndexer(index *boltstore.TextIndex, lib library.PhotoLibrary, places library.GeoIndex, events *boltstore.EventIndex) *TextIndexer {
+	return &TextIndexer{index: index, lib: lib, places: places, events: events}
+}
+
+// Add indexes the given photo
+func (t *TextIndexer) Add(ctx context.Context, photo *library.Photo) error {
+	doc, err := t.documentOf(ctx, photo)
+	if err != nil {
+		return err
+	}
+	return t.index.Put(ctx, doc)
+}
+
+// Update indexes the given photo again after its capture time, location or
+// tags were changed
+func (t *TextIndexer) Update(ctx context.Context, before, after *library.Photo) error {
+	return t.Add(ctx, after)
+}
+
+// AddressResolved indexes the given photo again after its address has been
+// resolved
+func (t *TextIndexer) AddressResolved(ctx context.Context, photo library.ExtendedPhotoID, address *gps.Address) error {
+	return t.Reindex(ctx, []library.PhotoID{photo.ID})
+}
+
+// Reindex indexes the given photos again, e.g. after their event changed
+func (t *TextIndexer) Reindex(ctx context.Context, photos []library.PhotoID) error {
+	docs := make([]boltstore.TextDocument, 0, len(photos))
+	seen := make(map[library.PhotoID]bool)
+	for _, id := range photos {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		photo, err := t.lib.Get(ctx, id)
+		if _, notFound := err.(library.ErrNotFound); notFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		doc, err := t.documentOf(ctx, photo)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, doc)
+	}
+	return t.index.Put(ctx, docs...)
+}
+
+func (t *TextIndexer) documentOf(ctx context.Context, photo *library.Photo) (boltstore.TextDocument, error) {
+	terms := make(map[string]int)
+	add := func(weight int, texts ...string) {
+		seen := make(map[string]bool)
+		for _, text := range texts {
+			for _, term := range library.Tokenize(text) {
+				if !seen[term] {
+					terms[term] += weight
+					seen[term] = true
+				}
+			}
+		}
+	}
+	name := photo.Name()
+	add(nameWeight, strings.TrimSuffix(name, filepath.Ext(name)))
+	if taken := photo.LocalTime(); !taken.IsZero() {
+		add(dateWeight, strconv.Itoa(taken.Year()), taken.Month().String())
+	}
+	add(tagWeight, photo.Tags...)
+	address, found, err := t.places.Get(ctx, photo.ID)
+	if err != nil {
+		return boltstore.TextDocument{}, err
+	}
+	if found {
+		add(placeWeight, address.Country.Country, address.Region, address.City, address.Neighbourhood)
+	}
+	event, found, err := t.events.EventOf(ctx, photo.ID)
+	if err != nil {
+		return boltstore.TextDocument{}, err
+	}
+	if found {
+		add(eventWeight, event.Name)
+	}
+	return boltstore.TextDocument{ExtendedPhotoID: photo.ExtendedPhotoID, Terms: terms}, nil
+}
+
+// TextSource finds the photos matching a text in the full-text index
+func TextSource(index library.TextIndex) Source {
+	return SourceFunc(func(ctx context.Context, value string) ([]library.PhotoID, error) {
+		matches, err := index.Find(ctx, library.Tokenize(value))
+		if err != nil {
+			return nil, err
+		}
+		photos := make([]library.PhotoID, len(matches))
+		for i, m := range matches {
+			photos[i] = m.ID
+		}
+		return photos, nil
+	})
+}
+
+// SearchText returns the photos matching the given text, most relevant
+// first. If a query is given, only the photos also matching it are returned.
+func (p *Planner) SearchText(ctx context.Context, lib library.PhotoLibrary, index library.TextIndex, text string, q library.Query, filters ...Filter) ([]*library.Photo, error) {
+	terms := library.Tokenize(text)
+	if len(terms) == 0 {
+		return nil, ErrInvalidValue{Field: "q", Value: text}
+	}
+	var candidates Set
+	if q != nil {
+		var err error
+		if candidates, err = p.Find(ctx, q); err != nil {
+			return nil, err
+		}
+	}
+	matches, err := index.Find(ctx, terms)
+	if err != nil {
+		return nil, err
+	}
+	photos := make([]*library.Photo, 0, len(matches))
+	for _, m := range matches {
+		if _, isCandidate := candidates[m.ID]; candidates != nil && !isCandidate {
+			continue
+		}
+		photo, err := lib.Get(ctx, m.ID)
+		if _, notFound := err.(library.ErrNotFound); notFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if matchesAll(photo, filters) {
+			photos = append(photos, photo)
+		}
+	}
+	return photos, nil
+}
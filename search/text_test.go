@@ -0,0 +1,126 @@
+package search
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain/gps"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/library/boltstore"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+// photoMap is a library only able to get its photos by ID
+type photoMap struct {
+	library.PhotoLibrary
+	photos map[library.PhotoID]*library.Photo
+}
+
+func (m photoMap) Get(ctx context.Context, id library.PhotoID) (*library.Photo, error) {
+	if p, found := m.photos[id]; found {
+		return p, nil
+	}
+	return nil, library.NotFound(id)
+}
+
+func openTestDB(t *testing.T) (*bolt.DB, func()) {
+	dir, err := ioutil.TempDir("", "search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(filepath.Join(dir, "test.db"), 0644, nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestTextIndexer(t *testing.T) {
+	db, closeDB := openTestDB(t)
+	defer closeDB()
+	ctx := context.Background()
+
+	index, err := boltstore.NewTextIndex(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	places, err := boltstore.NewBoltGeoIndex(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := boltstore.NewEventIndex(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib := photoMap{photos: map[library.PhotoID]*library.Photo{}}
+	for _, p := range []*library.Photo{
+		{ExtendedPhotoID: library.ExtendedPhotoID{ID: "1", SortID: library.OrderedID("1")}, Path: "IMG_0001.jpg", DateTaken: time.Date(2019, 7, 14, 12, 0, 0, 0, time.UTC), Tags: []string{"Beach"}},
+		{ExtendedPhotoID: library.ExtendedPhotoID{ID: "2", SortID: library.OrderedID("2")}, Path: "IMG_0002.jpg", DateTaken: time.Date(2019, 7, 15, 12, 0, 0, 0, time.UTC)},
+		{ExtendedPhotoID: library.ExtendedPhotoID{ID: "3", SortID: library.OrderedID("3")}, Path: "beach.mov", DateTaken: time.Date(2020, 1, 2, 12, 0, 0, 0, time.UTC)},
+	} {
+		lib.photos[p.ID] = p
+	}
+	indexer := NewTextIndexer(index, lib, places, events)
+	events.AddEventsChangedCallback(indexer.Reindex)
+	for _, id := range []library.PhotoID{"1", "2", "3"} {
+		if err := indexer.Add(ctx, lib.photos[id]); err != nil {
+			t.Fatalf("Failed to index photo %s: %s", id, err)
+		}
+	}
+	nice := &gps.Address{
+		ID: "nice",
+		AddressFields: gps.AddressFields{
+			Country: gps.Country{Country: "France", ID: "fr"},
+			Region:  "Provence-Alpes-Côte d'Azur",
+			City:    "Nice",
+		},
+	}
+	if err := places.Update(ctx, lib.photos["2"].ExtendedPhotoID, nice); err != nil {
+		t.Fatal(err)
+	}
+	if err := indexer.AddressResolved(ctx, lib.photos["2"].ExtendedPhotoID, nice); err != nil {
+		t.Fatalf("Failed to index address: %s", err)
+	}
+	event := boltstore.Event{ID: "e1", Name: "Summer on the Côte"}
+	if err := events.AddPhotosToEvent(ctx, event, []library.ExtendedPhotoID{lib.photos["1"].ExtendedPhotoID, lib.photos["2"].ExtendedPhotoID}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPlanner()
+	data := []struct {
+		text     string
+		expected []library.PhotoID
+	}{
+		{"beach", []library.PhotoID{"1", "3"}},
+		{"img", []library.PhotoID{"2", "1"}},
+		{"cote", []library.PhotoID{"2", "1"}},
+		{"summer 2019", []library.PhotoID{"2", "1"}},
+		{"july nic", []library.PhotoID{"2"}},
+		{"France, 2020", nil},
+	}
+	for _, d := range data {
+		found, err := p.SearchText(ctx, lib, index, d.text, nil)
+		assert.NoError(t, err, d.text)
+		var ids []library.PhotoID
+		for _, photo := range found {
+			ids = append(ids, photo.ID)
+		}
+		assert.Equal(t, d.expected, ids, d.text)
+	}
+
+	_, err = p.SearchText(ctx, lib, index, " - ", nil)
+	assert.Equal(t, ErrInvalidValue{Field: "q", Value: " - "}, err)
+
+	matched, err := TextSource(index).Find(ctx, "Beach")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []library.PhotoID{"1", "3"}, matched)
+}
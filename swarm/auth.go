@@ -0,0 +1,109 @@
+package swarm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxClockSkew is the maximum age of a signed announcement or request
+	maxClockSkew = time.Minute
+
+	authScheme = "HMAC "
+	timeHeader = "X-Swarm-Time"
+	// authPath is the path on which workers prove that they know the key
+	authPath   = "/auth"
+	nonceParam = "nonce"
+)
+
+// ErrNoKey is returned when a swarm is set up without shared key
+var ErrNoKey = errors.New("A shared key is required to distribute thumbs")
+
+// Key is the secret shared by the instances of a swarm: announcements,
+// requests and workers are authenticated with it, so that photos are only
+// ever sent to workers of the owner of the library
+type Key []byte
+
+// ReadKey reads the key stored in the given file
+func ReadKey(path string) (Key, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := Key(bytes.TrimSpace(data))
+	if len(key) == 0 {
+		return nil, fmt.Errorf("Empty key in %s", path)
+	}
+	return key, nil
+}
+
+// sign returns the hex encoded HMAC-SHA256 of the given parts
+func (k Key) sign(parts ...string) string {
+	mac := hmac.New(sha256.New, k)
+	for _, p := range parts {
+		mac.Write([]byte(p))
+		mac.Write([]byte{0})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks that signature is the signature of the given parts
+func (k Key) verify(signature string, parts ...string) bool {
+	if len(k) == 0 {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(k.sign(parts...)))
+}
+
+// fresh checks that a signed timestamp is close enough to now to not be the
+// replay of an old message
+func fresh(unix int64, now time.Time) bool {
+	d := now.Sub(time.Unix(unix, 0))
+	return d <= maxClockSkew && d >= -maxClockSkew
+}
+
+// signRequest authenticates a request sent to a worker
+func (k Key) signRequest(r *http.Request, now time.Time) {
+	ts := strconv.FormatInt(now.Unix(), 10)
+	r.Header.Set(timeHeader, ts)
+	r.Header.Set("Authorization", authScheme+k.sign(r.Method, r.URL.RequestURI(), ts))
+}
+
+// verifyRequest checks that a request received by a worker has been sent by
+// an instance knowing the key
+func (k Key) verifyRequest(r *http.Request, now time.Time) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, authScheme) {
+		return false
+	}
+	ts := r.Header.Get(timeHeader)
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || !fresh(unix, now) {
+		return false
+	}
+	return k.verify(strings.TrimPrefix(auth, authScheme), r.Method, r.URL.RequestURI(), ts)
+}
+
+// newNonce returns a random challenge for a worker
+func newNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(nonce), nil
+}
+
+// proof is the answer of a worker to the given challenge
+func (k Key) proof(nonce string) string {
+	return k.sign("worker", nonce)
+}
@@ -0,0 +1,59 @@
+package swarm
+
+import (
+	"context"
+	"image"
+	"io"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"go.uber.org/zap"
+)
+
+type balancedThumber struct {
+	registry *Registry
+	local    domain.Thumber
+	logger   *zap.Logger
+}
+
+// NewBalancedThumber returns a thumber sending each thumb to the least busy
+// worker of the registry. Thumbs are created by the local thumber when no
+// worker is available or when the worker fails. Videos and contents which
+// cannot be read again after a failure are always rendered locally.
+func NewBalancedThumber(ctx context.Context, registry *Registry, local domain.Thumber) domain.Thumber {
+	logger, _ := logging.SubFrom(ctx, "swarm")
+	return &balancedThumber{
+		registry: registry,
+		local:    local,
+		logger:   logger,
+	}
+}
+
+func (t *balancedThumber) CreateThumb(in io.Reader, f domain.Format, o domain.Orientation, size domain.ThumbSize) (image.Image, error) {
+	// The content is streamed to the worker and read again from the start
+	// to fall back to the local thumber
+	content, seekable := in.(io.ReadSeeker)
+	if !seekable || f.Type() == domain.Video {
+		return t.local.CreateThumb(in, f, o, size)
+	}
+	start, err := content.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return t.local.CreateThumb(in, f, o, size)
+	}
+	w, found := t.registry.acquire(time.Now())
+	if !found {
+		return t.local.CreateThumb(in, f, o, size)
+	}
+	thumb, err := w.thumber.CreateThumb(content, f, o, size)
+	_, unsupported := err.(domain.ErrThumbsNotSupported)
+	t.registry.release(w, err != nil && !unsupported, time.Now())
+	if err == nil {
+		return thumb, nil
+	}
+	t.logger.Warn("Thumb worker failed, creating thumb locally", zap.String("worker", w.url), zap.Error(err))
+	if _, err := content.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return t.local.CreateThumb(content, f, o, size)
+}
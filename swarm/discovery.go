@@ -0,0 +1,131 @@
+package swarm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultDiscoveryAddr is the multicast group on which workers announce
+	// themselves
+	DefaultDiscoveryAddr = "239.255.80.83:7878"
+	// DefaultAnnounceInterval is the interval between two announcements of a
+	// worker
+	DefaultAnnounceInterval = 10 * time.Second
+	// DefaultWorkerTTL is the duration after which a worker which has not been
+	// announced any more is considered gone
+	DefaultWorkerTTL = 3 * DefaultAnnounceInterval
+
+	thumbService       = "thumbd"
+	maxAnnouncementLen = 1024
+)
+
+// announcement is the message periodically sent by workers, signed with the
+// key of the swarm
+type announcement struct {
+	ID      string `json:"id"`
+	Service string `json:"service"`
+	Port    int    `json:"port"`
+	Time    int64  `json:"time"`
+	MAC     string `json:"mac"`
+}
+
+func (a announcement) signed() []string {
+	return []string{a.ID, a.Service, strconv.Itoa(a.Port), strconv.FormatInt(a.Time, 10)}
+}
+
+// Announce periodically announces a worker listening on the given port to
+// the multicast group at addr, until the context is cancelled
+func Announce(ctx context.Context, addr, id string, port int, interval time.Duration, key Key) error {
+	if len(key) == 0 {
+		return ErrNoKey
+	}
+	logger, ctx := logging.SubFrom(ctx, "swarmAnnounce")
+	group, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.DialUDP("udp4", nil, group)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		a := announcement{ID: id, Service: thumbService, Port: port, Time: time.Now().Unix()}
+		a.MAC = key.sign(a.signed()...)
+		msg, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.Write(msg); err != nil {
+			logger.Warn("Failed to announce worker", zap.String("group", addr), zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Listen registers the workers announcing themselves on the multicast group
+// at addr, until the context is cancelled
+func (r *Registry) Listen(ctx context.Context, addr string) error {
+	logger, ctx := logging.SubFrom(ctx, "swarmDiscovery")
+	group, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	logger.Info("Discovering thumb workers", zap.String("group", addr))
+	buf := make([]byte, maxAnnouncementLen)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err := r.announced(buf[:n], from.IP, time.Now()); err != nil {
+			logger.Debug("Ignoring announcement", zap.Stringer("from", from), zap.Error(err))
+		}
+	}
+}
+
+// announced registers the worker announced by the given message, sent from
+// the given address. Announcements which are not signed with the key of the
+// registry or are too old are rejected.
+func (r *Registry) announced(msg []byte, from net.IP, now time.Time) error {
+	var a announcement
+	if err := json.Unmarshal(msg, &a); err != nil {
+		return err
+	}
+	if a.Service != thumbService || a.ID == "" || a.Port <= 0 {
+		return fmt.Errorf("Bad announcement %s", msg)
+	}
+	if !r.key.verify(a.MAC, a.signed()...) {
+		return fmt.Errorf("Unauthenticated announcement of worker %s", a.ID)
+	}
+	if !fresh(a.Time, now) {
+		return fmt.Errorf("Stale announcement of worker %s", a.ID)
+	}
+	host := net.JoinHostPort(from.String(), fmt.Sprintf("%d", a.Port))
+	return r.seen(a.ID, "http://"+host, now)
+}
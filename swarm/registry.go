@@ -0,0 +1,138 @@
+package swarm
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain"
+)
+
+// DefaultRetryDelay is the duration during which a failed worker is not sent
+// any request
+const DefaultRetryDelay = time.Minute
+
+// worker is a remote instance creating thumbs
+type worker struct {
+	id      string
+	url     string
+	thumber domain.Thumber
+
+	// static workers are configured explicitly and never expire
+	static      bool
+	lastSeen    time.Time
+	inFlight    int
+	failedUntil time.Time
+}
+
+// Registry tracks the known thumb workers and their load
+type Registry struct {
+	mutex       sync.Mutex
+	workers     map[string]*worker
+	thumbFormat domain.Format
+	key         Key
+	ttl         time.Duration
+	retryDelay  time.Duration
+}
+
+// NewRegistry returns an empty registry of workers creating thumbs in the
+// given format. Discovered workers expire after ttl without announcement.
+// Only the workers knowing the given key are used.
+func NewRegistry(thumbFormat domain.Format, ttl time.Duration, key Key) (*Registry, error) {
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
+	return &Registry{
+		workers:     make(map[string]*worker),
+		thumbFormat: thumbFormat,
+		key:         key,
+		ttl:         ttl,
+		retryDelay:  DefaultRetryDelay,
+	}, nil
+}
+
+// Register adds a worker at the given URL which never expires
+func (r *Registry) Register(url string) error {
+	thumber, err := NewRemoteThumber(url, r.thumbFormat, r.key)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.workers[url] = &worker{id: url, url: url, thumber: thumber, static: true}
+	return nil
+}
+
+// seen registers or refreshes a discovered worker
+func (r *Registry) seen(id, url string, now time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if w, found := r.workers[id]; found && w.url == url {
+		w.lastSeen = now
+		return nil
+	}
+	thumber, err := NewRemoteThumber(url, r.thumbFormat, r.key)
+	if err != nil {
+		return err
+	}
+	r.workers[id] = &worker{id: id, url: url, thumber: thumber, lastSeen: now}
+	return nil
+}
+
+// Workers returns the URLs of the workers currently available
+func (r *Registry) Workers() []string {
+	now := time.Now()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var urls []string
+	for _, w := range r.workers {
+		if r.available(w, now) {
+			urls = append(urls, w.url)
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+func (r *Registry) available(w *worker, now time.Time) bool {
+	if !w.static && now.Sub(w.lastSeen) > r.ttl {
+		return false
+	}
+	return !now.Before(w.failedUntil)
+}
+
+// acquire returns the available worker with the fewest requests in flight,
+// expired workers are removed
+func (r *Registry) acquire(now time.Time) (*worker, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var best *worker
+	for id, w := range r.workers {
+		if !w.static && now.Sub(w.lastSeen) > r.ttl {
+			delete(r.workers, id)
+			continue
+		}
+		if !r.available(w, now) {
+			continue
+		}
+		if best == nil || w.inFlight < best.inFlight || (w.inFlight == best.inFlight && w.id < best.id) {
+			best = w
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	best.inFlight++
+	return best, true
+}
+
+// release ends a request to the given worker, a failed worker is not used
+// until the retry delay has elapsed
+func (r *Registry) release(w *worker, failed bool, now time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	w.inFlight--
+	if failed {
+		w.failedUntil = now.Add(r.retryDelay)
+	}
+}
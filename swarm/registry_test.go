@@ -0,0 +1,193 @@
+package swarm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+var testKey = Key("swarm secret")
+
+func newTestRegistry(t *testing.T) *Registry {
+	registry, err := NewRegistry(domain.JPEG, time.Minute, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+func signedAnnouncement(key Key, id, service string, port int, at time.Time) []byte {
+	a := announcement{ID: id, Service: service, Port: port, Time: at.Unix()}
+	a.MAC = key.sign(a.signed()...)
+	msg, _ := json.Marshal(a)
+	return msg
+}
+
+func TestAnnouncedWorkers(t *testing.T) {
+	registry := newTestRegistry(t)
+	now := time.Now()
+	from := net.ParseIP("192.168.1.20")
+
+	assert.NoError(t, registry.announced(signedAnnouncement(testKey, "a", "thumbd", 8090, now), from, now))
+	assert.Error(t, registry.announced(signedAnnouncement(testKey, "b", "other", 8090, now), from, now))
+	assert.Error(t, registry.announced([]byte(`not json`), from, now))
+	assert.Equal(t, []string{"http://192.168.1.20:8090"}, registry.Workers())
+
+	w, found := registry.acquire(now.Add(2 * time.Minute))
+	assert.False(t, found, "Expired workers must not be used, got %v", w)
+	assert.Empty(t, registry.Workers())
+}
+
+func TestUnauthenticatedAnnouncements(t *testing.T) {
+	registry := newTestRegistry(t)
+	now := time.Now()
+	from := net.ParseIP("192.168.1.66")
+
+	data := []struct {
+		name string
+		msg  []byte
+	}{
+		{"unsigned", []byte(`{"id":"a","service":"thumbd","port":8090}`)},
+		{"other key", signedAnnouncement(Key("guess"), "a", "thumbd", 8090, now)},
+		{"stale", signedAnnouncement(testKey, "a", "thumbd", 8090, now.Add(-2*maxClockSkew))},
+		{"tampered", bytes.Replace(signedAnnouncement(testKey, "a", "thumbd", 8090, now), []byte("8090"), []byte("8091"), 1)},
+	}
+	for _, d := range data {
+		assert.Error(t, registry.announced(d.msg, from, now), d.name)
+	}
+	assert.Empty(t, registry.Workers())
+}
+
+func TestRegistryRequiresKey(t *testing.T) {
+	_, err := NewRegistry(domain.JPEG, time.Minute, nil)
+	assert.Equal(t, ErrNoKey, err)
+}
+
+func TestAcquireLeastBusyWorker(t *testing.T) {
+	registry := newTestRegistry(t)
+	now := time.Now()
+	assert.NoError(t, registry.Register("http://a:8090"))
+	assert.NoError(t, registry.Register("http://b:8090"))
+
+	first, _ := registry.acquire(now)
+	second, _ := registry.acquire(now)
+	assert.NotEqual(t, first.url, second.url, "Requests must be spread over workers")
+
+	registry.release(first, true, now)
+	third, found := registry.acquire(now)
+	assert.True(t, found)
+	assert.Equal(t, second.url, third.url, "Failed workers must not be used")
+
+	registry.release(second, false, now)
+	registry.release(third, false, now)
+	retried, _ := registry.acquire(now.Add(DefaultRetryDelay))
+	assert.Equal(t, first.url, retried.url, "Failed workers must be retried after the delay")
+}
+
+type countingThumber struct {
+	calls int
+}
+
+func (t *countingThumber) CreateThumb(in io.Reader, f domain.Format, o domain.Orientation, size domain.ThumbSize) (image.Image, error) {
+	t.calls++
+	if _, err := f.Decode(in); err != nil {
+		return nil, errors.New("Content must be complete")
+	}
+	return image.NewRGBA(image.Rect(0, 0, 1, 1)), nil
+}
+
+func TestBalancedThumberFallback(t *testing.T) {
+	srcImg := resource(t, "testdata/Canon_40D.jpg")
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	worker := newWorkerServer()
+	defer worker.Close()
+
+	registry := newTestRegistry(t)
+	local := &countingThumber{}
+	thumber := NewBalancedThumber(context.Background(), registry, local)
+
+	_, err := thumber.CreateThumb(bytes.NewReader(srcImg), domain.JPEG, domain.NormalOrientation, domain.Small)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, local.calls, "Thumbs must be created locally without workers")
+
+	registry.Register(failing.URL)
+	_, err = thumber.CreateThumb(bytes.NewReader(srcImg), domain.JPEG, domain.NormalOrientation, domain.Small)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, local.calls, "Thumbs must be created locally when the worker fails")
+	assert.Empty(t, registry.Workers(), "Failed worker must not be available")
+
+	registry.Register(worker.URL)
+	thumb, err := thumber.CreateThumb(bytes.NewReader(srcImg), domain.JPEG, domain.NormalOrientation, domain.Small)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, local.calls, "Thumbs must be created by the worker")
+	assert.NotNil(t, thumb)
+
+	_, err = thumber.CreateThumb(struct{ io.Reader }{bytes.NewReader(srcImg)}, domain.JPEG, domain.NormalOrientation, domain.Small)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, local.calls, "Contents which cannot be read again must be rendered locally")
+}
+
+type failingThumber struct{}
+
+func (failingThumber) CreateThumb(in io.Reader, f domain.Format, o domain.Orientation, size domain.ThumbSize) (image.Image, error) {
+	ioutil.ReadAll(in)
+	return nil, errors.New("Worker failure")
+}
+
+func TestBalancedThumberFallbackWithFile(t *testing.T) {
+	// The worker authenticates and reads the whole content before failing
+	router := mux.NewRouter()
+	handler, _ := NewWorkerHandler(failingThumber{}, testKey)
+	handler.InitRoutes(router)
+	failing := httptest.NewServer(router)
+	defer failing.Close()
+
+	registry := newTestRegistry(t)
+	registry.Register(failing.URL)
+	local := &countingThumber{}
+	thumber := NewBalancedThumber(context.Background(), registry, local)
+
+	in, err := os.Open("testdata/Canon_40D.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	_, err = thumber.CreateThumb(in, domain.JPEG, domain.NormalOrientation, domain.Small)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, local.calls, "Photo files must be rendered locally when the worker fails")
+}
+
+func TestBalancedThumberRendersVideosLocally(t *testing.T) {
+	requests := 0
+	worker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer worker.Close()
+	registry := newTestRegistry(t)
+	registry.Register(worker.URL)
+	local := &countingThumber{}
+	thumber := NewBalancedThumber(context.Background(), registry, local)
+
+	mov, _ := domain.FormatForExt("mov")
+	thumber.CreateThumb(bytes.NewReader([]byte("not really a video")), mov, domain.NormalOrientation, domain.Small)
+	assert.Equal(t, 1, local.calls)
+	assert.Equal(t, 0, requests, "Videos must not be sent to workers")
+}
@@ -4,45 +4,110 @@ import (
 	"fmt"
 	"image"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sync"
+	"time"
 
 	"bitbucket.org/kleinnic74/photos/domain"
 )
 
+// remoteTimeout is the maximum duration of a thumb request to a worker
+const remoteTimeout = 30 * time.Second
+
 type remoteThumber struct {
 	baseURL *url.URL
 	client  *http.Client
+	key     Key
 
 	thumbFormat domain.Format
+
+	mutex    sync.Mutex
+	verified bool
 }
 
-func NewRemoteThumber(baseURL string, thumbFormat domain.Format) (domain.Thumber, error) {
+// NewRemoteThumber returns a thumber sending photos to the worker at the
+// given URL, once it has proven that it knows the given key
+func NewRemoteThumber(baseURL string, thumbFormat domain.Format, key Key) (domain.Thumber, error) {
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
 	endpoint, err := url.Parse(baseURL)
 	if err != nil {
 		return nil, err
 	}
 	return &remoteThumber{
 		baseURL:     endpoint,
-		client:      &http.Client{},
+		client:      &http.Client{Timeout: remoteTimeout},
+		key:         key,
 		thumbFormat: thumbFormat,
 	}, nil
 }
 
+// verify challenges the worker to prove that it knows the key, no photo is
+// sent to a worker before it succeeded
+func (t *remoteThumber) verify() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.verified {
+		return nil
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s%s?%s=%s", t.baseURL, authPath, nonceParam, nonce), nil)
+	if err != nil {
+		return err
+	}
+	t.key.signRequest(r, time.Now())
+	resp, err := t.client.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Thumb worker %s rejected authentication: %s", t.baseURL, resp.Status)
+	}
+	proof, err := ioutil.ReadAll(io.LimitReader(resp.Body, 128))
+	if err != nil {
+		return err
+	}
+	if !t.key.verify(string(proof), "worker", nonce) {
+		return fmt.Errorf("Thumb worker %s failed to authenticate", t.baseURL)
+	}
+	t.verified = true
+	return nil
+}
+
 func (t *remoteThumber) CreateThumb(in io.Reader, f domain.Format, o domain.Orientation, size domain.ThumbSize) (image.Image, error) {
-	endpoint := fmt.Sprintf("%s/%s/%s", t.baseURL.String(), t.thumbFormat.ID(), size.Name)
-	r, err := http.NewRequest(http.MethodPost, endpoint, in)
+	if err := t.verify(); err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("%s/%s/%s?%s=%d", t.baseURL.String(), t.thumbFormat.ID(), size.Name, orientationParam, o)
+	// The transport closes the body of the request, the content must stay
+	// open for the caller to read it again when the worker fails
+	r, err := http.NewRequest(http.MethodPost, endpoint, ioutil.NopCloser(struct{ io.Reader }{in}))
 	if err != nil {
 		return nil, err
 	}
 	r.Header.Set("Content-Type", f.Mime())
+	t.key.signRequest(r, time.Now())
 	resp, err := t.client.Do(r)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotImplemented:
+		return nil, domain.ErrThumbsNotSupported(f.ID())
+	default:
+		return nil, fmt.Errorf("Thumb worker %s failed: %s", t.baseURL, resp.Status)
+	}
 
-	thumb, err := f.Decode(resp.Body)
+	thumb, err := t.thumbFormat.Decode(resp.Body)
 	if err != nil {
 		return nil, err
 	}
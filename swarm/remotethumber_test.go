@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"bitbucket.org/kleinnic74/photos/domain"
 	"github.com/stretchr/testify/assert"
@@ -17,6 +18,14 @@ func TestCreateThumber(t *testing.T) {
 	srcImg := resource(t, "testdata/Canon_40D.jpg")
 	var requestedPath string
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !testKey.verifyRequest(r, time.Now()) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/base"+authPath {
+			w.Write([]byte(testKey.proof(r.URL.Query().Get(nonceParam))))
+			return
+		}
 		requestedPath = r.URL.Path
 		w.WriteHeader(http.StatusOK)
 		w.Write(srcImg)
@@ -24,7 +33,7 @@ func TestCreateThumber(t *testing.T) {
 	defer server.Close()
 
 	baseURL := fmt.Sprintf("%s/%s", server.URL, "base")
-	thumber, err := NewRemoteThumber(baseURL, domain.JPEG)
+	thumber, err := NewRemoteThumber(baseURL, domain.JPEG, testKey)
 	if err != nil {
 		t.Fatalf("Failed to create thumber: %s", err)
 	}
@@ -0,0 +1,122 @@
+// Package swarm distributes the creation of thumbs to worker instances on
+// the local network
+package swarm
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const (
+	// orientationParam is the query parameter carrying the EXIF orientation
+	// of the photo sent to a worker
+	orientationParam = "orientation"
+	// MaxContentLength is the maximum size of a photo sent to a worker
+	MaxContentLength = 64 << 20
+)
+
+// WorkerHandler answers the thumb requests sent by remote thumbers: the
+// content of the photo is posted to /{format}/{size} with its MIME type as
+// Content-Type, the thumb is returned encoded in the requested format.
+// Requests which are not signed with the key of the swarm are rejected.
+type WorkerHandler struct {
+	thumber domain.Thumber
+	key     Key
+}
+
+// NewWorkerHandler returns a handler creating thumbs with the given thumber
+// for the instances knowing the given key
+func NewWorkerHandler(thumber domain.Thumber, key Key) (*WorkerHandler, error) {
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
+	return &WorkerHandler{thumber: thumber, key: key}, nil
+}
+
+func (h *WorkerHandler) InitRoutes(r *mux.Router) {
+	r.HandleFunc(authPath, h.authenticated(h.prove)).Methods(http.MethodGet)
+	r.HandleFunc("/{format}/{size}", h.authenticated(h.createThumb)).Methods(http.MethodPost)
+}
+
+func (h *WorkerHandler) authenticated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.key.verifyRequest(r, time.Now()) {
+			http.Error(w, "Bad or missing signature", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// prove answers the challenge sent by an instance checking that this worker
+// knows the key before sending it photos
+func (h *WorkerHandler) prove(w http.ResponseWriter, r *http.Request) {
+	nonce := r.URL.Query().Get(nonceParam)
+	if nonce == "" {
+		http.Error(w, "Missing nonce", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(h.key.proof(nonce)))
+}
+
+func (h *WorkerHandler) createThumb(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	thumbFormat, found := domain.FormatForExt(vars["format"])
+	if !found {
+		http.Error(w, fmt.Sprintf("Unknown thumb format %s", vars["format"]), http.StatusBadRequest)
+		return
+	}
+	size, found := domain.ThumbSizeFromName(vars["size"])
+	if !found {
+		http.Error(w, fmt.Sprintf("Unknown thumb size %s", vars["size"]), http.StatusBadRequest)
+		return
+	}
+	mime := r.Header.Get("Content-Type")
+	format, found := domain.FormatForMime(mime)
+	if !found {
+		http.Error(w, fmt.Sprintf("Unsupported content type %s", mime), http.StatusUnsupportedMediaType)
+		return
+	}
+	if r.ContentLength > MaxContentLength {
+		http.Error(w, "Content too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, MaxContentLength)
+	defer body.Close()
+	thumb, err := h.thumber.CreateThumb(body, format, orientationOf(r), size)
+	if err != nil {
+		if _, unsupported := err.(domain.ErrThumbsNotSupported); unsupported {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		logging.From(r.Context()).Warn("Failed to create thumb", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := thumbFormat.Encode(thumb, &buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", thumbFormat.Mime())
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
+
+func orientationOf(r *http.Request) domain.Orientation {
+	o, err := strconv.Atoi(r.URL.Query().Get(orientationParam))
+	if err != nil {
+		return domain.UnknownOrientation
+	}
+	return domain.Orientation(o)
+}
@@ -0,0 +1,129 @@
+package swarm
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/domain"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newWorkerServer() *httptest.Server {
+	router := mux.NewRouter()
+	worker, _ := NewWorkerHandler(domain.LocalThumber{}, testKey)
+	worker.InitRoutes(router)
+	return httptest.NewServer(router)
+}
+
+func post(t *testing.T, url, contentType string, content []byte, key Key) *http.Response {
+	r, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", contentType)
+	if key != nil {
+		key.signRequest(r, time.Now())
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("%s: request failed: %s", url, err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestWorkerHandler(t *testing.T) {
+	srcImg := resource(t, "testdata/Canon_40D.jpg")
+	server := newWorkerServer()
+	defer server.Close()
+
+	data := []struct {
+		path        string
+		contentType string
+		status      int
+	}{
+		{"/jpg/S", "image/jpeg", http.StatusOK},
+		{"/jpg/P", "image/jpeg", http.StatusOK},
+		{"/jpg/XL", "image/jpeg", http.StatusBadRequest},
+		{"/gif/S", "image/jpeg", http.StatusBadRequest},
+		{"/jpg/S", "image/gif", http.StatusUnsupportedMediaType},
+	}
+	for _, d := range data {
+		resp := post(t, server.URL+d.path, d.contentType, srcImg, testKey)
+		assert.Equal(t, d.status, resp.StatusCode, "%s (%s): bad status", d.path, d.contentType)
+	}
+}
+
+func TestWorkerRejectsUnauthenticatedRequests(t *testing.T) {
+	srcImg := resource(t, "testdata/Canon_40D.jpg")
+	server := newWorkerServer()
+	defer server.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, post(t, server.URL+"/jpg/S", "image/jpeg", srcImg, nil).StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, post(t, server.URL+"/jpg/S", "image/jpeg", srcImg, Key("guess")).StatusCode)
+
+	r, _ := http.NewRequest(http.MethodPost, server.URL+"/jpg/S", bytes.NewReader(srcImg))
+	r.Header.Set("Content-Type", "image/jpeg")
+	testKey.signRequest(r, time.Now().Add(-2*maxClockSkew))
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "Replayed requests must be rejected")
+}
+
+func TestWorkerLimitsContentLength(t *testing.T) {
+	server := newWorkerServer()
+	defer server.Close()
+
+	resp := post(t, server.URL+"/jpg/S", "image/jpeg", make([]byte, MaxContentLength+1), testKey)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+func TestRemoteThumberRejectsUnauthenticatedWorker(t *testing.T) {
+	srcImg := resource(t, "testdata/Canon_40D.jpg")
+	received := 0
+	router := mux.NewRouter()
+	impostor, _ := NewWorkerHandler(domain.LocalThumber{}, Key("guess"))
+	impostor.InitRoutes(router)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			received++
+		}
+		router.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	thumber, err := NewRemoteThumber(server.URL, domain.JPEG, testKey)
+	if err != nil {
+		t.Fatalf("Failed to create thumber: %s", err)
+	}
+	_, err = thumber.CreateThumb(bytes.NewReader(srcImg), domain.JPEG, domain.NormalOrientation, domain.Small)
+	assert.Error(t, err)
+	assert.Equal(t, 0, received, "Photos must not be sent to workers which do not know the key")
+}
+
+func TestRemoteThumberWithWorker(t *testing.T) {
+	srcImg := resource(t, "testdata/Canon_40D.jpg")
+	server := newWorkerServer()
+	defer server.Close()
+
+	thumber, err := NewRemoteThumber(server.URL, domain.JPEG, testKey)
+	if err != nil {
+		t.Fatalf("Failed to create thumber: %s", err)
+	}
+	remote, err := thumber.CreateThumb(bytes.NewReader(srcImg), domain.JPEG, domain.Rotate90Orientation, domain.Small)
+	if err != nil {
+		t.Fatalf("Error while retrieving thumb: %s", err)
+	}
+	local, err := domain.LocalThumber{}.CreateThumb(bytes.NewReader(srcImg), domain.JPEG, domain.Rotate90Orientation, domain.Small)
+	if err != nil {
+		t.Fatalf("Error while creating local thumb: %s", err)
+	}
+	assert.Equal(t, local.Bounds(), remote.Bounds(), "Orientation must be applied by the worker")
+}
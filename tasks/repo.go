@@ -39,6 +39,9 @@ func (r *TaskRepository) RegisterWithProperties(name string, init TaskInitFunc,
 			continue
 		}
 		parameter := strings.SplitN(tag, ",", 2)[0]
+		if parameter == "-" {
+			continue
+		}
 		parameters = append(parameters, parameter)
 		log.Info("Task parameter", zap.String("param", parameter))
 	}
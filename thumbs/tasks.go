@@ -0,0 +1,195 @@
+// Package thumbs provides the tasks pre-rendering, verifying and purging the
+// thumbs of photos
+package thumbs
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/kleinnic74/photos/consts"
+	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/logging"
+	"bitbucket.org/kleinnic74/photos/tasks"
+	"go.uber.org/zap"
+)
+
+const (
+	// RenderTaskType is the name of the task rendering the thumbs of a photo
+	RenderTaskType = "renderThumbs"
+	// VerifyTaskType is the name of the task regenerating corrupt thumbs
+	VerifyTaskType = "verifyThumbs"
+	// PurgeTaskType is the name of the task removing the thumbs of deleted
+	// photos
+	PurgeTaskType = "purgeThumbs"
+)
+
+const verifyPageSize = 100
+
+// IndexVersion is the version of the thumbs index, changing it renders the
+// thumbs of all photos again
+const IndexVersion = library.Version(1)
+
+// ThumbVerifier checks the thumb of a photo and creates it again if it is
+// corrupt, returning true in that case
+type ThumbVerifier interface {
+	VerifyThumb(context.Context, library.PhotoID, domain.ThumbSize) (bool, error)
+}
+
+// ThumbRenderer creates the thumb of a photo ahead of any request for it
+// without marking it as recently used
+type ThumbRenderer interface {
+	RenderThumb(context.Context, library.PhotoID, domain.ThumbSize, domain.Format) error
+}
+
+// ThumbPurger removes the thumbs of photos no longer in the library and
+// returns the number of photos whose thumbs have been removed
+type ThumbPurger interface {
+	PurgeThumbs(context.Context) (int, error)
+}
+
+// ThumbStore verifies and purges the stored thumbs
+type ThumbStore interface {
+	ThumbVerifier
+	ThumbPurger
+}
+
+// RegisterTasks defines the tasks managing thumbs
+func RegisterTasks(repo *tasks.TaskRepository, store ThumbStore) {
+	repo.RegisterWithProperties(RenderTaskType, func() tasks.Task {
+		return &RenderTask{}
+	}, tasks.TaskProperties{
+		RunOnStart:   false,
+		UserRunnable: true,
+	})
+	repo.RegisterWithProperties(VerifyTaskType, func() tasks.Task {
+		return NewVerifyTask(store)
+	}, tasks.TaskProperties{
+		RunOnStart:   false,
+		UserRunnable: true,
+	})
+	repo.RegisterWithProperties(PurgeTaskType, func() tasks.Task {
+		return NewPurgeTask(store)
+	}, tasks.TaskProperties{
+		RunOnStart:   true,
+		UserRunnable: true,
+	})
+}
+
+// RenderOnAdd returns the task rendering all thumb sizes of a newly added
+// photo, so that they need not be created while browsing the library
+func RenderOnAdd(ctx context.Context, p *library.Photo) (tasks.Task, bool) {
+	return NewRenderTask(p.ID), true
+}
+
+// RenderTask renders the JPEG thumbs of a photo in all sizes, existing thumbs
+// are left untouched. Thumbs in other formats are rendered on request.
+type RenderTask struct {
+	Photo library.PhotoID `json:"photo"`
+}
+
+// NewRenderTask returns a task rendering the thumbs of the given photo
+func NewRenderTask(id library.PhotoID) *RenderTask {
+	return &RenderTask{Photo: id}
+}
+
+func (t *RenderTask) Describe() string {
+	return "Rendering thumbs of " + string(t.Photo)
+}
+
+func (t *RenderTask) Execute(ctx context.Context, executor tasks.TaskExecutor, lib library.PhotoLibrary) error {
+	logger, ctx := logging.FromWithNameAndFields(ctx, "renderThumbs", zap.String("photo", string(t.Photo)))
+	start := time.Now()
+	for _, size := range domain.ThumbSizes {
+		if err := renderThumb(ctx, lib, t.Photo, size); err != nil {
+			if _, unsupported := err.(domain.ErrThumbsNotSupported); unsupported {
+				logger.Debug("Photo has no thumbs", zap.Error(err))
+				return nil
+			}
+			return err
+		}
+	}
+	logger.Debug("Rendered thumbs", zap.Duration("duration", time.Since(start)))
+	return nil
+}
+
+// renderThumb pre-renders the JPEG thumb of a photo, libraries which cannot
+// pre-render thumbs create them by opening them
+func renderThumb(ctx context.Context, lib library.PhotoLibrary, id library.PhotoID, size domain.ThumbSize) error {
+	if renderer, ok := lib.(ThumbRenderer); ok {
+		return renderer.RenderThumb(ctx, id, size, domain.JPEG)
+	}
+	thumb, err := lib.OpenThumb(ctx, id, size, domain.JPEG)
+	if err != nil {
+		return err
+	}
+	return thumb.Close()
+}
+
+// VerifyTask checks the existing thumbs of all photos and regenerates those
+// which cannot be decoded, e.g. left truncated by a crash
+type VerifyTask struct {
+	verifier ThumbVerifier
+}
+
+// NewVerifyTask returns a task verifying thumbs with the given verifier
+func NewVerifyTask(verifier ThumbVerifier) *VerifyTask {
+	return &VerifyTask{verifier: verifier}
+}
+
+func (t *VerifyTask) Describe() string {
+	return "Verifying thumbs"
+}
+
+func (t *VerifyTask) Execute(ctx context.Context, executor tasks.TaskExecutor, lib library.PhotoLibrary) error {
+	logger, ctx := logging.SubFrom(ctx, "verifyThumbs")
+	var checked, regenerated int
+	for start, hasMore := 0, true; hasMore; start += verifyPageSize {
+		var photos []*library.Photo
+		var err error
+		photos, hasMore, err = lib.FindAllPaged(ctx, start, verifyPageSize, consts.Ascending)
+		if err != nil {
+			return err
+		}
+		for _, p := range photos {
+			for _, size := range domain.ThumbSizes {
+				created, err := t.verifier.VerifyThumb(ctx, p.ID, size)
+				if err != nil {
+					logger.Warn("Failed to verify thumb", zap.String("photo", string(p.ID)), zap.String("size", size.Name), zap.Error(err))
+					continue
+				}
+				checked++
+				if created {
+					regenerated++
+				}
+			}
+		}
+	}
+	logger.Info("Verified thumbs", zap.Int("checked", checked), zap.Int("regenerated", regenerated))
+	return nil
+}
+
+// PurgeTask removes the thumbs of photos which have been deleted from the
+// library
+type PurgeTask struct {
+	purger ThumbPurger
+}
+
+// NewPurgeTask returns a task purging thumbs with the given purger
+func NewPurgeTask(purger ThumbPurger) *PurgeTask {
+	return &PurgeTask{purger: purger}
+}
+
+func (t *PurgeTask) Describe() string {
+	return "Purging thumbs of deleted photos"
+}
+
+func (t *PurgeTask) Execute(ctx context.Context, executor tasks.TaskExecutor, lib library.PhotoLibrary) error {
+	logger, ctx := logging.SubFrom(ctx, "purgeThumbs")
+	purged, err := t.purger.PurgeThumbs(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Info("Purged thumbs", zap.Int("photos", purged))
+	return nil
+}
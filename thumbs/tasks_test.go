@@ -0,0 +1,60 @@
+package thumbs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"bitbucket.org/kleinnic74/photos/domain"
+	"bitbucket.org/kleinnic74/photos/library"
+	"bitbucket.org/kleinnic74/photos/tasks"
+	"github.com/stretchr/testify/assert"
+)
+
+type thumbLib struct {
+	library.PhotoLibrary
+	err      error
+	rendered []string
+}
+
+func (lib *thumbLib) OpenThumb(ctx context.Context, id library.PhotoID, size domain.ThumbSize, format domain.Format) (io.ReadCloser, error) {
+	if lib.err != nil {
+		return nil, lib.err
+	}
+	lib.rendered = append(lib.rendered, size.Name)
+	return ioutil.NopCloser(bytes.NewReader(nil)), nil
+}
+
+func TestRenderTask(t *testing.T) {
+	lib := &thumbLib{}
+	task, ok := RenderOnAdd(context.Background(), &library.Photo{ExtendedPhotoID: library.ExtendedPhotoID{ID: "1234"}})
+	assert.True(t, ok)
+	assert.NoError(t, task.Execute(context.Background(), tasks.NewDummyTaskExecutor(), lib))
+	assert.Equal(t, []string{"S", "M", "L", "P"}, lib.rendered)
+}
+
+func TestRenderTaskUnsupportedFormat(t *testing.T) {
+	lib := &thumbLib{err: domain.ErrThumbsNotSupported("gpx")}
+	task := NewRenderTask("1234")
+	assert.NoError(t, task.Execute(context.Background(), tasks.NewDummyTaskExecutor(), lib))
+}
+
+type renderingLib struct {
+	thumbLib
+	prerendered []string
+}
+
+func (lib *renderingLib) RenderThumb(ctx context.Context, id library.PhotoID, size domain.ThumbSize, format domain.Format) error {
+	lib.prerendered = append(lib.prerendered, size.Name)
+	return nil
+}
+
+func TestRenderTaskPrerendersThumbs(t *testing.T) {
+	lib := &renderingLib{}
+	task := NewRenderTask("1234")
+	assert.NoError(t, task.Execute(context.Background(), tasks.NewDummyTaskExecutor(), lib))
+	assert.Equal(t, []string{"S", "M", "L", "P"}, lib.prerendered)
+	assert.Empty(t, lib.rendered, "Thumbs must not be opened when they can be pre-rendered")
+}